@@ -50,7 +50,11 @@ func (bc *BlockChain) execBlock(block *types.Block, confirmedBlocks types.Blocks
 
 	//
 	parentBlock := bc.GetBlock(block.Header.ParentFullHash)
-	stateDb, _ = bc.StateAt(parentBlock.Header.StateHash)
+	stateDb, err := bc.StateAt(parentBlock.Header.StateHash)
+	if err != nil {
+		bc.logger.Error("Get parent state failed", "hash", block.FullHash(), "parent", block.Header.ParentFullHash, "err", err)
+		return nil, nil, nil, nil, err
+	}
 
 	var (
 		executedTxs []*types.TxWithIndex
@@ -72,7 +76,7 @@ func (bc *BlockChain) execBlock(block *types.Block, confirmedBlocks types.Blocks
 	}
 
 	// set reward
-	state.AddBlockReward(stateDb, block, confirmedBlocks)
+	state.AddBlockReward(stateDb, bc.chainConfig.Rewards(), block, confirmedBlocks)
 
 	stateDb.Finalise(true)
 	bc.logger.Info("finish finalising statedb", "hash", block.FullHash(), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
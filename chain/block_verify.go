@@ -132,6 +132,9 @@ func (bc *BlockChain) VerifyBlock(block *types.Block, checkGreedy bool) (types.B
 			return nil, common.Hash{}, 0, common.Hash{}, ErrBlockFullSigError
 		}
 	}
+
+	// * Whether the miner has signed another block in this round
+	bc.checkEquivocation(block)
 	bc.logger.Info("Block verify hash function OK", "hash", block.FullHash(), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
 
 	// Check if Compliance with check point rule
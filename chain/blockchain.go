@@ -60,6 +60,8 @@ var (
 
 	ErrBlockStateNotFound = errors.New("Block state not Found")
 
+	ErrStatePruned = errors.New("state pruned")
+
 	ErrBlockHeightError = errors.New("Block height error")
 
 	ErrBlockRoundTooLow = errors.New("The block round is too low")
@@ -126,6 +128,7 @@ type BlockChain struct {
 
 	// for state in blockchain
 	stateCache state.Database // State database to reuse between imports (contains state cache)
+	pruner     *statePruner   // nil in archive gc mode
 
 	// for checkPoint in blockchain
 	checkPointHandler *checkPointHandler
@@ -151,6 +154,7 @@ type BlockChain struct {
 
 	// feed
 	chainUpdateFeed     event.Feed
+	chainReorgFeed      event.Feed
 	blockExecutedFeed   event.Feed
 	futureBlockFeed     event.Feed // future block to be processed
 	futureTxPackageFeed event.Feed // future tx package to be processed
@@ -208,13 +212,18 @@ func NewBlockChain(cfg *config.Config, db dbwrapper.Database, executor txexec.Tx
 		futureBlockTxPackages:  make(map[common.Hash]*types.TxPackages),
 	}
 
+	// prune the old states in full gc mode
+	if cfg.GCMode == config.GCModeFull {
+		bc.pruner = newStatePruner(bc, cfg.StateHistory)
+	}
+
 	// set genesis block
 	genesisHash := dbaccessor.ReadGenesisBlockHash(bc.db)
 	if genesisHash == (common.Hash{}) {
 		return nil, ErrNoGenesis
 	}
 	bc.genesisBlock = bc.GetBlock(genesisHash)
-	bc.checkPointHandler, err = newCheckPointHandler(bc, checkPointNodeType)
+	bc.checkPointHandler, err = newCheckPointHandler(bc, checkPointNodeType, cfg.CheckPointRPCs)
 	if err != nil {
 		logger.Error("Create check point handle error", "err", err)
 		return nil, err
@@ -317,16 +326,26 @@ ForAgain:
 func (bc *BlockChain) checkAndSetHead(block *types.Block) {
 	// calc current blocks
 	currentBlock := bc.currentBlock.Load().(*types.Block)
+	var reorg *types.ChainReorgEvent
 	if block.CompareByHeightAndRoundAndSimpleHash(currentBlock) > 0 {
 		if bc.calcAndCheckState(block) {
 			log.Info("Switch head block",
 				"oldHash", currentBlock.FullHash(), "oldHeight", currentBlock.Header.Height, "oldRound", currentBlock.Header.Round,
 				"newHash", block.FullHash(), "newHeight", block.Header.Height, "newRound", block.Header.Round)
+			if block.Header.ParentFullHash != currentBlock.FullHash() {
+				reorg = bc.newReorgEvent(currentBlock, block)
+			}
 			currentBlock = block
 		}
 	}
 	bc.currentBlock.Store(currentBlock)
 	dbaccessor.WriteHeadBlockHash(bc.db, currentBlock.FullHash())
+
+	if reorg != nil {
+		log.Info("Chain reorg", "ancestor", reorg.CommonAncestor.FullHash(), "ancestorHeight", reorg.CommonAncestor.Header.Height,
+			"dropped", len(reorg.Dropped), "added", len(reorg.Added))
+		bc.chainReorgFeed.Send(*reorg)
+	}
 }
 
 // GetBlockStateChecked return the state checked flag
@@ -554,6 +573,7 @@ func (bc *BlockChain) GetChainConfig() *config.ChainConfig { return bc.chainConf
 
 func (bc *BlockChain) SetMainBranchRecordBackend(m *MainBranchRecord)         { bc.mainBranchRecord = m }
 func (bc *BlockChain) GetMainBranchBlock(height uint64) (*types.Block, error) { return bc.mainBranchRecord.GetMainBranchBlock(height) }
+func (bc *BlockChain) GetMainBranchHead() *types.Block                         { return bc.mainBranchRecord.GetMainBranchHead() }
 func (bc *BlockChain) IsInMainBranch(block *types.Block) bool {
 	if block == nil {
 		return false
@@ -591,4 +611,7 @@ func (bc *BlockChain) SearchTransactionInCache(txHash common.Hash) (*types.Trans
 func (bc *BlockChain) StopRecord() {
 	bc.mainBranchRecord.Stop()
 	bc.txInChainProcessor.Stop()
+	if bc.pruner != nil {
+		bc.pruner.stop()
+	}
 }
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/fractal-platform/fractal/common"
-	"github.com/fractal-platform/fractal/common/hexutil"
 	"github.com/fractal-platform/fractal/core/dbaccessor"
 	"github.com/fractal-platform/fractal/core/types"
 	"github.com/fractal-platform/fractal/crypto"
@@ -28,14 +27,45 @@ const (
 	CheckPointCreateHeight  uint64 = 30  // 1000
 	CheckPointCreateDelay   uint64 = 20  // 50
 	CheckPointVerifyDelay   uint64 = 40  // 100
+
+	checkPointQueryTimeout = 15 * time.Second // deadline of a query to all the check point nodes
+	checkPointDialInterval = 3 * time.Second
 )
 
 var (
-	errCheckPointNotFound   = errors.New("check point not found")
-	errRemoteMsgNotSigned   = errors.New("remote message not signed")
-	errRemoteMsgSignedError = errors.New("remote message signed error")
+	errCheckPointNotFound    = errors.New("check point not found")
+	errNoCheckPointRPC       = errors.New("no check point rpc address")
+	errRemoteCheckPointEmpty = errors.New("remote check point is empty")
 )
 
+func (bc *BlockChain) SetCheckPointPriKey(key crypto.PrivateKey) {
+	bc.checkPointHandler.checkPointPriKey = key
+}
+
+// InsertSignedCheckPoint merges the authority signs of a signed check point received from the network,
+// and returns whether the check point got new signs (so that it should be propagated).
+func (bc *BlockChain) InsertSignedCheckPoint(signed *types.SignedCheckPoint) (bool, error) {
+	return bc.checkPointHandler.insertSignedCheckPoint(signed)
+}
+
+// GetSignedCheckPoint returns the signs collected for the check point hash.
+func (bc *BlockChain) GetSignedCheckPoint(hash common.Hash) *types.SignedCheckPoint {
+	return bc.checkPointHandler.getSignedCheckPoint(hash)
+}
+
+// GetLatestSignedCheckPoint returns the highest check point which is signed by enough authorities.
+func (bc *BlockChain) GetLatestSignedCheckPoint() *types.SignedCheckPoint {
+	value := bc.checkPointHandler.latestSignedCheckPoint.Load()
+	if value == nil {
+		return nil
+	}
+	return value.(*types.SignedCheckPoint)
+}
+
+func (bc *BlockChain) SubscribeNewSignedCheckPointEvent(ch chan<- types.NewSignedCheckPointEvent) event.Subscription {
+	return bc.checkPointHandler.signedCheckPointFeed.Subscribe(ch)
+}
+
 func (bc *BlockChain) StartCreateCheckPoint() {
 	if bc.chainConfig.CheckPointEnable {
 		bc.checkPointHandler.startCreateCheckPoint()
@@ -150,8 +180,8 @@ func (bc *BlockChain) meetCheckPointRule(block *types.Block) bool {
 
 type nodeBehavior interface {
 	genCheckPointDistance() uint64
-	verifyCheckPoint(checkPoint *types.CheckPoint) bool
-	initLastCheckPoint(c *checkPointHandler)
+	verifyCheckPoint(c *checkPointHandler, checkPoint *types.CheckPoint) bool
+	initLastCheckPoint(c *checkPointHandler) error
 	startCheck(c *checkPointHandler)
 }
 
@@ -163,26 +193,41 @@ type checkPointHandler struct {
 	queryCache          *lru.Cache // index -> checkPoint lru map
 	queryCacheMu        sync.Mutex
 
+	// for signed check points gossiped over the network
+	authorities            []string
+	threshold              uint64
+	checkPointPriKey       crypto.PrivateKey // only for check point authority
+	signedCheckPoints      *lru.Cache        // checkPointHash -> signedCheckPoint lru map
+	signedCheckPointsMu    sync.Mutex
+	latestSignedCheckPoint atomic.Value
+	signedCheckPointFeed   event.Feed
+
 	ctx    context.Context
 	cancel context.CancelFunc
 
 	running int32
 }
 
-func newCheckPointHandler(blockChain *BlockChain, checkPointNodeType types.CheckPointNodeTypeEnum) (*checkPointHandler, error) {
+func newCheckPointHandler(blockChain *BlockChain, checkPointNodeType types.CheckPointNodeTypeEnum, rpcAddresses []string) (*checkPointHandler, error) {
 	var nodeBehavior nodeBehavior
 	switch checkPointNodeType {
 	case types.NormalNode:
+		if len(rpcAddresses) == 0 {
+			rpcAddresses = []string{types.CheckPointNodeRPC}
+		}
 		nodeBehavior = &normalNode{
-			rpcAddress: types.CheckPointNodeRPC,
+			rpcAddresses: rpcAddresses,
 		}
 	case types.SpecialNode:
 		nodeBehavior = &specialNode{}
 	}
 
+	authorities, threshold := blockChain.chainConfig.CheckPointAuthoritySet()
 	c := &checkPointHandler{
 		blockChain:   blockChain,
 		nodeBehavior: nodeBehavior,
+		authorities:  authorities,
+		threshold:    threshold,
 	}
 
 	var err error
@@ -190,8 +235,20 @@ func newCheckPointHandler(blockChain *BlockChain, checkPointNodeType types.Check
 	if err != nil {
 		return nil, err
 	}
+	c.signedCheckPoints, err = lru.New(10)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash := dbaccessor.ReadLatestSignedCheckPointHash(blockChain.db); hash != (common.Hash{}) {
+		if signed := dbaccessor.ReadSignedCheckPoint(blockChain.db, hash); signed != nil {
+			c.latestSignedCheckPoint.Store(signed)
+		}
+	}
 
-	c.initLastCheckPoint()
+	if err := c.initLastCheckPoint(); err != nil {
+		return nil, err
+	}
 	atomic.StoreInt32(&c.running, 0)
 
 	return c, nil
@@ -276,7 +333,7 @@ Loop:
 			checkPoint := &types.CheckPoint{TreePoint: treePoint}
 
 			// verify
-			if !c.nodeBehavior.verifyCheckPoint(checkPoint) {
+			if !c.nodeBehavior.verifyCheckPoint(c, checkPoint) {
 				log.Error("checkPointHandler createCheckPointLoop: checkPoint verify failed")
 				// TODO: to be more graceful
 				errStr := `
@@ -321,8 +378,8 @@ func (c *checkPointHandler) createCheckPoint(index uint64) *types.CheckPoint {
 	return checkPoint
 }
 
-func (c *checkPointHandler) initLastCheckPoint() {
-	c.nodeBehavior.initLastCheckPoint(c)
+func (c *checkPointHandler) initLastCheckPoint() error {
+	return c.nodeBehavior.initLastCheckPoint(c)
 }
 
 func (c *checkPointHandler) saveCheckPoint(index uint64, checkPoint *types.CheckPoint) {
@@ -338,6 +395,114 @@ func (c *checkPointHandler) saveCheckPoint(index uint64, checkPoint *types.Check
 		c.lastCheckPointCache.Store(checkPoint)
 		dbaccessor.WriteLastCheckPoint(db, checkPoint)
 	}
+
+	c.signCheckPoint(checkPoint)
+}
+
+// resetLastCheckPoint drops the cached check points after the chain is
+// rewound, and sets the last check point.
+func (c *checkPointHandler) resetLastCheckPoint(checkPoint *types.CheckPoint) {
+	c.queryCacheMu.Lock()
+	c.queryCache.Purge()
+	c.queryCacheMu.Unlock()
+
+	if checkPoint != nil {
+		c.lastCheckPointCache.Store(checkPoint)
+	}
+}
+
+// signCheckPoint signs the check point with the authority key, and propagates it.
+func (c *checkPointHandler) signCheckPoint(checkPoint *types.CheckPoint) {
+	key := c.checkPointPriKey
+	if key == nil || checkPoint.Height == 0 {
+		return
+	}
+
+	hash := checkPoint.Hash()
+	sign, err := key.Sign(hash[:])
+	if err != nil {
+		log.Error("checkPointHandler signCheckPoint: sign failed", "hash", hash, "err", err)
+		return
+	}
+
+	if _, err := c.insertSignedCheckPoint(&types.SignedCheckPoint{CheckPoint: checkPoint, Signs: [][]byte{sign}}); err != nil {
+		log.Error("checkPointHandler signCheckPoint: insert signed check point failed", "hash", hash, "err", err)
+	}
+}
+
+func (c *checkPointHandler) insertSignedCheckPoint(signed *types.SignedCheckPoint) (bool, error) {
+	if signed.CheckPoint == nil || signed.CheckPoint.TreePoint == nil {
+		return false, errRemoteCheckPointEmpty
+	}
+	hash := signed.CheckPoint.Hash()
+
+	// only keep the signs of authorities
+	var valid [][]byte
+	for _, sign := range signed.Signs {
+		if types.VerifyCheckPointSigns(hash, [][]byte{sign}, c.authorities, 1) == nil {
+			valid = append(valid, sign)
+		}
+	}
+	if len(valid) == 0 {
+		return false, types.ErrCheckPointSignNotAuthority
+	}
+
+	c.signedCheckPointsMu.Lock()
+	merged := &types.SignedCheckPoint{CheckPoint: signed.CheckPoint}
+	if old := c.loadSignedCheckPoint(hash); old != nil {
+		merged.CheckPoint = old.CheckPoint
+		merged.Signs = old.Signs
+	}
+	oldSignsLen := len(merged.Signs)
+	merged.Signs = types.MergeCheckPointSigns(merged.Signs, valid)
+	if len(merged.Signs) == oldSignsLen {
+		c.signedCheckPointsMu.Unlock()
+		return false, nil
+	}
+	c.signedCheckPoints.Add(hash, merged)
+	dbaccessor.WriteSignedCheckPoint(c.blockChain.db, merged)
+
+	if types.VerifyCheckPointSigns(hash, merged.Signs, c.authorities, c.threshold) == nil {
+		latest, _ := c.latestSignedCheckPoint.Load().(*types.SignedCheckPoint)
+		if latest == nil || latest.CheckPoint.Height <= merged.CheckPoint.Height {
+			log.Info("checkPointHandler accept signed check point", "hash", hash, "height", merged.CheckPoint.Height, "signs", len(merged.Signs))
+			c.latestSignedCheckPoint.Store(merged)
+			dbaccessor.WriteLatestSignedCheckPointHash(c.blockChain.db, hash)
+		}
+	}
+	c.signedCheckPointsMu.Unlock()
+
+	c.signedCheckPointFeed.Send(types.NewSignedCheckPointEvent{CheckPoint: merged})
+	return true, nil
+}
+
+func (c *checkPointHandler) getSignedCheckPoint(hash common.Hash) *types.SignedCheckPoint {
+	c.signedCheckPointsMu.Lock()
+	defer c.signedCheckPointsMu.Unlock()
+
+	return c.loadSignedCheckPoint(hash)
+}
+
+// loadSignedCheckPoint returns the signs collected for the check point hash from
+// the cache, or from the database. The caller holds signedCheckPointsMu.
+func (c *checkPointHandler) loadSignedCheckPoint(hash common.Hash) *types.SignedCheckPoint {
+	if p, ok := c.signedCheckPoints.Get(hash); ok {
+		return p.(*types.SignedCheckPoint)
+	}
+	signed := dbaccessor.ReadSignedCheckPoint(c.blockChain.db, hash)
+	if signed != nil {
+		c.signedCheckPoints.Add(hash, signed)
+	}
+	return signed
+}
+
+// isCheckPointAccepted tells whether the check point is signed by enough authorities over the network.
+func (c *checkPointHandler) isCheckPointAccepted(checkPoint *types.CheckPoint) bool {
+	signed := c.getSignedCheckPoint(checkPoint.Hash())
+	if signed == nil {
+		return false
+	}
+	return types.VerifyCheckPointSigns(checkPoint.Hash(), signed.Signs, c.authorities, c.threshold) == nil
 }
 
 func (c *checkPointHandler) getLocalLastCheckPoint() *types.CheckPoint {
@@ -373,11 +538,11 @@ func (n *specialNode) genCheckPointDistance() uint64 {
 	return CheckPointCreateHeight + CheckPointCreateDelay
 }
 
-func (n *specialNode) verifyCheckPoint(checkPoint *types.CheckPoint) bool {
+func (n *specialNode) verifyCheckPoint(c *checkPointHandler, checkPoint *types.CheckPoint) bool {
 	return true
 }
 
-func (n *specialNode) initLastCheckPoint(c *checkPointHandler) {
+func (n *specialNode) initLastCheckPoint(c *checkPointHandler) error {
 	db := c.blockChain.db
 	last := dbaccessor.ReadLastCheckPoint(db)
 	if last == nil {
@@ -387,6 +552,7 @@ func (n *specialNode) initLastCheckPoint(c *checkPointHandler) {
 	} else {
 		c.lastCheckPointCache.Store(last)
 	}
+	return nil
 }
 
 func (n *specialNode) startCheck(c *checkPointHandler) {
@@ -415,17 +581,22 @@ func (n *specialNode) startCheck(c *checkPointHandler) {
 }
 
 type normalNode struct {
-	rpcAddress string
+	rpcAddresses []string
 }
 
 func (n *normalNode) genCheckPointDistance() uint64 {
 	return CheckPointCreateHeight + CheckPointVerifyDelay
 }
 
-func (n *normalNode) verifyCheckPoint(checkPoint *types.CheckPoint) bool {
-	remoteCheckPointHash, err := n.getRemoteCheckPointHashFromRPC()
+func (n *normalNode) verifyCheckPoint(c *checkPointHandler, checkPoint *types.CheckPoint) bool {
+	// the check point has been signed by enough authorities over the network
+	if c.isCheckPointAccepted(checkPoint) {
+		return true
+	}
+
+	remoteCheckPointHash, err := n.getRemoteCheckPointHashFromRPC(c)
 	if err != nil {
-		log.Error("normalNode getRemoteCheckPointFromRPC: get remote checkPoint error", "rpcAddress", n.rpcAddress, "err", err)
+		log.Error("normalNode getRemoteCheckPointFromRPC: get remote checkPoint error", "rpcAddresses", n.rpcAddresses, "err", err)
 		return false
 	}
 
@@ -436,17 +607,18 @@ func (n *normalNode) verifyCheckPoint(checkPoint *types.CheckPoint) bool {
 	return true
 }
 
-func (n *normalNode) initLastCheckPoint(c *checkPointHandler) {
+func (n *normalNode) initLastCheckPoint(c *checkPointHandler) error {
 	if !c.blockChain.chainConfig.CheckPointEnable {
 		genesisHash := dbaccessor.ReadGenesisBlockHash(c.blockChain.db) // genesis should not be nil
 		checkPoint := &types.CheckPoint{TreePoint: &types.TreePoint{Height: 0, FullHash: genesisHash, MainChainHashList: []common.Hash{genesisHash}, HashPairs: []types.HashPairFullAcc{{genesisHash, common.Hash{}}}}}
 		c.saveCheckPoint(0, checkPoint)
-		return
+		return nil
 	}
 
-	checkPoint, err := n.getRemoteCheckPointFromRPC()
+	checkPoint, err := n.getRemoteCheckPointFromRPC(c)
 	if err != nil {
-		panic(err)
+		log.Error("normalNode initLastCheckPoint: get remote check point failed", "rpcAddresses", n.rpcAddresses, "err", err)
+		return err
 	}
 
 	var index uint64
@@ -457,11 +629,14 @@ func (n *normalNode) initLastCheckPoint(c *checkPointHandler) {
 	}
 
 	c.saveCheckPoint(index, checkPoint)
+	return nil
 }
 
 func (n *normalNode) startCheck(c *checkPointHandler) {}
 
-func (n *normalNode) getRemoteCheckPointHashFromRPC() (common.Hash, error) {
+// callRemote calls the rpc method of one check point node, and retries to
+// connect until the context is done.
+func (n *normalNode) callRemote(ctx context.Context, rpcAddress string, result interface{}, method string) error {
 	var (
 		tryTimes = 0
 		client   *rpcclient.Client
@@ -469,85 +644,130 @@ func (n *normalNode) getRemoteCheckPointHashFromRPC() (common.Hash, error) {
 	)
 
 	for {
-		if tryTimes == 5 {
-			return common.Hash{}, err
-		}
-		client, err = rpcclient.Dial(n.rpcAddress)
-		if err != nil {
-			tryTimes++
-			log.Error("getRemoteCheckPointHashFromRPC: connect to rpc error", "rpc", n.rpcAddress, "tryTimes", tryTimes)
-			time.Sleep(3 * time.Second)
-		} else {
+		client, err = rpcclient.DialContext(ctx, rpcAddress)
+		if err == nil {
 			break
 		}
+		tryTimes++
+		log.Error("normalNode callRemote: connect to rpc error", "rpc", rpcAddress, "method", method, "tryTimes", tryTimes, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(checkPointDialInterval):
+		}
 	}
+	defer client.Close()
 
-	var signedCheckPointHash *types.SignedCheckPointHash
-	err = client.Call(&signedCheckPointHash, "ftl_getLastCheckPointHash")
-	if err != nil {
-		log.Error("get last check point hash error", "err", err)
-		return common.Hash{}, err
-	}
+	return client.CallContext(ctx, result, method)
+}
 
-	if len(signedCheckPointHash.Sign) == 0 {
-		return common.Hash{}, errRemoteMsgNotSigned
+// queryRemotes calls the rpc method of all check point nodes at the same time,
+// and hands the results to accept one by one as they come. It returns when a
+// result is accepted, or the error of the last result when all the nodes have
+// answered or the query times out.
+func (n *normalNode) queryRemotes(method string, newResult func() interface{}, accept func(rpcAddress string, result interface{}) error) error {
+	if len(n.rpcAddresses) == 0 {
+		return errNoCheckPointRPC
 	}
 
-	// verify sign here
-	pubKey, err := crypto.Ecrecover(signedCheckPointHash.Hash[:], signedCheckPointHash.Sign)
-	if err != nil {
-		log.Error("remote pubkey recover failed", "rpc", n.rpcAddress, "err", err)
-		return common.Hash{}, err
+	ctx, cancel := context.WithTimeout(context.Background(), checkPointQueryTimeout)
+	defer cancel()
+
+	type response struct {
+		rpcAddress string
+		result     interface{}
+		err        error
 	}
-	if hexutil.Encode(pubKey) != types.CheckPointNodePubKeyStr {
-		return common.Hash{}, errRemoteMsgSignedError
+	responses := make(chan response, len(n.rpcAddresses))
+	for _, rpcAddress := range n.rpcAddresses {
+		go func(rpcAddress string) {
+			result := newResult()
+			err := n.callRemote(ctx, rpcAddress, result, method)
+			responses <- response{rpcAddress, result, err}
+		}(rpcAddress)
 	}
 
-	return signedCheckPointHash.Hash, nil
+	lastErr := errNoCheckPointRPC
+	for range n.rpcAddresses {
+		select {
+		case resp := <-responses:
+			if resp.err != nil {
+				log.Error("normalNode queryRemotes: call rpc error", "rpc", resp.rpcAddress, "method", method, "err", resp.err)
+				lastErr = resp.err
+				continue
+			}
+			if err := accept(resp.rpcAddress, resp.result); err != nil {
+				lastErr = err
+				continue
+			}
+			return nil
+		case <-ctx.Done():
+			return lastErr
+		}
+	}
+	return lastErr
 }
 
-func (n *normalNode) getRemoteCheckPointFromRPC() (*types.CheckPoint, error) {
+// getRemoteCheckPointHashFromRPC collects the signs from all check point nodes,
+// and returns the first check point hash signed by enough authorities.
+func (n *normalNode) getRemoteCheckPointHashFromRPC(c *checkPointHandler) (common.Hash, error) {
 	var (
-		tryTimes = 0
-		client   *rpcclient.Client
-		err      error
+		signs    = make(map[common.Hash][][]byte)
+		accepted common.Hash
 	)
 
-	for {
-		if tryTimes == 5 {
-			return nil, err
+	err := n.queryRemotes("ftl_getLastCheckPointHash", func() interface{} {
+		return new(*types.SignedCheckPointHash)
+	}, func(rpcAddress string, result interface{}) error {
+		signedCheckPointHash := *result.(**types.SignedCheckPointHash)
+		if signedCheckPointHash == nil {
+			return errRemoteCheckPointEmpty
 		}
-		client, err = rpcclient.Dial(n.rpcAddress)
-		if err != nil {
-			tryTimes++
-			log.Error("getRemoteCheckPointFromRPC: connect to rpc error", "rpc", n.rpcAddress, "tryTimes", tryTimes)
-			time.Sleep(3 * time.Second)
-		} else {
-			break
-		}
-	}
 
-	var signedCheckPoint *types.SignedCheckPoint
-	err = client.Call(&signedCheckPoint, "ftl_getLastCheckPoint")
+		hash := signedCheckPointHash.Hash
+		signs[hash] = types.MergeCheckPointSigns(signs[hash], signedCheckPointHash.Signs)
+		if err := types.VerifyCheckPointSigns(hash, signs[hash], c.authorities, c.threshold); err != nil {
+			log.Warn("remote check point hash not accepted yet", "rpc", rpcAddress, "hash", hash, "signs", len(signs[hash]), "err", err)
+			return err
+		}
+		accepted = hash
+		return nil
+	})
 	if err != nil {
-		log.Error("get last check point error", "err", err)
-		return nil, err
+		return common.Hash{}, err
 	}
+	return accepted, nil
+}
 
-	if len(signedCheckPoint.Sign) == 0 {
-		return nil, errRemoteMsgNotSigned
-	}
+// getRemoteCheckPointFromRPC collects the signs from all check point nodes,
+// and returns the first check point signed by enough authorities.
+func (n *normalNode) getRemoteCheckPointFromRPC(c *checkPointHandler) (*types.CheckPoint, error) {
+	var (
+		checkPoints = make(map[common.Hash]*types.CheckPoint)
+		signs       = make(map[common.Hash][][]byte)
+		accepted    *types.CheckPoint
+	)
+
+	err := n.queryRemotes("ftl_getLastCheckPoint", func() interface{} {
+		return new(*types.SignedCheckPoint)
+	}, func(rpcAddress string, result interface{}) error {
+		signedCheckPoint := *result.(**types.SignedCheckPoint)
+		if signedCheckPoint == nil || signedCheckPoint.CheckPoint == nil || signedCheckPoint.CheckPoint.TreePoint == nil {
+			return errRemoteCheckPointEmpty
+		}
 
-	// verify sign here
-	checkPointHash := signedCheckPoint.CheckPoint.Hash()
-	pubKey, err := crypto.Ecrecover(checkPointHash[:], signedCheckPoint.Sign)
+		hash := signedCheckPoint.CheckPoint.Hash()
+		checkPoints[hash] = signedCheckPoint.CheckPoint
+		signs[hash] = types.MergeCheckPointSigns(signs[hash], signedCheckPoint.Signs)
+		if err := types.VerifyCheckPointSigns(hash, signs[hash], c.authorities, c.threshold); err != nil {
+			log.Warn("remote check point not accepted yet", "rpc", rpcAddress, "hash", hash, "signs", len(signs[hash]), "err", err)
+			return err
+		}
+		accepted = checkPoints[hash]
+		return nil
+	})
 	if err != nil {
-		log.Error("remote pubkey recover failed", "rpc", n.rpcAddress, "err", err)
 		return nil, err
 	}
-	if hexutil.Encode(pubKey) != types.CheckPointNodePubKeyStr {
-		return nil, errRemoteMsgSignedError
-	}
-
-	return signedCheckPoint.CheckPoint, nil
+	return accepted, nil
 }
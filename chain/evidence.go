@@ -0,0 +1,47 @@
+package chain
+
+import (
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
+)
+
+// checkEquivocation looks for another block mined by the same coinbase in the
+// round of the block. Such a double signing is stored as an evidence, which
+// can be submitted to the miner key contract to deregister the mining key.
+// The block itself is not rejected, since we can't tell which one is honest.
+func (bc *BlockChain) checkEquivocation(block *types.Block) {
+	for _, roundHash := range dbaccessor.ReadHashListByRound(bc.db, block.Header.Round) {
+		if roundHash.Round != block.Header.Round || roundHash.FullHash == block.FullHash() {
+			continue
+		}
+		other := bc.GetBlock(roundHash.FullHash)
+		if other == nil || other.Header.Coinbase != block.Header.Coinbase {
+			continue
+		}
+
+		evidence := types.NewEvidence(&other.Header, &block.Header)
+		if dbaccessor.HasEvidence(bc.db, evidence.Hash()) {
+			continue
+		}
+		dbaccessor.WriteEvidence(bc.db, evidence)
+		bc.logger.Warn("Miner equivocation detected", "coinbase", block.Header.Coinbase, "round", block.Header.Round,
+			"block1", other.FullHash(), "block2", block.FullHash(), "evidence", evidence.Hash())
+	}
+}
+
+// GetEvidence returns the equivocation evidence with the hash.
+func (bc *BlockChain) GetEvidence(hash common.Hash) *types.Evidence {
+	return dbaccessor.ReadEvidence(bc.db, hash)
+}
+
+// GetEvidences returns all the equivocation evidences found so far.
+func (bc *BlockChain) GetEvidences() types.Evidences {
+	var evidences types.Evidences
+	for _, hash := range dbaccessor.ReadEvidenceHashes(bc.db) {
+		if evidence := dbaccessor.ReadEvidence(bc.db, hash); evidence != nil {
+			evidences = append(evidences, evidence)
+		}
+	}
+	return evidences
+}
@@ -0,0 +1,172 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/rlp"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+// importLogInterval is the interval between two progress logs of an import.
+const importLogInterval = 8 * time.Second
+
+var (
+	ErrInvalidExportRange = errors.New("invalid export range")
+	ErrImportInterrupted  = errors.New("import interrupted")
+)
+
+// ExportedBlock is an item of an exported chain stream: a block, with the
+// packages it references which are not in the stream before it.
+type ExportedBlock struct {
+	Block    *types.Block
+	Packages types.TxPackages
+}
+
+// ExportChain writes the blocks from the main branch block at height first to
+// the main branch block at height last to w, as a stream of ExportedBlock.
+// The side blocks confirmed by the main branch are exported as well, so all
+// the blocks in the round range are written, ordered by round. Exports of
+// consecutive height ranges can be imported one after another.
+func ExportChain(db dbwrapper.Database, w io.Writer, first uint64, last uint64) (int, error) {
+	if first == 0 {
+		first = 1
+	}
+	headHeight, _, err := dbaccessor.ReadMainBranchHeadHeightAndHash(db)
+	if err != nil {
+		return 0, err
+	}
+	if first > last || last > headHeight {
+		return 0, ErrInvalidExportRange
+	}
+
+	start, err := readMainBranchBlock(db, first-1)
+	if err != nil {
+		return 0, err
+	}
+	end, err := readMainBranchBlock(db, last)
+	if err != nil {
+		return 0, err
+	}
+
+	var hashList types.BlockRoundHashes = dbaccessor.ReadHashListByBlockRange(db, start, end)
+	hashList.SortByRoundHash()
+
+	exported := make(map[common.Hash]struct{})
+	for i, item := range hashList {
+		block := readBlock(db, item.FullHash)
+		if block == nil {
+			return i, fmt.Errorf("block %x not found", item.FullHash)
+		}
+
+		var pkgs types.TxPackages
+		for _, pkgHash := range block.Body.TxPackageHashes {
+			if _, ok := exported[pkgHash]; ok {
+				continue
+			}
+			pkg, err := dbaccessor.ReadTxPkg(db, pkgHash)
+			if pkg == nil {
+				return i, fmt.Errorf("package %x not found: %v", pkgHash, err)
+			}
+			pkgs = append(pkgs, pkg)
+			exported[pkgHash] = struct{}{}
+		}
+
+		if err := rlp.Encode(w, &ExportedBlock{Block: block, Packages: pkgs}); err != nil {
+			return i, err
+		}
+	}
+	return len(hashList), nil
+}
+
+// ImportChain reads a stream written by ExportChain, and inserts its packages
+// and blocks the same way as the blocks received from the network. Blocks
+// already in the chain are skipped, so an interrupted import can be resumed
+// by importing the same stream again. The import stops between two blocks
+// when abort is closed.
+func (bc *BlockChain) ImportChain(r io.Reader, abort <-chan struct{}) (int, error) {
+	var (
+		stream   = rlp.NewStream(r, 0)
+		imported = 0
+		skipped  = 0
+		start    = time.Now()
+		lastLog  = time.Now()
+	)
+	for {
+		select {
+		case <-abort:
+			return imported, ErrImportInterrupted
+		default:
+		}
+
+		var item ExportedBlock
+		if err := stream.Decode(&item); err == io.EOF {
+			break
+		} else if err != nil {
+			return imported, fmt.Errorf("block %d: %v", imported+skipped, err)
+		}
+
+		block := item.Block
+		if bc.HasBlock(block.FullHash()) {
+			skipped++
+			continue
+		}
+
+		for _, pkg := range item.Packages {
+			if !bc.HasTxPackage(pkg.Hash()) {
+				bc.InsertTxPackage(pkg)
+			}
+		}
+
+		block.ReceivedAt = time.Now()
+		block.ReceivedPath = types.BlockMined
+		if _, _, _, _, err := bc.VerifyBlock(block, true); err != nil {
+			return imported, fmt.Errorf("block %x at height %d: %v", block.FullHash(), block.Header.Height, err)
+		}
+		bc.InsertBlock(block)
+		imported++
+
+		if time.Since(lastLog) > importLogInterval {
+			bc.logger.Info("Importing chain", "imported", imported, "skipped", skipped, "height", block.Header.Height,
+				"round", block.Header.Round, "elapsed", common.PrettyDuration(time.Since(start)))
+			lastLog = time.Now()
+		}
+	}
+
+	bc.logger.Info("Imported chain", "imported", imported, "skipped", skipped, "head", bc.CurrentBlock().Header.Height,
+		"elapsed", common.PrettyDuration(time.Since(start)))
+	return imported, nil
+}
+
+func readMainBranchBlock(db dbwrapper.Database, height uint64) (*types.Block, error) {
+	hash, err := dbaccessor.ReadHeightBlockMap(db, height)
+	if err != nil {
+		return nil, fmt.Errorf("main branch block at height %d not found: %v", height, err)
+	}
+	block := readBlock(db, hash)
+	if block == nil {
+		return nil, fmt.Errorf("main branch block at height %d not found", height)
+	}
+	return block, nil
+}
+
+func readBlock(db dbwrapper.Database, hash common.Hash) *types.Block {
+	header := dbaccessor.ReadBlockHeader(db, hash)
+	if header == nil {
+		return nil
+	}
+	body := dbaccessor.ReadBlockBody(db, hash)
+	if body == nil {
+		log.Error("Block body not found", "hash", hash)
+		return nil
+	}
+	block := types.NewBlockWithHeader(header)
+	block.Body = *body
+	return block
+}
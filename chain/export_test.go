@@ -0,0 +1,92 @@
+package chain_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fractal-platform/fractal/chain"
+	"github.com/fractal-platform/fractal/rlp"
+	"github.com/fractal-platform/fractal/testnet"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// export returns the stream of the main branch blocks of the node from first
+// to last.
+func export(node *testnet.Node, first, last uint64) ([]byte, int) {
+	var buf bytes.Buffer
+	count, err := chain.ExportChain(node.BlockChain().Database(), &buf, first, last)
+	So(err, ShouldBeNil)
+	return buf.Bytes(), count
+}
+
+func TestExportImportChain(t *testing.T) {
+	Convey("a chain exported is imported into the nodes with the same genesis", t, func() {
+		n, err := testnet.New(testnet.Config{Nodes: []testnet.NodeConfig{{Miner: true}, {Offline: true}, {Offline: true}}})
+		So(err, ShouldBeNil)
+		Reset(n.Stop)
+		So(n.Start(), ShouldBeNil)
+
+		miner := n.Node(0)
+		So(n.RunUntil(200, func() bool { return miner.BlockChain().CurrentBlock().Header.Height >= 10 }), ShouldBeTrue)
+		head := miner.BlockChain().CurrentBlock()
+		stream, count := export(miner, 1, head.Header.Height)
+		So(count, ShouldBeGreaterThanOrEqualTo, head.Header.Height)
+
+		var buf bytes.Buffer
+		_, err = chain.ExportChain(miner.BlockChain().Database(), &buf, 4, 3)
+		So(err, ShouldEqual, chain.ErrInvalidExportRange)
+		_, err = chain.ExportChain(miner.BlockChain().Database(), &buf, 1, head.Header.Height+100)
+		So(err, ShouldEqual, chain.ErrInvalidExportRange)
+
+		// the nodes importing the chain do not sync it from the miner
+		n.Partition([]int{0}, []int{1}, []int{2})
+		So(n.StartNode(1), ShouldBeNil)
+		So(n.StartNode(2), ShouldBeNil)
+		node := n.Node(1)
+
+		// an aborted import stops before the first block
+		abort := make(chan struct{})
+		close(abort)
+		imported, err := node.BlockChain().ImportChain(bytes.NewReader(stream), abort)
+		So(err, ShouldEqual, chain.ErrImportInterrupted)
+		So(imported, ShouldEqual, 0)
+		So(node.BlockChain().CurrentBlock().Header.Height, ShouldEqual, 0)
+
+		// an import interrupted by the end of a stream cut in the fourth block
+		// is resumed
+		rest := stream
+		for i := 0; i < 3; i++ {
+			_, _, rest, err = rlp.Split(rest)
+			So(err, ShouldBeNil)
+		}
+		imported, err = node.BlockChain().ImportChain(bytes.NewReader(stream[:len(stream)-len(rest)+10]), nil)
+		So(err, ShouldNotBeNil)
+		So(imported, ShouldEqual, 3)
+		So(node.BlockChain().CurrentBlock().Header.Height, ShouldBeLessThan, head.Header.Height)
+
+		resumed, err := node.BlockChain().ImportChain(bytes.NewReader(stream), nil)
+		So(err, ShouldBeNil)
+		So(resumed, ShouldEqual, count-imported)
+		So(node.BlockChain().CurrentBlock().FullHash(), ShouldEqual, head.FullHash())
+
+		// the blocks imported are skipped
+		imported, err = node.BlockChain().ImportChain(bytes.NewReader(stream), nil)
+		So(err, ShouldBeNil)
+		So(imported, ShouldEqual, 0)
+		So(node.BlockChain().CurrentBlock().FullHash(), ShouldEqual, head.FullHash())
+
+		// the exports of consecutive height ranges are imported one after another
+		node = n.Node(2)
+		middle := head.Header.Height / 2
+		first, firstCount := export(miner, 1, middle)
+		last, lastCount := export(miner, middle+1, head.Header.Height)
+		imported, err = node.BlockChain().ImportChain(bytes.NewReader(first), nil)
+		So(err, ShouldBeNil)
+		So(imported, ShouldEqual, firstCount)
+		imported, err = node.BlockChain().ImportChain(bytes.NewReader(last), nil)
+		So(err, ShouldBeNil)
+		So(imported, ShouldEqual, lastCount)
+		So(node.BlockChain().CurrentBlock().FullHash(), ShouldEqual, head.FullHash())
+		So(node.BlockChain().CurrentBlock().Header.StateHash, ShouldEqual, head.Header.StateHash)
+	})
+}
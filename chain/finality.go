@@ -0,0 +1,234 @@
+package chain
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/event"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+// finalityScanHeights is the number of main branch heights looked at for a
+// block confirmed by the DAG on each update.
+const finalityScanHeights = 64
+
+// confirmWindow is a main branch block and the main branch block the depth
+// heights above it, between which the DAG confirmation is counted.
+type confirmWindow struct {
+	block common.Hash
+	end   common.Hash
+}
+
+// confirmScan is the result of isConfirmedByDAG for a window.
+type confirmScan struct {
+	height    uint64
+	confirmed bool
+}
+
+// updateFinalized advances the finalized block of the main branch. A main
+// branch block is finalized when the check point signed by the authorities
+// covers it, or when it is confirmed by the DAG: the main branch is greedy
+// plus ConfirmHeightDistance blocks above it, and more than two thirds of the
+// blocks mined in the rounds up to there reach it through their parents and
+// confirms. All the ancestors of a finalized block are finalized with it.
+//
+// The DAG is only scanned when the main branch head moves, from the height
+// scanned last, and the result of each block is kept.
+//
+// The finalized block never moves back. When the main branch or a signed
+// check point conflicts with it, a FinalityConflictEvent is sent and the
+// finalized block stops advancing until the chain is rewound.
+func (m *MainBranchRecord) updateFinalized() {
+	if atomic.LoadInt32(&m.finalityHalted) == 1 {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&m.finalityReset, 1, 0) {
+		m.scannedHead, m.scannedHeight, m.scannedHash = common.Hash{}, 0, common.Hash{}
+		m.confirmCache = make(map[confirmWindow]confirmScan)
+	}
+
+	db := m.blockChain.Database()
+	headHeight, headHash, err := dbaccessor.ReadMainBranchHeadHeightAndHash(db)
+	if err != nil {
+		return
+	}
+
+	finalizedHeight, finalizedHash, err := dbaccessor.ReadFinalizedBlockHeightAndHash(db)
+	if err == nil {
+		if hash, err := dbaccessor.ReadHeightBlockMap(db, finalizedHeight); err != nil || hash != finalizedHash {
+			m.haltFinality(types.FinalityConflictEvent{Height: finalizedHeight, Finalized: finalizedHash, Conflict: hash}, "main branch reverts the finalized block")
+			return
+		}
+	}
+
+	var height uint64
+	if headHash != m.scannedHead {
+		height = m.confirmedHeight(headHeight, finalizedHeight)
+		m.scannedHead = headHash
+	}
+	if signed := m.blockChain.GetLatestSignedCheckPoint(); signed != nil && signed.CheckPoint.Height <= headHeight {
+		checkPoint := signed.CheckPoint
+		hash, err := dbaccessor.ReadHeightBlockMap(db, checkPoint.Height)
+		if err == nil && hash != checkPoint.FullHash {
+			m.haltFinality(types.FinalityConflictEvent{Height: checkPoint.Height, Finalized: hash, Conflict: checkPoint.FullHash}, "main branch conflicts with the signed check point")
+			return
+		}
+		if err == nil && checkPoint.Height > height {
+			height = checkPoint.Height
+		}
+	}
+	if height <= finalizedHeight {
+		return
+	}
+
+	block, err := m.GetMainBranchBlock(height)
+	if err != nil {
+		log.Error("Get finalized block failed", "height", height, "err", err)
+		return
+	}
+	dbaccessor.WriteFinalizedBlockHeightAndHash(db, height, block.FullHash())
+	log.Info("Finalized block advances", "height", height, "hash", block.FullHash())
+	m.finalizedFeed.Send(types.FinalizedBlockEvent{Block: block})
+}
+
+// confirmedHeight returns the highest main branch height above the finalized
+// one and the one scanned last whose block is confirmed by the DAG, or 0 if
+// there is none. The heights below the one scanned last are scanned again
+// only if the main branch changed there.
+func (m *MainBranchRecord) confirmedHeight(headHeight uint64, finalizedHeight uint64) uint64 {
+	depth := uint64(m.blockChain.GetGreedy()) + params.ConfirmHeightDistance
+	if headHeight <= depth {
+		return 0
+	}
+	db := m.blockChain.Database()
+	from := finalizedHeight
+	if m.scannedHeight > from {
+		if hash, err := dbaccessor.ReadHeightBlockMap(db, m.scannedHeight); err == nil && hash == m.scannedHash {
+			from = m.scannedHeight
+		}
+	}
+	for window, scan := range m.confirmCache {
+		if scan.height <= finalizedHeight {
+			delete(m.confirmCache, window)
+		}
+	}
+
+	top := headHeight - depth
+	if hash, err := dbaccessor.ReadHeightBlockMap(db, top); err == nil {
+		m.scannedHeight, m.scannedHash = top, hash
+	}
+	for height := top; height > from && top-height < finalityScanHeights; height-- {
+		if m.isConfirmedByDAG(height, depth) {
+			return height
+		}
+	}
+	return 0
+}
+
+// isConfirmedByDAG tells whether more than two thirds of the blocks mined
+// after the main branch block at the height, until the main branch block depth
+// heights above it, have the block as an ancestor through their parents and
+// confirms.
+func (m *MainBranchRecord) isConfirmedByDAG(height uint64, depth uint64) bool {
+	block, err := m.GetMainBranchBlock(height)
+	if err != nil {
+		return false
+	}
+	end, err := m.GetMainBranchBlock(height + depth)
+	if err != nil {
+		return false
+	}
+	window := confirmWindow{block: block.FullHash(), end: end.FullHash()}
+	if scan, ok := m.confirmCache[window]; ok {
+		return scan.confirmed
+	}
+
+	items := dbaccessor.ReadHashListByRoundRange(m.blockChain.Database(), block.Header.Round, end.Header.Round)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Round < items[j].Round })
+
+	// a block only refers to the blocks of earlier rounds
+	reached := map[common.Hash]struct{}{block.FullHash(): {}}
+	var confirmers, total int
+	for _, item := range items {
+		b := m.blockChain.GetBlock(item.FullHash)
+		if b == nil {
+			continue
+		}
+		total++
+		if _, ok := reached[b.Header.ParentFullHash]; ok {
+			reached[b.FullHash()] = struct{}{}
+			confirmers++
+			continue
+		}
+		for _, hash := range b.Header.Confirms {
+			if _, ok := reached[hash]; ok {
+				reached[b.FullHash()] = struct{}{}
+				confirmers++
+				break
+			}
+		}
+	}
+	confirmed := confirmers*3 > total*2
+	m.confirmCache[window] = confirmScan{height: height, confirmed: confirmed}
+	return confirmed
+}
+
+func (m *MainBranchRecord) haltFinality(ev types.FinalityConflictEvent, reason string) {
+	if !atomic.CompareAndSwapInt32(&m.finalityHalted, 0, 1) {
+		return
+	}
+	log.Error("Finality conflict, the finalized block stops advancing", "reason", reason,
+		"height", ev.Height, "finalized", ev.Finalized, "conflict", ev.Conflict)
+	m.finalityConflictFeed.Send(ev)
+}
+
+// resetFinality lets the finalized block advance again after the chain is
+// rewound below a conflict, and scans the DAG of the rewound main branch.
+func (m *MainBranchRecord) resetFinality() {
+	atomic.StoreInt32(&m.finalityReset, 1)
+	atomic.StoreInt32(&m.finalityHalted, 0)
+}
+
+// GetFinalizedBlock returns the finalized block of the main branch.
+func (m *MainBranchRecord) GetFinalizedBlock() *types.Block {
+	_, hash, err := dbaccessor.ReadFinalizedBlockHeightAndHash(m.blockChain.Database())
+	if err != nil {
+		return nil
+	}
+	return m.blockChain.GetBlock(hash)
+}
+
+func (m *MainBranchRecord) SubscribeFinalizedBlockEvent(ch chan<- types.FinalizedBlockEvent) event.Subscription {
+	return m.finalizedFeed.Subscribe(ch)
+}
+
+func (m *MainBranchRecord) SubscribeFinalityConflictEvent(ch chan<- types.FinalityConflictEvent) event.Subscription {
+	return m.finalityConflictFeed.Subscribe(ch)
+}
+
+// GetFinalizedBlock returns the finalized block of the main branch, which
+// will not be reverted.
+func (bc *BlockChain) GetFinalizedBlock() *types.Block {
+	return bc.mainBranchRecord.GetFinalizedBlock()
+}
+
+// IsFinalized checks if the block is in the main branch and finalized.
+func (bc *BlockChain) IsFinalized(block *types.Block) bool {
+	finalized := bc.GetFinalizedBlock()
+	if finalized == nil || block == nil || block.Header.Height > finalized.Header.Height {
+		return false
+	}
+	return bc.IsInMainBranch(block)
+}
+
+func (bc *BlockChain) SubscribeFinalizedBlockEvent(ch chan<- types.FinalizedBlockEvent) event.Subscription {
+	return bc.mainBranchRecord.SubscribeFinalizedBlockEvent(ch)
+}
+
+func (bc *BlockChain) SubscribeFinalityConflictEvent(ch chan<- types.FinalityConflictEvent) event.Subscription {
+	return bc.mainBranchRecord.SubscribeFinalityConflictEvent(ch)
+}
@@ -0,0 +1,230 @@
+package chain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/event"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testFinalityChain is the chain backend of a main branch record under test.
+type testFinalityChain struct {
+	db     dbwrapper.Database
+	blocks map[common.Hash]*types.Block
+	rounds map[uint64]types.BlockRoundHashes
+	signed *types.SignedCheckPoint
+	main   types.Blocks
+	gets   int // number of GetBlock calls
+}
+
+func newTestFinalityChain() *testFinalityChain {
+	c := &testFinalityChain{
+		db:     dbwrapper.NewMemDatabase(),
+		blocks: make(map[common.Hash]*types.Block),
+		rounds: make(map[uint64]types.BlockRoundHashes),
+	}
+	genesis := types.NewBlock(common.Hash{}, 1000, nil, common.Address{}, big.NewInt(1), 0)
+	c.add(genesis)
+	c.setMain(genesis)
+	return c
+}
+
+func (c *testFinalityChain) GetBlock(hash common.Hash) *types.Block {
+	c.gets++
+	return c.blocks[hash]
+}
+func (c *testFinalityChain) Database() dbwrapper.Database { return c.db }
+func (c *testFinalityChain) CurrentBlock() *types.Block   { return c.main[len(c.main)-1] }
+func (c *testFinalityChain) GetGreedy() uint8             { return 2 }
+func (c *testFinalityChain) GetLatestSignedCheckPoint() *types.SignedCheckPoint {
+	return c.signed
+}
+func (c *testFinalityChain) SubscribeChainUpdateEvent(ch chan<- types.ChainUpdateEvent) event.Subscription {
+	return nil
+}
+func (c *testFinalityChain) SubscribeBlockExecutedEvent(ch chan<- types.BlockExecutedEvent) event.Subscription {
+	return nil
+}
+
+func (c *testFinalityChain) add(block *types.Block) {
+	c.blocks[block.FullHash()] = block
+	step := block.Header.Round - block.Header.Round%dbaccessor.RoundStep
+	c.rounds[step] = append(c.rounds[step], &types.BlockRoundHash{Round: block.Header.Round, SimpleHash: block.SimpleHash(), FullHash: block.FullHash()})
+	dbaccessor.WriteHashList(c.db, step, c.rounds[step])
+}
+
+func (c *testFinalityChain) setMain(block *types.Block) {
+	height := block.Header.Height
+	c.main = append(c.main[:height], block)
+	dbaccessor.WriteHeightBlockMap(c.db, height, block.FullHash())
+	dbaccessor.WriteMainBranchHeadHeightAndHash(c.db, height, block.FullHash())
+}
+
+// child returns a new block on the parent, confirming the blocks.
+func (c *testFinalityChain) child(parent *types.Block, round uint64, confirms ...*types.Block) *types.Block {
+	block := types.NewBlock(parent.SimpleHash(), round, []byte{byte(round), byte(round >> 8)}, common.Address{}, big.NewInt(1), parent.Header.Height+1)
+	block.Header.ParentFullHash = parent.FullHash()
+	for _, confirm := range confirms {
+		block.Header.Confirms = append(block.Header.Confirms, confirm.FullHash())
+	}
+	c.add(block)
+	return block
+}
+
+// grow adds main branch blocks on the head, 10 rounds apart.
+func (c *testFinalityChain) grow(n int) {
+	for i := 0; i < n; i++ {
+		head := c.CurrentBlock()
+		c.setMain(c.child(head, head.Header.Round+10))
+	}
+}
+
+func finalizedHeight(db dbwrapper.Database) uint64 {
+	height, _, err := dbaccessor.ReadFinalizedBlockHeightAndHash(db)
+	So(err, ShouldBeNil)
+	return height
+}
+
+func TestFinality(t *testing.T) {
+	// greedy 2 plus ConfirmHeightDistance 6
+	const depth = 8
+
+	Convey("finalize the blocks confirmed by the DAG", t, func() {
+		c := newTestFinalityChain()
+		m := NewMainBranchRecord(c)
+		events := make(chan types.FinalizedBlockEvent, 10)
+		m.SubscribeFinalizedBlockEvent(events)
+
+		c.grow(depth)
+		m.updateFinalized()
+		_, _, err := dbaccessor.ReadFinalizedBlockHeightAndHash(c.db)
+		So(err, ShouldNotBeNil)
+
+		c.grow(22)
+		m.updateFinalized()
+		So(finalizedHeight(c.db), ShouldEqual, 30-depth)
+		So(m.GetFinalizedBlock().FullHash(), ShouldEqual, c.main[30-depth].FullHash())
+		So((<-events).Block.Header.Height, ShouldEqual, 30-depth)
+
+		Convey("scan the DAG only when the main branch head moves", func() {
+			gets := c.gets
+			m.updateFinalized()
+			So(c.gets, ShouldEqual, gets)
+
+			c.grow(1)
+			m.updateFinalized()
+			So(finalizedHeight(c.db), ShouldEqual, 31-depth)
+		})
+
+		Convey("resume the scan from the height scanned last", func() {
+			c.grow(1)
+			head := c.CurrentBlock().Header.Height
+			So(m.confirmedHeight(head, 0), ShouldEqual, head-depth)
+			gets := c.gets
+			So(m.confirmedHeight(head, 0), ShouldEqual, 0)
+			So(c.gets, ShouldEqual, gets)
+
+			Convey("and keep the result of each block", func() {
+				m.scannedHeight = 0
+				So(m.confirmedHeight(head, 0), ShouldEqual, head-depth)
+				// only the block and the end of the window are read
+				So(c.gets-gets, ShouldEqual, 2)
+			})
+		})
+
+		Convey("not the blocks forks compete with", func() {
+			// blocks competing with the main branch block 32
+			c.grow(2)
+			var forks []*types.Block
+			for i := uint64(0); i < 5; i++ {
+				forks = append(forks, c.child(c.main[31], c.main[32].Header.Round+1+i))
+			}
+
+			Convey("left unconfirmed", func() {
+				c.grow(8)
+				m.updateFinalized()
+				So(finalizedHeight(c.db), ShouldEqual, 31)
+
+				Convey("unless the check point is signed", func() {
+					c.signed = &types.SignedCheckPoint{CheckPoint: &types.CheckPoint{TreePoint: &types.TreePoint{Height: 35, FullHash: c.main[35].FullHash()}}}
+					m.updateFinalized()
+					So(finalizedHeight(c.db), ShouldEqual, 35)
+				})
+			})
+
+			Convey("unless the DAG confirms them", func() {
+				c.grow(1)
+				for i := uint64(0); i < 10; i++ {
+					c.child(forks[i%5], c.main[33].Header.Round+1+i, c.main[32])
+				}
+				c.grow(7)
+				m.updateFinalized()
+				So(finalizedHeight(c.db), ShouldEqual, 32)
+			})
+		})
+
+		Convey("count the side blocks on the main branch", func() {
+			c.grow(3)
+			for i := uint64(0); i < 10; i++ {
+				c.child(c.main[32], c.main[33].Header.Round+1+i)
+			}
+			c.grow(7)
+			m.updateFinalized()
+			So(finalizedHeight(c.db), ShouldEqual, 40-depth)
+		})
+
+		Convey("stop on a conflict", func() {
+			conflicts := make(chan types.FinalityConflictEvent, 10)
+			m.SubscribeFinalityConflictEvent(conflicts)
+			finalized := c.main[30-depth]
+
+			Convey("with the signed check point", func() {
+				fork := c.child(c.main[27], c.main[28].Header.Round+1)
+				c.signed = &types.SignedCheckPoint{CheckPoint: &types.CheckPoint{TreePoint: &types.TreePoint{Height: 28, FullHash: fork.FullHash()}}}
+				c.grow(10)
+				m.updateFinalized()
+
+				ev := <-conflicts
+				So(ev.Height, ShouldEqual, 28)
+				So(ev.Finalized, ShouldEqual, c.main[28].FullHash())
+				So(ev.Conflict, ShouldEqual, fork.FullHash())
+				So(finalizedHeight(c.db), ShouldEqual, finalized.Header.Height)
+			})
+
+			Convey("with a main branch reverting the finalized block", func() {
+				parent := c.main[finalized.Header.Height-1]
+				fork := c.child(parent, parent.Header.Round+1)
+				c.setMain(fork)
+				c.grow(30)
+				m.updateFinalized()
+
+				ev := <-conflicts
+				So(ev.Height, ShouldEqual, finalized.Header.Height)
+				So(ev.Finalized, ShouldEqual, finalized.FullHash())
+				So(ev.Conflict, ShouldEqual, fork.FullHash())
+
+				// the finalized block doesn't move
+				height, hash, err := dbaccessor.ReadFinalizedBlockHeightAndHash(c.db)
+				So(err, ShouldBeNil)
+				So(height, ShouldEqual, finalized.Header.Height)
+				So(hash, ShouldEqual, finalized.FullHash())
+
+				// nor advances until a rewind
+				c.grow(10)
+				m.updateFinalized()
+				So(finalizedHeight(c.db), ShouldEqual, finalized.Header.Height)
+				So(len(conflicts), ShouldEqual, 0)
+
+				dbaccessor.WriteFinalizedBlockHeightAndHash(c.db, parent.Header.Height, parent.FullHash())
+				m.resetFinality()
+				m.updateFinalized()
+				So(finalizedHeight(c.db), ShouldEqual, c.CurrentBlock().Header.Height-depth)
+			})
+		})
+	})
+}
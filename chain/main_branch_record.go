@@ -21,11 +21,24 @@ type ChainBackend interface {
 	CurrentBlock() *types.Block
 	SubscribeChainUpdateEvent(ch chan<- types.ChainUpdateEvent) event.Subscription
 	SubscribeBlockExecutedEvent(ch chan<- types.BlockExecutedEvent) event.Subscription
+	GetGreedy() uint8
+	GetLatestSignedCheckPoint() *types.SignedCheckPoint
 }
 
 type MainBranchRecord struct {
 	blockChain ChainBackend
 
+	finalizedFeed        event.Feed
+	finalityConflictFeed event.Feed
+	finalityHalted       int32 // set on a finality conflict
+	finalityReset        int32 // set by a rewind, so that the DAG is scanned again
+
+	// the DAG scan of updateFinalized, only used by the event loop
+	scannedHead   common.Hash                   // main branch head of the last scan
+	scannedHeight uint64                        // highest main branch height scanned
+	scannedHash   common.Hash                   // main branch block at the scanned height
+	confirmCache  map[confirmWindow]confirmScan // results of isConfirmedByDAG
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup // for shutdown sync
@@ -33,7 +46,8 @@ type MainBranchRecord struct {
 
 func NewMainBranchRecord(bc ChainBackend) *MainBranchRecord {
 	m := &MainBranchRecord{
-		blockChain: bc,
+		blockChain:   bc,
+		confirmCache: make(map[confirmWindow]confirmScan),
 	}
 
 	_, _, err := dbaccessor.ReadMainBranchHeadHeightAndHash(bc.Database())
@@ -79,6 +93,11 @@ func (m *MainBranchRecord) eventLoop(events chan types.BlockExecutedEvent, sub e
 			blockReceivedPath := block.ReceivedPath
 			log.Info("MainBranchRecord recv block", "block", block.FullHash(), "blockReceivedPath", blockReceivedPath)
 
+			// the block is removed by a rewind of the chain
+			if m.blockChain.GetBlock(block.FullHash()) == nil {
+				continue
+			}
+
 			db := m.blockChain.Database()
 
 			savedHeight, hash, err := dbaccessor.ReadMainBranchHeadHeightAndHash(db)
@@ -102,6 +121,7 @@ func (m *MainBranchRecord) eventLoop(events chan types.BlockExecutedEvent, sub e
 					dbaccessor.WriteMainBranchHeadHeightAndHash(batch, block.Header.Height, block.FullHash())
 				}
 				batch.Write()
+				m.updateFinalized()
 				continue
 			}
 
@@ -126,6 +146,7 @@ func (m *MainBranchRecord) eventLoop(events chan types.BlockExecutedEvent, sub e
 				dbaccessor.WriteMainBranchHeadHeightAndHash(batch, block.Header.Height, block.FullHash())
 				batch.Write()
 			}
+			m.updateFinalized()
 		case <-m.ctx.Done():
 			return
 		}
@@ -144,3 +165,12 @@ func (m *MainBranchRecord) GetMainBranchBlock(height uint64) (*types.Block, erro
 
 	return block, nil
 }
+
+// GetMainBranchHead returns the head block of the main branch.
+func (m *MainBranchRecord) GetMainBranchHead() *types.Block {
+	_, hash, err := dbaccessor.ReadMainBranchHeadHeightAndHash(m.blockChain.Database())
+	if err != nil {
+		return nil
+	}
+	return m.blockChain.GetBlock(hash)
+}
@@ -0,0 +1,287 @@
+package chain
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/trie"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+)
+
+const (
+	// pruneInterval is the number of heights between two prunings.
+	pruneInterval = 128
+
+	// pruneRoundMargin covers the blocks mined a bit ahead of the local clock.
+	pruneRoundMargin = 60 * params.RoundsPerSecond
+
+	// pruneBatchKeys is the number of keys the sweep deletes at once.
+	pruneBatchKeys = dbwrapper.IdealBatchSize / common.HashLength
+)
+
+var errPruneInterrupted = errors.New("pruning interrupted")
+
+type iteratee interface {
+	NewIterator() iterator.Iterator
+}
+
+// statePruner deletes the trie nodes and codes no longer reachable from the
+// kept states, by marking the nodes of the kept states and sweeping the rest
+// of the database. The kept states are the states of all blocks above the
+// last StateHistory heights of the main branch, of the check points and of
+// the genesis.
+//
+// Trie nodes and codes are stored under their bare 32 bytes hash, while all
+// the other keys in the database are prefixed, so the sweep only looks at
+// 32 bytes keys.
+//
+// The blocks keep being inserted during a pruning: the states committed after
+// the mark are marked before each batch of the sweep is deleted.
+type statePruner struct {
+	bc      *BlockChain
+	db      iteratee
+	history uint64
+
+	// lock is held for reading by the state commits, and for writing by the
+	// sweep while it deletes a batch, so that no node is written between the
+	// check of the batch and its deletion.
+	lock sync.RWMutex
+
+	committedLock sync.Mutex
+	committed     []common.Hash // roots committed during the pruning, nil out of a pruning
+
+	running    int32
+	lastHeight uint64 // head height of the last pruning, guarded by running
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newStatePruner(bc *BlockChain, history uint64) *statePruner {
+	db, ok := bc.db.(iteratee)
+	if !ok {
+		bc.logger.Warn("State pruning is not supported by the database, keep all states")
+		return nil
+	}
+	prunedHeight := dbaccessor.ReadStatePrunedHeight(bc.db)
+	bc.logger.Info("State pruning enabled", "history", history, "prunedHeight", prunedHeight)
+	return &statePruner{
+		bc:         bc,
+		db:         db,
+		history:    history,
+		lastHeight: prunedHeight + history - 1,
+		quit:       make(chan struct{}),
+	}
+}
+
+func (p *statePruner) stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// maybePrune starts a pruning in background every pruneInterval heights.
+func (p *statePruner) maybePrune() {
+	if !atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+		return
+	}
+	head := p.bc.CurrentBlock().Header.Height
+	if head < p.lastHeight+pruneInterval || head < p.history {
+		atomic.StoreInt32(&p.running, 0)
+		return
+	}
+	p.lastHeight = head
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer atomic.StoreInt32(&p.running, 0)
+		p.prune(head - p.history + 1)
+	}()
+}
+
+// prune deletes the states only used by the main branch blocks below the height.
+func (p *statePruner) prune(height uint64) {
+	start := time.Now()
+	p.trackCommits(true)
+	defer p.trackCommits(false)
+
+	marked, err := p.mark(p.keptRoots(height))
+	if err != nil {
+		p.bc.logger.Error("Mark state failed, skip pruning", "err", err)
+		return
+	}
+	markElapse := time.Since(start)
+
+	deleted, err := p.sweep(marked)
+	if err != nil {
+		p.bc.logger.Error("Sweep state failed", "height", height, "deleted", deleted, "err", err)
+		return
+	}
+
+	dbaccessor.WriteStatePrunedHeight(p.bc.db, height)
+	p.bc.logger.Info("State pruned", "height", height, "kept", len(marked), "deleted", deleted,
+		"mark", common.PrettyDuration(markElapse), "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// trackCommits starts or stops recording the roots committed by the blocks.
+func (p *statePruner) trackCommits(on bool) {
+	p.committedLock.Lock()
+	defer p.committedLock.Unlock()
+
+	if on {
+		p.committed = []common.Hash{}
+	} else {
+		p.committed = nil
+	}
+}
+
+// stateCommitted records the root committed during a pruning, so that the
+// sweep keeps its nodes. It's called with the lock held for reading.
+func (p *statePruner) stateCommitted(root common.Hash) {
+	p.committedLock.Lock()
+	defer p.committedLock.Unlock()
+
+	if p.committed != nil {
+		p.committed = append(p.committed, root)
+	}
+}
+
+// mark returns the trie nodes and codes of the states.
+func (p *statePruner) mark(roots []common.Hash) (map[common.Hash]struct{}, error) {
+	marked := make(map[common.Hash]struct{})
+	for _, root := range roots {
+		if _, err := p.bc.stateCache.TrieDB().Node(root); err != nil {
+			// state not executed or not synced
+			continue
+		}
+		if err := state.MarkReachable(p.bc.stateCache, root, marked); err != nil {
+			return nil, err
+		}
+	}
+	return marked, nil
+}
+
+// keptRoots returns the state roots of the blocks whose state is kept.
+func (p *statePruner) keptRoots(height uint64) []common.Hash {
+	bc := p.bc
+	roots := []common.Hash{bc.genesisBlock.Header.StateHash}
+
+	// check points
+	if checkPoint := bc.GetCheckPoint(); checkPoint != nil {
+		for index := uint64(0); index <= checkPoint.Height/CheckPointHeightSpacing; index++ {
+			checkPoint, err := bc.GetCheckPointByIndex(index)
+			if err != nil {
+				continue
+			}
+			if block := bc.GetBlock(checkPoint.FullHash); block != nil {
+				roots = append(roots, block.Header.StateHash)
+			}
+		}
+	}
+
+	// blocks of the main branch and of the forks from the round of the
+	// main branch block at the height
+	block, err := bc.GetMainBranchBlock(height)
+	if err != nil {
+		bc.logger.Error("Get main branch block for pruning failed", "height", height, "err", err)
+		return roots
+	}
+	roots = append(roots, block.Header.StateHash)
+	endRound := uint64(time.Now().UnixNano()/(1e9/params.RoundsPerSecond)) + pruneRoundMargin
+	for _, item := range dbaccessor.ReadHashListByRoundRange(bc.db, block.Header.Round, endRound) {
+		if block := bc.GetBlock(item.FullHash); block != nil {
+			roots = append(roots, block.Header.StateHash)
+		}
+	}
+	return roots
+}
+
+// sweep deletes the unmarked trie nodes and codes from the database. The
+// database is walked without the lock, which is only held to delete each
+// batch of keys.
+func (p *statePruner) sweep(marked map[common.Hash]struct{}) (int, error) {
+	it := p.db.NewIterator()
+	defer it.Release()
+
+	var (
+		deleted int
+		keys    = make([][]byte, 0, pruneBatchKeys)
+	)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != common.HashLength {
+			continue
+		}
+		if _, ok := marked[common.BytesToHash(key)]; ok {
+			continue
+		}
+		keys = append(keys, common.CopyBytes(key))
+
+		if len(keys) >= pruneBatchKeys {
+			n, err := p.deleteBatch(keys, marked)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+			keys = keys[:0]
+
+			select {
+			case <-p.quit:
+				return deleted, errPruneInterrupted
+			default:
+			}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return deleted, err
+	}
+	n, err := p.deleteBatch(keys, marked)
+	return deleted + n, err
+}
+
+// deleteBatch marks the states committed since the last batch, then deletes
+// the keys still unmarked.
+func (p *statePruner) deleteBatch(keys [][]byte, marked map[common.Hash]struct{}) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.committedLock.Lock()
+	roots := p.committed
+	if roots != nil {
+		p.committed = []common.Hash{}
+	}
+	p.committedLock.Unlock()
+	for _, root := range roots {
+		if err := state.MarkReachable(p.bc.stateCache, root, marked); err != nil {
+			return 0, err
+		}
+	}
+
+	var (
+		deleted int
+		batch   = p.bc.db.NewBatch()
+	)
+	for _, key := range keys {
+		if _, ok := marked[common.BytesToHash(key)]; ok {
+			continue
+		}
+		batch.Delete(key)
+		deleted++
+	}
+	return deleted, batch.Write()
+}
+
+// stateError turns the missing node error of a pruned state into ErrStatePruned.
+func (bc *BlockChain) stateError(err error) error {
+	if _, ok := err.(*trie.MissingNodeError); ok && dbaccessor.ReadStatePrunedHeight(bc.db) > 0 {
+		return ErrStatePruned
+	}
+	return err
+}
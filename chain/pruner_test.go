@@ -0,0 +1,164 @@
+package chain
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/utils/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestPruner() (*statePruner, *dbwrapper.LDBDatabase, func()) {
+	dir, err := ioutil.TempDir("", "pruner_test_")
+	if err != nil {
+		panic(err)
+	}
+	db, err := dbwrapper.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		panic(err)
+	}
+	bc := &BlockChain{
+		db:         db,
+		stateCache: state.NewDatabase(db),
+		logger:     log.NewSubLogger("m", "blockchain"),
+	}
+	return newStatePruner(bc, 16), db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func testAccount(i int) common.Address {
+	return common.BytesToAddress([]byte{0xaa, byte(i)})
+}
+
+// commitTestState sets the balances and a storage row of the accounts in the
+// state of the root, and writes the new state into the database.
+func commitTestState(p *statePruner, root common.Hash, from, to int, balance int64) common.Hash {
+	statedb, err := state.New(root, p.bc.stateCache)
+	if err != nil {
+		panic(err)
+	}
+	for i := from; i < to; i++ {
+		addr := testAccount(i)
+		statedb.SetBalance(addr, big.NewInt(balance))
+		statedb.SetState(addr, state.GetStorageKey(1, []byte{byte(i)}), big.NewInt(balance).Bytes())
+		if i%10 == 0 {
+			statedb.SetCode(addr, []byte{0x00, 0x61, 0x73, 0x6d, byte(i), byte(balance)})
+		}
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	root, err = statedb.Commit(true)
+	if err != nil {
+		panic(err)
+	}
+	if err := p.bc.stateCache.TrieDB().Commit(root, false); err != nil {
+		panic(err)
+	}
+	p.stateCommitted(root)
+	return root
+}
+
+// checkTestState checks that all the nodes of the state are in the database,
+// and the balances of the accounts.
+func checkTestState(db dbwrapper.Database, root common.Hash, from, to int, balance int64) {
+	stateCache := state.NewDatabase(db)
+	So(state.MarkReachable(stateCache, root, make(map[common.Hash]struct{})), ShouldBeNil)
+
+	statedb, err := state.New(root, stateCache)
+	So(err, ShouldBeNil)
+	for i := from; i < to; i++ {
+		addr := testAccount(i)
+		So(statedb.GetBalance(addr).Int64(), ShouldEqual, balance)
+		So(statedb.GetState(addr, state.GetStorageKey(1, []byte{byte(i)})), ShouldResemble, big.NewInt(balance).Bytes())
+		if i%10 == 0 {
+			So(statedb.GetCode(addr), ShouldNotBeEmpty)
+		}
+	}
+}
+
+func TestStatePruner(t *testing.T) {
+	Convey("prune the states not kept", t, func() {
+		p, db, remove := newTestPruner()
+		defer remove()
+
+		oldRoot := commitTestState(p, common.Hash{}, 0, 100, 1)
+		liveRoot := commitTestState(p, oldRoot, 0, 50, 2)
+
+		marked, err := p.mark([]common.Hash{liveRoot})
+		So(err, ShouldBeNil)
+		deleted, err := p.sweep(marked)
+		So(err, ShouldBeNil)
+		So(deleted, ShouldBeGreaterThan, 0)
+
+		checkTestState(db, liveRoot, 0, 50, 2)
+		checkTestState(db, liveRoot, 50, 100, 1)
+
+		// the nodes and codes only used by the old state are removed
+		has, _ := db.Has(oldRoot.Bytes())
+		So(has, ShouldBeFalse)
+		oldCode := crypto.Keccak256Hash([]byte{0x00, 0x61, 0x73, 0x6d, 0, 1})
+		has, _ = db.Has(oldCode.Bytes())
+		So(has, ShouldBeFalse)
+		for key := range marked {
+			has, _ := db.Has(key.Bytes())
+			So(has, ShouldBeTrue)
+		}
+
+		// sweeping again deletes nothing
+		deleted, err = p.sweep(marked)
+		So(err, ShouldBeNil)
+		So(deleted, ShouldEqual, 0)
+	})
+
+	Convey("keep the states committed during the pruning", t, func() {
+		p, db, remove := newTestPruner()
+		defer remove()
+
+		oldRoot := commitTestState(p, common.Hash{}, 0, 100, 1)
+		liveRoot := commitTestState(p, oldRoot, 0, 50, 2)
+
+		p.trackCommits(true)
+		defer p.trackCommits(false)
+		marked, err := p.mark([]common.Hash{liveRoot})
+		So(err, ShouldBeNil)
+
+		// a block inserted between the mark and the sweep
+		newRoot := commitTestState(p, liveRoot, 50, 150, 3)
+
+		_, err = p.sweep(marked)
+		So(err, ShouldBeNil)
+		checkTestState(db, liveRoot, 0, 50, 2)
+		checkTestState(db, newRoot, 50, 150, 3)
+		checkTestState(db, newRoot, 0, 50, 2)
+
+		has, _ := db.Has(oldRoot.Bytes())
+		So(has, ShouldBeFalse)
+	})
+
+	Convey("delete the states committed out of a pruning", t, func() {
+		p, db, remove := newTestPruner()
+		defer remove()
+
+		liveRoot := commitTestState(p, common.Hash{}, 0, 50, 1)
+		marked, err := p.mark([]common.Hash{liveRoot})
+		So(err, ShouldBeNil)
+
+		// not tracked, the sweep takes it for a stale state
+		staleRoot := commitTestState(p, liveRoot, 0, 50, 2)
+
+		_, err = p.sweep(marked)
+		So(err, ShouldBeNil)
+		checkTestState(db, liveRoot, 0, 50, 1)
+		has, _ := db.Has(staleRoot.Bytes())
+		So(has, ShouldBeFalse)
+	})
+}
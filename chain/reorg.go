@@ -0,0 +1,51 @@
+package chain
+
+import (
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/event"
+)
+
+// newReorgEvent collects the blocks leaving and joining the main branch when
+// the head switches from oldHead to newHead. It returns nil if newHead is a
+// descendant of oldHead, or the blocks can't be found.
+func (bc *BlockChain) newReorgEvent(oldHead *types.Block, newHead *types.Block) *types.ChainReorgEvent {
+	var dropped, added types.Blocks
+	oldBlock, newBlock := oldHead, newHead
+	for newBlock.Header.Height > oldBlock.Header.Height {
+		added = append(added, newBlock)
+		if newBlock = bc.GetBlock(newBlock.Header.ParentFullHash); newBlock == nil {
+			return nil
+		}
+	}
+	for oldBlock.Header.Height > newBlock.Header.Height {
+		dropped = append(dropped, oldBlock)
+		if oldBlock = bc.GetBlock(oldBlock.Header.ParentFullHash); oldBlock == nil {
+			return nil
+		}
+	}
+	for oldBlock.FullHash() != newBlock.FullHash() {
+		dropped = append(dropped, oldBlock)
+		added = append(added, newBlock)
+		oldBlock = bc.GetBlock(oldBlock.Header.ParentFullHash)
+		newBlock = bc.GetBlock(newBlock.Header.ParentFullHash)
+		if oldBlock == nil || newBlock == nil {
+			return nil
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	// order by height
+	for i, j := 0, len(dropped)-1; i < j; i, j = i+1, j-1 {
+		dropped[i], dropped[j] = dropped[j], dropped[i]
+	}
+	for i, j := 0, len(added)-1; i < j; i, j = i+1, j-1 {
+		added[i], added[j] = added[j], added[i]
+	}
+	return &types.ChainReorgEvent{CommonAncestor: oldBlock, Dropped: dropped, Added: added}
+}
+
+func (bc *BlockChain) SubscribeChainReorgEvent(ch chan<- types.ChainReorgEvent) event.Subscription {
+	return bc.chainReorgFeed.Subscribe(ch)
+}
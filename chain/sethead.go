@@ -0,0 +1,208 @@
+package chain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+var ErrSetHeadTooHigh = errors.New("set head above the main branch head")
+
+// SetHead rewinds the chain to the main branch block at the height. The
+// blocks mined after it are removed, so they are synced and executed again
+// when they are received from the peers.
+func (bc *BlockChain) SetHead(height uint64) error {
+	bc.mu.Lock()
+	head, dropped, err := RewindDatabase(bc.db, height)
+	if err != nil {
+		bc.mu.Unlock()
+		return err
+	}
+
+	bc.blockCache.Purge()
+	bc.currentBlock.Store(head)
+	bc.checkPointHandler.resetLastCheckPoint(dbaccessor.ReadLastCheckPoint(bc.db))
+	bc.mainBranchRecord.resetFinality()
+	bc.txInChainProcessor.rewind(height)
+
+	bc.futureBlocksMutex.Lock()
+	bc.futureBlocks = make(map[common.Hash]*types.Blocks)
+	bc.futureBlockDependDepth = make(map[common.Hash]int)
+	bc.futureBlocksMutex.Unlock()
+	bc.futureTxPackageBlocksMutex.Lock()
+	bc.futureTxPackageBlocks = make(map[common.Hash]*types.Blocks)
+	bc.futureTxPackageBlocksMutex.Unlock()
+	bc.futureBlockTxPackagesMutex.Lock()
+	bc.futureBlockTxPackages = make(map[common.Hash]*types.TxPackages)
+	bc.futureBlockTxPackagesMutex.Unlock()
+	bc.mu.Unlock()
+
+	bc.logger.Info("Set head", "height", height, "hash", head.FullHash(), "dropped", len(dropped))
+	if len(dropped) > 0 {
+		bc.chainReorgFeed.Send(types.ChainReorgEvent{CommonAncestor: head, Dropped: dropped})
+	}
+	bc.chainUpdateFeed.Send(types.ChainUpdateEvent{Block: head})
+	return nil
+}
+
+// RewindDatabase rewinds the chain in the database to the main branch block
+// at the height, and returns the block with the main branch blocks dropped
+// above it. The blocks of the rounds after the block are removed, along with
+// their main branch records, tx lookups, bloom sections and check points.
+func RewindDatabase(db dbwrapper.Database, height uint64) (*types.Block, types.Blocks, error) {
+	headHeight, _, err := dbaccessor.ReadMainBranchHeadHeightAndHash(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	if height > headHeight {
+		return nil, nil, ErrSetHeadTooHigh
+	}
+	head, err := readMainBranchBlock(db, height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dbaccessor.ReadBlockStateCheck(db, head.FullHash()) != types.BlockStateChecked {
+		return nil, nil, ErrBlockStateNotFound
+	}
+	if _, err := state.New(head.Header.StateHash, state.NewDatabase(db)); err != nil {
+		return nil, nil, ErrStatePruned
+	}
+
+	var dropped types.Blocks
+	for h := height + 1; h <= headHeight; h++ {
+		block, err := readMainBranchBlock(db, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		dropped = append(dropped, block)
+	}
+
+	batch := db.NewBatch()
+
+	// remove the blocks of the later rounds
+	endRound := uint64(time.Now().UnixNano()/(1e9/params.RoundsPerSecond)) + pruneRoundMargin
+	if len(dropped) > 0 && dropped[len(dropped)-1].Header.Round > endRound {
+		endRound = dropped[len(dropped)-1].Header.Round
+	}
+	removed := make(map[common.Hash]struct{})
+	for _, item := range dbaccessor.ReadHashListByRoundRange(db, head.Header.Round, endRound) {
+		removed[item.FullHash] = struct{}{}
+	}
+	roundSteps := make(map[uint64]struct{})
+	childs := make(map[common.Hash][]common.Hash)
+	for hash := range removed {
+		if header := dbaccessor.ReadBlockHeader(db, hash); header != nil {
+			roundSteps[header.Round-header.Round%dbaccessor.RoundStep] = struct{}{}
+			if _, ok := removed[header.ParentFullHash]; !ok {
+				if _, ok := childs[header.ParentFullHash]; !ok {
+					childs[header.ParentFullHash] = dbaccessor.ReadBlockChilds(db, header.ParentFullHash)
+				}
+				childs[header.ParentFullHash] = removeHash(childs[header.ParentFullHash], hash)
+			}
+		}
+		dbaccessor.DeleteBlock(batch, hash)
+		dbaccessor.DeleteBlockChilds(batch, hash)
+		dbaccessor.DeleteBlockStateCheck(batch, hash)
+		dbaccessor.DeleteReceipts(batch, hash)
+		dbaccessor.DeleteBloom(batch, hash)
+	}
+	for parent, hashes := range childs {
+		dbaccessor.WriteBlockChilds(batch, parent, hashes)
+	}
+	for round := range roundSteps {
+		var hashList types.BlockRoundHashes
+		for _, item := range dbaccessor.ReadHashListByRound(db, round) {
+			if _, ok := removed[item.FullHash]; !ok {
+				hashList = append(hashList, item)
+			}
+		}
+		if len(hashList) == 0 {
+			dbaccessor.DeleteHashList(batch, round)
+		} else {
+			dbaccessor.WriteHashList(batch, round, hashList)
+		}
+	}
+
+	// reset the main branch record and the tx lookups
+	for _, block := range dropped {
+		dbaccessor.DeleteHeightBlockMap(batch, block.Header.Height)
+
+		txs := block.Body.Transactions
+		for _, pkgHash := range block.Body.TxPackageHashes {
+			if pkg, _ := dbaccessor.ReadTxPkg(db, pkgHash); pkg != nil {
+				txs = append(txs, pkg.Transactions()...)
+			}
+		}
+		for _, tx := range txs {
+			if entry, err := dbaccessor.ReadTxLookupEntry(db, tx.Hash()); err == nil && entry.BlockFullHash == block.FullHash() {
+				dbaccessor.DeleteTxLookupEntry(batch, tx.Hash())
+			}
+		}
+	}
+	dbaccessor.WriteMainBranchHeadHeightAndHash(batch, height, head.FullHash())
+	if finalizedHeight, _, err := dbaccessor.ReadFinalizedBlockHeightAndHash(db); err == nil && finalizedHeight > height {
+		dbaccessor.WriteFinalizedBlockHeightAndHash(batch, height, head.FullHash())
+	}
+	if savedHeight, _, err := dbaccessor.ReadTxSavedBlockHeightAndHash(db); err == nil && savedHeight > height {
+		dbaccessor.WriteTxSavedBlockHeightAndHash(batch, height, head.FullHash())
+	}
+	dbaccessor.WriteHeadBlockHash(batch, head.FullHash())
+
+	// clear the bloom sections after the one of the head
+	for section := height/params.BloomBitsSize + 1; section <= headHeight/params.BloomBitsSize; section++ {
+		if dbaccessor.ReadBloomSectionSavedFlag(db, section) {
+			for i := 0; i < types.BloomBitLength; i++ {
+				dbaccessor.DeleteBloomBits(batch, uint(i), section)
+			}
+			dbaccessor.WriteBloomSectionSavedFlag(batch, section, false)
+		}
+	}
+	if reachHeight, err := dbaccessor.ReadBloomFastSyncReachHeight(db); err == nil && reachHeight > height {
+		dbaccessor.WriteBloomFastSyncReachHeight(batch, height)
+	}
+
+	// remove the check points above the head
+	if last := dbaccessor.ReadLastCheckPoint(db); last != nil && last.Height > height {
+		var newLast *types.CheckPoint
+		for index := last.Height/CheckPointHeightSpacing + 1; newLast == nil; index-- {
+			if checkPoint := dbaccessor.ReadCheckPointByIndex(db, index); checkPoint != nil {
+				if checkPoint.Height > height {
+					dbaccessor.DeleteCheckPointByIndex(batch, index)
+				} else {
+					newLast = checkPoint
+				}
+			}
+			if index == 0 {
+				break
+			}
+		}
+		if newLast == nil {
+			genesisHash := dbaccessor.ReadGenesisBlockHash(db)
+			newLast = &types.CheckPoint{TreePoint: &types.TreePoint{Height: 0, FullHash: genesisHash, MainChainHashList: []common.Hash{genesisHash}, HashPairs: []types.HashPairFullAcc{{genesisHash, common.Hash{}}}}}
+			dbaccessor.WriteCheckPointByIndex(batch, 0, newLast)
+		}
+		dbaccessor.WriteLastCheckPoint(batch, newLast)
+	}
+
+	if err := batch.Write(); err != nil {
+		return nil, nil, err
+	}
+	log.Info("Rewind database", "height", height, "hash", head.FullHash(), "removed", len(removed), "dropped", len(dropped))
+	return head, dropped, nil
+}
+
+func removeHash(hashes []common.Hash, hash common.Hash) []common.Hash {
+	for i, h := range hashes {
+		if h == hash {
+			return append(hashes[:i:i], hashes[i+1:]...)
+		}
+	}
+	return hashes
+}
@@ -0,0 +1,172 @@
+package chain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/params"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testChainWriter struct {
+	db     dbwrapper.Database
+	rounds map[uint64]types.BlockRoundHashes
+	childs map[common.Hash][]common.Hash
+}
+
+// write stores the block with its round index and child record.
+func (w *testChainWriter) write(block *types.Block) {
+	hash := block.FullHash()
+	dbaccessor.WriteBlock(w.db, block)
+	dbaccessor.WriteBlockStateCheck(w.db, hash, types.BlockStateChecked)
+
+	step := block.Header.Round - block.Header.Round%dbaccessor.RoundStep
+	w.rounds[step] = append(w.rounds[step], &types.BlockRoundHash{Round: block.Header.Round, SimpleHash: block.SimpleHash(), FullHash: hash})
+	dbaccessor.WriteHashList(w.db, step, w.rounds[step])
+
+	parent := block.Header.ParentFullHash
+	w.childs[parent] = append(w.childs[parent], hash)
+	dbaccessor.WriteBlockChilds(w.db, parent, w.childs[parent])
+}
+
+func newTestBlock(parent *types.Block, round uint64, txs ...*types.Transaction) *types.Block {
+	block := types.NewBlock(parent.SimpleHash(), round, []byte{byte(round)}, common.Address{}, big.NewInt(1), parent.Header.Height+1)
+	block.Header.ParentFullHash = parent.FullHash()
+	block.Body.Transactions = txs
+	return block
+}
+
+func TestRewindDatabase(t *testing.T) {
+	Convey("rewind the main branch", t, func() {
+		db := dbwrapper.NewMemDatabase()
+		w := &testChainWriter{db: db, rounds: make(map[uint64]types.BlockRoundHashes), childs: make(map[common.Hash][]common.Hash)}
+
+		// a main branch from height 4090 to 4100, crossing the bloom section
+		// boundary at 4096
+		const base = 4090
+		startRound := uint64(time.Now().UnixNano()/(1e9/params.RoundsPerSecond)) - 1000
+		root := types.NewBlock(common.Hash{}, startRound, nil, common.Address{}, big.NewInt(1), base-1)
+		keptTx := types.NewTransaction(0, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil, false)
+		droppedTx := types.NewTransaction(1, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil, false)
+
+		var main types.Blocks
+		parent := root
+		for i := uint64(0); i <= 10; i++ {
+			var txs []*types.Transaction
+			switch i {
+			case 2:
+				txs = []*types.Transaction{keptTx}
+			case 8:
+				txs = []*types.Transaction{droppedTx}
+			}
+			block := newTestBlock(parent, startRound+i*20, txs...)
+			w.write(block)
+			dbaccessor.WriteHeightBlockMap(db, block.Header.Height, block.FullHash())
+			if len(txs) > 0 {
+				dbaccessor.WriteTxLookupEntries(db, block.Header.Height, block.FullHash(), []*types.TxWithIndex{{Tx: txs[0], TxPackageIndex: types.NotInPackage}})
+			}
+			main = append(main, block)
+			parent = block
+		}
+		head := main[4]
+		dbaccessor.WriteMainBranchHeadHeightAndHash(db, main[10].Header.Height, main[10].FullHash())
+		dbaccessor.WriteHeadBlockHash(db, main[10].FullHash())
+		dbaccessor.WriteFinalizedBlockHeightAndHash(db, main[9].Header.Height, main[9].FullHash())
+
+		// a fork block before the new head, and one after it
+		keptFork := newTestBlock(main[2], head.Header.Round-5)
+		w.write(keptFork)
+		droppedFork := newTestBlock(main[6], main[7].Header.Round+5)
+		w.write(droppedFork)
+
+		// bloom sections 0 and 1
+		bits := []byte{0x01}
+		for section := uint64(0); section <= 1; section++ {
+			for i := 0; i < types.BloomBitLength; i++ {
+				dbaccessor.WriteBloomBits(db, uint(i), section, bits)
+			}
+			dbaccessor.WriteBloomSectionSavedFlag(db, section, true)
+		}
+
+		// check points below and above the new head
+		keptCheckPoint := &types.CheckPoint{TreePoint: &types.TreePoint{Height: 4000, FullHash: common.Hash{0x40}}}
+		droppedCheckPoint := &types.CheckPoint{TreePoint: &types.TreePoint{Height: main[9].Header.Height, FullHash: main[9].FullHash()}}
+		dbaccessor.WriteCheckPointByIndex(db, 40, keptCheckPoint)
+		dbaccessor.WriteCheckPointByIndex(db, 41, droppedCheckPoint)
+		dbaccessor.WriteLastCheckPoint(db, droppedCheckPoint)
+
+		newHead, dropped, err := RewindDatabase(db, head.Header.Height)
+		So(err, ShouldBeNil)
+		So(newHead.FullHash(), ShouldEqual, head.FullHash())
+		So(len(dropped), ShouldEqual, 6)
+		for i, block := range dropped {
+			So(block.FullHash(), ShouldEqual, main[5+i].FullHash())
+		}
+
+		// main branch record
+		height, hash, err := dbaccessor.ReadMainBranchHeadHeightAndHash(db)
+		So(err, ShouldBeNil)
+		So(height, ShouldEqual, head.Header.Height)
+		So(hash, ShouldEqual, head.FullHash())
+		So(dbaccessor.ReadHeadBlockHash(db), ShouldEqual, head.FullHash())
+		height, _, err = dbaccessor.ReadFinalizedBlockHeightAndHash(db)
+		So(err, ShouldBeNil)
+		So(height, ShouldEqual, head.Header.Height)
+		for _, block := range main[:5] {
+			hash, err := dbaccessor.ReadHeightBlockMap(db, block.Header.Height)
+			So(err, ShouldBeNil)
+			So(hash, ShouldEqual, block.FullHash())
+		}
+		for _, block := range dropped {
+			_, err := dbaccessor.ReadHeightBlockMap(db, block.Header.Height)
+			So(err, ShouldNotBeNil)
+		}
+
+		// blocks and their indexes
+		for _, block := range append(dropped, droppedFork) {
+			So(dbaccessor.ReadBlockHeader(db, block.FullHash()), ShouldBeNil)
+			So(dbaccessor.ReadBlockStateCheck(db, block.FullHash()), ShouldNotEqual, types.BlockStateChecked)
+		}
+		So(dbaccessor.ReadBlockHeader(db, keptFork.FullHash()), ShouldNotBeNil)
+		So(dbaccessor.ReadBlockChilds(db, head.FullHash()), ShouldBeEmpty)
+		So(dbaccessor.ReadBlockChilds(db, main[2].FullHash()), ShouldResemble, []common.Hash{main[3].FullHash(), keptFork.FullHash()})
+		var indexed []common.Hash
+		for _, item := range dbaccessor.ReadHashListByRoundRange(db, startRound, main[10].Header.Round+100) {
+			indexed = append(indexed, item.FullHash)
+		}
+		So(indexed, ShouldHaveLength, 5)
+		So(indexed, ShouldContain, keptFork.FullHash())
+		So(indexed, ShouldContain, head.FullHash())
+
+		// tx lookups
+		entry, err := dbaccessor.ReadTxLookupEntry(db, keptTx.Hash())
+		So(err, ShouldBeNil)
+		So(entry.BlockFullHash, ShouldEqual, main[2].FullHash())
+		_, err = dbaccessor.ReadTxLookupEntry(db, droppedTx.Hash())
+		So(err, ShouldNotBeNil)
+
+		// bloom sections
+		So(dbaccessor.ReadBloomSectionSavedFlag(db, 0), ShouldBeTrue)
+		So(dbaccessor.ReadBloomSectionSavedFlag(db, 1), ShouldBeFalse)
+		kept, err := dbaccessor.ReadBloomBits(db, 0, 0)
+		So(err, ShouldBeNil)
+		So(kept, ShouldResemble, bits)
+		_, err = dbaccessor.ReadBloomBits(db, 0, 1)
+		So(err, ShouldNotBeNil)
+
+		// check points
+		So(dbaccessor.ReadCheckPointByIndex(db, 41), ShouldBeNil)
+		So(dbaccessor.ReadCheckPointByIndex(db, 40), ShouldNotBeNil)
+		So(dbaccessor.ReadLastCheckPoint(db).Height, ShouldEqual, keptCheckPoint.Height)
+
+		Convey("and refuse a height above the head", func() {
+			_, _, err := RewindDatabase(db, main[10].Header.Height)
+			So(err, ShouldEqual, ErrSetHeadTooHigh)
+		})
+	})
+}
@@ -14,7 +14,11 @@ import (
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(stateHash common.Hash) (*state.StateDB, error) {
-	return state.New(stateHash, bc.stateCache)
+	stateDb, err := state.New(stateHash, bc.stateCache)
+	if err != nil {
+		return nil, bc.stateError(err)
+	}
+	return stateDb, nil
 }
 
 func (bc *BlockChain) GetStateBeforeCacheHeight(block *types.Block, cacheHeight uint8) (*state.StateDB, *types.Block, bool) {
@@ -153,6 +157,30 @@ func (bc *BlockChain) GetPrePackerNumber(headBlockWhenPacking *types.Block) (uin
 	return packerNumber, nil
 }
 
+// commitState writes the state of the block into the database. In full gc
+// mode the committed root is given to the pruner, so that a running sweep
+// doesn't delete its nodes.
+func (bc *BlockChain) commitState(block *types.Block, state *state.StateDB) error {
+	if bc.pruner != nil {
+		bc.pruner.lock.RLock()
+		defer bc.pruner.lock.RUnlock()
+	}
+
+	root, err := state.Commit(true)
+	if err != nil {
+		bc.logger.Error("Insert block failed(state commit error)", "height", block.Header.Height, "round", block.Header.Round, "hash", block.FullHash(), "err", err)
+		return err
+	}
+	if err = bc.stateCache.TrieDB().Commit(root, false); err != nil {
+		bc.logger.Error("Insert block failed(trieDB commit error)", "height", block.Header.Height, "round", block.Header.Round, "hash", block.FullHash(), "err", err)
+		return err
+	}
+	if bc.pruner != nil {
+		bc.pruner.stateCommitted(root)
+	}
+	return nil
+}
+
 //
 func (bc *BlockChain) insertBlockState(block *types.Block, state *state.StateDB, receipts types.Receipts, executedTxs []*types.TxWithIndex, bloom *types.Bloom) error {
 	// store executedTxs
@@ -165,13 +193,7 @@ func (bc *BlockChain) insertBlockState(block *types.Block, state *state.StateDB,
 	})
 
 	// store stateDB
-	root, err := state.Commit(true)
-	if err != nil {
-		bc.logger.Error("Insert block failed(state commit error)", "height", block.Header.Height, "round", block.Header.Round, "hash", block.FullHash(), "err", err)
-		return err
-	}
-	if err = bc.stateCache.TrieDB().Commit(root, false); err != nil {
-		bc.logger.Error("Insert block failed(trieDB commit error)", "height", block.Header.Height, "round", block.Header.Round, "hash", block.FullHash(), "err", err)
+	if err := bc.commitState(block, state); err != nil {
 		return err
 	}
 
@@ -199,6 +221,10 @@ func (bc *BlockChain) insertBlockState(block *types.Block, state *state.StateDB,
 
 	bc.blockExecutedFeed.Send(types.BlockExecutedEvent{Block: block})
 
+	if bc.pruner != nil {
+		bc.pruner.maybePrune()
+	}
+
 	if !metrics.UseNilMetrics {
 		blockInsertTimer.UpdateSince(block.ReceivedAt)
 		transactionInsertMeter.Mark(int64(len(block.Body.Transactions)))
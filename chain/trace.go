@@ -0,0 +1,56 @@
+package chain
+
+import (
+	"math"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/core/wasm"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/transaction/txexec"
+)
+
+// ContractAbi returns the abi json of the contract set in the abi registry in
+// the state, or else the one registered on the node, or nil if neither is set.
+func (bc *BlockChain) ContractAbi(stateDb *state.StateDB, contract common.Address) []byte {
+	if abiDef := txexec.ReadContractAbi(stateDb, contract); len(abiDef) > 0 {
+		return abiDef
+	}
+	return dbaccessor.ReadContractAbi(bc.db, contract)
+}
+
+// TraceBlock re-executes the transactions of the block on the state of its
+// parent, the same way as execBlock, and records their contract calls, with
+// the action args decoded by the abis of the contracts. The receipts of the executed transactions are returned along with the tracer.
+func (bc *BlockChain) TraceBlock(block *types.Block) (*wasm.CallTracer, types.Receipts, error) {
+	parentBlock := bc.GetBlock(block.Header.ParentFullHash)
+	if parentBlock == nil {
+		return nil, nil, ErrCannotFindParentBlock
+	}
+	stateDb, err := bc.StateAt(parentBlock.Header.StateHash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		executedTxs []*types.TxWithIndex
+		allLogs     []*types.Log
+		receipts    types.Receipts
+		usedGas     = new(uint64)
+		gasPool     = new(types.GasPool).AddGas(math.MaxUint64)
+		txpkgs      = bc.GetTxPackageList(block.Body.TxPackageHashes)
+		tracer      = wasm.NewCallTracer()
+	)
+	tracer.SetAbiReader(func(contract common.Address) []byte { return bc.ContractAbi(stateDb, contract) })
+
+	prevStateDb, _, _ := bc.GetStateBeforeCacheHeight(parentBlock, uint8(params.ConfirmHeightDistance-1))
+	callbackParamKey := wasm.GetGlobalRegisterParam().RegisterParam(stateDb, block)
+	wasm.GetGlobalRegisterParam().SetTracer(callbackParamKey, tracer)
+	executedTxs, allLogs, receipts, _ = bc.txExecutor.ExecuteTxPackages(txpkgs, prevStateDb, stateDb, receipts, block, executedTxs, usedGas, allLogs, gasPool, callbackParamKey)
+	_, _, receipts, _ = bc.txExecutor.ExecuteTransactions(block.Body.Transactions, prevStateDb, stateDb, receipts, block, types.NotInPackage, executedTxs, usedGas, allLogs, gasPool, callbackParamKey)
+	wasm.GetGlobalRegisterParam().UnRegisterParam(callbackParamKey)
+
+	return tracer, receipts, nil
+}
@@ -137,6 +137,21 @@ func (p *TxInChainProcessor) AddBlock(b *blockWithExecutedTx) {
 	p.heapMu.Unlock()
 }
 
+// rewind drops the blocks above the height from the heap.
+func (p *TxInChainProcessor) rewind(height uint64) {
+	p.heapMu.Lock()
+	defer p.heapMu.Unlock()
+
+	blocks := make(blockWithExecutedTxHeap, 0, len(p.blockHeap))
+	for _, b := range p.blockHeap {
+		if b.block.Header.Height <= height {
+			blocks = append(blocks, b)
+		}
+	}
+	heap.Init(&blocks)
+	p.blockHeap = blocks
+}
+
 func (p *TxInChainProcessor) SearchTransactionInHeap(txHash common.Hash) (*types.Transaction, common.Hash) {
 	p.heapMu.RLock()
 	defer p.heapMu.RUnlock()
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fractal-platform/fractal/chain"
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/transaction/txexec"
+	"github.com/fractal-platform/fractal/utils/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	importCommand = cli.Command{
+		Action:    importChain,
+		Name:      "import",
+		Usage:     "Import a blockchain file",
+		ArgsUsage: "<filename>",
+		Description: `
+The import command replays the blocks and packages of a file written by
+'gtool chain export' into the database of the node. Blocks already in the
+database are skipped, so an interrupted import can be resumed by running
+the same command again.`,
+	}
+)
+
+func importChain(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return errors.New("import file not specified")
+	}
+	fileName := ctx.Args().First()
+
+	cfg := makeConfigNode(ctx)
+	if cfg.NodeConfig.DataDir == "" {
+		return errors.New("import needs a data directory")
+	}
+
+	db, err := dbwrapper.NewLDBDatabase(cfg.NodeConfig.ResolvePath("chaindata"), cfg.DatabaseCache, cfg.DatabaseHandles)
+	if err != nil {
+		log.Error("create leveldb failed", "error", err.Error())
+		return err
+	}
+	defer db.Close()
+
+	cfg.ChainConfig, err = config.SetupChainConfig(db, cfg.ChainConfig)
+	if err != nil {
+		log.Error("setup chain config failed", "error", err.Error())
+		return err
+	}
+	if err = txexec.CheckChainExecutor(cfg.ChainConfig.TxExecutorType); err != nil {
+		return err
+	}
+	if _, err = config.SetupGenesisBlock(db, cfg.Genesis); err != nil {
+		log.Error("setup genesis block failed", "error", err.Error())
+		return err
+	}
+
+	signer := types.MakeSigner(cfg.ChainConfig.TxSignerType, cfg.ChainConfig.ChainID)
+	executor := txexec.NewExecutor(cfg.ChainConfig.TxExecutorType, cfg.ChainConfig.MaxNonceBitLength, signer, cfg.ChainConfig.Rewards(), cfg.ChainConfig.Forks())
+	blockchain, err := chain.NewBlockChain(cfg, db, executor, cfg.PackerInfoCacheSize, types.NormalNode)
+	if err != nil {
+		log.Error("create blockchain failed", "error", err.Error())
+		return err
+	}
+	defer blockchain.StopRecord()
+
+	fh, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	// stop between two blocks on interrupt, the import can be resumed later
+	abort := make(chan struct{})
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	go func() {
+		if _, ok := <-sigc; ok {
+			fmt.Printf("Got interrupt, stopping import...\n")
+			close(abort)
+		}
+	}()
+
+	count, err := blockchain.ImportChain(bufio.NewReader(fh), abort)
+	if err != nil {
+		log.Error("import chain failed", "file", fileName, "imported", count, "error", err)
+		return err
+	}
+	log.Info("Import done", "file", fileName, "blocks", count, "head", blockchain.CurrentBlock().Header.Height)
+	return nil
+}
@@ -110,6 +110,35 @@ func makeConfigNode(ctx *cli.Context) *config.Config {
 
 	cfg.DatabaseHandles = makeDatabaseHandles()
 
+	// state pruning
+	if ctx.GlobalIsSet(gcModeFlag.Name) {
+		cfg.GCMode = ctx.GlobalString(gcModeFlag.Name)
+	}
+	if cfg.GCMode != config.GCModeFull && cfg.GCMode != config.GCModeArchive {
+		utils.Fatalf("--%s must be either 'full' or 'archive'", gcModeFlag.Name)
+	}
+	if ctx.GlobalIsSet(stateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.GlobalUint64(stateHistoryFlag.Name)
+	}
+	if cfg.StateHistory < config.MinStateHistory {
+		utils.Fatalf("--%s must be at least %d", stateHistoryFlag.Name, config.MinStateHistory)
+	}
+	if ctx.GlobalIsSet(gpoBlocksFlag.Name) {
+		cfg.GPOBlocks = ctx.GlobalInt(gpoBlocksFlag.Name)
+	}
+	if cfg.GPOBlocks < 1 {
+		utils.Fatalf("--%s must be at least 1", gpoBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(gpoPercentileFlag.Name) {
+		cfg.GPOPercentile = ctx.GlobalInt(gpoPercentileFlag.Name)
+	}
+	if cfg.GPOPercentile < 0 || cfg.GPOPercentile > 100 {
+		utils.Fatalf("--%s must be between 0 and 100", gpoPercentileFlag.Name)
+	}
+	if ctx.GlobalBool(txHistoryFlag.Name) {
+		cfg.TxHistory = true
+	}
+
 	// whether test fastSync network
 	if ctx.GlobalBool(syncTestFlag.Name) {
 		cfg.SyncTest = true
@@ -136,6 +165,9 @@ func makeConfigNode(ctx *cli.Context) *config.Config {
 	cfg.MinerKeyFolder = cfg.NodeConfig.ResolvePath("keys/mining_keys/")
 	cfg.PackerKeyFolder = cfg.NodeConfig.ResolvePath("keys/packer_keys/")
 	cfg.CheckPointPriKeyPass = ctx.GlobalString(unlockCheckPointPriKeyFlag.Name)
+	if ctx.GlobalIsSet(checkPointRPCFlag.Name) {
+		cfg.CheckPointRPCs = splitAndTrim(ctx.GlobalString(checkPointRPCFlag.Name))
+	}
 	return cfg
 }
 
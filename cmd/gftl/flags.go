@@ -49,12 +49,41 @@ var (
 		Name:  "synctest",
 		Usage: "test fastsync pre-configured test fastsync",
 	}
+	gcModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `State garbage collection mode ("full", "archive")`,
+		Value: config.GCModeArchive,
+	}
+	stateHistoryFlag = cli.Uint64Flag{
+		Name:  "statehistory",
+		Usage: "Number of recent heights of state kept in full gc mode",
+		Value: config.DefaultStateHistory,
+	}
+	gpoBlocksFlag = cli.IntFlag{
+		Name:  "gpoblocks",
+		Usage: "Number of recent main branch blocks sampled to suggest the gas price",
+		Value: config.DefaultGPOBlocks,
+	}
+	gpoPercentileFlag = cli.IntFlag{
+		Name:  "gpopercentile",
+		Usage: "Suggested gas price is the given percentile of the sampled gas prices",
+		Value: config.DefaultGPOPercentile,
+	}
+	txHistoryFlag = cli.BoolFlag{
+		Name:  "txhistory",
+		Usage: "Index the transactions touching each address",
+	}
 	generalFlags = []cli.Flag{
 		dataDirFlag,
 		testnetFlag,
 		testnet2Flag,
 		testnet3Flag,
 		syncTestFlag,
+		gcModeFlag,
+		stateHistoryFlag,
+		gpoBlocksFlag,
+		gpoPercentileFlag,
+		txHistoryFlag,
 	}
 
 	// Miner settings
@@ -86,6 +115,11 @@ var (
 		Usage: "The password to use for unlock the check point signer's private key",
 		Value: "",
 	}
+	checkPointRPCFlag = cli.StringFlag{
+		Name:  "checkpointrpc",
+		Usage: "Comma separated rpc addresses of the check point authorities",
+		Value: "",
+	}
 
 	// RPC settings
 	rpcEnabledFlag = cli.BoolFlag{
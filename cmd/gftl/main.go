@@ -45,13 +45,15 @@ VERSION:
 	app.Action = gftl
 	app.HideVersion = true // we have a command to print the version
 	app.Copyright = "Copyright 2013-2019 The go-fractal Authors"
-	app.Commands = []cli.Command{}
+	app.Commands = []cli.Command{
+		importCommand,
+	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
 	app.Flags = append(app.Flags, configFileFlag, genesisAllocFlag, checkPointsFlag)
 	app.Flags = append(app.Flags, generalFlags...)
 	app.Flags = append(app.Flags, miningEnabledFlag, packEnabledFlag, packerIdFlag, unlockedAccountFlag)
-	app.Flags = append(app.Flags, unlockCheckPointPriKeyFlag)
+	app.Flags = append(app.Flags, unlockCheckPointPriKeyFlag, checkPointRPCFlag)
 	app.Flags = append(app.Flags, rpcFlags...)
 	app.Flags = append(app.Flags, networkFlags...)
 	app.Flags = append(app.Flags, metricsFlags...)
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/fractal-platform/fractal/utils/abi/bind"
+	"github.com/fractal-platform/fractal/utils/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	abigenCommand = cli.Command{
+		Name:   "abigen",
+		Usage:  "Generate the Go bindings of a contract from its abi",
+		Action: abigen,
+		Flags: []cli.Flag{
+			AbiFlag,
+			PkgFlag,
+			TypeFlag,
+			OutFlag,
+		},
+	}
+)
+
+func abigen(ctx *cli.Context) error {
+	initLogger(ctx)
+
+	abiFile := ctx.String(AbiFlag.Name)
+	pkg := ctx.String(PkgFlag.Name)
+	if abiFile == "" || pkg == "" {
+		return errors.New("abi file and package name must be set")
+	}
+	abidef, err := ioutil.ReadFile(abiFile)
+	if err != nil {
+		log.Error("read abi file failed", "file", abiFile, "err", err)
+		return err
+	}
+
+	code, err := bind.Bind(string(abidef), pkg, ctx.String(TypeFlag.Name))
+	if err != nil {
+		log.Error("generate bindings failed", "err", err)
+		return err
+	}
+
+	out := ctx.String(OutFlag.Name)
+	if out == "" {
+		fmt.Print(string(code))
+		return nil
+	}
+	if err := ioutil.WriteFile(out, code, 0644); err != nil {
+		log.Error("write bindings failed", "file", out, "err", err)
+		return err
+	}
+	log.Info("bindings generated", "file", out)
+	return nil
+}
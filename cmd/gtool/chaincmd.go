@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+
+	"github.com/fractal-platform/fractal/chain"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/utils/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	chainCommand = cli.Command{
+		Name:  "chain",
+		Usage: "Export Fractal Chain",
+		Flags: []cli.Flag{
+			DbPathFlag,
+			ExportFromFlag,
+			ExportToFlag,
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:      "export",
+				Usage:     "export blocks and packages to a rlp file",
+				ArgsUsage: "<filename>",
+				Action:    exportChain,
+				Flags: []cli.Flag{
+					DbPathFlag,
+					ExportFromFlag,
+					ExportToFlag,
+				},
+			},
+		},
+	}
+)
+
+func exportChain(ctx *cli.Context) error {
+	initLogger(ctx)
+
+	if len(ctx.Args()) != 1 {
+		return errors.New("export file not specified")
+	}
+	fileName := ctx.Args().First()
+	dbPath := ctx.GlobalString(DbPathFlag.Name)
+
+	db, err := dbwrapper.NewLDBDatabase(dbPath, 768, 2048)
+	if err != nil {
+		log.Error("init level db failed", "dbpath", dbPath, "err", err)
+		return err
+	}
+	defer db.Close()
+
+	first := ctx.GlobalUint64(ExportFromFlag.Name)
+	last := ctx.GlobalUint64(ExportToFlag.Name)
+	if !ctx.GlobalIsSet(ExportToFlag.Name) {
+		last, _, err = dbaccessor.ReadMainBranchHeadHeightAndHash(db)
+		if err != nil {
+			log.Error("read main branch head failed", "dbpath", dbPath, "err", err)
+			return err
+		}
+	}
+
+	fh, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	writer := bufio.NewWriter(fh)
+
+	count, err := chain.ExportChain(db, writer, first, last)
+	if err != nil {
+		log.Error("export chain failed", "from", first, "to", last, "exported", count, "err", err)
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	log.Info("Export Ok", "from", first, "to", last, "blocks", count, "file", fileName)
+	return nil
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+
+	"github.com/fractal-platform/fractal/chain"
 	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/core/dbaccessor"
 	"github.com/fractal-platform/fractal/core/state"
@@ -18,6 +21,7 @@ var (
 			DbPathFlag,
 			OutPutPathFlag,
 			Keccak256HashFlag,
+			BlockHeightFlag,
 		},
 		Subcommands: []cli.Command{
 			{
@@ -39,6 +43,15 @@ var (
 					Keccak256HashFlag,
 				},
 			},
+			{
+				Name:   "rewind",
+				Usage:  "rewind the chain head to a main branch height",
+				Action: rewindChain,
+				Flags: []cli.Flag{
+					DbPathFlag,
+					BlockHeightFlag,
+				},
+			},
 		},
 	}
 )
@@ -116,3 +129,28 @@ func getSize(ctx *cli.Context) error {
 	log.Info("The hash does not match any blocks, transactions or packages.")
 	return nil
 }
+
+func rewindChain(ctx *cli.Context) error {
+	initLogger(ctx)
+
+	dbPath := ctx.GlobalString(DbPathFlag.Name)
+	if !ctx.GlobalIsSet(BlockHeightFlag.Name) {
+		return errors.New("rewind height not specified")
+	}
+	height := ctx.GlobalUint64(BlockHeightFlag.Name)
+
+	db, err := dbwrapper.NewLDBDatabase(dbPath, 768, 2048)
+	if err != nil {
+		log.Error("init level db failed", "dbpath", dbPath, "err", err)
+		return err
+	}
+	defer db.Close()
+
+	head, dropped, err := chain.RewindDatabase(db, height)
+	if err != nil {
+		log.Error("rewind chain failed", "dbpath", dbPath, "height", height, "err", err)
+		return err
+	}
+	log.Info("Rewind Ok", "height", height, "hash", head.FullHash(), "dropped", len(dropped))
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/keys"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/rlp"
+	"github.com/fractal-platform/fractal/rpc/client"
+	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	evidenceCommand = cli.Command{
+		Name:  "evidence",
+		Usage: "Miner Equivocation Evidence",
+		Flags: []cli.Flag{
+			RpcFlag,
+			EvidenceHashFlag,
+			OutPutPathFlag,
+			ChainIdFlag,
+			KeyFolderFlag,
+			PasswordFlag,
+			GasFlag,
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:   "export",
+				Usage:  "Export the evidences found by the node",
+				Action: exportEvidence,
+				Flags: []cli.Flag{
+					RpcFlag,
+					EvidenceHashFlag,
+					OutPutPathFlag,
+				},
+			},
+			{
+				Name:   "submit",
+				Usage:  "Submit an evidence to deregister the mining key",
+				Action: submitEvidence,
+				Flags: []cli.Flag{
+					RpcFlag,
+					EvidenceHashFlag,
+					ChainIdFlag,
+					KeyFolderFlag,
+					PasswordFlag,
+					GasFlag,
+				},
+			},
+		},
+	}
+)
+
+func exportEvidence(ctx *cli.Context) error {
+	initLogger(ctx)
+
+	rpc := ctx.GlobalString(RpcFlag.Name)
+	client, err := rpcclient.Dial(rpc)
+	if err != nil {
+		log.Error("connect to rpc error", "rpc", rpc)
+		return err
+	}
+
+	var result interface{}
+	if ctx.GlobalIsSet(EvidenceHashFlag.Name) {
+		var evidence *types.Evidence
+		err = client.Call(&evidence, "ftl_getEvidence", common.HexToHash(ctx.GlobalString(EvidenceHashFlag.Name)))
+		if err == nil && evidence == nil {
+			err = errors.New("evidence not found")
+		}
+		result = evidence
+	} else {
+		var evidences types.Evidences
+		err = client.Call(&evidences, "ftl_getEvidences")
+		result = evidences
+	}
+	if err != nil {
+		log.Error("get evidence error", "err", err)
+		return err
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if output := ctx.GlobalString(OutPutPathFlag.Name); output != "" {
+		return ioutil.WriteFile(output, data, 0644)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func submitEvidence(ctx *cli.Context) error {
+	initLogger(ctx)
+
+	rpc := ctx.GlobalString(RpcFlag.Name)
+	if !ctx.GlobalIsSet(EvidenceHashFlag.Name) {
+		return errors.New("evidence hash must be set")
+	}
+
+	//signer
+	chainid := ctx.GlobalInt(ChainIdFlag.Name)
+	signer := types.NewEIP155Signer(uint64(chainid))
+
+	//key
+	folder := ctx.GlobalString(KeyFolderFlag.Name)
+	password := ctx.GlobalString(PasswordFlag.Name)
+	accountKeyFile := path.Join(folder, "account.json")
+	accountKey, err := keys.LoadAccountKey(accountKeyFile, password)
+	if err != nil {
+		log.Error("load account key error", "err", err)
+		return err
+	}
+
+	client, err := rpcclient.Dial(rpc)
+	if err != nil {
+		log.Error("connect to rpc error", "rpc", rpc)
+		return err
+	}
+
+	var evidence *types.Evidence
+	err = client.Call(&evidence, "ftl_getEvidence", common.HexToHash(ctx.GlobalString(EvidenceHashFlag.Name)))
+	if err != nil {
+		log.Error("get evidence error", "err", err)
+		return err
+	}
+	if evidence == nil {
+		return errors.New("evidence not found")
+	}
+
+	var hexNonce hexutil.Uint64
+	err = client.Call(&hexNonce, "txpool_getTransactionNonce", accountKey.Address)
+	if err != nil {
+		log.Error("get tx nonce error", "err", err)
+		return err
+	}
+	nonce := (uint64)(hexNonce)
+	log.Info("get nonce ok", "nonce", nonce)
+
+	// generate action
+	evidenceBytes, err := rlp.EncodeToBytes(evidence)
+	if err != nil {
+		log.Error("encode evidence error", "err", err)
+		return err
+	}
+	actionSlice := make([]byte, 8, 8+len(evidenceBytes))
+	actionName, _ := utils.String2Uint64(params.MinerKeyContractEvidenceAction)
+	binary.LittleEndian.PutUint64(actionSlice[:8], actionName)
+	actionSlice = append(actionSlice, evidenceBytes...)
+
+	// sign tx
+	to := common.HexToAddress(params.MinerKeyContractAddr)
+	gas, err := gasLimit(ctx, client, accountKey.Address, &to, 0, actionSlice)
+	if err != nil {
+		return err
+	}
+	tx := types.NewTransaction(nonce, to, big.NewInt(0), gas, common.Big1, actionSlice, true)
+	tx, err = types.SignTx(tx, signer, accountKey.PrivKey)
+	if err != nil {
+		log.Error("sign tx error", "err", err)
+		return err
+	}
+
+	err = sendTxToRpc(tx, client)
+	if err != nil {
+		return err
+	}
+
+	err = retrieveRspFromRpc(tx, client)
+	return err
+}
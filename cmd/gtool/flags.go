@@ -65,6 +65,14 @@ var (
 		Usage: "transfer value",
 		Value: 1,
 	}
+	GasFlag = cli.Uint64Flag{
+		Name:  "gas",
+		Usage: "gas limit, estimated by the node if not given",
+	}
+	GasPriceFlag = cli.Uint64Flag{
+		Name:  "gasprice",
+		Usage: "gas price, suggested by the node if not given",
+	}
 
 	// for batch tx
 	TpsFlag = cli.IntFlag{
@@ -83,7 +91,21 @@ var (
 	}
 	AbiFlag = cli.StringFlag{
 		Name:  "abi",
-		Usage: "abi file path",
+		Usage: "abi file path (default: the abi set in the abi registry)",
+	}
+
+	// for abigen
+	PkgFlag = cli.StringFlag{
+		Name:  "pkg",
+		Usage: "package name of the generated bindings",
+	}
+	TypeFlag = cli.StringFlag{
+		Name:  "type",
+		Usage: "type name of the contract binding (default: the package name)",
+	}
+	OutFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "output file of the generated bindings (default: stdout)",
 	}
 	ActionFlag = cli.StringFlag{
 		Name:  "action",
@@ -132,6 +154,12 @@ var (
 		Usage: "packer public key (ECDSA)",
 	}
 
+	// for evidence
+	EvidenceHashFlag = cli.StringFlag{
+		Name:  "evidence",
+		Usage: "evidence hash",
+	}
+
 	// for database
 	StateRootHashFlag = cli.StringFlag{
 		Name:  "rootHash",
@@ -149,4 +177,15 @@ var (
 		Name:  "k256Hash",
 		Usage: "Keccak256 hash result",
 	}
+
+	// for chain
+	ExportFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "the first main branch height to export",
+		Value: 1,
+	}
+	ExportToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "the last main branch height to export (default: main branch head)",
+	}
 )
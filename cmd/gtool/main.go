@@ -61,6 +61,9 @@ OPTIONS:
 		blockCommand,
 		packerCommand,
 		dbCommand,
+		chainCommand,
+		evidenceCommand,
+		abigenCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
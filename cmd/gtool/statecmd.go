@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	"io/ioutil"
 
 	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/common/hexutil"
@@ -113,17 +112,24 @@ func queryStorage(ctx *cli.Context) error {
 	skey := ctx.GlobalString(StorageKeyFlag.Name)
 	log.Info("skey data", "skey", skey)
 
-	abiFile := ctx.GlobalString(AbiFlag.Name)
-	abidef, _ := ioutil.ReadFile(abiFile)
+	client, err := rpcclient.Dial(rpc)
+	if err != nil {
+		log.Error("connect to rpc error", "rpc", rpc)
+		return err
+	}
+	abidef, err := loadAbi(ctx, client, addr)
+	if err != nil {
+		return err
+	}
 
 	var skeyData interface{}
-	err := json.Unmarshal([]byte(skey), &skeyData)
+	err = json.Unmarshal([]byte(skey), &skeyData)
 	if err != nil {
 		log.Error("unmarshal skeys failed", "err", err)
 	}
 
 	writer := bytes.NewBuffer([]byte{})
-	serializer, err := abi.NewAbiSerializer(string(abidef))
+	serializer, err := abi.NewAbiSerializer(abidef)
 	if err != nil {
 		log.Error("queryStorage NewAbiSerializer failed", "err", err)
 		return err
@@ -136,12 +142,6 @@ func queryStorage(ctx *cli.Context) error {
 	}
 	skeyHex := hexutil.Encode(writer.Bytes())
 
-	client, err := rpcclient.Dial(rpc)
-	if err != nil {
-		log.Error("connect to rpc error", "rpc", rpc)
-		return err
-	}
-
 	var blockHash common.Hash
 	if bhashString == "" {
 		var head types.Block
@@ -164,5 +164,14 @@ func queryStorage(ctx *cli.Context) error {
 	}
 	log.Info("get storage ok", "addr", addr, "table", table, "skey", skeyHex, "value", hexutil.Encode(value))
 
+	if len(value) > 0 {
+		row, err := serializer.Deserialize(serializer.GetTableValueType(table), value)
+		if err != nil {
+			log.Error("can not deserialize value", "err", err)
+			return err
+		}
+		log.Info("decode storage ok", "row", string(row))
+	}
+
 	return nil
 }
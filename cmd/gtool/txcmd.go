@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -17,8 +18,10 @@ import (
 	"github.com/fractal-platform/fractal/crypto"
 	"github.com/fractal-platform/fractal/ftl/api"
 	"github.com/fractal-platform/fractal/keys"
+	"github.com/fractal-platform/fractal/params"
 	"github.com/fractal-platform/fractal/rlp"
 	"github.com/fractal-platform/fractal/rpc/client"
+	"github.com/fractal-platform/fractal/transaction/txexec"
 	"github.com/fractal-platform/fractal/utils"
 	"github.com/fractal-platform/fractal/utils/abi"
 	"github.com/fractal-platform/fractal/utils/log"
@@ -34,6 +37,8 @@ var (
 			PackerFlag,
 			ToFlag,
 			ValueFlag,
+			GasFlag,
+			GasPriceFlag,
 			TpsFlag,
 			NProcessFlag,
 			ChainIdFlag,
@@ -54,6 +59,8 @@ var (
 					PackerFlag,
 					ToFlag,
 					ValueFlag,
+					GasFlag,
+					GasPriceFlag,
 					ChainIdFlag,
 					KeyFolderFlag,
 					PasswordFlag,
@@ -82,11 +89,30 @@ var (
 				Flags: []cli.Flag{
 					RpcFlag,
 					ValueFlag,
+					GasFlag,
+					GasPriceFlag,
 					PackerFlag,
 					ChainIdFlag,
 					KeyFolderFlag,
 					PasswordFlag,
 					WasmFlag,
+					AbiFlag,
+				},
+			},
+			{
+				Name:   "setabi",
+				Usage:  "Set the abi of a contract in the abi registry",
+				Action: setAbi,
+				Flags: []cli.Flag{
+					RpcFlag,
+					GasFlag,
+					GasPriceFlag,
+					PackerFlag,
+					ChainIdFlag,
+					KeyFolderFlag,
+					PasswordFlag,
+					ToFlag,
+					AbiFlag,
 				},
 			},
 			{
@@ -101,6 +127,8 @@ var (
 					PasswordFlag,
 					ToFlag,
 					ValueFlag,
+					GasFlag,
+					GasPriceFlag,
 					AbiFlag,
 					ActionFlag,
 					ArgsFlag,
@@ -147,6 +175,98 @@ func retrieveRspFromRpc(tx *types.Transaction, client *rpcclient.Client) error {
 	return nil
 }
 
+// gasLimit returns the gas limit given on the command line, or the one
+// estimated by the node if it is not given.
+func gasLimit(ctx *cli.Context, client *rpcclient.Client, from common.Address, to *common.Address, value int64, data []byte) (uint64, error) {
+	if gas := ctx.GlobalUint64(GasFlag.Name); gas > 0 {
+		return gas, nil
+	}
+
+	var gas hexutil.Uint64
+	args := api.SendTxArgs{
+		From:     from,
+		To:       to,
+		GasPrice: (*hexutil.Big)(common.Big1),
+		Value:    (*hexutil.Big)(big.NewInt(value)),
+		Data:     (*hexutil.Bytes)(&data),
+	}
+	if err := client.Call(&gas, "ftl_estimateGas", args); err != nil {
+		log.Error("estimate gas error", "err", err)
+		return 0, err
+	}
+	log.Info("estimate gas ok", "gas", uint64(gas))
+	return uint64(gas), nil
+}
+
+// gasPrice returns the gas price given on the command line, or the one
+// suggested by the node if it is not given.
+func gasPrice(ctx *cli.Context, client *rpcclient.Client) (*big.Int, error) {
+	if price := ctx.GlobalUint64(GasPriceFlag.Name); price > 0 {
+		return new(big.Int).SetUint64(price), nil
+	}
+
+	var price hexutil.Big
+	if err := client.Call(&price, "ftl_gasPrice"); err != nil {
+		log.Error("get gas price error", "err", err)
+		return nil, err
+	}
+	log.Info("get gas price ok", "price", (*big.Int)(&price))
+	return (*big.Int)(&price), nil
+}
+
+// loadAbi returns the abi in the file given on the command line, or the abi of
+// the contract set in the abi registry if no file is given.
+func loadAbi(ctx *cli.Context, client *rpcclient.Client, contract common.Address) (string, error) {
+	if abiFile := ctx.GlobalString(AbiFlag.Name); abiFile != "" {
+		abidef, err := ioutil.ReadFile(abiFile)
+		if err != nil {
+			log.Error("read abi file failed", "err", err)
+			return "", err
+		}
+		return string(abidef), nil
+	}
+
+	var abidef json.RawMessage
+	if err := client.Call(&abidef, "ftl_getAbi", contract, "latest"); err != nil {
+		log.Error("get abi error", "err", err)
+		return "", err
+	}
+	if len(abidef) == 0 || string(abidef) == "null" {
+		return "", errors.New("no abi file given and no abi set for the contract")
+	}
+	log.Info("get abi ok", "contract", contract)
+	return string(abidef), nil
+}
+
+// sendSetAbi sends the transaction which sets the abi of the contract in the
+// abi registry, and waits for it to be executed.
+func sendSetAbi(ctx *cli.Context, client *rpcclient.Client, signer types.Signer, accountKey *keys.AccountKey, nonce uint64, contract common.Address, abidef []byte) error {
+	registry := common.HexToAddress(params.AbiRegistryContractAddr)
+	data := txexec.SetAbiData(contract, abidef)
+	gas, err := gasLimit(ctx, client, accountKey.Address, &registry, 0, data)
+	if err != nil {
+		return err
+	}
+	price, err := gasPrice(ctx, client)
+	if err != nil {
+		return err
+	}
+	tx := types.NewTransaction(nonce, registry, big.NewInt(0), gas, price, data, !ctx.GlobalBool(PackerFlag.Name))
+	tx, err = types.SignTx(tx, signer, accountKey.PrivKey)
+	if err != nil {
+		log.Error("sign tx error", "err", err)
+		return err
+	}
+
+	err = sendTxToRpc(tx, client)
+	if err != nil {
+		return err
+	}
+	log.Info("set abi", "hash", tx.Hash(), "contract", contract, "len", len(abidef))
+
+	return retrieveRspFromRpc(tx, client)
+}
+
 func sendTransaction(ctx *cli.Context) error {
 	initLogger(ctx)
 
@@ -185,11 +305,19 @@ func sendTransaction(ctx *cli.Context) error {
 	log.Info("get nonce ok", "nonce", nonce)
 
 	var addrTo = common.HexToAddress(to)
+	gas, err := gasLimit(ctx, client, accountKey.Address, &addrTo, value, []byte{})
+	if err != nil {
+		return err
+	}
+	price, err := gasPrice(ctx, client)
+	if err != nil {
+		return err
+	}
 	var tx *types.Transaction
 	if packer {
-		tx = types.NewTransaction((uint64)(nonce), addrTo, big.NewInt(value), 3e6, common.Big1, []byte{}, false)
+		tx = types.NewTransaction((uint64)(nonce), addrTo, big.NewInt(value), gas, price, []byte{}, false)
 	} else {
-		tx = types.NewTransaction((uint64)(nonce), addrTo, big.NewInt(value), 3e6, common.Big1, []byte{}, true)
+		tx = types.NewTransaction((uint64)(nonce), addrTo, big.NewInt(value), gas, price, []byte{}, true)
 	}
 	tx, err = types.SignTx(tx, signer, accountKey.PrivKey)
 	if err != nil {
@@ -331,11 +459,19 @@ func deployContract(ctx *cli.Context) error {
 	}
 	nonce = (uint64)(hexNonce)
 	log.Info("get nonce ok", "nonce", nonce)
+	gas, err := gasLimit(ctx, client, accountKey.Address, nil, value, code)
+	if err != nil {
+		return err
+	}
+	price, err := gasPrice(ctx, client)
+	if err != nil {
+		return err
+	}
 	var tx *types.Transaction
 	if packer {
-		tx = types.NewContractCreation((uint64)(nonce), big.NewInt(value), 1e9, common.Big1, code, false)
+		tx = types.NewContractCreation((uint64)(nonce), big.NewInt(value), gas, price, code, false)
 	} else {
-		tx = types.NewContractCreation((uint64)(nonce), big.NewInt(value), 1e9, common.Big1, code, true)
+		tx = types.NewContractCreation((uint64)(nonce), big.NewInt(value), gas, price, code, true)
 	}
 	tx, err = types.SignTx(tx, signer, accountKey.PrivKey)
 	if err != nil {
@@ -352,7 +488,63 @@ func deployContract(ctx *cli.Context) error {
 	log.Info("deploy contract over", "hash", tx.Hash(), "contract", contractAddr)
 
 	err = retrieveRspFromRpc(tx, client)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// set the abi of the new contract
+	if abiFile := ctx.GlobalString(AbiFlag.Name); abiFile != "" {
+		abidef, err := ioutil.ReadFile(abiFile)
+		if err != nil {
+			log.Error("read abi file failed", "err", err)
+			return err
+		}
+		return sendSetAbi(ctx, client, signer, accountKey, nonce+1, contractAddr, abidef)
+	}
+	return nil
+}
+
+func setAbi(ctx *cli.Context) error {
+	initLogger(ctx)
+
+	rpc := ctx.GlobalString(RpcFlag.Name)
+	contract := common.HexToAddress(ctx.GlobalString(ToFlag.Name))
+
+	abiFile := ctx.GlobalString(AbiFlag.Name)
+	abidef, err := ioutil.ReadFile(abiFile)
+	if err != nil {
+		log.Error("read abi file failed", "err", err)
+		return err
+	}
+
+	//signer
+	chainid := ctx.GlobalInt(ChainIdFlag.Name)
+	signer := types.NewEIP155Signer(uint64(chainid))
+
+	//key
+	folder := ctx.GlobalString(KeyFolderFlag.Name)
+	password := ctx.GlobalString(PasswordFlag.Name)
+	accountKeyFile := path.Join(folder, "account.json")
+	accountKey, err := keys.LoadAccountKey(accountKeyFile, password)
+	if err != nil {
+		log.Error("load account key error", "err", err)
+		return err
+	}
+
+	var hexNonce hexutil.Uint64
+	client, err := rpcclient.Dial(rpc)
+	if err != nil {
+		log.Error("connect to rpc error", "rpc", rpc)
+		return err
+	}
+	err = client.Call(&hexNonce, "txpool_getTransactionNonce", accountKey.Address)
+	if err != nil {
+		log.Error("get tx nonce error", "err", err)
+		return err
+	}
+	log.Info("get nonce ok", "nonce", uint64(hexNonce))
+
+	return sendSetAbi(ctx, client, signer, accountKey, uint64(hexNonce), contract, abidef)
 }
 
 func callContract(ctx *cli.Context) error {
@@ -362,10 +554,20 @@ func callContract(ctx *cli.Context) error {
 	packer := ctx.GlobalBool(PackerFlag.Name)
 	value := ctx.GlobalInt64(ValueFlag.Name)
 
-	abiFile := ctx.GlobalString(AbiFlag.Name)
-	abidef, _ := ioutil.ReadFile(abiFile)
 	action := ctx.GlobalString(ActionFlag.Name)
 	args := ctx.GlobalString(ArgsFlag.Name)
+	to := ctx.GlobalString(ToFlag.Name)
+	toAddr := common.HexToAddress(to)
+
+	client, err := rpcclient.Dial(rpc)
+	if err != nil {
+		log.Error("connect to rpc error", "rpc", rpc)
+		return err
+	}
+	abidef, err := loadAbi(ctx, client, toAddr)
+	if err != nil {
+		return err
+	}
 
 	actionUint, err := utils.String2Uint64(action)
 	if err != nil {
@@ -383,7 +585,7 @@ func callContract(ctx *cli.Context) error {
 	}
 
 	writer := bytes.NewBuffer(actionBytes)
-	serializer, err := abi.NewAbiSerializer(string(abidef))
+	serializer, err := abi.NewAbiSerializer(abidef)
 	if err != nil {
 		log.Error("callContract NewAbiSerializer failed", "err", err)
 		return err
@@ -396,9 +598,6 @@ func callContract(ctx *cli.Context) error {
 	actionSlice := writer.Bytes()
 	log.Info("generate action bytes ok", "action", hexutil.Encode(actionSlice), "length", len(actionSlice))
 
-	to := ctx.GlobalString(ToFlag.Name)
-	toAddr := common.HexToAddress(to)
-
 	//signer
 	chainid := ctx.GlobalInt(ChainIdFlag.Name)
 	signer := types.NewEIP155Signer(uint64(chainid))
@@ -415,11 +614,6 @@ func callContract(ctx *cli.Context) error {
 
 	var nonce uint64
 	var hexNonce hexutil.Uint64
-	client, err := rpcclient.Dial(rpc)
-	if err != nil {
-		log.Error("connect to rpc error", "rpc", rpc)
-		return err
-	}
 	err = client.Call(&hexNonce, "txpool_getTransactionNonce", accountKey.Address)
 	if err != nil {
 		log.Error("get tx nonce error", "err", err)
@@ -428,11 +622,19 @@ func callContract(ctx *cli.Context) error {
 	nonce = (uint64)(hexNonce)
 	log.Info("get nonce ok", "nonce", nonce)
 
+	gas, err := gasLimit(ctx, client, accountKey.Address, &toAddr, value, actionSlice)
+	if err != nil {
+		return err
+	}
+	price, err := gasPrice(ctx, client)
+	if err != nil {
+		return err
+	}
 	var tx *types.Transaction
 	if packer {
-		tx = types.NewTransaction((uint64)(nonce), toAddr, big.NewInt(value), 1e9, common.Big1, actionSlice, false)
+		tx = types.NewTransaction((uint64)(nonce), toAddr, big.NewInt(value), gas, price, actionSlice, false)
 	} else {
-		tx = types.NewTransaction((uint64)(nonce), toAddr, big.NewInt(value), 1e9, common.Big1, actionSlice, true)
+		tx = types.NewTransaction((uint64)(nonce), toAddr, big.NewInt(value), gas, price, actionSlice, true)
 	}
 	tx, err = types.SignTx(tx, signer, accountKey.PrivKey)
 	if err != nil {
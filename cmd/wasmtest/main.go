@@ -3,6 +3,8 @@
 2. export DYLD_LIBRARY_PATH=.../fractal/transaction/txexec
 
 use case: wasmtest --wasm ./floattest.wasm --action test --abi ./floattest.abi --args "[1.0, 1.1]" exec
+
+add --engine gowasm to run the contract with the pure go interpreter, which needs no libwasmlib.
 */
 package main
 
@@ -47,6 +49,11 @@ var (
 		Name:  "args",
 		Usage: "action args",
 	}
+	EngineFlag = cli.StringFlag{
+		Name:  "engine",
+		Usage: "wasm engine (wasm or gowasm)",
+		Value: "wasm",
+	}
 
 	execCommand = cli.Command{
 		Name:        "exec",
@@ -66,6 +73,7 @@ var (
 		AbiPathFlag,
 		ActionFlag,
 		ArgsFlag,
+		EngineFlag,
 	}
 )
 
@@ -96,6 +104,7 @@ func exec(ctx *cli.Context) error {
 	abiPath := ctx.GlobalString(AbiPathFlag.Name)
 	action := ctx.GlobalString(ActionFlag.Name)
 	args := ctx.GlobalString(ArgsFlag.Name)
+	engine := txexec.NewWasmEngine(ctx.GlobalString(EngineFlag.Name))
 
 	db := dbwrapper.NewMemDatabase()
 	stateCache := state.NewDatabase(db)
@@ -151,7 +160,7 @@ func exec(ctx *cli.Context) error {
 	log.Info("WASM TEST", "action", actionSlice, "length", len(actionSlice))
 
 	// DO
-	result := txexec.CallWasmContract(code, actionSlice, mockAddr1, mockAddr2, mockAddr1, mockAddr1, 10e6, false, false, &mockGas, callbackParamKey)
+	result := engine(code, actionSlice, mockAddr1, mockAddr2, mockAddr1, mockAddr1, 10e6, false, false, &mockGas, callbackParamKey)
 	if result != 0 {
 		log.Error("CallWasmContract returned with error", "result", result)
 	}
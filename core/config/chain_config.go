@@ -7,9 +7,12 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"reflect"
 
 	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
 	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/params"
 	"github.com/fractal-platform/fractal/utils/log"
 )
 
@@ -28,6 +31,7 @@ var (
 
 	ErrChainConfigConflict = errors.New("Input chain config conflicts with the stored chain config")
 	ErrPackerGroupSize     = errors.New("Input chain config param:<PackerGroupSize> can't be 0")
+	ErrCheckPointThreshold = errors.New("Input chain config param:<CheckPointThreshold> must be between 1 and the number of check point authorities")
 )
 
 // ChainConfig is the config for the current chain.
@@ -41,6 +45,42 @@ type ChainConfig struct {
 	MaxNonceBitLength uint64 `json:"maxNonceBitLength"`
 	CheckPointEnable  bool   `json:"checkPointEnable"`
 	PackerGroupSize   uint64 `json:"packerGroupSize"`
+
+	// check point authority set, a check point is accepted once CheckPointThreshold
+	// distinct authorities have signed it. If empty, the built-in check point node is used.
+	CheckPointAuthorities []string `json:"checkPointAuthorities,omitempty"` // hex encoded public keys
+	CheckPointThreshold   uint64   `json:"checkPointThreshold,omitempty"`
+
+	// block rewards and transaction fee handling, params.DefaultRewardSchedule if nil
+	RewardSchedule *params.RewardSchedule `json:"rewardSchedule,omitempty"`
+
+	// activation heights of the protocol changes, params.NoForks if nil
+	ForkSchedule *params.ForkSchedule `json:"forkSchedule,omitempty"`
+}
+
+// Rewards returns the reward schedule of the chain.
+func (c *ChainConfig) Rewards() *params.RewardSchedule {
+	if c.RewardSchedule == nil {
+		return params.DefaultRewardSchedule
+	}
+	return c.RewardSchedule
+}
+
+// Forks returns the fork schedule of the chain.
+func (c *ChainConfig) Forks() *params.ForkSchedule {
+	if c.ForkSchedule == nil {
+		return params.NoForks
+	}
+	return c.ForkSchedule
+}
+
+// CheckPointAuthoritySet returns the public keys of the check point authorities and
+// the number of distinct signatures needed to accept a check point.
+func (c *ChainConfig) CheckPointAuthoritySet() ([]string, uint64) {
+	if len(c.CheckPointAuthorities) == 0 {
+		return []string{types.CheckPointNodePubKeyStr}, 1
+	}
+	return c.CheckPointAuthorities, c.CheckPointThreshold
 }
 
 func readFromDatabase(db dbwrapper.Database) *ChainConfig {
@@ -81,7 +121,7 @@ func SetupChainConfig(db dbwrapper.Database, config *ChainConfig) (*ChainConfig,
 	storedConfig := readFromDatabase(db)
 	if storedConfig != nil {
 		if config != nil {
-			if *config == *storedConfig {
+			if reflect.DeepEqual(*config, *storedConfig) {
 				// input config equals to stored config
 				return config, nil
 			} else {
@@ -97,6 +137,14 @@ func SetupChainConfig(db dbwrapper.Database, config *ChainConfig) (*ChainConfig,
 			if config.PackerGroupSize == 0 {
 				return nil, ErrPackerGroupSize
 			}
+			if len(config.CheckPointAuthorities) > 0 && (config.CheckPointThreshold == 0 || config.CheckPointThreshold > uint64(len(config.CheckPointAuthorities))) {
+				return nil, ErrCheckPointThreshold
+			}
+			if config.RewardSchedule != nil {
+				if err := config.RewardSchedule.Validate(); err != nil {
+					return nil, err
+				}
+			}
 
 			newConfig = config
 		} else {
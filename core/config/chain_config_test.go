@@ -5,9 +5,12 @@
 package config
 
 import (
+	"math/big"
 	"testing"
 
+	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/params"
 	. "github.com/stretchr/testify/assert"
 )
 
@@ -72,4 +75,75 @@ func TestSetupChainConfig(t *testing.T) {
 		Nil(t, config)
 		NotNil(t, err)
 	})
+	t.Run("check point threshold", func(t *testing.T) {
+		db := dbwrapper.NewMemDatabase()
+		cfg := &ChainConfig{
+			ChainID:               88,
+			Greedy:                5,
+			PackerGroupSize:       16,
+			CheckPointAuthorities: []string{"0x01", "0x02", "0x03"},
+			CheckPointThreshold:   4,
+		}
+		config, err := SetupChainConfig(db, cfg)
+		Nil(t, config)
+		Equal(t, ErrCheckPointThreshold, err)
+
+		cfg.CheckPointThreshold = 2
+		config, err = SetupChainConfig(db, cfg)
+		Equal(t, *config, *cfg)
+		Nil(t, err)
+
+		config, err = SetupChainConfig(db, nil)
+		Equal(t, *config, *cfg)
+		Nil(t, err)
+
+		authorities, threshold := config.CheckPointAuthoritySet()
+		Equal(t, cfg.CheckPointAuthorities, authorities)
+		Equal(t, uint64(2), threshold)
+	})
+	t.Run("reward schedule", func(t *testing.T) {
+		db := dbwrapper.NewMemDatabase()
+		treasury := common.HexToAddress("0x0a")
+		cfg := &ChainConfig{
+			ChainID:         88,
+			Greedy:          5,
+			PackerGroupSize: 16,
+			RewardSchedule: &params.RewardSchedule{
+				MiningReward:     big.NewInt(1000),
+				ConfirmReward:    big.NewInt(100),
+				ConfirmedReward:  big.NewInt(100),
+				ReductionPercent: 50,
+				MaxReductions:    2,
+				FeeBurn:          true,
+				Treasury:         &treasury,
+			},
+		}
+		config, err := SetupChainConfig(db, cfg)
+		Nil(t, config)
+		Equal(t, params.ErrRewardCycle, err)
+
+		cfg.RewardSchedule.Cycle = 10
+		config, err = SetupChainConfig(db, cfg)
+		Nil(t, config)
+		Equal(t, params.ErrRewardFeeRecipient, err)
+
+		cfg.RewardSchedule.FeeBurn = false
+		config, err = SetupChainConfig(db, cfg)
+		Equal(t, *config, *cfg)
+		Nil(t, err)
+
+		config, err = SetupChainConfig(db, nil)
+		Equal(t, *cfg.RewardSchedule, *config.Rewards())
+		Nil(t, err)
+
+		mining, confirm, confirmed := config.Rewards().Rewards(25)
+		Equal(t, big.NewInt(250), mining)
+		Equal(t, big.NewInt(25), confirm)
+		Equal(t, big.NewInt(25), confirmed)
+		mining, _, _ = config.Rewards().Rewards(1000)
+		Equal(t, big.NewInt(250), mining)
+		Equal(t, &treasury, config.Rewards().FeeRecipient(common.Address{}))
+
+		Equal(t, params.DefaultRewardSchedule, MainnetChainConfig.Rewards())
+	})
 }
@@ -4,12 +4,41 @@
 // Package config contains the normal config for other modules.
 package config
 
+const (
+	// GCModeFull keeps only the recent states and the check point states.
+	GCModeFull = "full"
+
+	// GCModeArchive keeps all the states.
+	GCModeArchive = "archive"
+
+	// DefaultStateHistory is the number of heights of state kept in full mode.
+	DefaultStateHistory uint64 = 1024
+
+	// MinStateHistory covers the deepest state read below a block, which is
+	// limited by the uint8 cache height.
+	MinStateHistory uint64 = 256
+
+	// DefaultGPOBlocks is the number of recent main branch blocks sampled by
+	// the gas price oracle.
+	DefaultGPOBlocks = 20
+
+	// DefaultGPOPercentile is the percentile of the sampled gas prices
+	// suggested by the gas price oracle.
+	DefaultGPOPercentile = 60
+)
+
 // DefaultConfig contains default settings for use on the Fractal private net.
 var DefaultConfig = Config{
 	FakeMode: false,
 
 	DatabaseCache: 768,
 
+	GCMode:       GCModeArchive,
+	StateHistory: DefaultStateHistory,
+
+	GPOBlocks:     DefaultGPOBlocks,
+	GPOPercentile: DefaultGPOPercentile,
+
 	PkgCacheSize:        1024,
 	PackerInfoCacheSize: 16,
 
@@ -30,6 +59,12 @@ var DefaultMainnetConfig = Config{
 
 	DatabaseCache: 768,
 
+	GCMode:       GCModeArchive,
+	StateHistory: DefaultStateHistory,
+
+	GPOBlocks:     DefaultGPOBlocks,
+	GPOPercentile: DefaultGPOPercentile,
+
 	ChainConfig: MainnetChainConfig,
 	Genesis:     DefaultMainnetGenesisBlock(),
 
@@ -53,6 +88,12 @@ var DefaultTestnetConfig = Config{
 
 	DatabaseCache: 768,
 
+	GCMode:       GCModeArchive,
+	StateHistory: DefaultStateHistory,
+
+	GPOBlocks:     DefaultGPOBlocks,
+	GPOPercentile: DefaultGPOPercentile,
+
 	ChainConfig: TestnetChainConfig,
 	Genesis:     DefaultTestnetGenesisBlock(),
 
@@ -76,6 +117,12 @@ var DefaultTestnet2Config = Config{
 
 	DatabaseCache: 768,
 
+	GCMode:       GCModeArchive,
+	StateHistory: DefaultStateHistory,
+
+	GPOBlocks:     DefaultGPOBlocks,
+	GPOPercentile: DefaultGPOPercentile,
+
 	ChainConfig: Testnet2ChainConfig,
 	Genesis:     DefaultTestnet2GenesisBlock(),
 
@@ -99,6 +146,12 @@ var DefaultTestnet3Config = Config{
 
 	DatabaseCache: 768,
 
+	GCMode:       GCModeArchive,
+	StateHistory: DefaultStateHistory,
+
+	GPOBlocks:     DefaultGPOBlocks,
+	GPOPercentile: DefaultGPOPercentile,
+
 	ChainConfig: Testnet3ChainConfig,
 	Genesis:     DefaultTestnet3GenesisBlock(),
 
@@ -123,6 +176,17 @@ type Config struct {
 	DatabaseHandles int `toml:"-"`
 	DatabaseCache   int `toml:"-"`
 
+	// State pruning options
+	GCMode       string // GCModeFull or GCModeArchive
+	StateHistory uint64 // heights of state kept below the head in full mode
+
+	// Gas price oracle options
+	GPOBlocks     int // number of recent main branch blocks sampled
+	GPOPercentile int // percentile of the sampled gas prices suggested
+
+	// Indexing options
+	TxHistory bool // index the transactions touching each address
+
 	//
 	NodeConfig *NodeConfig `toml:",omitempty"`
 
@@ -139,6 +203,7 @@ type Config struct {
 	KeyPass string
 
 	CheckPointPriKeyPass string
+	CheckPointRPCs       []string // rpc addresses of check point authorities, queried by normal nodes
 
 	PkgCacheSize        int
 	PackerInfoCacheSize uint8
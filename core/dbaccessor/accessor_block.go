@@ -112,6 +112,59 @@ func WriteCheckPointByIndex(db DatabaseWriter, index uint64, checkPoint *types.C
 	}
 }
 
+// DeleteCheckPointByIndex removes the check point of the index.
+func DeleteCheckPointByIndex(db DatabaseDeleter, index uint64) {
+	if err := db.Delete(checkPointKey(index)); err != nil {
+		log.Crit("Failed to delete check point", "index", index, "err", err)
+	}
+}
+
+// ReadSignedCheckPoint retrieves the check point of the hash with the authority
+// signs collected for it.
+func ReadSignedCheckPoint(db DatabaseReader, hash common.Hash) *types.SignedCheckPoint {
+	data, _ := db.Get(signedCheckPointKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var signed types.SignedCheckPoint
+	if err := rlp.DecodeBytes(data, &signed); err != nil {
+		log.Error("Invalid signed check point RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return &signed
+}
+
+// WriteSignedCheckPoint stores the check point with the authority signs collected for it.
+func WriteSignedCheckPoint(db DatabaseWriter, signed *types.SignedCheckPoint) {
+	data, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		log.Error("Failed to encode signed check point", "err", err)
+		return
+	}
+	hash := signed.CheckPoint.Hash()
+	if err := db.Put(signedCheckPointKey(hash), data); err != nil {
+		log.Crit("Failed to store signed check point", "hash", hash, "err", err)
+	}
+}
+
+// ReadLatestSignedCheckPointHash retrieves the hash of the highest check point
+// signed by enough authorities.
+func ReadLatestSignedCheckPointHash(db DatabaseReader) common.Hash {
+	data, _ := db.Get(latestSignedCheckPointKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteLatestSignedCheckPointHash stores the hash of the highest check point
+// signed by enough authorities.
+func WriteLatestSignedCheckPointHash(db DatabaseWriter, hash common.Hash) {
+	if err := db.Put(latestSignedCheckPointKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store latest signed check point hash", "err", err)
+	}
+}
+
 // ReadBlockHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadBlockHeaderRLP(db DatabaseReader, hash common.Hash) rlp.RawValue {
 	data, _ := db.Get(blockHeaderKey(hash))
@@ -183,7 +236,8 @@ func WriteBlock(db DatabaseWriter, block *types.Block) {
 	)
 	log.Debug("WriteBlock", "round", round, "height", height, "hash", hash)
 
-	// Write the encoded body, write body first, then header
+	// Write the encoded body, write everything else first, then header, which
+	// the block is found by
 	bodyBytes, err := rlp.EncodeToBytes(block.Body)
 	if err != nil {
 		log.Crit("Failed to RLP encode block body", "err", err)
@@ -193,16 +247,6 @@ func WriteBlock(db DatabaseWriter, block *types.Block) {
 		log.Crit("Failed to store block body", "err", err)
 	}
 
-	// Write the encoded header
-	headerBytes, err := rlp.EncodeToBytes(block.Header)
-	if err != nil {
-		log.Crit("Failed to RLP encode block header", "err", err)
-	}
-	headerKey := blockHeaderKey(hash)
-	if err := db.Put(headerKey, headerBytes); err != nil {
-		log.Crit("Failed to store block header", "err", err)
-	}
-
 	// Write the receieve path
 	if err := db.Put(blockReceivePathKey(hash), []byte{byte(block.ReceivedPath)}); err != nil {
 		log.Crit("Failed to store block received path", "err", err)
@@ -213,6 +257,15 @@ func WriteBlock(db DatabaseWriter, block *types.Block) {
 		WriteAccHash(db, hash, block.AccHash)
 	}
 
+	// Write the encoded header
+	headerBytes, err := rlp.EncodeToBytes(block.Header)
+	if err != nil {
+		log.Crit("Failed to RLP encode block header", "err", err)
+	}
+	headerKey := blockHeaderKey(hash)
+	if err := db.Put(headerKey, headerBytes); err != nil {
+		log.Crit("Failed to store block header", "err", err)
+	}
 }
 
 // DeleteBlock removes all block data associated with a hash.
@@ -448,6 +501,13 @@ func WriteBlockStateCheck(db DatabaseWriter, hash common.Hash, value types.Block
 	log.Info("WriteBlockStateCheck", "hash", hash, "enum", value)
 }
 
+// DeleteBlockStateCheck removes the state check enum of the block.
+func DeleteBlockStateCheck(db DatabaseDeleter, hash common.Hash) {
+	if err := db.Delete(blockStateCheckKey(hash)); err != nil {
+		log.Crit("Failed to delete block state check flag", "err", err)
+	}
+}
+
 // WritePkgPoolHashList stores the pending pkgs in pkgPool.
 func WritePkgPoolHashList(db DatabaseWriter, hashList []common.Hash) {
 	log.Debug("WritePkgpoolHashList: ", "len", len(hashList))
@@ -516,6 +576,37 @@ func ReadReceipts(db DatabaseReader, hash common.Hash) types.Receipts {
 	return receipts
 }
 
+// ReadReceiptByIndex retrieves the receipt of the index in the receipts of a
+// block. Only that receipt is decoded, the ones before it are skipped.
+func ReadReceiptByIndex(db DatabaseReader, hash common.Hash, index uint32) *types.Receipt {
+	data := ReadReceiptsRLP(db, hash)
+	if len(data) == 0 {
+		return nil
+	}
+	items, _, err := rlp.SplitList(data)
+	if err != nil {
+		log.Error("Invalid receipt array RLP", "hash", hash, "err", err)
+		return nil
+	}
+	for i := uint32(0); len(items) > 0; i++ {
+		_, _, rest, err := rlp.Split(items)
+		if err != nil {
+			log.Error("Invalid receipt array RLP", "hash", hash, "err", err)
+			return nil
+		}
+		if i == index {
+			var receipt types.ReceiptForStorage
+			if err := rlp.DecodeBytes(items[:len(items)-len(rest)], &receipt); err != nil {
+				log.Error("Invalid receipt RLP", "hash", hash, "index", index, "err", err)
+				return nil
+			}
+			return (*types.Receipt)(&receipt)
+		}
+		items = rest
+	}
+	return nil
+}
+
 func ReadReceiptsRLP(db DatabaseReader, hash common.Hash) rlp.RawValue {
 	data, _ := db.Get(blockReceiptsKey(hash))
 	return data
@@ -573,6 +664,13 @@ func WriteBloom(db DatabaseWriter, hash common.Hash, bloom *types.Bloom) {
 	}
 }
 
+// DeleteBloom removes the transaction bloom belonging to a block.
+func DeleteBloom(db DatabaseDeleter, hash common.Hash) {
+	if err := db.Delete(blockBloomKey(hash)); err != nil {
+		log.Crit("Failed to delete block bloom", "err", err)
+	}
+}
+
 // ReadBloomBits retrieves the compressed bloom bit vector belonging to the given
 // section and bit index from the.
 func ReadBloomBits(db DatabaseReader, bit uint, section uint64) ([]byte, error) {
@@ -628,6 +726,12 @@ func WriteHeightBlockMap(db DatabaseWriter, height uint64, blockFullHash common.
 	}
 }
 
+func DeleteHeightBlockMap(db DatabaseDeleter, height uint64) {
+	if err := db.Delete(heightBlockMapKey(height)); err != nil {
+		log.Crit("Failed to delete height block map", "err", err)
+	}
+}
+
 func ReadHeightBlocks(db DatabaseReader, height uint64) ([]common.Hash, error) {
 	data, _ := db.Get(heightBlocksKey(height))
 	if len(data) == 0 {
@@ -674,6 +778,48 @@ func WriteMainBranchHeadHeightAndHash(db DatabaseWriter, height uint64, hash com
 	}
 }
 
+func ReadFinalizedBlockHeightAndHash(db DatabaseReader) (uint64, common.Hash, error) {
+	data, err := db.Get(finalizedBlockKey())
+	if err != nil {
+		return 0, common.Hash{}, err
+	}
+
+	if len(data) != 40 {
+		return 0, common.Hash{}, ErrDataLength
+	}
+	height := binary.BigEndian.Uint64(data[0:8])
+	var hash common.Hash
+	copy(hash[:], data[8:40])
+	return height, hash, nil
+}
+
+func WriteFinalizedBlockHeightAndHash(db DatabaseWriter, height uint64, hash common.Hash) {
+	var data [40]byte
+	binary.BigEndian.PutUint64(data[0:8], height)
+	copy(data[8:40], hash[:])
+	if err := db.Put(finalizedBlockKey(), data[:]); err != nil {
+		log.Crit("Failed to store finalized block data", "err", err)
+	}
+}
+
+// ReadStatePrunedHeight returns the height below which the states of the main
+// branch are pruned, or 0 if the states are never pruned.
+func ReadStatePrunedHeight(db DatabaseReader) uint64 {
+	data, err := db.Get(statePrunedHeightKey())
+	if err != nil || len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+func WriteStatePrunedHeight(db DatabaseWriter, height uint64) {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], height)
+	if err := db.Put(statePrunedHeightKey(), data[:]); err != nil {
+		log.Crit("Failed to store state pruned height", "err", err)
+	}
+}
+
 func ReadBloomSectionSavedFlag(db DatabaseReader, section uint64) bool {
 	var result = false
 	data, dbErr := db.Get(bloomSectionSavedFlagKey(section))
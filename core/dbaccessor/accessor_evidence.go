@@ -0,0 +1,59 @@
+package dbaccessor
+
+import (
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/rlp"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+// HasEvidence checks if the evidence with the hash has been stored.
+func HasEvidence(db DatabaseReader, hash common.Hash) bool {
+	if has, err := db.Has(evidenceKey(hash)); !has || err != nil {
+		return false
+	}
+	return true
+}
+
+// ReadEvidence retrieves the evidence with the hash.
+func ReadEvidence(db DatabaseReader, hash common.Hash) *types.Evidence {
+	data, _ := db.Get(evidenceKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var evidence types.Evidence
+	if err := rlp.DecodeBytes(data, &evidence); err != nil {
+		log.Error("Invalid evidence RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return &evidence
+}
+
+// ReadEvidenceHashes retrieves the hashes of all the stored evidences, in the
+// order of the hashes.
+func ReadEvidenceHashes(db DatabaseIteratee) []common.Hash {
+	it := db.NewIteratorWithPrefix(evidencePrefix)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for it.Next() {
+		// the keys of the other tables which share the prefix are skipped
+		if key := it.Key(); len(key) == len(evidencePrefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(evidencePrefix):]))
+		}
+	}
+	return hashes
+}
+
+// WriteEvidence stores the evidence under its hash.
+func WriteEvidence(db DatabaseWriter, evidence *types.Evidence) {
+	hash := evidence.Hash()
+	data, err := rlp.EncodeToBytes(evidence)
+	if err != nil {
+		log.Error("Failed to encode evidence", "hash", hash, "err", err)
+		return
+	}
+	if err := db.Put(evidenceKey(hash), data); err != nil {
+		log.Crit("Failed to store evidence", "hash", hash, "err", err)
+	}
+}
@@ -0,0 +1,45 @@
+package dbaccessor
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteEvidence(t *testing.T) {
+	Convey("the evidences written concurrently are all stored", t, func() {
+		db := dbwrapper.NewMemDatabase()
+		// the list of the evidence hashes stored before
+		So(db.Put([]byte("EVL"), []byte{0xc0}), ShouldBeNil)
+
+		var (
+			evidences = make(map[common.Hash]*types.Evidence)
+			wg        sync.WaitGroup
+		)
+		for i := 0; i < 16; i++ {
+			a := types.NewBlock(common.Hash{}, uint64(i), []byte{1}, common.Address{}, big.NewInt(1), 1)
+			b := types.NewBlock(common.Hash{}, uint64(i), []byte{2}, common.Address{}, big.NewInt(1), 1)
+			evidence := types.NewEvidence(&a.Header, &b.Header)
+			evidences[evidence.Hash()] = evidence
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				WriteEvidence(db, evidence)
+			}()
+		}
+		wg.Wait()
+
+		hashes := ReadEvidenceHashes(db)
+		So(hashes, ShouldHaveLength, len(evidences))
+		for _, hash := range hashes {
+			So(HasEvidence(db, hash), ShouldBeTrue)
+			So(ReadEvidence(db, hash).Hash(), ShouldEqual, evidences[hash].Hash())
+		}
+	})
+}
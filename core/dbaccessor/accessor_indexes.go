@@ -41,12 +41,44 @@ func ReadTxLookupEntry(db DatabaseReader, hash common.Hash) (TxLookupEntry, erro
 	}
 	var entry TxLookupEntry
 	if err := rlp.DecodeBytes(data, &entry); err != nil {
-		log.Error("Invalid transaction lookup entry RLP", "hash", hash, "err", err)
-		return TxLookupEntry{}, err
+		var legacy legacyTxLookupEntry
+		if rlp.DecodeBytes(data, &legacy) != nil {
+			log.Error("Invalid transaction lookup entry RLP", "hash", hash, "err", err)
+			return TxLookupEntry{}, err
+		}
+		entry = TxLookupEntry{
+			BlockFullHash:  legacy.BlockFullHash,
+			TxPackageIndex: legacy.TxPackageIndex,
+			TxIndex:        legacy.TxIndex,
+			ReceiptIndex:   NoReceiptIndex,
+		}
 	}
 	return entry, nil
 }
 
+// ReadReceipt retrieves the receipt of a transaction along with its lookup
+// entry. It returns a nil receipt if the block of the entry has no receipt
+// for the transaction.
+func ReadReceipt(db DatabaseReader, hash common.Hash) (*types.Receipt, TxLookupEntry, error) {
+	entry, err := ReadTxLookupEntry(db, hash)
+	if err != nil {
+		return nil, TxLookupEntry{}, err
+	}
+	if entry.ReceiptIndex != NoReceiptIndex {
+		receipt := ReadReceiptByIndex(db, entry.BlockFullHash, entry.ReceiptIndex)
+		if receipt != nil && receipt.TxHash == hash {
+			return receipt, entry, nil
+		}
+	}
+	// legacy entry, search the receipts of the block
+	for _, receipt := range ReadReceipts(db, entry.BlockFullHash) {
+		if receipt.TxHash == hash {
+			return receipt, entry, nil
+		}
+	}
+	return nil, entry, nil
+}
+
 func ReadTxLookupEntryRLP(db DatabaseReader, hash common.Hash) (rlp.RawValue, error) {
 	data, err := db.Get(txLookupKey(hash))
 	return data, err
@@ -61,11 +93,12 @@ func WriteTxLookupEntries(db dbwrapper.Database, blockHeight uint64, blockFullHa
 
 	batch := db.NewBatch()
 
-	for _, executedTx := range executedTxs {
+	for i, executedTx := range executedTxs {
 		entry := TxLookupEntry{
 			BlockFullHash:  blockFullHash,
 			TxPackageIndex: executedTx.TxPackageIndex,
 			TxIndex:        executedTx.TxIndex,
+			ReceiptIndex:   uint32(i),
 		}
 		data, err := rlp.EncodeToBytes(entry)
 		if err != nil {
@@ -80,3 +113,112 @@ func WriteTxLookupEntries(db dbwrapper.Database, blockHeight uint64, blockFullHa
 		log.Crit("Failed to write transaction lookup entry", "err", err)
 	}
 }
+
+// DeleteTxLookupEntry removes the lookup entry of a transaction.
+func DeleteTxLookupEntry(db DatabaseDeleter, hash common.Hash) {
+	if err := db.Delete(txLookupKey(hash)); err != nil {
+		log.Crit("Failed to delete transaction lookup entry", "err", err)
+	}
+}
+
+// ReadAddressHistory retrieves at most limit history entries of the address,
+// from the position of the start entry up to the height toHeight, ordered by
+// height, package index and tx index.
+func ReadAddressHistory(db DatabaseIteratee, address common.Address, start AddressHistoryEntry, toHeight uint64, limit int) []AddressHistoryEntry {
+	prefixLen := len(addressHistoryPrefix) + common.AddressLength
+	it := db.NewIteratorWithPrefix(addressHistoryKey(address, 0, 0, 0)[:prefixLen])
+	defer it.Release()
+
+	var entries []AddressHistoryEntry
+	for ok := it.Seek(addressHistoryKey(address, start.Height, start.TxPackageIndex, start.TxIndex)); ok && len(entries) < limit; ok = it.Next() {
+		key := it.Key()[prefixLen:]
+		if len(key) != 16 || len(it.Value()) != common.HashLength {
+			log.Error("Invalid address history entry", "address", address, "key", common.Bytes2Hex(key))
+			continue
+		}
+		entry := AddressHistoryEntry{
+			Height:         binary.BigEndian.Uint64(key[:8]),
+			TxPackageIndex: binary.BigEndian.Uint32(key[8:12]),
+			TxIndex:        binary.BigEndian.Uint32(key[12:]),
+			TxHash:         common.BytesToHash(it.Value()),
+		}
+		if entry.Height > toHeight {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// WriteAddressHistoryEntry stores a history entry of the address.
+func WriteAddressHistoryEntry(db DatabaseWriter, address common.Address, entry *AddressHistoryEntry) {
+	if err := db.Put(addressHistoryKey(address, entry.Height, entry.TxPackageIndex, entry.TxIndex), entry.TxHash[:]); err != nil {
+		log.Crit("Failed to store address history entry", "err", err)
+	}
+}
+
+// DeleteAddressHistoryEntry removes a history entry of the address.
+func DeleteAddressHistoryEntry(db DatabaseDeleter, address common.Address, entry *AddressHistoryEntry) {
+	if err := db.Delete(addressHistoryKey(address, entry.Height, entry.TxPackageIndex, entry.TxIndex)); err != nil {
+		log.Crit("Failed to delete address history entry", "err", err)
+	}
+}
+
+// ReadAddressHistoryBlock retrieves the block indexed into the address history
+// at the height, or nil if no block is indexed at the height.
+func ReadAddressHistoryBlock(db DatabaseReader, height uint64) *AddressHistoryBlock {
+	data, _ := db.Get(addressHistoryBlockKey(height))
+	if len(data) == 0 {
+		return nil
+	}
+	block := new(AddressHistoryBlock)
+	if err := rlp.DecodeBytes(data, block); err != nil {
+		log.Error("Invalid address history block RLP", "height", height, "err", err)
+		return nil
+	}
+	return block
+}
+
+// WriteAddressHistoryBlock stores the block indexed into the address history
+// at the height.
+func WriteAddressHistoryBlock(db DatabaseWriter, height uint64, block *AddressHistoryBlock) {
+	data, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Crit("Failed to encode address history block", "err", err)
+	}
+	if err := db.Put(addressHistoryBlockKey(height), data); err != nil {
+		log.Crit("Failed to store address history block", "err", err)
+	}
+}
+
+// DeleteAddressHistoryBlock removes the block indexed into the address
+// history at the height.
+func DeleteAddressHistoryBlock(db DatabaseDeleter, height uint64) {
+	if err := db.Delete(addressHistoryBlockKey(height)); err != nil {
+		log.Crit("Failed to delete address history block", "err", err)
+	}
+}
+
+// ReadAddressHistoryHead retrieves the height and hash of the last main branch
+// block indexed into the address history.
+func ReadAddressHistoryHead(db DatabaseReader) (uint64, common.Hash, error) {
+	data, err := db.Get(addressHistoryHeadKey)
+	if err != nil {
+		return 0, common.Hash{}, err
+	}
+	if len(data) != 40 {
+		return 0, common.Hash{}, ErrDataLength
+	}
+	return binary.BigEndian.Uint64(data[0:8]), common.BytesToHash(data[8:40]), nil
+}
+
+// WriteAddressHistoryHead stores the height and hash of the last main branch
+// block indexed into the address history.
+func WriteAddressHistoryHead(db DatabaseWriter, height uint64, hash common.Hash) {
+	var data [40]byte
+	binary.BigEndian.PutUint64(data[0:8], height)
+	copy(data[8:40], hash[:])
+	if err := db.Put(addressHistoryHeadKey, data[:]); err != nil {
+		log.Crit("Failed to store address history head", "err", err)
+	}
+}
@@ -0,0 +1,61 @@
+package dbaccessor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/rlp"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadReceipt(t *testing.T) {
+	Convey("read the receipt of a transaction by its index", t, func() {
+		db := dbwrapper.NewMemDatabase()
+		blockHash := common.Hash{0x01}
+
+		var (
+			txs      []*types.TxWithIndex
+			receipts types.Receipts
+		)
+		for i := 0; i < 5; i++ {
+			tx := types.NewTransaction(uint64(i), common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil, false)
+			txs = append(txs, &types.TxWithIndex{Tx: tx, TxPackageIndex: types.NotInPackage, TxIndex: uint32(i)})
+
+			receipt := types.NewReceipt(nil, i == 3, uint64(21000*(i+1)))
+			receipt.Bloom = new(types.Bloom)
+			receipt.TxHash = tx.Hash()
+			receipt.GasUsed = 21000
+			receipt.Logs = []*types.Log{{Address: common.Address{byte(i)}, Data: []byte{byte(i)}}}
+			receipts = append(receipts, receipt)
+		}
+		WriteReceipts(db, blockHash, receipts)
+		WriteTxLookupEntries(db, 1, blockHash, txs)
+
+		for i, tx := range txs {
+			receipt, entry, err := ReadReceipt(db, tx.Tx.Hash())
+			So(err, ShouldBeNil)
+			So(entry.ReceiptIndex, ShouldEqual, i)
+			So(receipt.TxHash, ShouldEqual, tx.Tx.Hash())
+			So(receipt.CumulativeGasUsed, ShouldEqual, receipts[i].CumulativeGasUsed)
+			So(receipt.Status, ShouldEqual, receipts[i].Status)
+			So(receipt.Logs[0].Data, ShouldResemble, []byte{byte(i)})
+		}
+
+		So(ReadReceiptByIndex(db, blockHash, 5), ShouldBeNil)
+		So(ReadReceiptByIndex(db, common.Hash{0x02}, 0), ShouldBeNil)
+
+		Convey("and search the receipts for a legacy entry", func() {
+			hash := txs[2].Tx.Hash()
+			data, _ := rlp.EncodeToBytes(legacyTxLookupEntry{BlockFullHash: blockHash, TxPackageIndex: types.NotInPackage, TxIndex: 2})
+			So(db.Put(txLookupKey(hash), data), ShouldBeNil)
+
+			receipt, entry, err := ReadReceipt(db, hash)
+			So(err, ShouldBeNil)
+			So(entry.ReceiptIndex, ShouldEqual, NoReceiptIndex)
+			So(receipt.TxHash, ShouldEqual, hash)
+		})
+	})
+}
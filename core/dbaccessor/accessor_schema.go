@@ -3,8 +3,10 @@ package dbaccessor
 import (
 	"encoding/binary"
 	"errors"
+	"math"
 
 	"github.com/fractal-platform/fractal/common"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
 )
 
 const (
@@ -30,8 +32,13 @@ var (
 	genesisBlockKey = []byte("GenesisBlock")
 
 	// checkpoint
-	lastCheckPointKey = []byte("LCP")
-	checkPointPrefix  = []byte("CP")
+	lastCheckPointKey         = []byte("LCP")
+	checkPointPrefix          = []byte("CP")
+	signedCheckPointPrefix    = []byte("CPS")  // signedCheckPointPrefix + hash -> check point with the authority signs
+	latestSignedCheckPointKey = []byte("LSCP") // latestSignedCheckPointKey -> hash of the highest check point signed by enough authorities
+
+	// equivocation evidence
+	evidencePrefix = []byte("EV") // evidencePrefix + hash -> evidence
 
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix          = []byte("H")  // headerPrefix + hash -> block header
@@ -51,6 +58,10 @@ var (
 
 	mainBranchHeadPrefix = []byte("MBH")
 
+	finalizedBlockPrefix = []byte("FB") // finalizedBlockPrefix -> height and hash of the finalized block
+
+	statePrunedHeightPrefix = []byte("SPH") // statePrunedHeightPrefix -> height below which the states are pruned
+
 	bloomBitsPrefix                = []byte("BB") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) -> bloom bits
 	bloomSectionSavedFlagPrefix    = []byte("BSF")
 	bloomFastSyncReachHeightPrefix = []byte("BFS")
@@ -59,10 +70,16 @@ var (
 
 	txLookupPrefix = []byte("l") // txLookupPrefix + hash -> transaction lookup metadata
 
+	addressHistoryPrefix      = []byte("AH")  // addressHistoryPrefix + address + height (uint64 big endian) + package index + tx index (uint32 big endian) -> tx hash
+	addressHistoryBlockPrefix = []byte("AHB") // addressHistoryBlockPrefix + height (uint64 big endian) -> indexed block hash and addresses
+	addressHistoryHeadKey     = []byte("AHH") // addressHistoryHeadKey -> height and hash of the last indexed main branch block
+
 	txPkgNoncePrefix = []byte("PN") // txPackageNoncePrefix + coinbase -> the current package nonce of this coinbase
 	txPkgHashPrefix  = []byte("PH") // txPkgHashPrefix + hash -> the txPackage data
 
 	pkgPoolHashPrefix = []byte("PPH")
+
+	contractAbiPrefix = []byte("ABI") // contractAbiPrefix + address -> abi json of the contract registered on the node
 )
 
 // encodeBlockRound encodes a block round as big endian uint64
@@ -108,8 +125,21 @@ type TxLookupEntry struct {
 	BlockFullHash  common.Hash
 	TxPackageIndex uint32
 	TxIndex        uint32
+	ReceiptIndex   uint32 // index in the receipts of the block
 }
 
+// legacyTxLookupEntry is the lookup entry written before the receipt index
+// was added.
+type legacyTxLookupEntry struct {
+	BlockFullHash  common.Hash
+	TxPackageIndex uint32
+	TxIndex        uint32
+}
+
+// NoReceiptIndex is the receipt index of a legacy lookup entry, whose receipt
+// has to be searched by the transaction hash.
+const NoReceiptIndex uint32 = math.MaxUint32
+
 type TxLookupList []TxLookupEntry
 
 // txLookupKey = txLookupPrefix + hash
@@ -117,6 +147,43 @@ func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
 }
 
+// AddressHistoryEntry is a transaction which touched an address, in the
+// main branch block at the height.
+type AddressHistoryEntry struct {
+	Height         uint64
+	TxPackageIndex uint32
+	TxIndex        uint32
+	TxHash         common.Hash
+}
+
+// AddressHistoryBlock is the main branch block indexed into the address
+// history at a height, with the addresses which have entries at the height.
+type AddressHistoryBlock struct {
+	Hash      common.Hash
+	Addresses []common.Address
+}
+
+// addressHistoryKey = addressHistoryPrefix + address + height + package index + tx index
+func addressHistoryKey(address common.Address, height uint64, pkgIndex uint32, txIndex uint32) []byte {
+	key := make([]byte, len(addressHistoryPrefix)+common.AddressLength+16)
+	n := copy(key, addressHistoryPrefix)
+	n += copy(key[n:], address[:])
+	binary.BigEndian.PutUint64(key[n:], height)
+	binary.BigEndian.PutUint32(key[n+8:], pkgIndex)
+	binary.BigEndian.PutUint32(key[n+12:], txIndex)
+	return key
+}
+
+// addressHistoryBlockKey = addressHistoryBlockPrefix + height
+func addressHistoryBlockKey(height uint64) []byte {
+	return append(addressHistoryBlockPrefix, encodeBlockRound(height)...)
+}
+
+// contractAbiKey = contractAbiPrefix + address
+func contractAbiKey(address common.Address) []byte {
+	return append(contractAbiPrefix, address.Bytes()...)
+}
+
 func txSavedBlockKey() []byte {
 	return savedTxBlockPrefix
 }
@@ -172,6 +239,14 @@ func mainBranchHeadKey() []byte {
 	return mainBranchHeadPrefix
 }
 
+func finalizedBlockKey() []byte {
+	return finalizedBlockPrefix
+}
+
+func statePrunedHeightKey() []byte {
+	return statePrunedHeightPrefix
+}
+
 func txPackageNonceKey(coinbase common.Address) []byte {
 	return append(txPkgNoncePrefix, coinbase.Bytes()...)
 }
@@ -186,6 +261,15 @@ func checkPointKey(index uint64) []byte {
 	return key
 }
 
+func signedCheckPointKey(hash common.Hash) []byte {
+	return append(signedCheckPointPrefix, hash.Bytes()...)
+}
+
+// evidenceKey = evidencePrefix + hash
+func evidenceKey(hash common.Hash) []byte {
+	return append(evidencePrefix, hash.Bytes()...)
+}
+
 // DatabaseReader wraps the Has and Get method of a backing data store.
 type DatabaseReader interface {
 	Has(key []byte) (bool, error)
@@ -201,3 +285,9 @@ type DatabaseWriter interface {
 type DatabaseDeleter interface {
 	Delete(key []byte) error
 }
+
+// DatabaseIteratee wraps the NewIteratorWithPrefix method of a backing data
+// store.
+type DatabaseIteratee interface {
+	NewIteratorWithPrefix(prefix []byte) iterator.Iterator
+}
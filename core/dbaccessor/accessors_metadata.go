@@ -1,6 +1,7 @@
 package dbaccessor
 
 import (
+	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/rlp"
 	"github.com/fractal-platform/fractal/utils/log"
 )
@@ -32,3 +33,16 @@ func ReadChainConfig(db DatabaseReader) ([]byte, error) {
 func WriteChainConfig(db DatabaseWriter, data []byte) error {
 	return db.Put(chainConfigKey, data)
 }
+
+// ReadContractAbi retrieves the abi registered on the node for the contract.
+func ReadContractAbi(db DatabaseReader, address common.Address) []byte {
+	data, _ := db.Get(contractAbiKey(address))
+	return data
+}
+
+// WriteContractAbi registers the abi of the contract on the node.
+func WriteContractAbi(db DatabaseWriter, address common.Address, abi []byte) {
+	if err := db.Put(contractAbiKey(address), abi); err != nil {
+		log.Crit("Failed to store contract abi", "err", err)
+	}
+}
@@ -0,0 +1,94 @@
+package state
+
+import (
+	"errors"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/rlp"
+	"github.com/fractal-platform/fractal/trie"
+)
+
+var ErrAccountNotExist = errors.New("account does not exist")
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// proofList collects the trie nodes of a merkle proof in order.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
+// GetProof returns the merkle proof of the account in the state trie. For an
+// account not in the state, the proof proves its absence.
+func (self *StateDB) GetProof(addr common.Address) ([][]byte, error) {
+	var proof proofList
+	err := self.trie.Prove(crypto.Keccak256(addr.Bytes()), 0, &proof)
+	return proof, err
+}
+
+// GetStorageProof returns the merkle proof of the key in the storage trie of
+// the account.
+func (self *StateDB) GetStorageProof(addr common.Address, key StorageKey) ([][]byte, error) {
+	var proof proofList
+	trie := self.StorageTrie(addr)
+	if trie == nil {
+		return proof, ErrAccountNotExist
+	}
+	err := trie.Prove(crypto.Keccak256(key.ToSlice()), 0, &proof)
+	return proof, err
+}
+
+// GetStorageRoot returns the root hash of the storage trie of the account.
+func (self *StateDB) GetStorageRoot(addr common.Address) common.Hash {
+	stateObject := self.getStateObject(addr)
+	if stateObject != nil {
+		return stateObject.data.Root
+	}
+	return common.Hash{}
+}
+
+// VerifyProof checks the merkle proof of the account against the state root,
+// and returns the account proved, or nil if the proof proves its absence.
+func VerifyProof(root common.Hash, addr common.Address, proof [][]byte) (*Account, error) {
+	value, err := verifyProof(root, crypto.Keccak256(addr.Bytes()), proof)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	var account Account
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// VerifyStorageProof checks the merkle proof of the key against the storage
+// root of an account, and returns the value proved, or nil if the proof proves
+// its absence.
+func VerifyStorageProof(root common.Hash, key StorageKey, proof [][]byte) ([]byte, error) {
+	value, err := verifyProof(root, crypto.Keccak256(key.ToSlice()), proof)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	_, content, _, err := rlp.Split(value)
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func verifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	if root == emptyRoot || root == (common.Hash{}) {
+		return nil, nil
+	}
+	proofDb := dbwrapper.NewMemDatabase()
+	for _, node := range proof {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	value, _, err := trie.VerifyProof(root, key, proofDb)
+	return value, err
+}
@@ -0,0 +1,118 @@
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// tamper returns a copy of the proof with a byte of the node i flipped.
+func tamper(proof [][]byte, i int) [][]byte {
+	cpy := make([][]byte, len(proof))
+	copy(cpy, proof)
+	cpy[i] = common.CopyBytes(proof[i])
+	cpy[i][len(cpy[i])-1] ^= 0xff
+	return cpy
+}
+
+func TestProof(t *testing.T) {
+	Convey("a state with accounts and storage", t, func() {
+		stateDb, err := New(common.Hash{}, NewDatabase(dbwrapper.NewMemDatabase()))
+		So(err, ShouldBeNil)
+
+		var (
+			addr    = common.HexToAddress("0x0101")
+			owner   = common.HexToAddress("0x0202")
+			absent  = common.HexToAddress("0x0303")
+			table   = uint64(0x10)
+			key     = GetStorageKey(table, []byte("key"))
+			missing = GetStorageKey(table, []byte("missing"))
+		)
+		stateDb.AddBalance(addr, big.NewInt(1000))
+		stateDb.SetContractOwner(addr, owner)
+		stateDb.SetState(addr, key, []byte("value"))
+		// fill the tries so that the proofs have more than one node
+		for i := 0; i < 32; i++ {
+			stateDb.AddBalance(common.BigToAddress(big.NewInt(int64(0x1000+i))), big.NewInt(1))
+			stateDb.SetState(addr, GetStorageKey(table, []byte{byte(i)}), []byte{byte(i + 1)})
+		}
+		root, err := stateDb.Commit(false)
+		So(err, ShouldBeNil)
+
+		Convey("the account proof proves the account", func() {
+			proof, err := stateDb.GetProof(addr)
+			So(err, ShouldBeNil)
+			So(len(proof), ShouldBeGreaterThan, 1)
+
+			account, err := VerifyProof(root, addr, proof)
+			So(err, ShouldBeNil)
+			So(account, ShouldNotBeNil)
+			So(account.Balance.Int64(), ShouldEqual, 1000)
+			So(account.ContractOwner, ShouldEqual, owner)
+			So(account.Root, ShouldEqual, stateDb.GetStorageRoot(addr))
+			So(account.CodeHash, ShouldResemble, stateDb.GetCodeHash(addr).Bytes())
+
+			Convey("but not against another root", func() {
+				_, err := VerifyProof(common.HexToHash("0x01"), addr, proof)
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("but not if a node is tampered", func() {
+				for i := range proof {
+					_, err := VerifyProof(root, addr, tamper(proof, i))
+					So(err, ShouldNotBeNil)
+				}
+			})
+
+			Convey("but not if a node is missing", func() {
+				_, err := VerifyProof(root, addr, proof[:len(proof)-1])
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("the account proof proves the absence of an account", func() {
+			proof, err := stateDb.GetProof(absent)
+			So(err, ShouldBeNil)
+			account, err := VerifyProof(root, absent, proof)
+			So(err, ShouldBeNil)
+			So(account, ShouldBeNil)
+		})
+
+		Convey("the storage proof proves the value", func() {
+			storageRoot := stateDb.GetStorageRoot(addr)
+			proof, err := stateDb.GetStorageProof(addr, key)
+			So(err, ShouldBeNil)
+			So(len(proof), ShouldBeGreaterThan, 1)
+
+			value, err := VerifyStorageProof(storageRoot, key, proof)
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, []byte("value"))
+
+			Convey("but not if a node is tampered", func() {
+				for i := range proof {
+					_, err := VerifyStorageProof(storageRoot, key, tamper(proof, i))
+					So(err, ShouldNotBeNil)
+				}
+			})
+		})
+
+		Convey("the storage proof proves the absence of a key", func() {
+			proof, err := stateDb.GetStorageProof(addr, missing)
+			So(err, ShouldBeNil)
+			value, err := VerifyStorageProof(stateDb.GetStorageRoot(addr), missing, proof)
+			So(err, ShouldBeNil)
+			So(value, ShouldBeNil)
+		})
+
+		Convey("the storage proof of an absent account is empty", func() {
+			proof, err := stateDb.GetStorageProof(absent, key)
+			So(err, ShouldEqual, ErrAccountNotExist)
+			value, err := VerifyStorageProof(stateDb.GetStorageRoot(absent), key, proof)
+			So(err, ShouldBeNil)
+			So(value, ShouldBeNil)
+		})
+	})
+}
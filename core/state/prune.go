@@ -0,0 +1,55 @@
+package state
+
+import (
+	"bytes"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/rlp"
+	"github.com/fractal-platform/fractal/trie"
+)
+
+// MarkReachable adds the hashes of the trie nodes and the contract codes of
+// the state to the marked set. Sub tries already in the set are not walked
+// again, so marking many states sharing most of their nodes is cheap.
+func MarkReachable(db Database, root common.Hash, marked map[common.Hash]struct{}) error {
+	return markTrie(db.TrieDB(), root, marked, func(leaf []byte) error {
+		var obj Account
+		if err := rlp.Decode(bytes.NewReader(leaf), &obj); err != nil {
+			return err
+		}
+		if err := markTrie(db.TrieDB(), obj.Root, marked, nil); err != nil {
+			return err
+		}
+		if codeHash := common.BytesToHash(obj.CodeHash); codeHash != emptyCode {
+			marked[codeHash] = struct{}{}
+		}
+		return nil
+	})
+}
+
+func markTrie(triedb *trie.Database, root common.Hash, marked map[common.Hash]struct{}, onleaf func(leaf []byte) error) error {
+	if _, ok := marked[root]; ok {
+		return nil
+	}
+	t, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	it := t.NodeIterator(nil)
+	for descend := true; it.Next(descend); {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if _, ok := marked[hash]; ok {
+				descend = false
+				continue
+			}
+			marked[hash] = struct{}{}
+		}
+		if it.Leaf() && onleaf != nil {
+			if err := onleaf(it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
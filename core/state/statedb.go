@@ -678,6 +678,11 @@ func (self *StateDB) Prepare(thash common.Hash, pi uint32, ti uint32) {
 	self.txIndex = ti
 }
 
+// TxHash returns the hash of the transaction set by Prepare.
+func (self *StateDB) TxHash() common.Hash {
+	return self.thash
+}
+
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = newJournal()
 	s.validRevisions = s.validRevisions[:0]
@@ -800,23 +805,10 @@ func confirmReward(state *StateDB, block *types.Block, confirmedBlock *types.Blo
 	state.AddBalance(confirmedBlock.Header.Coinbase, confirmedRewardValue)
 }
 
-func AddBlockReward(state *StateDB, block *types.Block, confirmedBlocks []*types.Block) {
-	currentMiningAwardCycleIndex := block.Header.Height / params.MiningAwardCycle
-	reductionTime := params.MaxMiningRewardReductionTimes
-	if currentMiningAwardCycleIndex < reductionTime {
-		reductionTime = currentMiningAwardCycleIndex
-	}
-
-	miningRewardValue := params.OriginalMiningRewardValue
-	confirmRewardValue := params.OriginalConfirmRewardValue
-	confirmedRewardValue := params.OriginalConfirmedRewardValue
-
-	for i := 0; i < int(reductionTime); i++ {
-		// reduce 10%
-		miningRewardValue = new(big.Int).Quo(new(big.Int).Mul(miningRewardValue, big.NewInt(9)), big.NewInt(10))
-		confirmRewardValue = new(big.Int).Quo(new(big.Int).Mul(confirmRewardValue, big.NewInt(9)), big.NewInt(10))
-		confirmedRewardValue = new(big.Int).Quo(new(big.Int).Mul(confirmedRewardValue, big.NewInt(9)), big.NewInt(10))
-	}
+// AddBlockReward pays the rewards of the block on the schedule to its miner,
+// and to the miners of the blocks it confirms.
+func AddBlockReward(state *StateDB, rewards *params.RewardSchedule, block *types.Block, confirmedBlocks []*types.Block) {
+	miningRewardValue, confirmRewardValue, confirmedRewardValue := rewards.Rewards(block.Header.Height)
 
 	for _, confirmedBlock := range confirmedBlocks {
 		confirmReward(state, block, confirmedBlock, confirmRewardValue, confirmedRewardValue)
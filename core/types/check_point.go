@@ -1,16 +1,26 @@
 package types
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"strconv"
 	"sync/atomic"
 
 	"github.com/deckarep/golang-set"
 	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/crypto"
 	"github.com/fractal-platform/fractal/rlp"
 )
 
+var (
+	ErrCheckPointNotSigned        = errors.New("check point not signed")
+	ErrCheckPointSignNotAuthority = errors.New("check point signed by unknown authority")
+	ErrCheckPointSignNotEnough    = errors.New("check point signs not enough")
+)
+
 type TreePoint struct {
 	Height            uint64
 	FullHash          common.Hash
@@ -110,14 +120,129 @@ func (c *CheckPoint) UnMarshalJSON(input []byte) error {
 	return nil
 }
 
+// SignedCheckPoint carries a check point together with the signatures
+// of the check point authorities on CheckPoint.Hash().
 type SignedCheckPoint struct {
 	CheckPoint *CheckPoint
-	Sign       []byte
+	Signs      [][]byte
 }
 
 type SignedCheckPointHash struct {
-	Hash common.Hash
-	Sign []byte
+	Hash  common.Hash
+	Signs [][]byte
+}
+
+// signedCheckPointJSON is the JSON form of SignedCheckPoint. Sign is the only
+// sign of the check point nodes before the authority set: it is still written
+// for them, and merged into Signs when read from them.
+type signedCheckPointJSON struct {
+	CheckPoint *CheckPoint
+	Signs      [][]byte
+	Sign       []byte `json:",omitempty"`
+}
+
+type signedCheckPointHashJSON struct {
+	Hash  common.Hash
+	Signs [][]byte
+	Sign  []byte `json:",omitempty"`
+}
+
+func legacySign(signs [][]byte) []byte {
+	if len(signs) == 0 {
+		return nil
+	}
+	return signs[0]
+}
+
+func (s *SignedCheckPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(signedCheckPointJSON{CheckPoint: s.CheckPoint, Signs: s.Signs, Sign: legacySign(s.Signs)})
+}
+
+func (s *SignedCheckPoint) UnmarshalJSON(input []byte) error {
+	var dec signedCheckPointJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	s.CheckPoint = dec.CheckPoint
+	s.Signs = dec.Signs
+	if len(dec.Sign) > 0 {
+		s.Signs = MergeCheckPointSigns(s.Signs, [][]byte{dec.Sign})
+	}
+	return nil
+}
+
+func (s *SignedCheckPointHash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(signedCheckPointHashJSON{Hash: s.Hash, Signs: s.Signs, Sign: legacySign(s.Signs)})
+}
+
+func (s *SignedCheckPointHash) UnmarshalJSON(input []byte) error {
+	var dec signedCheckPointHashJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	s.Hash = dec.Hash
+	s.Signs = dec.Signs
+	if len(dec.Sign) > 0 {
+		s.Signs = MergeCheckPointSigns(s.Signs, [][]byte{dec.Sign})
+	}
+	return nil
+}
+
+// RecoverCheckPointSigner returns the hex encoded public key which signed the check point hash.
+func RecoverCheckPointSigner(hash common.Hash, sign []byte) (string, error) {
+	pubKey, err := crypto.Ecrecover(hash[:], sign)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(pubKey), nil
+}
+
+// VerifyCheckPointSigns checks that at least threshold distinct authorities signed the check point hash.
+// Signs from unknown keys are ignored.
+func VerifyCheckPointSigns(hash common.Hash, signs [][]byte, authorities []string, threshold uint64) error {
+	if len(signs) == 0 {
+		return ErrCheckPointNotSigned
+	}
+
+	authoritySet := mapset.NewSet()
+	for _, authority := range authorities {
+		authoritySet.Add(authority)
+	}
+
+	signers := mapset.NewSet()
+	for _, sign := range signs {
+		signer, err := RecoverCheckPointSigner(hash, sign)
+		if err != nil || !authoritySet.Contains(signer) {
+			continue
+		}
+		signers.Add(signer)
+	}
+
+	if signers.Cardinality() == 0 {
+		return ErrCheckPointSignNotAuthority
+	}
+	if uint64(signers.Cardinality()) < threshold {
+		return ErrCheckPointSignNotEnough
+	}
+	return nil
+}
+
+// MergeCheckPointSigns returns a new slice with the signs in more which are not yet in signs appended.
+func MergeCheckPointSigns(signs [][]byte, more [][]byte) [][]byte {
+	merged := append([][]byte{}, signs...)
+	for _, sign := range more {
+		found := false
+		for _, exist := range merged {
+			if bytes.Equal(exist, sign) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, sign)
+		}
+	}
+	return merged
 }
 
 type CheckPointNodeTypeEnum byte
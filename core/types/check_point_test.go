@@ -0,0 +1,164 @@
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/crypto"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// secp256k1N is the order of the secp256k1 curve.
+var secp256k1N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+func newTestAuthority() (string, crypto.PrivateKey) {
+	pk, sk, err := crypto.NewKeys(crypto.ECDSA)
+	if err != nil {
+		panic(err)
+	}
+	return hexutil.Encode(pk.Marshal()), sk
+}
+
+func signTestCheckPoint(sk crypto.PrivateKey, hash common.Hash) []byte {
+	sign, err := sk.Sign(hash[:])
+	if err != nil {
+		panic(err)
+	}
+	return sign
+}
+
+// malleate returns the other valid signature of the same key: s -> N-s, with
+// the recovery id flipped.
+func malleate(sign []byte) []byte {
+	s := new(big.Int).SetBytes(sign[32:64])
+	s.Sub(secp256k1N, s)
+	malleated := make([]byte, 65)
+	copy(malleated, sign[:32])
+	copy(malleated[64-len(s.Bytes()):64], s.Bytes())
+	malleated[64] = sign[64] ^ 1
+	return malleated
+}
+
+func TestVerifyCheckPointSigns(t *testing.T) {
+	hash := common.Hash{0x01, 0x02}
+	var (
+		authorities []string
+		keys        []crypto.PrivateKey
+	)
+	for i := 0; i < 3; i++ {
+		authority, sk := newTestAuthority()
+		authorities = append(authorities, authority)
+		keys = append(keys, sk)
+	}
+
+	Convey("the signer of a sign is recovered", t, func() {
+		signer, err := RecoverCheckPointSigner(hash, signTestCheckPoint(keys[0], hash))
+		So(err, ShouldBeNil)
+		So(signer, ShouldEqual, authorities[0])
+	})
+
+	Convey("the check point is accepted with the threshold of authorities", t, func() {
+		signs := [][]byte{signTestCheckPoint(keys[0], hash), signTestCheckPoint(keys[2], hash)}
+		So(VerifyCheckPointSigns(hash, signs, authorities, 2), ShouldBeNil)
+		So(VerifyCheckPointSigns(hash, signs, authorities, 3), ShouldEqual, ErrCheckPointSignNotEnough)
+	})
+
+	Convey("the check point is not accepted without signs", t, func() {
+		So(VerifyCheckPointSigns(hash, nil, authorities, 1), ShouldEqual, ErrCheckPointNotSigned)
+	})
+
+	Convey("a signer is counted once", t, func() {
+		sign := signTestCheckPoint(keys[0], hash)
+		So(VerifyCheckPointSigns(hash, [][]byte{sign, sign}, authorities, 2), ShouldEqual, ErrCheckPointSignNotEnough)
+
+		// another signature of the same key
+		signs := [][]byte{sign, malleate(sign)}
+		So(VerifyCheckPointSigns(hash, signs, authorities, 2), ShouldEqual, ErrCheckPointSignNotEnough)
+		So(VerifyCheckPointSigns(hash, signs, authorities, 1), ShouldBeNil)
+	})
+
+	Convey("the signs of unknown keys are ignored", t, func() {
+		_, unknown := newTestAuthority()
+		sign := signTestCheckPoint(unknown, hash)
+		So(VerifyCheckPointSigns(hash, [][]byte{sign}, authorities, 1), ShouldEqual, ErrCheckPointSignNotAuthority)
+
+		signs := [][]byte{sign, signTestCheckPoint(keys[1], hash)}
+		So(VerifyCheckPointSigns(hash, signs, authorities, 1), ShouldBeNil)
+		So(VerifyCheckPointSigns(hash, signs, authorities, 2), ShouldEqual, ErrCheckPointSignNotEnough)
+	})
+
+	Convey("the bad signatures are ignored", t, func() {
+		// signed on another hash
+		other := signTestCheckPoint(keys[0], common.Hash{0x03})
+		So(VerifyCheckPointSigns(hash, [][]byte{other}, authorities, 1), ShouldEqual, ErrCheckPointSignNotAuthority)
+
+		// garbage and truncated signatures
+		sign := signTestCheckPoint(keys[1], hash)
+		garbage := [][]byte{{0x01, 0x02}, sign[:64], make([]byte, 65)}
+		So(VerifyCheckPointSigns(hash, garbage, authorities, 1), ShouldEqual, ErrCheckPointSignNotAuthority)
+		So(VerifyCheckPointSigns(hash, append(garbage, sign), authorities, 1), ShouldBeNil)
+	})
+}
+
+func TestMergeCheckPointSigns(t *testing.T) {
+	Convey("merge the signs without duplicates", t, func() {
+		a, b, c := []byte{0x0a}, []byte{0x0b}, []byte{0x0c}
+		signs := [][]byte{a, b}
+
+		merged := MergeCheckPointSigns(signs, [][]byte{b, c, c})
+		So(merged, ShouldResemble, [][]byte{a, b, c})
+		So(signs, ShouldResemble, [][]byte{a, b})
+
+		So(MergeCheckPointSigns(nil, [][]byte{a, a}), ShouldResemble, [][]byte{a})
+		So(MergeCheckPointSigns(signs, nil), ShouldResemble, signs)
+	})
+}
+
+func TestSignedCheckPointJSON(t *testing.T) {
+	checkPoint := &CheckPoint{TreePoint: &TreePoint{Height: 100, FullHash: common.Hash{0x01}}}
+	a, b := []byte{0x0a}, []byte{0x0b}
+
+	Convey("the signs round trip", t, func() {
+		data, err := json.Marshal(&SignedCheckPoint{CheckPoint: checkPoint, Signs: [][]byte{a, b}})
+		So(err, ShouldBeNil)
+
+		var signed SignedCheckPoint
+		So(json.Unmarshal(data, &signed), ShouldBeNil)
+		So(signed.CheckPoint.Hash(), ShouldEqual, checkPoint.Hash())
+		So(signed.Signs, ShouldResemble, [][]byte{a, b})
+	})
+
+	Convey("the legacy sign is read", t, func() {
+		data, err := json.Marshal(map[string]interface{}{"CheckPoint": checkPoint, "Sign": a})
+		So(err, ShouldBeNil)
+
+		var signed SignedCheckPoint
+		So(json.Unmarshal(data, &signed), ShouldBeNil)
+		So(signed.CheckPoint.Hash(), ShouldEqual, checkPoint.Hash())
+		So(signed.Signs, ShouldResemble, [][]byte{a})
+
+		data, err = json.Marshal(map[string]interface{}{"Hash": checkPoint.Hash(), "Sign": a})
+		So(err, ShouldBeNil)
+
+		var signedHash SignedCheckPointHash
+		So(json.Unmarshal(data, &signedHash), ShouldBeNil)
+		So(signedHash.Hash, ShouldEqual, checkPoint.Hash())
+		So(signedHash.Signs, ShouldResemble, [][]byte{a})
+	})
+
+	Convey("the legacy sign is written for the old nodes", t, func() {
+		data, err := json.Marshal(&SignedCheckPointHash{Hash: checkPoint.Hash(), Signs: [][]byte{a, b}})
+		So(err, ShouldBeNil)
+
+		var legacy struct {
+			Hash common.Hash
+			Sign []byte
+		}
+		So(json.Unmarshal(data, &legacy), ShouldBeNil)
+		So(legacy.Hash, ShouldEqual, checkPoint.Hash())
+		So(legacy.Sign, ShouldResemble, a)
+	})
+}
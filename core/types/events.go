@@ -4,11 +4,22 @@
 // Package types contains data types related to Fractal consensus.
 package types
 
+import "github.com/fractal-platform/fractal/common"
+
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*Transaction }
 
 type ChainUpdateEvent struct{ Block *Block }
 
+// ChainReorgEvent is posted when the head switches to a block which is not a
+// descendant of the old head. Dropped are the blocks leaving the main branch,
+// and Added are the blocks joining it, both ordered by height.
+type ChainReorgEvent struct {
+	CommonAncestor *Block
+	Dropped        Blocks
+	Added          Blocks
+}
+
 type NewMinedBlockEvent struct{ Block *Block }
 
 type BlockExecutedEvent struct{ Block *Block }
@@ -20,3 +31,18 @@ type FutureBlockEvent struct{ Block *Block }
 
 // future txpkg which is sent from blockchain, and will be processed in network handler
 type FutureTxPackageEvent struct{ Pkg *TxPackage }
+
+// signed check point which is accepted or gets more signs, and will be broadcast in network handler
+type NewSignedCheckPointEvent struct{ CheckPoint *SignedCheckPoint }
+
+// FinalizedBlockEvent is posted when the finalized block of the main branch advances.
+type FinalizedBlockEvent struct{ Block *Block }
+
+// FinalityConflictEvent is posted when the main branch or a signed check point
+// disagrees with the finalized block. The finalized block doesn't advance
+// after it.
+type FinalityConflictEvent struct {
+	Height    uint64      // the height of the conflict
+	Finalized common.Hash // the finalized block, or the main branch block at the height
+	Conflict  common.Hash // the block conflicting with it
+}
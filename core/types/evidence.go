@@ -0,0 +1,102 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/crypto"
+)
+
+var (
+	ErrEvidenceSameBlock     = errors.New("evidence headers are the same block")
+	ErrEvidenceDiffRound     = errors.New("evidence headers are not in the same round")
+	ErrEvidenceDiffCoinbase  = errors.New("evidence headers are not mined by the same coinbase")
+	ErrEvidenceSigError      = errors.New("evidence header sig verify failed")
+	ErrEvidenceFullSigError  = errors.New("evidence header full sig verify failed")
+	ErrEvidenceInvalidPubkey = errors.New("evidence pubkey is invalid")
+)
+
+// Evidence proves that a miner signed two different blocks in the same round.
+// The headers carry the signatures of the miner, so anyone can verify the
+// evidence with the mining pubkey of the coinbase.
+type Evidence struct {
+	HeaderA BlockHeader `json:"headerA"`
+	HeaderB BlockHeader `json:"headerB"`
+
+	// cache
+	hash atomic.Value
+}
+
+// NewEvidence creates the evidence of two headers, ordered by full hash so
+// that the same pair of blocks always gives the same evidence.
+func NewEvidence(a *BlockHeader, b *BlockHeader) *Evidence {
+	hashA, hashB := a.FullHash(), b.FullHash()
+	if bytes.Compare(hashA[:], hashB[:]) > 0 {
+		a, b = b, a
+	}
+	return &Evidence{HeaderA: *a, HeaderB: *b}
+}
+
+func (e *Evidence) Hash() common.Hash {
+	if hash := e.hash.Load(); hash != nil {
+		return hash.(common.Hash)
+	}
+	v := common.RlpHash([]interface{}{
+		e.HeaderA.FullHash(),
+		e.HeaderB.FullHash(),
+	})
+	e.hash.Store(v)
+	return v
+}
+
+func (e *Evidence) Coinbase() common.Address {
+	return e.HeaderA.Coinbase
+}
+
+func (e *Evidence) Round() uint64 {
+	return e.HeaderA.Round
+}
+
+// Validate checks that the headers are two different blocks of one coinbase
+// in the same round. It doesn't verify the signatures.
+func (e *Evidence) Validate() error {
+	if e.HeaderA.FullHash() == e.HeaderB.FullHash() {
+		return ErrEvidenceSameBlock
+	}
+	if e.HeaderA.Round != e.HeaderB.Round {
+		return ErrEvidenceDiffRound
+	}
+	if e.HeaderA.Coinbase != e.HeaderB.Coinbase {
+		return ErrEvidenceDiffCoinbase
+	}
+	return nil
+}
+
+// Verify validates the evidence and checks the signatures of both headers
+// against the BLS mining pubkey.
+func (e *Evidence) Verify(pubkey []byte) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	key, err := crypto.UnmarshalPubKey(crypto.BLS, pubkey)
+	if err != nil {
+		return ErrEvidenceInvalidPubkey
+	}
+	for _, header := range []*BlockHeader{&e.HeaderA, &e.HeaderB} {
+		block := NewBlockWithHeader(header)
+		if !key.Verify(block.SignHashByte(), header.Sig) {
+			return ErrEvidenceSigError
+		}
+		if !key.Verify(block.FullHash().Bytes(), header.FullSig) {
+			return ErrEvidenceFullSigError
+		}
+	}
+	return nil
+}
+
+type Evidences []*Evidence
@@ -23,6 +23,7 @@ func (l Log) MarshalJSON() ([]byte, error) {
 		PkgIndex    uint32         `json:"packageIndex" gencodec:"required"`
 		TxIndex     uint32         `json:"transactionIndex" gencodec:"required"`
 		Index       uint32         `json:"logIndex" gencodec:"required"`
+		Removed     bool           `json:"removed"`
 	}
 	var enc Log
 	enc.Address = l.Address
@@ -33,6 +34,7 @@ func (l Log) MarshalJSON() ([]byte, error) {
 	enc.PkgIndex = l.PkgIndex
 	enc.TxIndex = l.TxIndex
 	enc.Index = l.Index
+	enc.Removed = l.Removed
 	return json.Marshal(&enc)
 }
 
@@ -47,6 +49,7 @@ func (l *Log) UnmarshalJSON(input []byte) error {
 		PkgIndex    *uint32         `json:"packageIndex" gencodec:"required"`
 		TxIndex     *uint32         `json:"transactionIndex" gencodec:"required"`
 		Index       *uint32         `json:"logIndex" gencodec:"required"`
+		Removed     *bool           `json:"removed"`
 	}
 	var dec Log
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -84,5 +87,8 @@ func (l *Log) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'logIndex' for Log")
 	}
 	l.Index = *dec.Index
+	if dec.Removed != nil {
+		l.Removed = *dec.Removed
+	}
 	return nil
 }
@@ -33,6 +33,10 @@ type Log struct {
 	TxIndex uint32 `json:"transactionIndex" gencodec:"required"`
 	// index of the log in the receipt
 	Index uint32 `json:"logIndex" gencodec:"required"`
+
+	// The Removed field is true if this log was reverted due to a chain reorganisation.
+	// You must pay attention to this field if you receive logs through a filter query.
+	Removed bool `json:"removed"`
 }
 
 type logMarshaling struct {
@@ -35,6 +35,7 @@ type registerParam struct {
 	remainedGas *uint64
 	callstack   []callframe
 	lastframe   callframe
+	tracer      *CallTracer
 }
 
 type RegisterParam struct {
@@ -91,6 +92,25 @@ func (r *RegisterParam) getBlock(key uint64) *types.Block {
 	return r.item[key].block
 }
 
+// SetTracer attaches the call tracer to the param, so that the contract calls
+// executed under the key are traced.
+func (r *RegisterParam) SetTracer(key uint64, tracer *CallTracer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.item[key].tracer = tracer
+}
+
+func (r *RegisterParam) getTracer(key uint64) *CallTracer {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if item, ok := r.item[key]; ok {
+		return item.tracer
+	}
+	return nil
+}
+
 func (r *RegisterParam) GetContractCode(key uint64, address common.Address) []byte {
 	return r.getState(key).GetCode(address)
 }
@@ -150,6 +170,9 @@ func (r *RegisterParam) ClearCallstack(key uint64) {
 	defer r.lock.RUnlock()
 
 	r.item[key].callstack = make([]callframe, 0)
+	if r.item[key].tracer != nil {
+		r.item[key].tracer.reset()
+	}
 }
 
 func (r *RegisterParam) AddCallstack(key uint64, from common.Address, to common.Address, user common.Address, action []byte, storageDelegate bool, userDelegate bool) {
@@ -182,6 +205,9 @@ func (r *RegisterParam) AddCallstack(key uint64, from common.Address, to common.
 		result:          nil,
 		gas:             0,
 	})
+	if r.item[key].tracer != nil {
+		r.item[key].tracer.enter(r.item[key].stateDb.TxHash(), &r.item[key].callstack[depth])
+	}
 }
 
 func (r *RegisterParam) FulfillCallstack(key uint64, ret int, err string, gas uint64) {
@@ -195,6 +221,9 @@ func (r *RegisterParam) FulfillCallstack(key uint64, ret int, err string, gas ui
 
 	r.item[key].lastframe = r.item[key].callstack[index]
 	r.item[key].callstack = r.item[key].callstack[:index]
+	if r.item[key].tracer != nil {
+		r.item[key].tracer.exit(&r.item[key].lastframe)
+	}
 }
 
 func (r *RegisterParam) GetCallResult(key uint64) []byte {
@@ -222,6 +251,9 @@ func DbStore(callbackParamKey uint64, address common.Address, table uint64, key
 	storageKey := state.GetStorageKey(table, key)
 	log.Info("DbStore", "address", hexutil.Encode(address[:]), "storageKey", hexutil.Encode(storageKey.ToSlice()), "value", value)
 	s.SetState(address, storageKey, value)
+	if tracer := GetGlobalRegisterParam().getTracer(callbackParamKey); tracer != nil {
+		tracer.write(table, key, value)
+	}
 }
 
 func DbLoad(callbackParamKey uint64, address common.Address, table uint64, key []byte) []byte {
@@ -234,6 +266,9 @@ func DbLoad(callbackParamKey uint64, address common.Address, table uint64, key [
 	storageKey := state.GetStorageKey(table, key)
 	value := s.GetState(address, storageKey)
 	log.Info("DbLoad", "address", hexutil.Encode(address[:]), "storageKey", hexutil.Encode(storageKey.ToSlice()), "value", value)
+	if tracer := GetGlobalRegisterParam().getTracer(callbackParamKey); tracer != nil {
+		tracer.read(table, key, value)
+	}
 	return value
 }
 
@@ -264,6 +299,9 @@ func DbRemoveKey(callbackParamKey uint64, address common.Address, table uint64,
 		storageKey := state.GetStorageKey(table, key)
 		stateObject.RemoveKey(s.Database(), storageKey)
 	}
+	if tracer := GetGlobalRegisterParam().getTracer(callbackParamKey); tracer != nil {
+		tracer.write(table, key, nil)
+	}
 }
 
 func DbHasTable(callbackParamKey uint64, address common.Address, table uint64) int {
@@ -345,12 +383,16 @@ func AddLog(callbackParamKey uint64, address common.Address, topicSlice []byte,
 	var data = make([]byte, dataLength)
 	copy(data, dataSlice)
 
-	s.AddLog(&types.Log{
+	l := &types.Log{
 		Address:     address,
 		Topics:      topics,
 		Data:        data,
 		BlockNumber: blockHeight,
-	})
+	}
+	s.AddLog(l)
+	if tracer := GetGlobalRegisterParam().getTracer(callbackParamKey); tracer != nil {
+		tracer.log(l)
+	}
 }
 
 func Transfer(callbackParamKey uint64, from common.Address, to common.Address, amount uint64, remainedGas *uint64) int {
@@ -376,5 +418,8 @@ func Transfer(callbackParamKey uint64, from common.Address, to common.Address, a
 	*remainedGas -= params.TxGas
 	s.SubBalance(from, value)
 	s.AddBalance(to, value)
+	if tracer := GetGlobalRegisterParam().getTracer(callbackParamKey); tracer != nil {
+		tracer.transfer(from, to, amount)
+	}
 	return 0
 }
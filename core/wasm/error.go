@@ -3,5 +3,9 @@ package wasm
 type WasmError int
 
 const (
-	WasmErrorDepthExceed = 1000
+	WasmErrorDepthExceed   = 1000
+	WasmErrorTrap          = 1001
+	WasmErrorOutOfGas      = 1002
+	WasmErrorInvalidModule = 1003
+	WasmErrorNotSupported  = 1004
 )
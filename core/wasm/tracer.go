@@ -0,0 +1,194 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/abi"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+// StorageAccess is a key read or written by a contract call. A nil value
+// written means the key is removed.
+type StorageAccess struct {
+	Key   hexutil.Bytes `json:"key"`
+	Value hexutil.Bytes `json:"value"`
+}
+
+// TransferTrace is a transfer made by a contract call.
+type TransferTrace struct {
+	From   common.Address `json:"from"`
+	To     common.Address `json:"to"`
+	Amount uint64         `json:"amount"`
+}
+
+// CallTrace is a contract call, with the calls it made nested in Calls.
+type CallTrace struct {
+	From            common.Address  `json:"from"`
+	To              common.Address  `json:"to"`
+	Storage         common.Address  `json:"storage"`
+	User            common.Address  `json:"user"`
+	Action          hexutil.Bytes   `json:"action"`
+	Method          string          `json:"method"`
+	Args            json.RawMessage `json:"args,omitempty"` // the action args decoded with the abi of the contract
+	StorageDelegate bool            `json:"storageDelegate"`
+	UserDelegate    bool            `json:"userDelegate"`
+	Ret             int             `json:"ret"`
+	Error           string          `json:"error,omitempty"`
+	Result          hexutil.Bytes   `json:"result"`
+	GasUsed         uint64          `json:"gasUsed"`
+
+	Reads     map[string][]StorageAccess `json:"reads,omitempty"`  // table name -> keys read
+	Writes    map[string][]StorageAccess `json:"writes,omitempty"` // table name -> keys written
+	Transfers []TransferTrace            `json:"transfers,omitempty"`
+	Logs      []*types.Log               `json:"logs,omitempty"`
+	Calls     []*CallTrace               `json:"calls,omitempty"`
+}
+
+// AbiReader returns the abi json of the contract, or nil if it has none.
+type AbiReader func(contract common.Address) []byte
+
+// CallTracer records the contract calls of the transactions executed under a
+// callback param key. It is only used by a single execution, so it is not
+// safe for concurrent use.
+type CallTracer struct {
+	calls map[common.Hash][]*CallTrace // tx hash -> top level calls
+	stack []*CallTrace
+
+	abiReader   AbiReader
+	serializers map[string]*abi.AbiSerializer // abi json -> serializer, nil if the abi is invalid
+}
+
+func NewCallTracer() *CallTracer {
+	return &CallTracer{
+		calls:       make(map[common.Hash][]*CallTrace),
+		serializers: make(map[string]*abi.AbiSerializer),
+	}
+}
+
+// SetAbiReader sets where the abis of the called contracts are read from, so
+// that the args of their actions are decoded.
+func (t *CallTracer) SetAbiReader(reader AbiReader) {
+	t.abiReader = reader
+}
+
+// Calls returns the top level calls of the transaction.
+func (t *CallTracer) Calls(txHash common.Hash) []*CallTrace {
+	return t.calls[txHash]
+}
+
+func (t *CallTracer) enter(txHash common.Hash, frame *callframe) {
+	call := &CallTrace{
+		From:            frame.from,
+		To:              frame.to,
+		Storage:         frame.storage,
+		User:            frame.user,
+		Action:          common.CopyBytes(frame.action),
+		StorageDelegate: frame.storageDelegate,
+		UserDelegate:    frame.userDelegate,
+	}
+	if len(frame.action) >= 8 {
+		call.Method = utils.Uint642String(binary.LittleEndian.Uint64(frame.action[:8]))
+		call.Args = t.decodeArgs(frame.to, call.Method, frame.action[8:])
+	}
+
+	if len(t.stack) == 0 {
+		t.calls[txHash] = append(t.calls[txHash], call)
+	} else {
+		parent := t.stack[len(t.stack)-1]
+		parent.Calls = append(parent.Calls, call)
+	}
+	t.stack = append(t.stack, call)
+}
+
+// decodeArgs decodes the args of the action with the abi of the contract. It
+// returns nil if the contract has no abi or the args don't match it.
+func (t *CallTracer) decodeArgs(contract common.Address, method string, data []byte) json.RawMessage {
+	if t.abiReader == nil {
+		return nil
+	}
+	abiDef := t.abiReader(contract)
+	if len(abiDef) == 0 {
+		return nil
+	}
+	serializer, ok := t.serializers[string(abiDef)]
+	if !ok {
+		var err error
+		if serializer, err = abi.NewAbiSerializer(string(abiDef)); err != nil {
+			log.Debug("tracer: invalid abi", "contract", contract, "err", err)
+		}
+		t.serializers[string(abiDef)] = serializer
+	}
+	if serializer == nil {
+		return nil
+	}
+	typeName := serializer.GetActionType(method)
+	if typeName == "" {
+		return nil
+	}
+	args, err := serializer.Deserialize(typeName, data)
+	if err != nil {
+		log.Debug("tracer: decode action args failed", "contract", contract, "method", method, "err", err)
+		return nil
+	}
+	return args
+}
+
+func (t *CallTracer) exit(frame *callframe) {
+	if len(t.stack) == 0 {
+		return
+	}
+	call := t.stack[len(t.stack)-1]
+	call.Ret = frame.ret
+	call.Error = frame.err
+	call.Result = common.CopyBytes(frame.result)
+	call.GasUsed = frame.gas
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *CallTracer) reset() {
+	t.stack = nil
+}
+
+func (t *CallTracer) current() *CallTrace {
+	if len(t.stack) == 0 {
+		return nil
+	}
+	return t.stack[len(t.stack)-1]
+}
+
+func (t *CallTracer) read(table uint64, key []byte, value []byte) {
+	if call := t.current(); call != nil {
+		if call.Reads == nil {
+			call.Reads = make(map[string][]StorageAccess)
+		}
+		name := utils.Uint642String(table)
+		call.Reads[name] = append(call.Reads[name], StorageAccess{Key: common.CopyBytes(key), Value: common.CopyBytes(value)})
+	}
+}
+
+func (t *CallTracer) write(table uint64, key []byte, value []byte) {
+	if call := t.current(); call != nil {
+		if call.Writes == nil {
+			call.Writes = make(map[string][]StorageAccess)
+		}
+		name := utils.Uint642String(table)
+		call.Writes[name] = append(call.Writes[name], StorageAccess{Key: common.CopyBytes(key), Value: common.CopyBytes(value)})
+	}
+}
+
+func (t *CallTracer) transfer(from common.Address, to common.Address, amount uint64) {
+	if call := t.current(); call != nil {
+		call.Transfers = append(call.Transfers, TransferTrace{From: from, To: to, Amount: amount})
+	}
+}
+
+func (t *CallTracer) log(log *types.Log) {
+	if call := t.current(); call != nil {
+		call.Logs = append(call.Logs, log)
+	}
+}
@@ -0,0 +1,123 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const bankAbi = `{
+    "version": "ftl::abi/0.3.0",
+    "types": [],
+    "structs": [
+        {
+            "name": "deposit",
+            "base": "",
+            "fields": [
+                {
+                    "name": "amount",
+                    "type": "uint64"
+                }
+            ]
+        }
+    ],
+    "actions": [
+        {
+            "name": "deposit",
+            "type": "deposit"
+        }
+    ],
+    "tables": []
+}`
+
+func testName(s string) uint64 {
+	name, err := utils.String2Uint64(s)
+	So(err, ShouldBeNil)
+	return name
+}
+
+func testAction(method string, args []byte) []byte {
+	action := make([]byte, 8, 8+len(args))
+	binary.LittleEndian.PutUint64(action, testName(method))
+	return append(action, args...)
+}
+
+func TestCallTracer(t *testing.T) {
+	Convey("the tracer records a nested call", t, func() {
+		stateDb, err := state.New(common.Hash{}, state.NewDatabase(dbwrapper.NewMemDatabase()))
+		So(err, ShouldBeNil)
+		txHash := common.HexToHash("0x01")
+		stateDb.Prepare(txHash, 0, 0)
+
+		var (
+			user   = common.HexToAddress("0x10")
+			bank   = common.HexToAddress("0x20")
+			vault  = common.HexToAddress("0x30")
+			table  = testName("acc")
+			gas    = params.TxGas
+			amount = make([]byte, 8)
+		)
+		stateDb.AddBalance(bank, big.NewInt(100))
+		binary.LittleEndian.PutUint64(amount, 100)
+
+		tracer := NewCallTracer()
+		tracer.SetAbiReader(func(contract common.Address) []byte {
+			if contract == bank {
+				return []byte(bankAbi)
+			}
+			return nil
+		})
+		r := GetGlobalRegisterParam()
+		key := r.RegisterParam(stateDb, nil)
+		defer r.UnRegisterParam(key)
+		r.SetTracer(key, tracer)
+
+		r.AddCallstack(key, user, bank, user, testAction("deposit", amount), false, false)
+		DbLoad(key, bank, table, []byte("k"))
+		So(Transfer(key, bank, vault, 100, &gas), ShouldEqual, 0)
+
+		r.AddCallstack(key, bank, vault, user, testAction("credit", amount), false, true)
+		DbStore(key, vault, table, []byte("k"), []byte("v"))
+		r.SetCallResult(key, []byte("ok"))
+		r.FulfillCallstack(key, 0, "", 10)
+
+		DbRemoveKey(key, bank, table, []byte("k"))
+		r.FulfillCallstack(key, 1, "failed", 30)
+
+		calls := tracer.Calls(txHash)
+		So(calls, ShouldHaveLength, 1)
+		outer := calls[0]
+		So(outer.From, ShouldEqual, user)
+		So(outer.To, ShouldEqual, bank)
+		So(outer.Method, ShouldEqual, "deposit")
+		So(string(outer.Args), ShouldEqual, `{"amount":100}`)
+		So(outer.Ret, ShouldEqual, 1)
+		So(outer.Error, ShouldEqual, "failed")
+		So(outer.GasUsed, ShouldEqual, 30)
+		So(outer.Reads, ShouldResemble, map[string][]StorageAccess{"acc": {{Key: []byte("k")}}})
+		So(outer.Writes, ShouldResemble, map[string][]StorageAccess{"acc": {{Key: []byte("k")}}})
+		So(outer.Transfers, ShouldResemble, []TransferTrace{{From: bank, To: vault, Amount: 100}})
+
+		So(outer.Calls, ShouldHaveLength, 1)
+		inner := outer.Calls[0]
+		So(inner.From, ShouldEqual, bank)
+		So(inner.To, ShouldEqual, vault)
+		So(inner.User, ShouldEqual, bank)
+		So(inner.UserDelegate, ShouldBeTrue)
+		So(inner.Method, ShouldEqual, "credit")
+		So(inner.Args, ShouldBeNil)
+		So(string(inner.Result), ShouldEqual, "ok")
+		So(inner.GasUsed, ShouldEqual, 10)
+		So(inner.Reads, ShouldBeNil)
+		So(inner.Writes, ShouldResemble, map[string][]StorageAccess{"acc": {{Key: []byte("k"), Value: []byte("v")}}})
+		So(inner.Transfers, ShouldBeNil)
+		So(inner.Calls, ShouldBeEmpty)
+	})
+}
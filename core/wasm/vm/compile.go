@@ -0,0 +1,229 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package vm
+
+// Control, parametric, variable and memory opcodes. Numeric opcodes are
+// handled by value in numeric.go.
+const (
+	opUnreachable  byte = 0x00
+	opNop          byte = 0x01
+	opBlock        byte = 0x02
+	opLoop         byte = 0x03
+	opIf           byte = 0x04
+	opElse         byte = 0x05
+	opEnd          byte = 0x0b
+	opBr           byte = 0x0c
+	opBrIf         byte = 0x0d
+	opBrTable      byte = 0x0e
+	opReturn       byte = 0x0f
+	opCall         byte = 0x10
+	opCallIndirect byte = 0x11
+
+	opDrop   byte = 0x1a
+	opSelect byte = 0x1b
+
+	opLocalGet  byte = 0x20
+	opLocalSet  byte = 0x21
+	opLocalTee  byte = 0x22
+	opGlobalGet byte = 0x23
+	opGlobalSet byte = 0x24
+
+	opI32Load    byte = 0x28
+	opI64Load    byte = 0x29
+	opF32Load    byte = 0x2a
+	opF64Load    byte = 0x2b
+	opI32Load8S  byte = 0x2c
+	opI32Load8U  byte = 0x2d
+	opI32Load16S byte = 0x2e
+	opI32Load16U byte = 0x2f
+	opI64Load8S  byte = 0x30
+	opI64Load8U  byte = 0x31
+	opI64Load16S byte = 0x32
+	opI64Load16U byte = 0x33
+	opI64Load32S byte = 0x34
+	opI64Load32U byte = 0x35
+	opI32Store   byte = 0x36
+	opI64Store   byte = 0x37
+	opF32Store   byte = 0x38
+	opF64Store   byte = 0x39
+	opI32Store8  byte = 0x3a
+	opI32Store16 byte = 0x3b
+	opI64Store8  byte = 0x3c
+	opI64Store16 byte = 0x3d
+	opI64Store32 byte = 0x3e
+	opMemorySize byte = 0x3f
+	opMemoryGrow byte = 0x40
+
+	opI32Const byte = 0x41
+	opI64Const byte = 0x42
+	opF32Const byte = 0x43
+	opF64Const byte = 0x44
+
+	opNumericFirst byte = 0x45
+	opNumericLast  byte = 0xc4 // including the sign extension operators
+
+	opPrefixMisc byte = 0xfc // saturating truncation
+)
+
+// instr is a pre-decoded instruction. Branch targets are resolved at
+// compile time, so the interpreter never scans the byte code.
+type instr struct {
+	op    byte
+	arity byte     // result count of block, loop and if
+	a     uint64   // first immediate, or the target of a structured instruction
+	b     uint64   // second immediate, or the end of an if
+	table []uint32 // br_table depths
+}
+
+type function struct {
+	typ    *FuncType
+	locals []byte
+	code   []instr
+}
+
+type control struct {
+	start   int
+	elseIdx int
+}
+
+// compile decodes a function body into instructions, resolves block
+// targets and checks immediates against the module.
+func compile(m *Module, typ *FuncType, locals []byte, body []byte) (*function, error) {
+	var (
+		r        = &reader{buf: body}
+		code     []instr
+		controls []control
+		done     bool
+		numLocal = uint64(len(typ.Params) + len(locals))
+	)
+	for r.pos < len(r.buf) {
+		ins := instr{op: r.byte()}
+		idx := len(code)
+		switch op := ins.op; {
+		case op == opBlock || op == opLoop || op == opIf:
+			t := r.byte()
+			switch {
+			case t == blockTypeEmpty:
+			case isValueType(t):
+				ins.arity = 1
+			default:
+				return nil, ErrInvalidValueType
+			}
+			controls = append(controls, control{start: idx, elseIdx: -1})
+		case op == opElse:
+			if len(controls) == 0 {
+				return nil, ErrInvalidBlock
+			}
+			c := &controls[len(controls)-1]
+			if code[c.start].op != opIf || c.elseIdx >= 0 {
+				return nil, ErrInvalidBlock
+			}
+			c.elseIdx = idx
+		case op == opEnd:
+			if len(controls) == 0 {
+				// the end of the function body
+				if r.pos != len(r.buf) {
+					return nil, ErrInvalidBlock
+				}
+				done = true
+				break
+			}
+			c := controls[len(controls)-1]
+			controls = controls[:len(controls)-1]
+			start := &code[c.start]
+			switch start.op {
+			case opBlock:
+				start.a = uint64(idx)
+			case opIf:
+				start.a, start.b = uint64(idx), uint64(idx)
+				if c.elseIdx >= 0 {
+					start.a = uint64(c.elseIdx)
+					code[c.elseIdx].a = uint64(idx)
+				}
+			}
+		case op == opBr || op == opBrIf:
+			ins.a = uint64(r.u32())
+			if ins.a > uint64(len(controls)) {
+				return nil, ErrInvalidBlock
+			}
+		case op == opBrTable:
+			count := r.u32()
+			for i := uint32(0); i < count && r.err == nil; i++ {
+				depth := r.u32()
+				if uint64(depth) > uint64(len(controls)) {
+					return nil, ErrInvalidBlock
+				}
+				ins.table = append(ins.table, depth)
+			}
+			ins.a = uint64(r.u32())
+			if ins.a > uint64(len(controls)) {
+				return nil, ErrInvalidBlock
+			}
+		case op == opCall:
+			ins.a = uint64(r.u32())
+			if ins.a >= uint64(len(m.funcTypes)) {
+				return nil, ErrInvalidIndex
+			}
+		case op == opCallIndirect:
+			ins.a = uint64(r.u32())
+			if ins.a >= uint64(len(m.Types)) || m.table == nil {
+				return nil, ErrInvalidIndex
+			}
+			if r.byte() != 0 {
+				return nil, ErrInvalidIndex
+			}
+		case op == opLocalGet || op == opLocalSet || op == opLocalTee:
+			ins.a = uint64(r.u32())
+			if ins.a >= numLocal {
+				return nil, ErrInvalidIndex
+			}
+		case op == opGlobalGet || op == opGlobalSet:
+			ins.a = uint64(r.u32())
+			if ins.a >= uint64(len(m.globals)) {
+				return nil, ErrInvalidIndex
+			}
+			if op == opGlobalSet && !m.globals[ins.a].Mutable {
+				return nil, ErrInvalidIndex
+			}
+		case op >= opI32Load && op <= opI64Store32:
+			r.u32() // alignment hint
+			ins.a = uint64(r.u32())
+			if m.memory == nil {
+				return nil, ErrInvalidIndex
+			}
+		case op == opMemorySize || op == opMemoryGrow:
+			if r.byte() != 0 {
+				return nil, ErrInvalidMemoryIndex
+			}
+			if m.memory == nil {
+				return nil, ErrInvalidIndex
+			}
+		case op == opI32Const:
+			ins.a = uint64(uint32(r.s32()))
+		case op == opI64Const:
+			ins.a = uint64(r.s64())
+		case op == opF32Const:
+			ins.a = uint64(r.fixed32())
+		case op == opF64Const:
+			ins.a = r.fixed64()
+		case op == opPrefixMisc:
+			ins.a = uint64(r.u32())
+			if ins.a > 7 {
+				return nil, ErrInvalidOpcode
+			}
+		case op == opUnreachable || op == opNop || op == opReturn || op == opDrop || op == opSelect:
+		case op >= opNumericFirst && op <= opNumericLast:
+		default:
+			return nil, ErrInvalidOpcode
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		code = append(code, ins)
+	}
+	if !done {
+		return nil, ErrInvalidBlock
+	}
+	return &function{typ: typ, locals: locals, code: code}, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package vm
+
+import (
+	"encoding/binary"
+)
+
+type label struct {
+	arity  int
+	height int  // value stack height at the block entry
+	cont   int  // where a branch to this label continues
+	loop   bool // a branch to a loop restarts it instead of leaving it
+}
+
+func (in *Instance) push(v uint64) {
+	in.stack = append(in.stack, v)
+}
+
+func (in *Instance) pop() uint64 {
+	v := in.stack[len(in.stack)-1]
+	in.stack = in.stack[:len(in.stack)-1]
+	return v
+}
+
+// unwind drops the values above height, keeping the arity values on top.
+func (in *Instance) unwind(height int, arity int) {
+	top := len(in.stack) - arity
+	if top < height {
+		panic(trap{ErrInvalidExecution})
+	}
+	if top != height {
+		copy(in.stack[height:], in.stack[top:])
+		in.stack = in.stack[:height+arity]
+	}
+}
+
+// branch leaves the labels up to the given depth and returns the remaining
+// labels with the continuation of the target.
+func (in *Instance) branch(labels []label, depth uint64) ([]label, int) {
+	idx := len(labels) - 1 - int(depth)
+	l := labels[idx]
+	if l.loop {
+		in.unwind(l.height, 0)
+		return labels[:idx+1], l.cont
+	}
+	in.unwind(l.height, l.arity)
+	return labels[:idx], l.cont
+}
+
+// address computes the effective address of a memory access of size bytes.
+func (in *Instance) address(offset uint64, size uint64) uint64 {
+	ea := uint64(uint32(in.pop())) + offset
+	if ea+size > uint64(len(in.memory)) {
+		panic(trap{ErrMemoryOutOfBounds})
+	}
+	return ea
+}
+
+func (in *Instance) execute(f *function, locals []uint64) {
+	code := f.code
+	labels := make([]label, 1, 16)
+	labels[0] = label{arity: len(f.typ.Results), height: len(in.stack), cont: len(code)}
+
+	for pc := 0; pc < len(code); {
+		ins := &code[pc]
+		pc++
+		in.useGas(InstructionGas[ins.op])
+
+		switch ins.op {
+		case opUnreachable:
+			panic(trap{ErrUnreachable})
+		case opNop:
+		case opBlock:
+			labels = append(labels, label{arity: int(ins.arity), height: len(in.stack), cont: int(ins.a) + 1})
+		case opLoop:
+			labels = append(labels, label{arity: int(ins.arity), height: len(in.stack), cont: pc, loop: true})
+		case opIf:
+			cond := uint32(in.pop())
+			labels = append(labels, label{arity: int(ins.arity), height: len(in.stack), cont: int(ins.b) + 1})
+			if cond == 0 {
+				if ins.a != ins.b {
+					pc = int(ins.a) + 1 // skip the else
+				} else {
+					pc = int(ins.b)
+				}
+			}
+		case opElse:
+			// the then branch is done, continue at the end of the if
+			pc = int(ins.a)
+		case opEnd:
+			l := labels[len(labels)-1]
+			in.unwind(l.height, l.arity)
+			labels = labels[:len(labels)-1]
+		case opBr:
+			labels, pc = in.branch(labels, ins.a)
+		case opBrIf:
+			if uint32(in.pop()) != 0 {
+				labels, pc = in.branch(labels, ins.a)
+			}
+		case opBrTable:
+			i := uint32(in.pop())
+			depth := ins.a
+			if uint64(i) < uint64(len(ins.table)) {
+				depth = uint64(ins.table[i])
+			}
+			labels, pc = in.branch(labels, depth)
+		case opReturn:
+			labels, pc = in.branch(labels, uint64(len(labels)-1))
+		case opCall:
+			in.call(uint32(ins.a))
+		case opCallIndirect:
+			i := uint32(in.pop())
+			if uint64(i) >= uint64(len(in.table)) || in.table[i] < 0 {
+				panic(trap{ErrUndefinedElement})
+			}
+			index := uint32(in.table[i])
+			if !in.module.FuncType(index).Equal(&in.module.Types[ins.a]) {
+				panic(trap{ErrIndirectCallType})
+			}
+			in.call(index)
+
+		case opDrop:
+			in.pop()
+		case opSelect:
+			cond := uint32(in.pop())
+			v2 := in.pop()
+			if cond == 0 {
+				in.stack[len(in.stack)-1] = v2
+			}
+
+		case opLocalGet:
+			in.push(locals[ins.a])
+		case opLocalSet:
+			locals[ins.a] = in.pop()
+		case opLocalTee:
+			locals[ins.a] = in.stack[len(in.stack)-1]
+		case opGlobalGet:
+			in.push(in.globals[ins.a])
+		case opGlobalSet:
+			in.globals[ins.a] = in.pop()
+
+		case opI32Load, opF32Load:
+			ea := in.address(ins.a, 4)
+			in.push(uint64(binary.LittleEndian.Uint32(in.memory[ea:])))
+		case opI64Load, opF64Load:
+			ea := in.address(ins.a, 8)
+			in.push(binary.LittleEndian.Uint64(in.memory[ea:]))
+		case opI32Load8S:
+			ea := in.address(ins.a, 1)
+			in.push(uint64(uint32(int8(in.memory[ea]))))
+		case opI32Load8U:
+			ea := in.address(ins.a, 1)
+			in.push(uint64(in.memory[ea]))
+		case opI32Load16S:
+			ea := in.address(ins.a, 2)
+			in.push(uint64(uint32(int16(binary.LittleEndian.Uint16(in.memory[ea:])))))
+		case opI32Load16U:
+			ea := in.address(ins.a, 2)
+			in.push(uint64(binary.LittleEndian.Uint16(in.memory[ea:])))
+		case opI64Load8S:
+			ea := in.address(ins.a, 1)
+			in.push(uint64(int8(in.memory[ea])))
+		case opI64Load8U:
+			ea := in.address(ins.a, 1)
+			in.push(uint64(in.memory[ea]))
+		case opI64Load16S:
+			ea := in.address(ins.a, 2)
+			in.push(uint64(int16(binary.LittleEndian.Uint16(in.memory[ea:]))))
+		case opI64Load16U:
+			ea := in.address(ins.a, 2)
+			in.push(uint64(binary.LittleEndian.Uint16(in.memory[ea:])))
+		case opI64Load32S:
+			ea := in.address(ins.a, 4)
+			in.push(uint64(int32(binary.LittleEndian.Uint32(in.memory[ea:]))))
+		case opI64Load32U:
+			ea := in.address(ins.a, 4)
+			in.push(uint64(binary.LittleEndian.Uint32(in.memory[ea:])))
+		case opI32Store, opF32Store, opI64Store32:
+			v := in.pop()
+			ea := in.address(ins.a, 4)
+			binary.LittleEndian.PutUint32(in.memory[ea:], uint32(v))
+		case opI64Store, opF64Store:
+			v := in.pop()
+			ea := in.address(ins.a, 8)
+			binary.LittleEndian.PutUint64(in.memory[ea:], v)
+		case opI32Store8, opI64Store8:
+			v := in.pop()
+			ea := in.address(ins.a, 1)
+			in.memory[ea] = byte(v)
+		case opI32Store16, opI64Store16:
+			v := in.pop()
+			ea := in.address(ins.a, 2)
+			binary.LittleEndian.PutUint16(in.memory[ea:], uint16(v))
+		case opMemorySize:
+			in.push(uint64(len(in.memory) / PageSize))
+		case opMemoryGrow:
+			in.push(in.grow(uint32(in.pop())))
+
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			in.push(ins.a)
+		case opPrefixMisc:
+			in.truncSat(byte(ins.a))
+		default:
+			in.numeric(ins.op)
+		}
+	}
+}
+
+// grow adds pages to the memory and returns the previous size in pages, or
+// -1 if the memory cannot grow.
+func (in *Instance) grow(pages uint32) uint64 {
+	old := uint32(len(in.memory) / PageSize)
+	if uint64(old)+uint64(pages) > uint64(in.maxPages) {
+		return uint64(0xffffffff)
+	}
+	in.useGas(uint64(pages) * GasPerMemoryPage)
+	if pages > 0 {
+		in.memory = append(in.memory, make([]byte, int(pages)*PageSize)...)
+	}
+	return uint64(old)
+}
@@ -0,0 +1,721 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package vm implements a WebAssembly (MVP) interpreter with gas metering.
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"unicode/utf8"
+)
+
+const (
+	magic   = 0x6d736100 // \0asm
+	version = 0x1
+
+	// PageSize is the size of one wasm memory page.
+	PageSize = 65536
+
+	// MaxMemoryPages limits the linear memory of an instance (16MB).
+	MaxMemoryPages = 256
+
+	maxTableSize    = 65536
+	maxFunctionSize = 1 << 20
+	maxLocals       = 50000
+)
+
+// Value types
+const (
+	ValueTypeI32 byte = 0x7f
+	ValueTypeI64 byte = 0x7e
+	ValueTypeF32 byte = 0x7d
+	ValueTypeF64 byte = 0x7c
+
+	blockTypeEmpty byte = 0x40
+	elemTypeFunc   byte = 0x70
+	funcTypeForm   byte = 0x60
+)
+
+// External kinds
+const (
+	ExternalFunction byte = 0x00
+	ExternalTable    byte = 0x01
+	ExternalMemory   byte = 0x02
+	ExternalGlobal   byte = 0x03
+)
+
+// Section ids
+const (
+	sectionCustom   byte = 0
+	sectionType     byte = 1
+	sectionImport   byte = 2
+	sectionFunction byte = 3
+	sectionTable    byte = 4
+	sectionMemory   byte = 5
+	sectionGlobal   byte = 6
+	sectionExport   byte = 7
+	sectionStart    byte = 8
+	sectionElement  byte = 9
+	sectionCode     byte = 10
+	sectionData     byte = 11
+)
+
+var (
+	ErrInvalidMagic       = errors.New("wasm: invalid magic number")
+	ErrInvalidVersion     = errors.New("wasm: invalid version")
+	ErrUnexpectedEOF      = errors.New("wasm: unexpected end of module")
+	ErrInvalidLEB128      = errors.New("wasm: invalid leb128 integer")
+	ErrInvalidSection     = errors.New("wasm: invalid section")
+	ErrInvalidValueType   = errors.New("wasm: invalid value type")
+	ErrInvalidName        = errors.New("wasm: invalid utf8 name")
+	ErrInvalidIndex       = errors.New("wasm: index out of range")
+	ErrInvalidInitExpr    = errors.New("wasm: invalid init expression")
+	ErrInvalidOpcode      = errors.New("wasm: invalid opcode")
+	ErrInvalidBlock       = errors.New("wasm: unbalanced block structure")
+	ErrFunctionCount      = errors.New("wasm: function and code section counts differ")
+	ErrTooManyLocals      = errors.New("wasm: too many locals")
+	ErrFunctionTooLarge   = errors.New("wasm: function body too large")
+	ErrMultipleMemories   = errors.New("wasm: multiple memories")
+	ErrMultipleTables     = errors.New("wasm: multiple tables")
+	ErrUnsupportedImport  = errors.New("wasm: unsupported import kind")
+	ErrDuplicateExport    = errors.New("wasm: duplicate export name")
+	ErrMemoryLimitExceed  = errors.New("wasm: memory limit exceeded")
+	ErrTableLimitExceed   = errors.New("wasm: table limit exceeded")
+	ErrMultiValueResults  = errors.New("wasm: multiple results are not supported")
+	ErrInvalidStartFunc   = errors.New("wasm: invalid start function")
+	ErrInvalidMemoryIndex = errors.New("wasm: memory index must be zero")
+)
+
+// FuncType is a function signature.
+type FuncType struct {
+	Params  []byte
+	Results []byte
+}
+
+// Equal reports whether two signatures are identical.
+func (t *FuncType) Equal(o *FuncType) bool {
+	if len(t.Params) != len(o.Params) || len(t.Results) != len(o.Results) {
+		return false
+	}
+	for i := range t.Params {
+		if t.Params[i] != o.Params[i] {
+			return false
+		}
+	}
+	for i := range t.Results {
+		if t.Results[i] != o.Results[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Limits describes the size of a memory or a table.
+type Limits struct {
+	Min    uint32
+	Max    uint32
+	HasMax bool
+}
+
+// Import is an entry of the import section.
+type Import struct {
+	Module string
+	Name   string
+	Kind   byte
+
+	TypeIndex uint32 // for function imports
+	Limits    Limits // for table and memory imports
+}
+
+// Export is an entry of the export section.
+type Export struct {
+	Name  string
+	Kind  byte
+	Index uint32
+}
+
+// Global is a module defined global variable.
+type Global struct {
+	Type    byte
+	Mutable bool
+	Init    uint64
+}
+
+type element struct {
+	offset uint32
+	funcs  []uint32
+}
+
+type dataSegment struct {
+	offset uint32
+	data   []byte
+}
+
+// Module is a decoded and compiled wasm module. It is immutable and can
+// be shared by any number of instances.
+type Module struct {
+	Types   []FuncType
+	Imports []Import
+	Exports map[string]Export
+
+	funcTypes     []uint32 // type index of every function, imports first
+	importedFuncs int
+	functions     []*function
+
+	table  *Limits
+	memory *Limits
+
+	globals  []Global
+	start    *uint32
+	elements []element
+	data     []dataSegment
+}
+
+// FuncType returns the signature of the function at the given index.
+func (m *Module) FuncType(index uint32) *FuncType {
+	return &m.Types[m.funcTypes[index]]
+}
+
+// ReadModule decodes and compiles a binary wasm module.
+func ReadModule(code []byte) (*Module, error) {
+	r := &reader{buf: code}
+	if len(code) < 8 {
+		return nil, ErrUnexpectedEOF
+	}
+	if binary.LittleEndian.Uint32(code[0:4]) != magic {
+		return nil, ErrInvalidMagic
+	}
+	if binary.LittleEndian.Uint32(code[4:8]) != version {
+		return nil, ErrInvalidVersion
+	}
+	r.pos = 8
+
+	m := &Module{Exports: make(map[string]Export)}
+	var (
+		bodies  [][]byte
+		locals  [][]byte
+		lastID  byte
+		funcDef []uint32
+	)
+	for r.pos < len(r.buf) {
+		id := r.byte()
+		size := r.u32()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if uint64(r.pos)+uint64(size) > uint64(len(r.buf)) {
+			return nil, ErrUnexpectedEOF
+		}
+		if id != sectionCustom {
+			if id > sectionData || id <= lastID {
+				return nil, ErrInvalidSection
+			}
+			lastID = id
+		}
+		s := &reader{buf: r.buf[r.pos : r.pos+int(size)]}
+		r.pos += int(size)
+
+		var err error
+		switch id {
+		case sectionCustom:
+			continue
+		case sectionType:
+			err = m.readTypes(s)
+		case sectionImport:
+			err = m.readImports(s)
+		case sectionFunction:
+			funcDef, err = readIndices(s)
+		case sectionTable:
+			err = m.readTable(s)
+		case sectionMemory:
+			err = m.readMemory(s)
+		case sectionGlobal:
+			err = m.readGlobals(s)
+		case sectionExport:
+			err = m.readExports(s)
+		case sectionStart:
+			index := s.u32()
+			m.start = &index
+		case sectionElement:
+			err = m.readElements(s)
+		case sectionCode:
+			locals, bodies, err = readCodes(s)
+		case sectionData:
+			err = m.readData(s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.err != nil {
+			return nil, s.err
+		}
+		if s.pos != len(s.buf) {
+			return nil, ErrInvalidSection
+		}
+	}
+
+	if len(funcDef) != len(bodies) {
+		return nil, ErrFunctionCount
+	}
+	for _, typeIndex := range funcDef {
+		if int(typeIndex) >= len(m.Types) {
+			return nil, ErrInvalidIndex
+		}
+		m.funcTypes = append(m.funcTypes, typeIndex)
+	}
+	if err := m.checkIndices(); err != nil {
+		return nil, err
+	}
+
+	m.functions = make([]*function, len(bodies))
+	for i := range bodies {
+		f, err := compile(m, m.FuncType(uint32(m.importedFuncs+i)), locals[i], bodies[i])
+		if err != nil {
+			return nil, err
+		}
+		m.functions[i] = f
+	}
+	return m, nil
+}
+
+func (m *Module) checkIndices() error {
+	for _, e := range m.Exports {
+		var count int
+		switch e.Kind {
+		case ExternalFunction:
+			count = len(m.funcTypes)
+		case ExternalGlobal:
+			count = len(m.globals)
+		case ExternalMemory:
+			if m.memory != nil {
+				count = 1
+			}
+		case ExternalTable:
+			if m.table != nil {
+				count = 1
+			}
+		}
+		if int(e.Index) >= count {
+			return ErrInvalidIndex
+		}
+	}
+	if m.start != nil {
+		if int(*m.start) >= len(m.funcTypes) {
+			return ErrInvalidIndex
+		}
+		t := m.FuncType(*m.start)
+		if len(t.Params) != 0 || len(t.Results) != 0 {
+			return ErrInvalidStartFunc
+		}
+	}
+	if len(m.elements) > 0 && m.table == nil {
+		return ErrInvalidIndex
+	}
+	for _, e := range m.elements {
+		for _, index := range e.funcs {
+			if int(index) >= len(m.funcTypes) {
+				return ErrInvalidIndex
+			}
+		}
+	}
+	if len(m.data) > 0 && m.memory == nil {
+		return ErrInvalidIndex
+	}
+	return nil
+}
+
+func (m *Module) readTypes(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if r.byte() != funcTypeForm {
+			return ErrInvalidSection
+		}
+		params, err := readValueTypes(r)
+		if err != nil {
+			return err
+		}
+		results, err := readValueTypes(r)
+		if err != nil {
+			return err
+		}
+		if len(results) > 1 {
+			return ErrMultiValueResults
+		}
+		m.Types = append(m.Types, FuncType{Params: params, Results: results})
+	}
+	return nil
+}
+
+func (m *Module) readImports(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		imp := Import{Module: r.name(), Name: r.name(), Kind: r.byte()}
+		switch imp.Kind {
+		case ExternalFunction:
+			imp.TypeIndex = r.u32()
+			if int(imp.TypeIndex) >= len(m.Types) {
+				return ErrInvalidIndex
+			}
+			m.funcTypes = append(m.funcTypes, imp.TypeIndex)
+			m.importedFuncs++
+		case ExternalTable:
+			if r.byte() != elemTypeFunc {
+				return ErrInvalidValueType
+			}
+			imp.Limits = r.limits()
+			if err := m.setTable(imp.Limits); err != nil {
+				return err
+			}
+		case ExternalMemory:
+			imp.Limits = r.limits()
+			if err := m.setMemory(imp.Limits); err != nil {
+				return err
+			}
+		default:
+			return ErrUnsupportedImport
+		}
+		m.Imports = append(m.Imports, imp)
+	}
+	return nil
+}
+
+func (m *Module) setTable(l Limits) error {
+	if m.table != nil {
+		return ErrMultipleTables
+	}
+	if l.Min > maxTableSize {
+		return ErrTableLimitExceed
+	}
+	m.table = &l
+	return nil
+}
+
+func (m *Module) setMemory(l Limits) error {
+	if m.memory != nil {
+		return ErrMultipleMemories
+	}
+	if l.Min > MaxMemoryPages {
+		return ErrMemoryLimitExceed
+	}
+	m.memory = &l
+	return nil
+}
+
+func (m *Module) readTable(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if r.byte() != elemTypeFunc {
+			return ErrInvalidValueType
+		}
+		if err := m.setTable(r.limits()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Module) readMemory(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if err := m.setMemory(r.limits()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Module) readGlobals(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		g := Global{Type: r.byte()}
+		if !isValueType(g.Type) {
+			return ErrInvalidValueType
+		}
+		g.Mutable = r.byte() == 1
+		init, err := readInitExpr(r, g.Type)
+		if err != nil {
+			return err
+		}
+		g.Init = init
+		m.globals = append(m.globals, g)
+	}
+	return nil
+}
+
+func (m *Module) readExports(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		e := Export{Name: r.name(), Kind: r.byte(), Index: r.u32()}
+		if e.Kind > ExternalGlobal {
+			return ErrInvalidSection
+		}
+		if _, ok := m.Exports[e.Name]; ok {
+			return ErrDuplicateExport
+		}
+		m.Exports[e.Name] = e
+	}
+	return nil
+}
+
+func (m *Module) readElements(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if r.u32() != 0 {
+			return ErrInvalidIndex
+		}
+		offset, err := readInitExpr(r, ValueTypeI32)
+		if err != nil {
+			return err
+		}
+		funcs, err := readIndices(r)
+		if err != nil {
+			return err
+		}
+		m.elements = append(m.elements, element{offset: uint32(offset), funcs: funcs})
+	}
+	return nil
+}
+
+func (m *Module) readData(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if r.u32() != 0 {
+			return ErrInvalidMemoryIndex
+		}
+		offset, err := readInitExpr(r, ValueTypeI32)
+		if err != nil {
+			return err
+		}
+		size := r.u32()
+		data := r.bytes(int(size))
+		m.data = append(m.data, dataSegment{offset: uint32(offset), data: data})
+	}
+	return nil
+}
+
+func readCodes(r *reader) ([][]byte, [][]byte, error) {
+	var (
+		locals [][]byte
+		bodies [][]byte
+	)
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		size := r.u32()
+		if size > maxFunctionSize {
+			return nil, nil, ErrFunctionTooLarge
+		}
+		body := &reader{buf: r.bytes(int(size))}
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+
+		var types []byte
+		groups := body.u32()
+		for j := uint32(0); j < groups && body.err == nil; j++ {
+			n := body.u32()
+			t := body.byte()
+			if !isValueType(t) {
+				return nil, nil, ErrInvalidValueType
+			}
+			if uint64(len(types))+uint64(n) > maxLocals {
+				return nil, nil, ErrTooManyLocals
+			}
+			for k := uint32(0); k < n; k++ {
+				types = append(types, t)
+			}
+		}
+		if body.err != nil {
+			return nil, nil, body.err
+		}
+		locals = append(locals, types)
+		bodies = append(bodies, body.buf[body.pos:])
+	}
+	return locals, bodies, nil
+}
+
+func readIndices(r *reader) ([]uint32, error) {
+	count := r.u32()
+	var indices []uint32
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		indices = append(indices, r.u32())
+	}
+	return indices, r.err
+}
+
+func readValueTypes(r *reader) ([]byte, error) {
+	count := r.u32()
+	var types []byte
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		t := r.byte()
+		if !isValueType(t) {
+			return nil, ErrInvalidValueType
+		}
+		types = append(types, t)
+	}
+	return types, r.err
+}
+
+// readInitExpr evaluates a constant expression. Imported globals are not
+// supported, so only the const instructions are accepted.
+func readInitExpr(r *reader, typ byte) (uint64, error) {
+	var value uint64
+	op := r.byte()
+	switch {
+	case op == opI32Const && typ == ValueTypeI32:
+		value = uint64(uint32(r.s32()))
+	case op == opI64Const && typ == ValueTypeI64:
+		value = uint64(r.s64())
+	case op == opF32Const && typ == ValueTypeF32:
+		value = uint64(r.fixed32())
+	case op == opF64Const && typ == ValueTypeF64:
+		value = r.fixed64()
+	default:
+		return 0, ErrInvalidInitExpr
+	}
+	if r.byte() != opEnd {
+		return 0, ErrInvalidInitExpr
+	}
+	return value, r.err
+}
+
+func isValueType(t byte) bool {
+	return t == ValueTypeI32 || t == ValueTypeI64 || t == ValueTypeF32 || t == ValueTypeF64
+}
+
+// reader decodes the binary format. The first error is sticky, so callers
+// only need to check it once after a group of reads.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.buf) {
+		r.err = ErrUnexpectedEOF
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = ErrUnexpectedEOF
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) uleb(bits uint) uint64 {
+	var (
+		result uint64
+		shift  uint
+	)
+	for {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift >= bits {
+			r.err = ErrInvalidLEB128
+			return 0
+		}
+		result |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return result
+		}
+	}
+}
+
+func (r *reader) sleb(bits uint) int64 {
+	var (
+		result int64
+		shift  uint
+		b      byte
+	)
+	for {
+		b = r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift >= bits {
+			r.err = ErrInvalidLEB128
+			return 0
+		}
+		result |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if shift < 64 && b&0x40 != 0 {
+		result |= -1 << shift
+	}
+	return result
+}
+
+func (r *reader) u32() uint32 {
+	v := r.uleb(32)
+	if v > 0xffffffff {
+		r.err = ErrInvalidLEB128
+		return 0
+	}
+	return uint32(v)
+}
+
+func (r *reader) s32() int32 {
+	return int32(r.sleb(32))
+}
+
+func (r *reader) s64() int64 {
+	return r.sleb(64)
+}
+
+func (r *reader) fixed32() uint32 {
+	b := r.bytes(4)
+	if r.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) fixed64() uint64 {
+	b := r.bytes(8)
+	if r.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (r *reader) name() string {
+	b := r.bytes(int(r.u32()))
+	if r.err == nil && !utf8.Valid(b) {
+		r.err = ErrInvalidName
+	}
+	return string(b)
+}
+
+func (r *reader) limits() Limits {
+	var l Limits
+	flag := r.byte()
+	l.Min = r.u32()
+	if flag == 1 {
+		l.HasMax = true
+		l.Max = r.u32()
+	} else if flag != 0 {
+		r.err = ErrInvalidSection
+	}
+	return l
+}
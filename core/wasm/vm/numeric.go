@@ -0,0 +1,490 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package vm
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Canonical NaNs. Arithmetic results are canonicalized so that execution is
+// deterministic on every platform.
+const (
+	canonicalNaN32 uint32 = 0x7fc00000
+	canonicalNaN64 uint64 = 0x7ff8000000000000
+)
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func f32bits(f float32) uint64 {
+	if f != f {
+		return uint64(canonicalNaN32)
+	}
+	return uint64(math.Float32bits(f))
+}
+
+func f64bits(f float64) uint64 {
+	if f != f {
+		return canonicalNaN64
+	}
+	return math.Float64bits(f)
+}
+
+func (in *Instance) popI32() uint32 {
+	return uint32(in.pop())
+}
+
+func (in *Instance) popF32() float32 {
+	return math.Float32frombits(uint32(in.pop()))
+}
+
+func (in *Instance) popF64() float64 {
+	return math.Float64frombits(in.pop())
+}
+
+func (in *Instance) numeric(op byte) {
+	switch {
+	case op == 0x45: // i32.eqz
+		in.push(b2u(in.popI32() == 0))
+	case op >= 0x46 && op <= 0x4f:
+		b, a := in.popI32(), in.popI32()
+		in.push(b2u(compareI32(op, a, b)))
+	case op == 0x50: // i64.eqz
+		in.push(b2u(in.pop() == 0))
+	case op >= 0x51 && op <= 0x5a:
+		b, a := in.pop(), in.pop()
+		in.push(b2u(compareI64(op, a, b)))
+	case op >= 0x5b && op <= 0x60:
+		b, a := in.popF32(), in.popF32()
+		in.push(b2u(compareFloat(op-0x5b, float64(a), float64(b))))
+	case op >= 0x61 && op <= 0x66:
+		b, a := in.popF64(), in.popF64()
+		in.push(b2u(compareFloat(op-0x61, a, b)))
+
+	case op >= 0x67 && op <= 0x69:
+		in.push(uint64(unaryI32(op, in.popI32())))
+	case op >= 0x6a && op <= 0x78:
+		b, a := in.popI32(), in.popI32()
+		in.push(uint64(binaryI32(op, a, b)))
+	case op >= 0x79 && op <= 0x7b:
+		in.push(unaryI64(op, in.pop()))
+	case op >= 0x7c && op <= 0x8a:
+		b, a := in.pop(), in.pop()
+		in.push(binaryI64(op, a, b))
+
+	case op == 0x8b: // f32.abs
+		in.push(in.pop() &^ (1 << 31))
+	case op == 0x8c: // f32.neg
+		in.push(in.pop() ^ (1 << 31))
+	case op >= 0x8d && op <= 0x91:
+		in.push(f32bits(float32(unaryFloat(op-0x8d, float64(in.popF32())))))
+	case op >= 0x92 && op <= 0x95:
+		b, a := in.popF32(), in.popF32()
+		var r float32
+		switch op {
+		case 0x92:
+			r = a + b
+		case 0x93:
+			r = a - b
+		case 0x94:
+			r = a * b
+		case 0x95:
+			r = a / b
+		}
+		in.push(f32bits(r))
+	case op == 0x96 || op == 0x97: // f32.min, f32.max
+		b, a := in.popF32(), in.popF32()
+		in.push(f32bits(float32(minMax(op == 0x96, float64(a), float64(b)))))
+	case op == 0x98: // f32.copysign
+		b, a := in.pop(), in.pop()
+		in.push(a&^(1<<31) | b&(1<<31))
+
+	case op == 0x99: // f64.abs
+		in.push(in.pop() &^ (1 << 63))
+	case op == 0x9a: // f64.neg
+		in.push(in.pop() ^ (1 << 63))
+	case op >= 0x9b && op <= 0x9f:
+		in.push(f64bits(unaryFloat(op-0x9b, in.popF64())))
+	case op >= 0xa0 && op <= 0xa3:
+		b, a := in.popF64(), in.popF64()
+		var r float64
+		switch op {
+		case 0xa0:
+			r = a + b
+		case 0xa1:
+			r = a - b
+		case 0xa2:
+			r = a * b
+		case 0xa3:
+			r = a / b
+		}
+		in.push(f64bits(r))
+	case op == 0xa4 || op == 0xa5: // f64.min, f64.max
+		b, a := in.popF64(), in.popF64()
+		in.push(f64bits(minMax(op == 0xa4, a, b)))
+	case op == 0xa6: // f64.copysign
+		b, a := in.pop(), in.pop()
+		in.push(a&^(1<<63) | b&(1<<63))
+
+	default:
+		in.convert(op)
+	}
+}
+
+func compareI32(op byte, a, b uint32) bool {
+	switch op {
+	case 0x46: // eq
+		return a == b
+	case 0x47: // ne
+		return a != b
+	case 0x48: // lt_s
+		return int32(a) < int32(b)
+	case 0x49: // lt_u
+		return a < b
+	case 0x4a: // gt_s
+		return int32(a) > int32(b)
+	case 0x4b: // gt_u
+		return a > b
+	case 0x4c: // le_s
+		return int32(a) <= int32(b)
+	case 0x4d: // le_u
+		return a <= b
+	case 0x4e: // ge_s
+		return int32(a) >= int32(b)
+	default: // ge_u
+		return a >= b
+	}
+}
+
+func compareI64(op byte, a, b uint64) bool {
+	switch op {
+	case 0x51: // eq
+		return a == b
+	case 0x52: // ne
+		return a != b
+	case 0x53: // lt_s
+		return int64(a) < int64(b)
+	case 0x54: // lt_u
+		return a < b
+	case 0x55: // gt_s
+		return int64(a) > int64(b)
+	case 0x56: // gt_u
+		return a > b
+	case 0x57: // le_s
+		return int64(a) <= int64(b)
+	case 0x58: // le_u
+		return a <= b
+	case 0x59: // ge_s
+		return int64(a) >= int64(b)
+	default: // ge_u
+		return a >= b
+	}
+}
+
+// compareFloat handles eq, ne, lt, gt, le and ge in this order.
+func compareFloat(i byte, a, b float64) bool {
+	switch i {
+	case 0:
+		return a == b
+	case 1:
+		return a != b
+	case 2:
+		return a < b
+	case 3:
+		return a > b
+	case 4:
+		return a <= b
+	default:
+		return a >= b
+	}
+}
+
+func unaryI32(op byte, a uint32) uint32 {
+	switch op {
+	case 0x67: // clz
+		return uint32(bits.LeadingZeros32(a))
+	case 0x68: // ctz
+		return uint32(bits.TrailingZeros32(a))
+	default: // popcnt
+		return uint32(bits.OnesCount32(a))
+	}
+}
+
+func binaryI32(op byte, a, b uint32) uint32 {
+	switch op {
+	case 0x6a: // add
+		return a + b
+	case 0x6b: // sub
+		return a - b
+	case 0x6c: // mul
+		return a * b
+	case 0x6d: // div_s
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			panic(trap{ErrIntegerOverflow})
+		}
+		return uint32(int32(a) / int32(b))
+	case 0x6e: // div_u
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		return a / b
+	case 0x6f: // rem_s
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		if int32(b) == -1 {
+			return 0
+		}
+		return uint32(int32(a) % int32(b))
+	case 0x70: // rem_u
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		return a % b
+	case 0x71: // and
+		return a & b
+	case 0x72: // or
+		return a | b
+	case 0x73: // xor
+		return a ^ b
+	case 0x74: // shl
+		return a << (b & 31)
+	case 0x75: // shr_s
+		return uint32(int32(a) >> (b & 31))
+	case 0x76: // shr_u
+		return a >> (b & 31)
+	case 0x77: // rotl
+		return bits.RotateLeft32(a, int(b&31))
+	default: // rotr
+		return bits.RotateLeft32(a, -int(b&31))
+	}
+}
+
+func unaryI64(op byte, a uint64) uint64 {
+	switch op {
+	case 0x79: // clz
+		return uint64(bits.LeadingZeros64(a))
+	case 0x7a: // ctz
+		return uint64(bits.TrailingZeros64(a))
+	default: // popcnt
+		return uint64(bits.OnesCount64(a))
+	}
+}
+
+func binaryI64(op byte, a, b uint64) uint64 {
+	switch op {
+	case 0x7c: // add
+		return a + b
+	case 0x7d: // sub
+		return a - b
+	case 0x7e: // mul
+		return a * b
+	case 0x7f: // div_s
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			panic(trap{ErrIntegerOverflow})
+		}
+		return uint64(int64(a) / int64(b))
+	case 0x80: // div_u
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		return a / b
+	case 0x81: // rem_s
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case 0x82: // rem_u
+		if b == 0 {
+			panic(trap{ErrIntegerDivideByZero})
+		}
+		return a % b
+	case 0x83: // and
+		return a & b
+	case 0x84: // or
+		return a | b
+	case 0x85: // xor
+		return a ^ b
+	case 0x86: // shl
+		return a << (b & 63)
+	case 0x87: // shr_s
+		return uint64(int64(a) >> (b & 63))
+	case 0x88: // shr_u
+		return a >> (b & 63)
+	case 0x89: // rotl
+		return bits.RotateLeft64(a, int(b&63))
+	default: // rotr
+		return bits.RotateLeft64(a, -int(b&63))
+	}
+}
+
+// unaryFloat handles ceil, floor, trunc, nearest and sqrt in this order.
+// All of them are exact for f32 operands computed in float64.
+func unaryFloat(i byte, a float64) float64 {
+	switch i {
+	case 0:
+		return math.Ceil(a)
+	case 1:
+		return math.Floor(a)
+	case 2:
+		return math.Trunc(a)
+	case 3:
+		return math.RoundToEven(a)
+	default:
+		return math.Sqrt(a)
+	}
+}
+
+func minMax(isMin bool, a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.NaN()
+	}
+	if isMin {
+		return math.Min(a, b)
+	}
+	return math.Max(a, b)
+}
+
+// truncation bounds, exclusive
+const (
+	minI32 = -2147483649.0
+	maxI32 = 2147483648.0
+	maxU32 = 4294967296.0
+	minI64 = -9223372036854777856.0 // the next float64 below -2^63
+	maxI64 = 9223372036854775808.0
+	maxU64 = 18446744073709551616.0
+)
+
+func truncCheck(f, lower, upper float64) {
+	if math.IsNaN(f) {
+		panic(trap{ErrInvalidConversion})
+	}
+	if f <= lower || f >= upper {
+		panic(trap{ErrIntegerOverflow})
+	}
+}
+
+func (in *Instance) convert(op byte) {
+	switch op {
+	case 0xa7: // i32.wrap_i64
+		in.push(uint64(uint32(in.pop())))
+	case 0xa8, 0xaa: // i32.trunc_f32_s, i32.trunc_f64_s
+		f := in.popFloat(op == 0xa8)
+		truncCheck(f, minI32, maxI32)
+		in.push(uint64(uint32(int32(f))))
+	case 0xa9, 0xab: // i32.trunc_f32_u, i32.trunc_f64_u
+		f := in.popFloat(op == 0xa9)
+		truncCheck(f, -1, maxU32)
+		in.push(uint64(uint32(f)))
+	case 0xac: // i64.extend_i32_s
+		in.push(uint64(int64(int32(in.pop()))))
+	case 0xad: // i64.extend_i32_u
+		in.push(uint64(uint32(in.pop())))
+	case 0xae, 0xb0: // i64.trunc_f32_s, i64.trunc_f64_s
+		f := in.popFloat(op == 0xae)
+		truncCheck(f, minI64, maxI64)
+		in.push(uint64(int64(f)))
+	case 0xaf, 0xb1: // i64.trunc_f32_u, i64.trunc_f64_u
+		f := in.popFloat(op == 0xaf)
+		truncCheck(f, -1, maxU64)
+		in.push(uint64(f))
+	case 0xb2: // f32.convert_i32_s
+		in.push(f32bits(float32(int32(in.pop()))))
+	case 0xb3: // f32.convert_i32_u
+		in.push(f32bits(float32(uint32(in.pop()))))
+	case 0xb4: // f32.convert_i64_s
+		in.push(f32bits(float32(int64(in.pop()))))
+	case 0xb5: // f32.convert_i64_u
+		in.push(f32bits(float32(in.pop())))
+	case 0xb6: // f32.demote_f64
+		in.push(f32bits(float32(in.popF64())))
+	case 0xb7: // f64.convert_i32_s
+		in.push(f64bits(float64(int32(in.pop()))))
+	case 0xb8: // f64.convert_i32_u
+		in.push(f64bits(float64(uint32(in.pop()))))
+	case 0xb9: // f64.convert_i64_s
+		in.push(f64bits(float64(int64(in.pop()))))
+	case 0xba: // f64.convert_i64_u
+		in.push(f64bits(float64(in.pop())))
+	case 0xbb: // f64.promote_f32
+		in.push(f64bits(float64(in.popF32())))
+	case 0xbc, 0xbd, 0xbe, 0xbf:
+		// reinterpretations keep the bits as they are
+	case 0xc0: // i32.extend8_s
+		in.push(uint64(uint32(int8(in.pop()))))
+	case 0xc1: // i32.extend16_s
+		in.push(uint64(uint32(int16(in.pop()))))
+	case 0xc2: // i64.extend8_s
+		in.push(uint64(int8(in.pop())))
+	case 0xc3: // i64.extend16_s
+		in.push(uint64(int16(in.pop())))
+	case 0xc4: // i64.extend32_s
+		in.push(uint64(int32(in.pop())))
+	default:
+		panic(trap{ErrInvalidExecution})
+	}
+}
+
+func (in *Instance) popFloat(f32 bool) float64 {
+	if f32 {
+		return float64(in.popF32())
+	}
+	return in.popF64()
+}
+
+// truncSat implements the saturating float to integer conversions
+// (0xfc 0x00-0x07), which never trap.
+func (in *Instance) truncSat(sub byte) {
+	f := in.popFloat(sub == 0 || sub == 1 || sub == 4 || sub == 5)
+	signed := sub%2 == 0
+	if sub < 4 {
+		switch {
+		case math.IsNaN(f):
+			in.push(0)
+		case signed && f <= minI32:
+			in.push(0x80000000)
+		case signed && f >= maxI32:
+			in.push(uint64(uint32(math.MaxInt32)))
+		case signed:
+			in.push(uint64(uint32(int32(f))))
+		case f <= -1:
+			in.push(0)
+		case f >= maxU32:
+			in.push(uint64(math.MaxUint32))
+		default:
+			in.push(uint64(uint32(f)))
+		}
+		return
+	}
+	switch {
+	case math.IsNaN(f):
+		in.push(0)
+	case signed && f <= minI64:
+		in.push(uint64(1) << 63)
+	case signed && f >= maxI64:
+		in.push(uint64(math.MaxInt64))
+	case signed:
+		in.push(uint64(int64(f)))
+	case f <= -1:
+		in.push(0)
+	case f >= maxU64:
+		in.push(math.MaxUint64)
+	default:
+		in.push(uint64(f))
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package vm
+
+import (
+	"errors"
+	"runtime"
+)
+
+// Gas schedule of the interpreter. Host functions charge their own cost on
+// top of the call. It must be the metering of libwasmlib for the gas used to
+// be a node's, which TestGoWasmParity of txexec checks where libwasmlib is
+// linked.
+const (
+	GasPerInstruction uint64 = 1
+	GasPerCall        uint64 = 10
+	GasPerMemoryPage  uint64 = 1024
+)
+
+// InstructionGas is the gas charged for an instruction by its opcode,
+// GasPerInstruction unless an opcode is given its own cost.
+var InstructionGas = func() (gas [256]uint64) {
+	for op := range gas {
+		gas[op] = GasPerInstruction
+	}
+	return gas
+}()
+
+const (
+	// MaxCallDepth limits the nesting of wasm function calls.
+	MaxCallDepth = 1024
+
+	maxStackSize = 1 << 20
+)
+
+var (
+	ErrOutOfGas             = errors.New("wasm: out of gas")
+	ErrUnreachable          = errors.New("wasm: unreachable executed")
+	ErrMemoryOutOfBounds    = errors.New("wasm: memory access out of bounds")
+	ErrIntegerDivideByZero  = errors.New("wasm: integer divide by zero")
+	ErrIntegerOverflow      = errors.New("wasm: integer overflow")
+	ErrInvalidConversion    = errors.New("wasm: invalid conversion to integer")
+	ErrUndefinedElement     = errors.New("wasm: undefined table element")
+	ErrIndirectCallType     = errors.New("wasm: indirect call type mismatch")
+	ErrCallStackExhausted   = errors.New("wasm: call stack exhausted")
+	ErrValueStackExhausted  = errors.New("wasm: value stack exhausted")
+	ErrInvalidExecution     = errors.New("wasm: invalid execution")
+	ErrImportNotFound       = errors.New("wasm: import not found")
+	ErrImportSignature      = errors.New("wasm: import signature mismatch")
+	ErrExportNotFound       = errors.New("wasm: export not found")
+	ErrInvalidArguments     = errors.New("wasm: invalid arguments")
+	ErrSegmentOutOfBounds   = errors.New("wasm: segment out of bounds")
+	ErrNilGasCounter        = errors.New("wasm: gas counter is nil")
+	ErrInvalidHostFuncValue = errors.New("wasm: host function returned invalid values")
+)
+
+// HostFunction is a function provided by the embedder and imported by a
+// module. Arguments and results use the interpreter's value encoding:
+// i32 values are zero-extended and floats are stored as their bits.
+type HostFunction struct {
+	Type FuncType
+	Gas  uint64
+	Call func(in *Instance, args []uint64) ([]uint64, error)
+}
+
+// ImportResolver looks up the host function for a function import.
+type ImportResolver func(module string, name string) (*HostFunction, error)
+
+// trap aborts the execution; it is raised with panic and recovered at the
+// entry points of an Instance.
+type trap struct {
+	err error
+}
+
+// Instance is an instantiated module with its own memory, table and
+// globals. It is not safe for concurrent use.
+type Instance struct {
+	module *Module
+	hosts  []*HostFunction
+
+	memory   []byte
+	maxPages uint32
+	table    []int64
+	globals  []uint64
+
+	stack []uint64
+	depth int
+	gas   *uint64
+}
+
+// NewInstance instantiates the module, charging gas for the initial memory
+// and running the start function if there is one.
+func NewInstance(m *Module, resolve ImportResolver, gas *uint64) (inst *Instance, err error) {
+	if gas == nil {
+		return nil, ErrNilGasCounter
+	}
+
+	in := &Instance{module: m, gas: gas}
+	for _, imp := range m.Imports {
+		if imp.Kind != ExternalFunction {
+			continue
+		}
+		h, err := resolve(imp.Module, imp.Name)
+		if err != nil {
+			return nil, err
+		}
+		if h == nil {
+			return nil, ErrImportNotFound
+		}
+		if !h.Type.Equal(&m.Types[imp.TypeIndex]) {
+			return nil, ErrImportSignature
+		}
+		in.hosts = append(in.hosts, h)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			inst, err = nil, recoverTrap(r)
+		}
+	}()
+
+	if m.memory != nil {
+		in.useGas(uint64(m.memory.Min) * GasPerMemoryPage)
+		in.memory = make([]byte, int(m.memory.Min)*PageSize)
+		in.maxPages = MaxMemoryPages
+		if m.memory.HasMax && m.memory.Max < in.maxPages {
+			in.maxPages = m.memory.Max
+		}
+	}
+	if m.table != nil {
+		in.table = make([]int64, m.table.Min)
+		for i := range in.table {
+			in.table[i] = -1
+		}
+	}
+	in.globals = make([]uint64, len(m.globals))
+	for i, g := range m.globals {
+		in.globals[i] = g.Init
+	}
+	for _, e := range m.elements {
+		if uint64(e.offset)+uint64(len(e.funcs)) > uint64(len(in.table)) {
+			return nil, ErrSegmentOutOfBounds
+		}
+		for i, index := range e.funcs {
+			in.table[int(e.offset)+i] = int64(index)
+		}
+	}
+	for _, d := range m.data {
+		if uint64(d.offset)+uint64(len(d.data)) > uint64(len(in.memory)) {
+			return nil, ErrSegmentOutOfBounds
+		}
+		copy(in.memory[d.offset:], d.data)
+	}
+
+	if m.start != nil {
+		in.call(*m.start)
+	}
+	return in, nil
+}
+
+// Invoke calls an exported function and returns its results.
+func (in *Instance) Invoke(name string, args ...uint64) (results []uint64, err error) {
+	e, ok := in.module.Exports[name]
+	if !ok || e.Kind != ExternalFunction {
+		return nil, ErrExportNotFound
+	}
+	t := in.module.FuncType(e.Index)
+	if len(args) != len(t.Params) {
+		return nil, ErrInvalidArguments
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			results, err = nil, recoverTrap(r)
+		}
+		in.stack = in.stack[:0]
+		in.depth = 0
+	}()
+
+	in.stack = append(in.stack[:0], args...)
+	in.call(e.Index)
+	results = make([]uint64, len(t.Results))
+	copy(results, in.stack[len(in.stack)-len(t.Results):])
+	return results, nil
+}
+
+// HasExport reports whether the module exports a function with the name.
+func (in *Instance) HasExport(name string) bool {
+	e, ok := in.module.Exports[name]
+	return ok && e.Kind == ExternalFunction
+}
+
+// Memory returns the linear memory of the instance.
+func (in *Instance) Memory() []byte {
+	return in.memory
+}
+
+// ReadMemory returns a copy of length bytes at ptr.
+func (in *Instance) ReadMemory(ptr uint32, length uint32) ([]byte, error) {
+	if uint64(ptr)+uint64(length) > uint64(len(in.memory)) {
+		return nil, ErrMemoryOutOfBounds
+	}
+	data := make([]byte, length)
+	copy(data, in.memory[ptr:])
+	return data, nil
+}
+
+// WriteMemory copies data into the memory at ptr.
+func (in *Instance) WriteMemory(ptr uint32, data []byte) error {
+	if uint64(ptr)+uint64(len(data)) > uint64(len(in.memory)) {
+		return ErrMemoryOutOfBounds
+	}
+	copy(in.memory[ptr:], data)
+	return nil
+}
+
+// UseGas charges gas from the host side.
+func (in *Instance) UseGas(amount uint64) error {
+	if *in.gas < amount {
+		*in.gas = 0
+		return ErrOutOfGas
+	}
+	*in.gas -= amount
+	return nil
+}
+
+func (in *Instance) useGas(amount uint64) {
+	if err := in.UseGas(amount); err != nil {
+		panic(trap{err})
+	}
+}
+
+func recoverTrap(r interface{}) error {
+	switch e := r.(type) {
+	case trap:
+		return e.err
+	case runtime.Error:
+		// malformed but structurally valid code, e.g. a value stack underflow
+		return ErrInvalidExecution
+	}
+	panic(r)
+}
+
+// call runs the function at index with its arguments on top of the stack
+// and leaves its results there.
+func (in *Instance) call(index uint32) {
+	in.useGas(GasPerCall)
+	t := in.module.FuncType(index)
+	n := len(t.Params)
+	if n > len(in.stack) {
+		panic(trap{ErrInvalidExecution})
+	}
+
+	if int(index) < len(in.hosts) {
+		h := in.hosts[index]
+		in.useGas(h.Gas)
+		args := make([]uint64, n)
+		copy(args, in.stack[len(in.stack)-n:])
+		in.stack = in.stack[:len(in.stack)-n]
+		results, err := h.Call(in, args)
+		if err != nil {
+			panic(trap{err})
+		}
+		if len(results) != len(t.Results) {
+			panic(trap{ErrInvalidHostFuncValue})
+		}
+		in.stack = append(in.stack, results...)
+		return
+	}
+
+	if in.depth >= MaxCallDepth {
+		panic(trap{ErrCallStackExhausted})
+	}
+	if len(in.stack) > maxStackSize {
+		panic(trap{ErrValueStackExhausted})
+	}
+	f := in.module.functions[int(index)-len(in.hosts)]
+	locals := make([]uint64, n+len(f.locals))
+	copy(locals, in.stack[len(in.stack)-n:])
+	in.stack = in.stack[:len(in.stack)-n]
+
+	in.depth++
+	in.execute(f, locals)
+	in.depth--
+}
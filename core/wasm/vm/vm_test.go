@@ -0,0 +1,288 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// helpers to assemble binary modules by hand
+
+func uleb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(uleb(uint64(len(items))), cat(items...))
+}
+
+func name(s string) []byte {
+	return cat(uleb(uint64(len(s))), []byte(s))
+}
+
+func section(id byte, items ...[]byte) []byte {
+	payload := vec(items...)
+	return cat([]byte{id}, uleb(uint64(len(payload))), payload)
+}
+
+func functype(params []byte, results []byte) []byte {
+	return cat([]byte{funcTypeForm}, vec(splitBytes(params)...), vec(splitBytes(results)...))
+}
+
+func splitBytes(b []byte) [][]byte {
+	var out [][]byte
+	for i := range b {
+		out = append(out, b[i:i+1])
+	}
+	return out
+}
+
+func body(locals [][]byte, code ...[]byte) []byte {
+	b := cat(vec(locals...), cat(code...), []byte{opEnd})
+	return cat(uleb(uint64(len(b))), b)
+}
+
+func exportFunc(n string, index uint32) []byte {
+	return cat(name(n), []byte{ExternalFunction}, uleb(uint64(index)))
+}
+
+func module(sections ...[]byte) []byte {
+	return cat([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, cat(sections...))
+}
+
+func i32(v int32) []byte {
+	return cat([]byte{opI32Const}, sleb(int64(v)))
+}
+
+func i64(v int64) []byte {
+	return cat([]byte{opI64Const}, sleb(v))
+}
+
+func local(op byte, index uint32) []byte {
+	return cat([]byte{op}, uleb(uint64(index)))
+}
+
+var (
+	i32x1 = []byte{ValueTypeI32}
+	i64x1 = []byte{ValueTypeI64}
+)
+
+func instantiate(t *testing.T, code []byte, resolve ImportResolver, gas uint64) *Instance {
+	m, err := ReadModule(code)
+	if err != nil {
+		t.Fatalf("read module failed: %v", err)
+	}
+	if resolve == nil {
+		resolve = func(module string, name string) (*HostFunction, error) {
+			return nil, ErrImportNotFound
+		}
+	}
+	in, err := NewInstance(m, resolve, &gas)
+	if err != nil {
+		t.Fatalf("instantiate failed: %v", err)
+	}
+	return in
+}
+
+func TestArithmetic(t *testing.T) {
+	code := module(
+		section(sectionType, functype([]byte{ValueTypeI32, ValueTypeI32}, i32x1)),
+		section(sectionFunction, uleb(0), uleb(0)),
+		section(sectionExport, exportFunc("add", 0), exportFunc("div", 1)),
+		section(sectionCode,
+			body(nil, local(opLocalGet, 0), local(opLocalGet, 1), []byte{0x6a}),
+			body(nil, local(opLocalGet, 0), local(opLocalGet, 1), []byte{0x6d}),
+		),
+	)
+	in := instantiate(t, code, nil, 1000)
+
+	ret, err := in.Invoke("add", 0xffffffff, 2)
+	if err != nil || ret[0] != 1 {
+		t.Errorf("add: have %v %v, want 1", ret, err)
+	}
+	ret, err = in.Invoke("div", uint64(uint32(0xfffffff6)), 3) // -10 / 3
+	if err != nil || int32(ret[0]) != -3 {
+		t.Errorf("div: have %v %v, want -3", ret, err)
+	}
+	if _, err = in.Invoke("div", 1, 0); err != ErrIntegerDivideByZero {
+		t.Errorf("div by zero: have %v, want %v", err, ErrIntegerDivideByZero)
+	}
+	if _, err = in.Invoke("add", 1); err != ErrInvalidArguments {
+		t.Errorf("arguments: have %v, want %v", err, ErrInvalidArguments)
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	code := module(
+		section(sectionType, functype(i64x1, i64x1), functype(i32x1, i32x1)),
+		section(sectionFunction, uleb(0), uleb(0), uleb(1)),
+		section(sectionExport, exportFunc("fac", 0), exportFunc("sum", 1), exportFunc("switch", 2)),
+		section(sectionCode,
+			// fac(n) = n == 0 ? 1 : n * fac(n-1)
+			body(nil,
+				local(opLocalGet, 0), []byte{0x50, opIf, ValueTypeI64},
+				i64(1),
+				[]byte{opElse},
+				local(opLocalGet, 0), local(opLocalGet, 0), i64(1), []byte{0x7d, opCall, 0x00, 0x7e},
+				[]byte{opEnd},
+			),
+			// sum(n) = n + (n-1) + ... + 1 with a loop
+			body([][]byte{cat(uleb(1), i64x1)},
+				[]byte{opBlock, blockTypeEmpty, opLoop, blockTypeEmpty},
+				local(opLocalGet, 0), []byte{0x50, opBrIf, 0x01},
+				local(opLocalGet, 1), local(opLocalGet, 0), []byte{0x7c}, local(opLocalSet, 1),
+				local(opLocalGet, 0), i64(1), []byte{0x7d}, local(opLocalSet, 0),
+				[]byte{opBr, 0x00, opEnd, opEnd},
+				local(opLocalGet, 1),
+			),
+			// switch(i) maps 0, 1 and anything else to 10, 20 and 30
+			body(nil,
+				[]byte{opBlock, blockTypeEmpty, opBlock, blockTypeEmpty, opBlock, blockTypeEmpty},
+				local(opLocalGet, 0), []byte{opBrTable, 0x02, 0x00, 0x01, 0x02, opEnd},
+				i32(10), []byte{opReturn, opEnd},
+				i32(20), []byte{opReturn, opEnd},
+				i32(30),
+			),
+		),
+	)
+	in := instantiate(t, code, nil, 100000)
+
+	if ret, err := in.Invoke("fac", 10); err != nil || ret[0] != 3628800 {
+		t.Errorf("fac: have %v %v, want 3628800", ret, err)
+	}
+	if ret, err := in.Invoke("sum", 100); err != nil || ret[0] != 5050 {
+		t.Errorf("sum: have %v %v, want 5050", ret, err)
+	}
+	for i, want := range []uint64{10, 20, 30, 30} {
+		if ret, err := in.Invoke("switch", uint64(i)); err != nil || ret[0] != want {
+			t.Errorf("switch(%d): have %v %v, want %d", i, ret, err, want)
+		}
+	}
+}
+
+func TestMemoryAndHost(t *testing.T) {
+	var logged []byte
+	resolve := func(module string, field string) (*HostFunction, error) {
+		if module != "env" || field != "log" {
+			return nil, ErrImportNotFound
+		}
+		return &HostFunction{
+			Type: FuncType{Params: []byte{ValueTypeI32, ValueTypeI32}, Results: i32x1},
+			Gas:  5,
+			Call: func(in *Instance, args []uint64) ([]uint64, error) {
+				data, err := in.ReadMemory(uint32(args[0]), uint32(args[1]))
+				if err != nil {
+					return nil, err
+				}
+				logged = data
+				return []uint64{uint64(len(data))}, nil
+			},
+		}, nil
+	}
+
+	code := module(
+		section(sectionType, functype([]byte{ValueTypeI32, ValueTypeI32}, i32x1), functype(nil, i32x1)),
+		section(sectionImport, cat(name("env"), name("log"), []byte{ExternalFunction}, uleb(0))),
+		section(sectionFunction, uleb(1), uleb(1)),
+		section(sectionMemory, []byte{0x01, 0x01, 0x02}),
+		section(sectionExport, exportFunc("apply", 1), exportFunc("oob", 2)),
+		section(sectionCode,
+			// store "hi!" after the data segment and log 5 bytes from offset 8
+			body(nil,
+				i32(10), i32(0x216968), []byte{opI32Store, 0x02, 0x00},
+				i32(8), i32(5), []byte{opCall, 0x00},
+			),
+			body(nil, i32(PageSize*2-2), []byte{opI32Load, 0x02, 0x00}),
+		),
+		section(sectionData, cat(uleb(0), i32(8), []byte{opEnd}, name("ok"))),
+	)
+	in := instantiate(t, code, resolve, 100000)
+
+	ret, err := in.Invoke("apply")
+	if err != nil || ret[0] != 5 {
+		t.Fatalf("apply: have %v %v, want 5", ret, err)
+	}
+	if string(logged) != "okhi!" {
+		t.Errorf("logged: have %q, want %q", logged, "okhi!")
+	}
+	if _, err = in.Invoke("oob"); err != ErrMemoryOutOfBounds {
+		t.Errorf("oob: have %v, want %v", err, ErrMemoryOutOfBounds)
+	}
+}
+
+func TestOutOfGas(t *testing.T) {
+	code := module(
+		section(sectionType, functype(nil, nil)),
+		section(sectionFunction, uleb(0)),
+		section(sectionExport, exportFunc("spin", 0)),
+		section(sectionCode, body(nil, []byte{opLoop, blockTypeEmpty, opBr, 0x00, opEnd})),
+	)
+	m, err := ReadModule(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gas := uint64(1000)
+	in, err := NewInstance(m, nil, &gas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = in.Invoke("spin"); err != ErrOutOfGas {
+		t.Errorf("have %v, want %v", err, ErrOutOfGas)
+	}
+	if gas != 0 {
+		t.Errorf("remained gas: have %d, want 0", gas)
+	}
+}
+
+func TestInvalidModule(t *testing.T) {
+	tests := []struct {
+		code []byte
+		err  error
+	}{
+		{[]byte{0x00, 0x61, 0x73}, ErrUnexpectedEOF},
+		{[]byte{0x01, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, ErrInvalidMagic},
+		{module(section(sectionFunction, uleb(0))), ErrFunctionCount},
+		{module(
+			section(sectionType, functype(nil, nil)),
+			section(sectionFunction, uleb(0)),
+			section(sectionCode, body(nil, []byte{opBlock, blockTypeEmpty})),
+		), ErrInvalidBlock},
+		{module(
+			section(sectionType, functype(nil, nil)),
+			section(sectionFunction, uleb(0)),
+			section(sectionCode, body(nil, []byte{0xd0})),
+		), ErrInvalidOpcode},
+	}
+	for i, test := range tests {
+		if _, err := ReadModule(test.code); err != test.err {
+			t.Errorf("test %d: have %v, want %v", i, err, test.err)
+		}
+	}
+}
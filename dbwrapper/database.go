@@ -409,6 +409,27 @@ func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
 
+func (dt *table) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+	return &tableIterator{dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...)), dt.prefix}
+}
+
+// tableIterator strips the prefix of the table from the keys.
+type tableIterator struct {
+	iterator.Iterator
+	prefix string
+}
+
+func (it *tableIterator) Seek(key []byte) bool {
+	return it.Iterator.Seek(append([]byte(it.prefix), key...))
+}
+
+func (it *tableIterator) Key() []byte {
+	if key := it.Iterator.Key(); key != nil {
+		return key[len(it.prefix):]
+	}
+	return nil
+}
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
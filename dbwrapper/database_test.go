@@ -210,3 +210,46 @@ func testParallelPutGet(db dbwrapper.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_IteratePrefix(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIteratePrefix(db, t)
+}
+
+func TestMemoryDB_IteratePrefix(t *testing.T) {
+	testIteratePrefix(dbwrapper.NewMemDatabase(), t)
+}
+
+func TestTable_IteratePrefix(t *testing.T) {
+	db := dbwrapper.NewMemDatabase()
+	db.Put([]byte("pa1"), []byte("other table"))
+	testIteratePrefix(dbwrapper.NewTable(db, "t"), t)
+}
+
+func testIteratePrefix(db dbwrapper.Database, t *testing.T) {
+	for _, k := range []string{"b", "a3", "a1", "a", "a2", "c1"} {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+
+	it := db.NewIteratorWithPrefix([]byte("a"))
+	var keys []string
+	for it.Next() {
+		if string(it.Value()) != "v"+string(it.Key()) {
+			t.Fatalf("iterated wrong value %q for key %q", it.Value(), it.Key())
+		}
+		keys = append(keys, string(it.Key()))
+	}
+	it.Release()
+	if fmt.Sprint(keys) != "[a a1 a2 a3]" {
+		t.Fatalf("iterated wrong keys %v", keys)
+	}
+
+	it = db.NewIteratorWithPrefix([]byte("a"))
+	defer it.Release()
+	if !it.Seek([]byte("a15")) || string(it.Key()) != "a2" {
+		t.Fatalf("seek to wrong key %q", it.Key())
+	}
+}
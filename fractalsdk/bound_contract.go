@@ -0,0 +1,172 @@
+package fractalsdk
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/abi"
+)
+
+var (
+	ErrNoTxSender        = errors.New("The contract is bound without a tx sender")
+	ErrTransactionFailed = errors.New("The transaction failed")
+)
+
+// defaultTransactTimeout is how long Transact waits for the transaction to
+// be executed when no timeout is given.
+const defaultTransactTimeout = 60 * time.Second
+
+// TransactOpts are the options of the transactions sent to a bound contract.
+type TransactOpts struct {
+	Amount    *big.Int
+	GasLimit  uint64   // 0 estimates the gas
+	GasPrice  *big.Int // nil uses the suggested gas price
+	Broadcast bool
+	Timeout   time.Duration
+}
+
+// CallOpts are the options of the calls to a bound contract.
+type CallOpts struct {
+	From      string
+	Amount    *big.Int
+	GasLimit  uint64   // 0 uses the default gas of the node
+	GasPrice  *big.Int // nil uses the suggested gas price
+	Block     string   // the head block if empty
+	Overrides StateOverride
+}
+
+// BoundContract sends the actions to a contract and reads its tables, encoded
+// with the abi. It backs the bindings generated by gtool abigen.
+type BoundContract struct {
+	address string
+	reader  ChainReader
+	sender  TxSender
+}
+
+// NewBoundContract binds the contract at the address. The sender can be nil
+// if no transaction is sent.
+func NewBoundContract(address string, reader ChainReader, sender TxSender) *BoundContract {
+	if reader == nil && sender != nil {
+		reader = sender
+	}
+	return &BoundContract{
+		address: address,
+		reader:  reader,
+		sender:  sender,
+	}
+}
+
+// Address returns the address of the contract.
+func (c *BoundContract) Address() string {
+	return c.address
+}
+
+// ActionData returns the data of the message which calls the action with the
+// args.
+func ActionData(action string, args interface{}) ([]byte, error) {
+	name, err := utils.String2Uint64(action)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := abi.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 8, 8+len(encoded))
+	binary.LittleEndian.PutUint64(data, name) // use LittleEndian
+	return append(data, encoded...), nil
+}
+
+// Transact sends a transaction calling the action, and waits for it to be
+// executed.
+func (c *BoundContract) Transact(opts *TransactOpts, action string, args interface{}) (*TransactionDetails, error) {
+	if c.sender == nil {
+		return nil, ErrNoTxSender
+	}
+	data, err := ActionData(action, args)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &TransactOpts{}
+	}
+	amount := opts.Amount
+	if amount == nil {
+		amount = new(big.Int)
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultTransactTimeout
+	}
+
+	details, err := c.sender.SendTransactionSync(c.address, amount, opts.GasLimit, opts.GasPrice, data, opts.Broadcast, timeout)
+	if err != nil {
+		return details, err
+	}
+	if details.Receipt != nil && details.Receipt.Status == types.ReceiptStatusFailed {
+		return details, ErrTransactionFailed
+	}
+	return details, nil
+}
+
+// Call executes the action without sending a transaction, and returns the
+// logs of the execution.
+func (c *BoundContract) Call(opts *CallOpts, action string, args interface{}) (CallResult, error) {
+	data, err := ActionData(action, args)
+	if err != nil {
+		return CallResult{}, err
+	}
+	if opts == nil {
+		opts = &CallOpts{}
+	}
+	amount := opts.Amount
+	if amount == nil {
+		amount = new(big.Int)
+	}
+	block := opts.Block
+	if block == "" {
+		block = "latest"
+	}
+	return c.reader.Call(opts.From, c.address, amount, opts.GasLimit, opts.GasPrice, data, block, opts.Overrides)
+}
+
+// ReadRow reads the row of the table with the key in the state of the block
+// into the row pointer. It returns false if the row is not found.
+func (c *BoundContract) ReadRow(table string, key interface{}, row interface{}, blockFullHash string) (bool, error) {
+	encodedKey, err := abi.Marshal(key)
+	if err != nil {
+		return false, err
+	}
+	if blockFullHash == "" {
+		blockFullHash = "latest"
+	}
+	value, err := c.reader.GetStorage(c.address, table, hexutil.Encode(encodedKey), blockFullHash)
+	if err != nil {
+		return false, err
+	}
+	data, err := hexutil.Decode(value)
+	if err != nil || len(data) == 0 {
+		return false, err
+	}
+	return true, abi.Unmarshal(data, row)
+}
+
+// UnpackLog decodes the data of the log of the event into the value pointer.
+// It returns false if the log is not an event of the contract.
+func (c *BoundContract) UnpackLog(event string, log *Log, value interface{}) (bool, error) {
+	topic, err := abi.EventTopic(event)
+	if err != nil {
+		return false, err
+	}
+	if common.HexToAddress(log.Address) != common.HexToAddress(c.address) ||
+		len(log.Topics) == 0 || common.HexToHash(log.Topics[0]) != topic {
+		return false, nil
+	}
+	return true, abi.Unmarshal(log.Data, value)
+}
@@ -2,6 +2,7 @@ package fractalsdk
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"reflect"
@@ -138,6 +139,42 @@ func (c *chainReader) GetStorage(address string, table string, key string, block
 	return storage, err
 }
 
+// GetTableRow returns the row of the contract table with the key in the state
+// of the block, decoded into JSON by the node with the abi of the contract.
+// The key is encoded as the key type of the table. It returns nil if the row
+// is not found.
+func (c *chainReader) GetTableRow(contractAddr string, table string, key interface{}, blockFullHash string) (json.RawMessage, error) {
+	var row json.RawMessage
+	err := c.call(&row, "ftl_getTableRow", contractAddr, table, key, blockFullHash)
+	if string(row) == "null" {
+		return nil, err
+	}
+	return row, err
+}
+
+// GetAbi returns the abi of the contract set in the abi registry in the state
+// of the block. It returns nil if no abi is set.
+func (c *chainReader) GetAbi(contractAddr string, blockFullHash string) (json.RawMessage, error) {
+	var abi json.RawMessage
+	err := c.call(&abi, "ftl_getAbi", contractAddr, blockFullHash)
+	if string(abi) == "null" {
+		return nil, err
+	}
+	return abi, err
+}
+
+// GetProof returns the merkle proof of the account and of the storage keys in
+// the state of the block, which can be checked with VerifyProof.
+func (c *chainReader) GetProof(address string, keys []StorageKey, blockFullHash string) (*AccountProof, error) {
+	args := make([]api.StorageKeyArgs, len(keys))
+	for i, key := range keys {
+		args[i] = api.StorageKeyArgs{Table: key.Table, Key: key.Key}
+	}
+	var proof *AccountProof
+	err := c.call(&proof, "ftl_getProof", address, args, blockFullHash)
+	return proof, err
+}
+
 func (c *chainReader) GetContractOwner(contractAddr string) (string, error) {
 	var owner string
 	err := c.call(&owner, "ftl_getContractOwner", contractAddr)
@@ -162,6 +199,12 @@ func (c *chainReader) GetHeadBlock() (*Block, error) {
 	return block, err
 }
 
+func (c *chainReader) GetFinalizedBlock() (*Block, error) {
+	var block *Block
+	err := c.call(&block, "ftl_getFinalizedBlock")
+	return block, err
+}
+
 func (c *chainReader) GetBlockByHeight(height uint64) (*Block, error) {
 	var block *Block
 	err := c.call(&block, "ftl_getBlockByHeight", hexutil.Uint64(height))
@@ -213,11 +256,25 @@ func (c *chainReader) GetTransactionByHash(hash string) (*TransactionDetails, er
 	return transactionDetails, err
 }
 
+func (c *chainReader) GetTransactionReceipt(hash string) (*ReceiptDetails, error) {
+	var receiptDetails *ReceiptDetails
+	err := c.call(&receiptDetails, "ftl_getTransactionReceipt", hash)
+	return receiptDetails, err
+}
+
 func (c *chainReader) SubNewBlock(unsubscribe <-chan struct{}, blockCh chan *Block) error {
 	return c.subscribe(unsubscribe, "ftl", blockCh, "subNewBlock")
 }
 
-func (c *chainReader) Call(from string, to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) (CallResult, error) {
+func (c *chainReader) SubFinalizedBlock(unsubscribe <-chan struct{}, blockCh chan *Block) error {
+	return c.subscribe(unsubscribe, "ftl", blockCh, "subFinalizedBlock")
+}
+
+// Call executes the transaction on top of the given block without sending it.
+// The block is "latest", a block full hash or a block height, and the
+// overrides replace account fields in the state of the block for this call
+// only.
+func (c *chainReader) Call(from string, to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, block string, overrides StateOverride) (CallResult, error) {
 	var (
 		args   api.SendTxArgs
 		result CallResult
@@ -231,12 +288,92 @@ func (c *chainReader) Call(from string, to string, amount *big.Int, gasLimit uin
 		toAddr := common.HexToAddress(to)
 		args.To = &toAddr
 	}
-	args.Gas = (*hexutil.Uint64)(&gasLimit)
+	if gasLimit != 0 {
+		// the node's default gas otherwise
+		args.Gas = (*hexutil.Uint64)(&gasLimit)
+	}
 	args.GasPrice = (*hexutil.Big)(gasPrice)
-	args.Value = (*hexutil.Big)(gasPrice)
+	args.Value = (*hexutil.Big)(amount)
 	args.Nonce = (*hexutil.Uint64)(&nonce)
 	args.Data = (*hexutil.Bytes)(&data)
 
-	err := c.call(&result, "txpool_call", args)
+	if block == "" {
+		block = "latest"
+	}
+	stateOverride := make(api.StateOverride, len(overrides))
+	for addr, account := range overrides {
+		var override api.AccountOverride
+		if account.Balance != nil {
+			override.Balance = (*hexutil.Big)(account.Balance)
+		}
+		if account.Code != nil {
+			code := hexutil.Bytes(account.Code)
+			override.Code = &code
+		}
+		if account.Owner != "" {
+			owner := common.HexToAddress(account.Owner)
+			override.Owner = &owner
+		}
+		for _, storage := range account.Storage {
+			override.Storage = append(override.Storage, api.StorageOverride{Table: storage.Table, Key: storage.Key, Value: storage.Value})
+		}
+		stateOverride[common.HexToAddress(addr)] = override
+	}
+
+	err := c.call(&result, "txpool_call", args, block, stateOverride)
 	return result, err
 }
+
+// EstimateGas returns the smallest gas limit for which the transaction
+// executes successfully on top of the current block.
+func (c *chainReader) EstimateGas(from string, to string, amount *big.Int, gasPrice *big.Int, data []byte) (uint64, error) {
+	var (
+		args   api.SendTxArgs
+		result hexutil.Uint64
+	)
+
+	args.From = common.HexToAddress(from)
+	if to != "" {
+		toAddr := common.HexToAddress(to)
+		args.To = &toAddr
+	}
+	args.GasPrice = (*hexutil.Big)(gasPrice)
+	args.Value = (*hexutil.Big)(amount)
+	args.Data = (*hexutil.Bytes)(&data)
+
+	err := c.call(&result, "ftl_estimateGas", args)
+	return uint64(result), err
+}
+
+// GasPrice returns the gas price suggested by the node from the transactions
+// of the recent main branch blocks.
+func (c *chainReader) GasPrice() (*big.Int, error) {
+	var price *hexutil.Big
+	err := c.call(&price, "ftl_gasPrice")
+	if err != nil {
+		return nil, err
+	}
+	return (*big.Int)(price), nil
+}
+
+// FeeHistory returns the gas prices at the percentiles of the transactions in
+// each of the last blocks of the main branch, from the oldest to the head.
+func (c *chainReader) FeeHistory(blocks uint64, percentiles []float64) (*FeeHistory, error) {
+	var result *api.FeeHistoryResult
+	err := c.call(&result, "ftl_feeHistory", hexutil.Uint64(blocks), percentiles)
+	if err != nil {
+		return nil, err
+	}
+	history := &FeeHistory{
+		OldestBlock:  uint64(result.OldestBlock),
+		GasUsedRatio: result.GasUsedRatio,
+		Reward:       make([][]*big.Int, len(result.Reward)),
+	}
+	for i, rewards := range result.Reward {
+		history.Reward[i] = make([]*big.Int, len(rewards))
+		for j, reward := range rewards {
+			history.Reward[i][j] = (*big.Int)(reward)
+		}
+	}
+	return history, nil
+}
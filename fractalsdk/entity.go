@@ -2,6 +2,8 @@ package fractalsdk
 
 import (
 	"math/big"
+
+	"github.com/fractal-platform/fractal/core/nonces"
 )
 
 type Block struct {
@@ -65,6 +67,17 @@ type Log struct {
 	PkgIndex    uint32
 	TxIndex     uint32
 	Index       uint32
+	Removed     bool // the block of the log is reverted by a reorg
+}
+
+// FilterCriteria selects the logs of GetLogs and SubscribeLogs.
+type FilterCriteria struct {
+	BlockHash       string     // the logs of this block only, if set
+	FromBlockHeight *uint64    // the genesis block if nil
+	ToBlockHeight   *uint64    // the head block if nil
+	BlockTag        string     // "finalized" keeps the logs of the finalized blocks only
+	Addresses       []string   // the contracts creating the logs, any contract if empty
+	Topics          [][]string // the topics allowed at each position, any topic if empty
 }
 
 type Receipt struct {
@@ -78,6 +91,20 @@ type Receipt struct {
 	GasUsed           uint64
 }
 
+type ReceiptDetails struct {
+	BlockHash         string
+	BlockHeight       uint64
+	PackageHash       string
+	TxPackageIndex    uint32
+	TxIndex           uint32
+	TxHash            string
+	Status            uint64
+	GasUsed           uint64
+	CumulativeGasUsed uint64
+	Logs              []*Log
+	ContractAddress   string
+}
+
 type TransactionDetails struct {
 	From      string
 	Hash      string
@@ -100,6 +127,54 @@ type CallResult struct {
 	GasUsed uint64
 }
 
+type StorageOverride struct {
+	Table string
+	Key   []byte
+	Value []byte
+}
+
+type AccountOverride struct {
+	Balance *big.Int
+	Code    []byte
+	Owner   string
+	Storage []StorageOverride
+}
+
+// StateOverride maps account addresses to the fields replaced for a call.
+type StateOverride map[string]*AccountOverride
+
+type StorageKey struct {
+	Table string
+	Key   []byte
+}
+
+type StorageProof struct {
+	Table string
+	Key   []byte
+	Value []byte
+	Proof [][]byte
+}
+
+type AccountProof struct {
+	Address         string
+	TxNonceSet      nonces.NonceSet
+	PackageNonceSet nonces.NonceSet
+	Balance         *big.Int
+	StorageHash     string
+	CodeHash        string
+	ContractOwner   string
+	AccountProof    [][]byte
+	StorageProof    []StorageProof
+}
+
+// FeeHistory is the gas prices at the queried percentiles of the transactions
+// in each block of a range of main branch blocks.
+type FeeHistory struct {
+	OldestBlock  uint64
+	GasUsedRatio []float64
+	Reward       [][]*big.Int
+}
+
 type ExecResult struct {
 	TxDetails *TransactionDetails
 	Err       error
@@ -7,6 +7,7 @@ import (
 
 	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/nonces"
 	"github.com/fractal-platform/fractal/core/types"
 )
 
@@ -257,6 +258,7 @@ func (l *Log) UnmarshalJSON(input []byte) error {
 		PkgIndex    *uint32         `json:"packageIndex"`
 		TxIndex     *uint32         `json:"transactionIndex"`
 		Index       *uint32         `json:"logIndex"`
+		Removed     *bool           `json:"removed"`
 	}
 	var dec Log
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -297,6 +299,9 @@ func (l *Log) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'logIndex' for Log")
 	}
 	l.Index = *dec.Index
+	if dec.Removed != nil {
+		l.Removed = *dec.Removed
+	}
 	return nil
 }
 
@@ -349,6 +354,62 @@ func (r *Receipt) UnmarshalJSON(input []byte) error {
 	return nil
 }
 
+func (r *ReceiptDetails) UnmarshalJSON(input []byte) error {
+	type RPCReceipt struct {
+		BlockHash         *common.Hash    `json:"blockHash"`
+		BlockHeight       *hexutil.Uint64 `json:"blockHeight"`
+		PackageHash       *common.Hash    `json:"packageHash"`
+		TxPackageIndex    *hexutil.Uint64 `json:"txPackageIndex"`
+		TxIndex           *hexutil.Uint64 `json:"transactionIndex"`
+		TxHash            *common.Hash    `json:"transactionHash"`
+		Status            *hexutil.Uint64 `json:"status"`
+		GasUsed           *hexutil.Uint64 `json:"gasUsed"`
+		CumulativeGasUsed *hexutil.Uint64 `json:"cumulativeGasUsed"`
+		Logs              []*Log          `json:"logs"`
+		ContractAddress   *common.Address `json:"contractAddress"`
+	}
+	var dec RPCReceipt
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.BlockHash == nil {
+		return errors.New("missing required field 'blockHash' for ReceiptDetails")
+	}
+	r.BlockHash = dec.BlockHash.String()
+	if dec.BlockHeight == nil {
+		return errors.New("missing required field 'blockHeight' for ReceiptDetails")
+	}
+	r.BlockHeight = uint64(*dec.BlockHeight)
+	if dec.PackageHash != nil {
+		r.PackageHash = dec.PackageHash.String()
+	}
+	if dec.TxPackageIndex != nil {
+		r.TxPackageIndex = uint32(*dec.TxPackageIndex)
+	}
+	if dec.TxIndex != nil {
+		r.TxIndex = uint32(*dec.TxIndex)
+	}
+	if dec.TxHash == nil {
+		return errors.New("missing required field 'transactionHash' for ReceiptDetails")
+	}
+	r.TxHash = dec.TxHash.String()
+	if dec.Status != nil {
+		r.Status = uint64(*dec.Status)
+	}
+	if dec.GasUsed == nil {
+		return errors.New("missing required field 'gasUsed' for ReceiptDetails")
+	}
+	r.GasUsed = uint64(*dec.GasUsed)
+	if dec.CumulativeGasUsed != nil {
+		r.CumulativeGasUsed = uint64(*dec.CumulativeGasUsed)
+	}
+	r.Logs = dec.Logs
+	if dec.ContractAddress != nil {
+		r.ContractAddress = dec.ContractAddress.String()
+	}
+	return nil
+}
+
 func (t *TransactionDetails) UnmarshalJSON(input []byte) error {
 	type RPCTransaction struct {
 		From      *common.Address `json:"from"`
@@ -432,3 +493,73 @@ func (c *CallResult) UnmarshalJSON(input []byte) error {
 	}
 	return nil
 }
+
+func (p *AccountProof) UnmarshalJSON(input []byte) error {
+	type storageProof struct {
+		Table *string         `json:"table"`
+		Key   *hexutil.Bytes  `json:"key"`
+		Value hexutil.Bytes   `json:"value"`
+		Proof []hexutil.Bytes `json:"proof"`
+	}
+	type AccountProof struct {
+		Address         *common.Address  `json:"address"`
+		TxNonceSet      *nonces.NonceSet `json:"txNonceSet"`
+		PackageNonceSet *nonces.NonceSet `json:"packageNonceSet"`
+		Balance         *hexutil.Big     `json:"balance"`
+		StorageHash     *common.Hash     `json:"storageHash"`
+		CodeHash        *common.Hash     `json:"codeHash"`
+		ContractOwner   *common.Address  `json:"contractOwner"`
+		AccountProof    []hexutil.Bytes  `json:"accountProof"`
+		StorageProof    []storageProof   `json:"storageProof"`
+	}
+	var dec AccountProof
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Address == nil {
+		return errors.New("missing required field 'address' for AccountProof")
+	}
+	p.Address = dec.Address.String()
+	if dec.TxNonceSet != nil {
+		p.TxNonceSet = *dec.TxNonceSet
+	}
+	if dec.PackageNonceSet != nil {
+		p.PackageNonceSet = *dec.PackageNonceSet
+	}
+	if dec.Balance == nil {
+		return errors.New("missing required field 'balance' for AccountProof")
+	}
+	p.Balance = (*big.Int)(dec.Balance)
+	if dec.StorageHash == nil {
+		return errors.New("missing required field 'storageHash' for AccountProof")
+	}
+	p.StorageHash = dec.StorageHash.String()
+	if dec.CodeHash == nil {
+		return errors.New("missing required field 'codeHash' for AccountProof")
+	}
+	p.CodeHash = dec.CodeHash.String()
+	if dec.ContractOwner != nil {
+		p.ContractOwner = dec.ContractOwner.String()
+	}
+	if dec.AccountProof == nil {
+		return errors.New("missing required field 'accountProof' for AccountProof")
+	}
+	p.AccountProof = make([][]byte, len(dec.AccountProof))
+	for i := range dec.AccountProof {
+		p.AccountProof[i] = dec.AccountProof[i]
+	}
+	p.StorageProof = make([]StorageProof, len(dec.StorageProof))
+	for i, storage := range dec.StorageProof {
+		if storage.Table == nil || storage.Key == nil {
+			return errors.New("missing required field 'table' or 'key' for StorageProof")
+		}
+		p.StorageProof[i].Table = *storage.Table
+		p.StorageProof[i].Key = *storage.Key
+		p.StorageProof[i].Value = storage.Value
+		p.StorageProof[i].Proof = make([][]byte, len(storage.Proof))
+		for j := range storage.Proof {
+			p.StorageProof[i].Proof[j] = storage.Proof[j]
+		}
+	}
+	return nil
+}
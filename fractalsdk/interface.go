@@ -1,11 +1,14 @@
 package fractalsdk
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"math/big"
 	"time"
 
 	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/crypto"
 	"github.com/fractal-platform/fractal/keys"
 )
 
@@ -19,6 +22,8 @@ const (
 var (
 	ErrNotDial                = errors.New("Should dial first")
 	ErrTransactionNotExecuted = errors.New("The transaction is not executed")
+	ErrProofAccountMismatch   = errors.New("The account doesn't match the proof")
+	ErrProofStorageMismatch   = errors.New("The storage value doesn't match the proof")
 )
 
 type Logger interface {
@@ -37,9 +42,13 @@ type ChainReader interface {
 	GetCurrentStorage(address string, table string, key string) (string, error)
 	GetStorage(address string, table string, key string, blockFullHash string) (string, error)
 	GetContractOwner(contractAddr string) (string, error)
+	GetProof(address string, keys []StorageKey, blockFullHash string) (*AccountProof, error)
+	GetTableRow(contractAddr string, table string, key interface{}, blockFullHash string) (json.RawMessage, error)
+	GetAbi(contractAddr string, blockFullHash string) (json.RawMessage, error)
 	GetGenesis() (*Block, error)
 	GetBlock(blockFullHash string) (*Block, error)
 	GetHeadBlock() (*Block, error)
+	GetFinalizedBlock() (*Block, error)
 	GetBlockByHeight(height uint64) (*Block, error)
 	GetBackwardBlocks(blockFullHash string, count uint32) ([]*Block, error)
 	GetAncestorBlocks(blockFullHash string, count uint32) ([]*Block, error)
@@ -48,9 +57,16 @@ type ChainReader interface {
 	GetTxPackageByHash(pkgHash string) (*TxPackage, error)
 	GetTransactionNonce(address string) (uint64, error)
 	GetTransactionByHash(hash string) (*TransactionDetails, error)
-	Call(from string, to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) (CallResult, error)
+	GetTransactionReceipt(hash string) (*ReceiptDetails, error)
+	Call(from string, to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, block string, overrides StateOverride) (CallResult, error)
+	EstimateGas(from string, to string, amount *big.Int, gasPrice *big.Int, data []byte) (uint64, error)
+	GasPrice() (*big.Int, error)
+	FeeHistory(blocks uint64, percentiles []float64) (*FeeHistory, error)
+	GetLogs(crit FilterCriteria) ([]*Log, error)
 
 	SubNewBlock(unsubscribe <-chan struct{}, blockCh chan *Block) error
+	SubFinalizedBlock(unsubscribe <-chan struct{}, blockCh chan *Block) error
+	SubscribeLogs(ctx context.Context, crit FilterCriteria, stableDistance uint64, logCh chan<- *Log) error
 }
 
 type TxSender interface {
@@ -69,6 +85,16 @@ func NewChainReader(logger Logger) ChainReader {
 }
 
 func NewTxSender(logger Logger, chainId uint64, signerType SignerTypeEnum, priKeyPath string, password string) (TxSender, error) {
+	account, err := keys.LoadAccountKey(priKeyPath, password)
+	if err != nil {
+		return nil, err
+	}
+	return NewTxSenderWithKey(logger, chainId, signerType, account.PrivKey), nil
+}
+
+// NewTxSenderWithKey creates a tx sender which signs the transactions with the
+// private key, instead of a key file.
+func NewTxSenderWithKey(logger Logger, chainId uint64, signerType SignerTypeEnum, priKey crypto.PrivateKey) TxSender {
 	t := &txSender{
 		chainReader: &chainReader{
 			logger: logger,
@@ -84,11 +110,7 @@ func NewTxSender(logger Logger, chainId uint64, signerType SignerTypeEnum, priKe
 		t.signer = types.NewEIP155Signer(chainId)
 	}
 
-	account, err := keys.LoadAccountKey(priKeyPath, password)
-	if err != nil {
-		return nil, err
-	}
-	t.accountPriKey = account.PrivKey
-	t.accountAddr = account.Address.String()
-	return t, nil
+	t.accountPriKey = priKey
+	t.accountAddr = priKey.Public().ToAddress().String()
+	return t
 }
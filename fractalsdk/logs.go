@@ -0,0 +1,206 @@
+package fractalsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/ftl/api"
+	"github.com/fractal-platform/fractal/utils/abi"
+)
+
+// resubscribeInterval is the wait before subscribing the logs again after
+// the subscription is lost.
+const resubscribeInterval = 2 * time.Second
+
+func (crit FilterCriteria) toApi() api.FilterCriteria {
+	var args api.FilterCriteria
+	if crit.BlockHash != "" {
+		hash := common.HexToHash(crit.BlockHash)
+		args.BlockHash = &hash
+	}
+	if crit.FromBlockHeight != nil {
+		args.FromBlockHeight = (*hexutil.Big)(new(big.Int).SetUint64(*crit.FromBlockHeight))
+	}
+	if crit.ToBlockHeight != nil {
+		args.ToBlockHeight = (*hexutil.Big)(new(big.Int).SetUint64(*crit.ToBlockHeight))
+	}
+	args.BlockTag = crit.BlockTag
+	for _, addr := range crit.Addresses {
+		args.Addresses = append(args.Addresses, common.HexToAddress(addr))
+	}
+	for _, topics := range crit.Topics {
+		var hashes []common.Hash
+		for _, topic := range topics {
+			hashes = append(hashes, common.HexToHash(topic))
+		}
+		args.Topics = append(args.Topics, hashes)
+	}
+	return args
+}
+
+// GetLogs returns the logs matching the criteria in the main branch.
+func (c *chainReader) GetLogs(crit FilterCriteria) ([]*Log, error) {
+	var logs []*Log
+	err := c.call(&logs, "ftl_getLogs", crit.toApi())
+	return logs, err
+}
+
+// SubscribeLogs sends the logs matching the criteria to the channel, once
+// their blocks are stableDistance blocks below the head block, until the
+// context is done. The logs in the blocks since FromBlockHeight are sent
+// first. If the connection is lost, the logs are subscribed again from the
+// height of the last log sent, so that no log is missed or sent twice. The
+// logs reverted by a reorg are sent again with Removed set, but a reorg
+// while the connection is lost is not seen.
+func (c *chainReader) SubscribeLogs(ctx context.Context, crit FilterCriteria, stableDistance uint64, logCh chan<- *Log) error {
+	if c.wsConn == nil {
+		return ErrNotDial
+	}
+
+	go func() {
+		var cursor logCursor
+		for {
+			args := crit.toApi()
+			if cursor.started {
+				args.FromBlockHeight = (*hexutil.Big)(new(big.Int).SetUint64(cursor.height))
+			}
+			logsCh := make(chan []*Log)
+			subscribe, err := c.wsConn.Subscribe(ctx, "ftl", logsCh, "subLogs", args, stableDistance)
+			if err != nil {
+				c.error(fmt.Sprintf("Log subscription error: %s", err.Error()))
+			} else {
+				c.info(fmt.Sprintf("Log subscription success, from height %d", cursor.height))
+				err = c.forwardLogs(ctx, &cursor, logsCh, subscribe.Err(), logCh)
+				subscribe.Unsubscribe()
+				if err == nil {
+					c.info("Log subscription stopped")
+					return
+				}
+				c.error(fmt.Sprintf("Log subscription connection lost: %s", err.Error()))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeInterval):
+			}
+		}
+	}()
+	return nil
+}
+
+// forwardLogs sends the new logs of the subscription to the channel. It
+// returns nil when the context is done, or the error of the subscription.
+func (c *chainReader) forwardLogs(ctx context.Context, cursor *logCursor, logsCh <-chan []*Log, errCh <-chan error, logCh chan<- *Log) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errCh:
+			return err
+		case logs := <-logsCh:
+			for _, log := range logs {
+				if !cursor.next(log) {
+					continue
+				}
+				select {
+				case logCh <- log:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
+
+type logKey struct {
+	txHash   string
+	pkgIndex uint32
+	txIndex  uint32
+	index    uint32
+}
+
+// logCursor is the position of a log subscription: the height of the last
+// log sent, and the logs sent at that height.
+type logCursor struct {
+	started bool
+	height  uint64
+	sent    map[logKey]bool
+}
+
+// next returns whether the log is new to the subscription, and moves the
+// cursor past it.
+func (c *logCursor) next(log *Log) bool {
+	if log.Removed {
+		// the logs from the reverted height on are sent again by the new branch
+		if c.started && log.BlockNumber <= c.height {
+			c.height = log.BlockNumber
+			c.sent = make(map[logKey]bool)
+		}
+		return true
+	}
+
+	key := logKey{log.TxHash, log.PkgIndex, log.TxIndex, log.Index}
+	if c.started {
+		if log.BlockNumber < c.height {
+			return false
+		}
+		if log.BlockNumber == c.height {
+			if c.sent[key] {
+				return false
+			}
+			c.sent[key] = true
+			return true
+		}
+	}
+	c.started = true
+	c.height = log.BlockNumber
+	c.sent = map[logKey]bool{key: true}
+	return true
+}
+
+// DecodedLog is a log with its data decoded by the abi of the contract.
+type DecodedLog struct {
+	*Log
+	Event string          // the name of the event
+	Value json.RawMessage // the data of the log in JSON
+}
+
+// LogDecoder decodes the logs of the events of a contract with its abi.
+type LogDecoder struct {
+	address    common.Address
+	serializer *abi.AbiSerializer
+}
+
+func NewLogDecoder(contractAddr string, abiDef string) (*LogDecoder, error) {
+	serializer, err := abi.NewAbiSerializer(abiDef)
+	if err != nil {
+		return nil, err
+	}
+	return &LogDecoder{address: common.HexToAddress(contractAddr), serializer: serializer}, nil
+}
+
+// Decode decodes the data of the log by the type of its event. It returns nil
+// if the log is not an event of the contract, and an error if the event may
+// be one the abi has with an invalid name.
+func (d *LogDecoder) Decode(log *Log) (*DecodedLog, error) {
+	if common.HexToAddress(log.Address) != d.address || len(log.Topics) == 0 {
+		return nil, nil
+	}
+	event, err := d.serializer.GetEvent(common.HexToHash(log.Topics[0]))
+	if err == abi.ErrUnknownEvent {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	value, err := d.serializer.Deserialize(event.Type, log.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedLog{Log: log, Event: event.Name, Value: value}, nil
+}
@@ -0,0 +1,57 @@
+package fractalsdk
+
+import (
+	"bytes"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/nonces"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/utils"
+)
+
+// VerifyProof checks the proof returned by GetProof against the StateHash of
+// the block it was requested for: the account proof must prove the account
+// fields, and every storage proof must prove its value under the storage hash.
+func VerifyProof(stateHash string, proof *AccountProof) error {
+	account, err := state.VerifyProof(common.HexToHash(stateHash), common.HexToAddress(proof.Address), proof.AccountProof)
+	if err != nil {
+		return err
+	}
+
+	var storageHash common.Hash
+	if account == nil {
+		// the proof proves the absence of the account
+		if proof.Balance.Sign() != 0 || common.HexToHash(proof.StorageHash) != (common.Hash{}) || common.HexToHash(proof.CodeHash) != (common.Hash{}) {
+			return ErrProofAccountMismatch
+		}
+	} else {
+		if account.Balance.Cmp(proof.Balance) != 0 ||
+			account.Root != common.HexToHash(proof.StorageHash) ||
+			common.BytesToHash(account.CodeHash) != common.HexToHash(proof.CodeHash) ||
+			account.ContractOwner != common.HexToAddress(proof.ContractOwner) ||
+			!equalNonceSet(&account.TxNonceSet, &proof.TxNonceSet) ||
+			!equalNonceSet(&account.PackageNonceSet, &proof.PackageNonceSet) {
+			return ErrProofAccountMismatch
+		}
+		storageHash = account.Root
+	}
+
+	for _, storage := range proof.StorageProof {
+		table, err := utils.String2Uint64(storage.Table)
+		if err != nil {
+			return err
+		}
+		value, err := state.VerifyStorageProof(storageHash, state.GetStorageKey(table, storage.Key), storage.Proof)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(value, storage.Value) {
+			return ErrProofStorageMismatch
+		}
+	}
+	return nil
+}
+
+func equalNonceSet(a *nonces.NonceSet, b *nonces.NonceSet) bool {
+	return a.Start == b.Start && a.Length == b.Length && bytes.Equal(a.BitMask, b.BitMask)
+}
@@ -0,0 +1,97 @@
+package fractalsdk_test
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/fractalsdk"
+	"github.com/fractal-platform/fractal/fractalsdk/simulated"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVerifyProof(t *testing.T) {
+	Convey("the proof of a contract with storage", t, func() {
+		_, key, err := crypto.NewKeys(crypto.ECDSA)
+		So(err, ShouldBeNil)
+		b, err := simulated.NewBackend(config.GenesisAlloc{key.Public().ToAddress(): {Balance: big.NewInt(1e18)}}, key)
+		So(err, ShouldBeNil)
+		Reset(b.Close)
+
+		// the echo contract stores the args of the set action as the row 1
+		code, err := ioutil.ReadFile("simulated/testdata/echo.wasm")
+		So(err, ShouldBeNil)
+		details, err := b.SendTransactionSync("", new(big.Int), 0, nil, code, true, 0)
+		So(err, ShouldBeNil)
+		So(details.Receipt.Status, ShouldEqual, types.ReceiptStatusSuccessful)
+		contract := details.Receipt.ContractAddress
+		data, err := fractalsdk.ActionData("set", struct {
+			Id   uint32
+			Name string
+		}{1, "one"})
+		So(err, ShouldBeNil)
+		details, err = b.SendTransactionSync(contract, big.NewInt(100), 0, nil, data, true, 0)
+		So(err, ShouldBeNil)
+		So(details.Receipt.Status, ShouldEqual, types.ReceiptStatusSuccessful)
+
+		head, err := b.GetHeadBlock()
+		So(err, ShouldBeNil)
+		keys := []fractalsdk.StorageKey{{Table: "rows", Key: []byte{1}}, {Table: "rows", Key: []byte{2}}}
+		proof, err := b.GetProof(contract, keys, head.FullHash)
+		So(err, ShouldBeNil)
+		So(proof.Balance.Int64(), ShouldEqual, 100)
+		So(proof.StorageProof, ShouldHaveLength, 2)
+		So(proof.StorageProof[0].Value, ShouldNotBeEmpty)
+		So(proof.StorageProof[1].Value, ShouldBeEmpty)
+
+		Convey("is verified against the state hash of the block", func() {
+			So(fractalsdk.VerifyProof(head.StateHash, proof), ShouldBeNil)
+		})
+
+		Convey("is not verified against another state hash", func() {
+			genesis, err := b.GetGenesis()
+			So(err, ShouldBeNil)
+			So(fractalsdk.VerifyProof(genesis.StateHash, proof), ShouldNotBeNil)
+		})
+
+		Convey("is not verified if an account field is changed", func() {
+			proof.Balance = big.NewInt(101)
+			So(fractalsdk.VerifyProof(head.StateHash, proof), ShouldEqual, fractalsdk.ErrProofAccountMismatch)
+		})
+
+		Convey("is not verified if a storage value is changed", func() {
+			proof.StorageProof[1].Value = []byte{1}
+			So(fractalsdk.VerifyProof(head.StateHash, proof), ShouldEqual, fractalsdk.ErrProofStorageMismatch)
+		})
+
+		Convey("is not verified if a proof node is tampered", func() {
+			node := proof.StorageProof[0].Proof[0]
+			node[len(node)-1] ^= 0xff
+			So(fractalsdk.VerifyProof(head.StateHash, proof), ShouldNotBeNil)
+		})
+	})
+
+	Convey("the proof of an absent account is verified", t, func() {
+		_, key, err := crypto.NewKeys(crypto.ECDSA)
+		So(err, ShouldBeNil)
+		b, err := simulated.NewBackend(config.GenesisAlloc{key.Public().ToAddress(): {Balance: big.NewInt(1e18)}}, key)
+		So(err, ShouldBeNil)
+		Reset(b.Close)
+
+		head, err := b.GetHeadBlock()
+		So(err, ShouldBeNil)
+		absent := common.HexToAddress("0x0303").String()
+		proof, err := b.GetProof(absent, []fractalsdk.StorageKey{{Table: "rows", Key: []byte{1}}}, head.FullHash)
+		So(err, ShouldBeNil)
+		So(fractalsdk.VerifyProof(head.StateHash, proof), ShouldBeNil)
+
+		Convey("but not if a balance is claimed", func() {
+			proof.Balance = big.NewInt(1)
+			So(fractalsdk.VerifyProof(head.StateHash, proof), ShouldEqual, fractalsdk.ErrProofAccountMismatch)
+		})
+	})
+}
@@ -0,0 +1,381 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package simulated implements a fractalsdk backend on an in-memory chain, so
+// that the applications using the sdk are tested without a running node.
+package simulated
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fractal-platform/fractal/chain"
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/core/diffculty"
+	"github.com/fractal-platform/fractal/core/pool"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/core/wasm"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/fractalsdk"
+	"github.com/fractal-platform/fractal/ftl/api"
+	"github.com/fractal-platform/fractal/ftl/gasprice"
+	"github.com/fractal-platform/fractal/ftl/router"
+	"github.com/fractal-platform/fractal/logbloom/bloomquery"
+	"github.com/fractal-platform/fractal/logbloom/bloomstorage"
+	"github.com/fractal-platform/fractal/miner"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/rpc/server"
+	"github.com/fractal-platform/fractal/transaction/txexec"
+)
+
+const (
+	// roundDuration is the duration of a mining round.
+	roundDuration = time.Second / params.RoundsPerSecond
+
+	// recordTimeout is the longest time Commit waits for the main branch
+	// record to take the block committed.
+	recordTimeout = 5 * time.Second
+)
+
+var (
+	errMiningNotSupported = errors.New("the simulated backend only mines on commit")
+	errStateNotFound      = errors.New("state of the head block not found")
+	errRecordTimeout      = errors.New("block committed not recorded in the main branch")
+)
+
+// Backend is a fractalsdk.TxSender on an in-memory chain. The sdk calls are
+// served by the rpc apis of a full node on a loopback listener, and the blocks
+// are mined by Commit at the rounds of a simulated clock.
+//
+// The backend has no packers, so the transactions are always broadcast.
+type Backend struct {
+	fractalsdk.TxSender
+
+	node    *node
+	server  *rpcserver.Server
+	address common.Address // the account the transactions are sent from
+
+	mu  sync.Mutex // protects the clock and the chain head
+	now time.Time
+
+	sendTxMu sync.Mutex
+	nonce    uint64
+}
+
+// NewBackend creates a backend whose genesis has the accounts of the alloc,
+// and which sends the transactions from the account of the key. The chain
+// runs the contracts on the "wasm" executor of a node where libwasmlib is
+// linked.
+//
+// Built with the nowasmlib tag, the contracts run on the pure Go interpreter
+// of the "gowasm" executor, whose metering is not yet the one of libwasmlib:
+// the gas used, the EstimateGas results and the out of gas failures then
+// differ from a node's, and only the other results of the contracts can be
+// relied on.
+func NewBackend(alloc config.GenesisAlloc, key crypto.PrivateKey) (*Backend, error) {
+	chainConfig := *config.TestnetChainConfig
+	if !txexec.WasmLibLinked {
+		chainConfig.TxExecutorType = "gowasm"
+	}
+	chainConfig.ForkSchedule = params.GenesisForks()
+	return NewBackendWithConfig(&chainConfig, alloc, key)
+}
+
+// NewBackendWithConfig creates a backend on the chain config. The blocks are
+// not signed, so BlockSigFake is always set.
+func NewBackendWithConfig(chainConfig *config.ChainConfig, alloc config.GenesisAlloc, key crypto.PrivateKey) (*Backend, error) {
+	poolConfig := config.DefaultPoolConfig
+	poolConfig.Journal = ""
+
+	cfg := config.DefaultConfig
+	cfg.NodeConfig = &config.NodeConfig{}
+	cfg.ChainConfig = new(config.ChainConfig)
+	*cfg.ChainConfig = *chainConfig
+	cfg.ChainConfig.BlockSigFake = true
+	cfg.TxPoolConfig = &poolConfig
+	cfg.Genesis = config.DefaultTestnetGenesisBlock()
+	cfg.Genesis.Alloc = alloc
+
+	n := &node{
+		config:        &cfg,
+		signer:        types.MakeSigner(cfg.ChainConfig.TxSignerType, cfg.ChainConfig.ChainID),
+		chainDb:       dbwrapper.NewMemDatabase(),
+		gasPrice:      common.Big1,
+		bloomRequests: make(chan chan *bloomquery.Retrieval),
+		shutdownChan:  make(chan bool),
+	}
+
+	var err error
+	if cfg.ChainConfig, err = config.SetupChainConfig(n.chainDb, cfg.ChainConfig); err != nil {
+		return nil, err
+	}
+	if _, err = config.SetupGenesisBlock(n.chainDb, cfg.Genesis); err != nil {
+		return nil, err
+	}
+	n.executor = txexec.NewExecutor(cfg.ChainConfig.TxExecutorType, cfg.ChainConfig.MaxNonceBitLength, n.signer, cfg.ChainConfig.Rewards(), cfg.ChainConfig.Forks())
+	if n.blockchain, err = chain.NewBlockChain(&cfg, n.chainDb, n.executor, cfg.PackerInfoCacheSize, types.NormalNode); err != nil {
+		return nil, err
+	}
+	n.gasPriceOracle = gasprice.NewOracle(n.blockchain, gasprice.Config{
+		Blocks:     cfg.GPOBlocks,
+		Percentile: cfg.GPOPercentile,
+		Default:    n.gasPrice,
+	})
+	n.bloomIndexer = bloomstorage.NewBloomIndexer(n.chainDb)
+	n.bloomIndexer.Start(n.blockchain)
+	bloomquery.StartBloomHandlers(n.shutdownChan, n.bloomRequests, n.chainDb)
+	n.txPool = pool.NewTxPool(&cfg, n.blockchain)
+	n.packerRouter = router.NewRouter(n.blockchain)
+
+	// serve the apis used by the sdk
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		n.stop()
+		return nil, err
+	}
+	srv := rpcserver.NewServer(nil, listener.Addr().String())
+	srv.RegisterApis([]rpcserver.RpcApi{
+		{Namespace: "ftl", Version: "1.0", Service: api.NewBlockChainAPI(n)},
+		{Namespace: "ftl", Version: "1.0", Service: api.NewFractalAPI(n)},
+		{Namespace: "ftl", Version: "1.0", Service: api.NewFilterAPI(n)},
+		{Namespace: "txpool", Version: "1.0", Service: api.NewTxPoolAPI(n, &cfg)},
+	})
+	go srv.Serve(listener)
+
+	b := &Backend{
+		TxSender: fractalsdk.NewTxSenderWithKey(nil, cfg.ChainConfig.ChainID, signerType(cfg.ChainConfig.TxSignerType), key),
+		node:     n,
+		server:   srv,
+		address:  key.Public().ToAddress(),
+		// start the clock at the round after the genesis
+		now: time.Unix(0, int64(n.blockchain.Genesis().Header.Round+1)*int64(roundDuration)),
+	}
+	if err := b.DialHttp("http://" + listener.Addr().String()); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if err := b.DialWs("ws://" + listener.Addr().String()); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func signerType(txSignerType string) fractalsdk.SignerTypeEnum {
+	if txSignerType == "fake" {
+		return fractalsdk.FakeSigner
+	}
+	return fractalsdk.Eip155Signer
+}
+
+// Close stops the rpc server and the chain of the backend.
+func (b *Backend) Close() {
+	b.server.Shutdown()
+	b.node.stop()
+}
+
+func (n *node) stop() {
+	close(n.shutdownChan)
+	n.bloomIndexer.Close()
+	n.txPool.Stop()
+	n.blockchain.StopRecord()
+	n.chainDb.Close()
+}
+
+// Address returns the address of the account the transactions are sent from.
+func (b *Backend) Address() common.Address { return b.address }
+
+// BlockChain returns the chain of the backend.
+func (b *Backend) BlockChain() *chain.BlockChain { return b.node.blockchain }
+
+// Time returns the time of the simulated clock.
+func (b *Backend) Time() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.now
+}
+
+// Round returns the round of the simulated clock, which the next block is
+// mined at.
+func (b *Backend) Round() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return uint64(b.now.UnixNano() / int64(roundDuration))
+}
+
+// AdjustTime moves the simulated clock by the duration.
+func (b *Backend) AdjustTime(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.now = b.now.Add(d)
+}
+
+// SkipRounds moves the simulated clock forward by the number of rounds.
+func (b *Backend) SkipRounds(rounds uint64) {
+	b.AdjustTime(time.Duration(rounds) * roundDuration)
+}
+
+// Commit mines a block on the head block with the transactions of the pool,
+// at the round of the simulated clock, and returns its full hash. The clock is
+// first moved to the next round of the head block if it is not past it.
+func (b *Backend) Commit() (common.Hash, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		n      = b.node
+		parent = n.blockchain.CurrentBlock()
+		round  = uint64(b.now.UnixNano() / int64(roundDuration))
+	)
+	if round <= parent.Header.Round {
+		round = parent.Header.Round + 1
+		b.now = time.Unix(0, int64(round)*int64(roundDuration))
+	}
+
+	stateDb, err := n.blockchain.StateAt(parent.Header.StateHash)
+	if err != nil {
+		return common.Hash{}, errStateNotFound
+	}
+	block := types.NewBlock(parent.SimpleHash(), round, []byte{}, n.coinbase,
+		difficulty.CalcDifficulty(round, parent.Header.Round, parent.Header.Difficulty), parent.Header.Height+1)
+	block.ReceivedAt = time.Now()
+	block.ReceivedPath = types.BlockMined
+	block.Header.MinedTime = uint64(b.now.UnixNano() / 1e6)
+	block.Header.ParentFullHash = parent.FullHash()
+	block.Header.GasLimit = types.CalcGasLimit(parent)
+
+	// pack and execute the transactions as the miner does
+	prevStateDb, _, _ := n.blockchain.GetStateBeforeCacheHeight(parent, uint8(params.ConfirmHeightDistance-1))
+	if queue := n.txPool.ContentForPack(); len(queue) > 0 {
+		block.Body.Transactions = miner.FlattenTransactionsByPrice(block, queue, prevStateDb, stateDb, n.config.ChainConfig.MaxNonceBitLength)
+	}
+
+	var (
+		receipts    types.Receipts
+		executedTxs []*types.TxWithIndex
+		allLogs     []*types.Log
+		usedGas     = new(uint64)
+		gasPool     = new(types.GasPool).AddGas(block.Header.GasLimit)
+	)
+	callbackParamKey := wasm.GetGlobalRegisterParam().RegisterParam(stateDb, block)
+	executedTxs, _, receipts, txExecLoopEndIndex := n.executor.ExecuteTransactions(block.Body.Transactions, prevStateDb, stateDb, receipts, block, types.NotInPackage, executedTxs, usedGas, allLogs, gasPool, callbackParamKey)
+	wasm.GetGlobalRegisterParam().UnRegisterParam(callbackParamKey)
+
+	block.Body.Transactions = block.Body.Transactions[0:txExecLoopEndIndex]
+	block.Header.TxHash = types.DeriveSha(types.Transactions(block.Body.Transactions))
+	block.Header.GasUsed = *usedGas
+
+	state.AddBlockReward(stateDb, n.config.ChainConfig.Rewards(), block, nil)
+	block.Header.StateHash = stateDb.IntermediateRoot(true)
+	block.Header.Amount = parent.Header.Amount + 1
+
+	var bloom *types.Bloom
+	if len(receipts) == 0 {
+		block.Header.ReceiptHash = types.DeriveSha(types.Receipts{})
+		bloom = &types.Bloom{}
+	} else {
+		block.Header.ReceiptHash = types.DeriveSha(types.Receipts(receipts))
+		bloom = types.CreateBloom(receipts)
+	}
+	block.CacheBloom(bloom)
+	block.Header.FullSig = []byte{}
+
+	n.blockchain.InsertBlockWithState(block, stateDb, receipts, executedTxs, bloom)
+
+	// the main branch record, which the transactions and the blocks are looked
+	// up by, takes the block in the background
+	deadline := time.Now().Add(recordTimeout)
+	for {
+		if head := n.blockchain.GetMainBranchHead(); head != nil && head.FullHash() == block.FullHash() {
+			return block.FullHash(), nil
+		}
+		if time.Now().After(deadline) {
+			return block.FullHash(), errRecordTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// SendTransactionRaw sends the transaction to the pool without mining it.
+func (b *Backend) SendTransactionRaw(nonce uint64, to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, broadcast bool) (string, error) {
+	return b.TxSender.SendTransactionRaw(nonce, to, amount, gasLimit, gasPrice, data, true)
+}
+
+// SendTransactionSync sends the transaction and mines it with Commit.
+func (b *Backend) SendTransactionSync(to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, broadcast bool, timeout time.Duration) (*fractalsdk.TransactionDetails, error) {
+	b.sendTxMu.Lock()
+	defer b.sendTxMu.Unlock()
+
+	return b.sendAndCommit(to, amount, gasLimit, gasPrice, data)
+}
+
+// SendTransactionAsync sends the transaction and mines it with Commit, the
+// result is then sent to the channel.
+func (b *Backend) SendTransactionAsync(to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, broadcast bool, timeout time.Duration, resultChan chan<- *fractalsdk.ExecResult) error {
+	b.sendTxMu.Lock()
+	defer b.sendTxMu.Unlock()
+
+	txDetails, err := b.sendAndCommit(to, amount, gasLimit, gasPrice, data)
+	if txDetails == nil && err != fractalsdk.ErrTransactionNotExecuted {
+		return err
+	}
+	go func() {
+		resultChan <- &fractalsdk.ExecResult{
+			TxDetails: txDetails,
+			Err:       err,
+		}
+	}()
+	return nil
+}
+
+// BatchSendTransaction sends the transactions of the tasks one by one, and
+// mines each of them with Commit.
+func (b *Backend) BatchSendTransaction(taskChanContainer <-chan chan *fractalsdk.ExecTask) error {
+	go func() {
+		for taskChan := range taskChanContainer {
+			task := <-taskChan
+			b.sendTxMu.Lock()
+			task.TxDetails, task.Err = b.sendAndCommit(task.To, task.Amount, task.GasLimit, task.GasPrice, task.Data)
+			b.sendTxMu.Unlock()
+			taskChan <- task
+		}
+	}()
+	return nil
+}
+
+func (b *Backend) sendAndCommit(to string, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) (*fractalsdk.TransactionDetails, error) {
+	nonce, err := b.GetTransactionNonce(b.address.String())
+	if err != nil {
+		return nil, err
+	}
+	if nonce > b.nonce {
+		b.nonce = nonce
+	}
+
+	txHash, err := b.SendTransactionRaw(b.nonce, to, amount, gasLimit, gasPrice, data, true)
+	if err != nil {
+		return nil, err
+	}
+	b.nonce++
+
+	if _, err := b.Commit(); err != nil {
+		return nil, err
+	}
+	txDetails, err := b.GetTransactionByHash(txHash)
+	if err != nil {
+		return nil, err
+	}
+	if txDetails == nil || txDetails.Receipt == nil {
+		return nil, fractalsdk.ErrTransactionNotExecuted
+	}
+	return txDetails, nil
+}
@@ -0,0 +1,196 @@
+package simulated
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/fractalsdk"
+	"github.com/fractal-platform/fractal/utils/abi"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// echoRow is the row struct of the echo contract of testdata, which stores the
+// args of an action as the row 1 of the rows table and adds them as a stored
+// event.
+type echoRow struct {
+	Id   uint32
+	Name string
+}
+
+func newTestBackend() *Backend {
+	_, key, err := crypto.NewKeys(crypto.ECDSA)
+	So(err, ShouldBeNil)
+	b, err := NewBackend(config.GenesisAlloc{key.Public().ToAddress(): {Balance: big.NewInt(1e18)}}, key)
+	So(err, ShouldBeNil)
+	Reset(b.Close)
+	return b
+}
+
+// deployEcho deploys the echo contract and returns its address.
+func deployEcho(b *Backend) string {
+	code, err := ioutil.ReadFile("testdata/echo.wasm")
+	So(err, ShouldBeNil)
+	details, err := b.SendTransactionSync("", new(big.Int), 0, nil, code, true, 0)
+	So(err, ShouldBeNil)
+	So(details.Receipt.Status, ShouldEqual, types.ReceiptStatusSuccessful)
+	return details.Receipt.ContractAddress
+}
+
+func echoData(row echoRow) []byte {
+	data, err := fractalsdk.ActionData("set", row)
+	So(err, ShouldBeNil)
+	return data
+}
+
+// echoStorage returns the row 1 of the echo contract in the head state.
+func echoStorage(b *Backend, contract string) []byte {
+	value, err := b.GetCurrentStorage(contract, "rows", hexutil.Encode([]byte{1}))
+	So(err, ShouldBeNil)
+	data, err := hexutil.Decode(value)
+	So(err, ShouldBeNil)
+	return data
+}
+
+func TestBackendCommit(t *testing.T) {
+	Convey("commit mines a block on the head block", t, func() {
+		b := newTestBackend()
+		genesis := b.BlockChain().CurrentBlock()
+
+		hash, err := b.Commit()
+		So(err, ShouldBeNil)
+		head := b.BlockChain().CurrentBlock()
+		So(head.FullHash(), ShouldEqual, hash)
+		So(head.Header.Height, ShouldEqual, genesis.Header.Height+1)
+		So(head.Header.ParentFullHash, ShouldEqual, genesis.FullHash())
+		So(head.Header.Round, ShouldEqual, genesis.Header.Round+1)
+		So(head.Body.Transactions, ShouldBeEmpty)
+
+		Convey("at the next round if the clock is not moved", func() {
+			round := b.Round()
+			hash, err := b.Commit()
+			So(err, ShouldBeNil)
+			So(b.BlockChain().CurrentBlock().FullHash(), ShouldEqual, hash)
+			So(b.BlockChain().CurrentBlock().Header.Round, ShouldEqual, head.Header.Round+1)
+			So(b.Round(), ShouldEqual, round+1)
+		})
+
+		Convey("at the round of the clock", func() {
+			b.SkipRounds(10)
+			So(b.Round(), ShouldEqual, head.Header.Round+10)
+			_, err := b.Commit()
+			So(err, ShouldBeNil)
+			So(b.BlockChain().CurrentBlock().Header.Round, ShouldEqual, head.Header.Round+10)
+
+			b.AdjustTime(roundDuration * 5)
+			So(b.Round(), ShouldEqual, head.Header.Round+15)
+			_, err = b.Commit()
+			So(err, ShouldBeNil)
+			So(b.BlockChain().CurrentBlock().Header.Round, ShouldEqual, head.Header.Round+15)
+			So(b.BlockChain().CurrentBlock().Header.MinedTime, ShouldEqual, uint64(b.Time().UnixNano()/1e6))
+		})
+
+		Convey("after the head block if the clock is moved back", func() {
+			b.AdjustTime(-time.Hour)
+			_, err := b.Commit()
+			So(err, ShouldBeNil)
+			So(b.BlockChain().CurrentBlock().Header.Round, ShouldEqual, head.Header.Round+1)
+			So(b.Round(), ShouldEqual, head.Header.Round+1)
+		})
+	})
+}
+
+func TestBackendSendTransactionSync(t *testing.T) {
+	Convey("a transaction sent is mined", t, func() {
+		b := newTestBackend()
+		to := common.HexToAddress("0x0102")
+
+		details, err := b.SendTransactionSync(to.String(), big.NewInt(100), 0, nil, nil, false, time.Second)
+		So(err, ShouldBeNil)
+		So(details.Receipt.Status, ShouldEqual, types.ReceiptStatusSuccessful)
+		So(details.BlockHash, ShouldEqual, b.BlockChain().CurrentBlock().FullHash().String())
+		So(b.BlockChain().CurrentBlock().Body.Transactions, ShouldHaveLength, 1)
+		balance, err := b.GetCurrentBalance(to.String())
+		So(err, ShouldBeNil)
+		So(balance.Int64(), ShouldEqual, 100)
+
+		Convey("and executes the action of the contract", func() {
+			contract := deployEcho(b)
+			So(contract, ShouldNotBeEmpty)
+
+			details, err := b.SendTransactionSync(contract, new(big.Int), 0, nil, echoData(echoRow{Id: 7, Name: "seven"}), false, time.Second)
+			So(err, ShouldBeNil)
+			So(details.Receipt.Status, ShouldEqual, types.ReceiptStatusSuccessful)
+			So(details.Receipt.Logs, ShouldHaveLength, 1)
+
+			var row echoRow
+			So(abi.Unmarshal(echoStorage(b, contract), &row), ShouldBeNil)
+			So(row, ShouldResemble, echoRow{Id: 7, Name: "seven"})
+		})
+	})
+}
+
+func TestBackendCall(t *testing.T) {
+	Convey("a call is executed without changing the state", t, func() {
+		b := newTestBackend()
+		contract := deployEcho(b)
+		head := b.BlockChain().CurrentBlock()
+
+		result, err := b.Call(b.Address().String(), contract, new(big.Int), 0, nil, echoData(echoRow{Id: 8, Name: "eight"}), "latest", nil)
+		So(err, ShouldBeNil)
+		So(result.Logs, ShouldHaveLength, 1)
+		var row echoRow
+		So(abi.Unmarshal(result.Logs[0].Data, &row), ShouldBeNil)
+		So(row, ShouldResemble, echoRow{Id: 8, Name: "eight"})
+
+		So(echoStorage(b, contract), ShouldBeEmpty)
+		So(b.BlockChain().CurrentBlock().FullHash(), ShouldEqual, head.FullHash())
+	})
+}
+
+func TestBackendSubNewBlock(t *testing.T) {
+	Convey("the blocks committed are notified", t, func() {
+		b := newTestBackend()
+		unsubscribe := make(chan struct{})
+		defer close(unsubscribe)
+		blockCh := make(chan *fractalsdk.Block, 1)
+		So(b.SubNewBlock(unsubscribe, blockCh), ShouldBeNil)
+
+		// commit returns the notice of the block committed, skipping the
+		// notices of the blocks before
+		commit := func(wait time.Duration) (common.Hash, *fractalsdk.Block) {
+			hash, err := b.Commit()
+			So(err, ShouldBeNil)
+			timeout := time.After(wait)
+			for {
+				select {
+				case block := <-blockCh:
+					if block.FullHash == hash.String() {
+						return hash, block
+					}
+				case <-timeout:
+					return hash, nil
+				}
+			}
+		}
+		// the subscription is made in the background, commit until it is
+		var block *fractalsdk.Block
+		for i := 0; i < 100 && block == nil; i++ {
+			_, block = commit(50 * time.Millisecond)
+		}
+		So(block, ShouldNotBeNil)
+
+		for i := 0; i < 3; i++ {
+			hash, block := commit(5 * time.Second)
+			So(block, ShouldNotBeNil)
+			So(block.FullHash, ShouldEqual, hash.String())
+			So(block.Height, ShouldEqual, b.BlockChain().CurrentBlock().Header.Height)
+		}
+	})
+}
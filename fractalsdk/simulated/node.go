@@ -0,0 +1,131 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package simulated
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/fractal-platform/fractal/chain"
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/pool"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/event"
+	"github.com/fractal-platform/fractal/ftl/gasprice"
+	"github.com/fractal-platform/fractal/ftl/protocol"
+	"github.com/fractal-platform/fractal/ftl/router"
+	ftl_sync "github.com/fractal-platform/fractal/ftl/sync"
+	"github.com/fractal-platform/fractal/ftl/txhistory"
+	"github.com/fractal-platform/fractal/keys"
+	"github.com/fractal-platform/fractal/logbloom/bloomquery"
+	"github.com/fractal-platform/fractal/logbloom/bloomstorage"
+	"github.com/fractal-platform/fractal/packer"
+	"github.com/fractal-platform/fractal/transaction/txexec"
+)
+
+// node is the chain of the backend, which the rpc apis are served on. It has
+// no miner, no packer and no peers: the blocks are only mined by Commit.
+type node struct {
+	config *config.Config
+	signer types.Signer
+
+	chainDb    dbwrapper.Database
+	blockchain *chain.BlockChain
+	executor   txexec.TxExecutor
+	txPool     pool.Pool
+
+	packerRouter   *router.Router
+	gasPrice       *big.Int
+	gasPriceOracle *gasprice.Oracle
+
+	bloomRequests chan chan *bloomquery.Retrieval
+	bloomIndexer  *bloomstorage.BloomIndexer
+	shutdownChan  chan bool
+
+	coinbase common.Address
+}
+
+func (n *node) IsMining() bool                           { return false }
+func (n *node) StartMining() error                       { return errMiningNotSupported }
+func (n *node) StopMining()                              {}
+func (n *node) MiningKeyManager() *keys.MiningKeyManager { return nil }
+func (n *node) Coinbase() common.Address                 { return n.coinbase }
+
+func (n *node) Config() *config.Config               { return n.config }
+func (n *node) Packer() packer.Packer                { return nil }
+func (n *node) PackerRouter() *router.Router         { return n.packerRouter }
+func (n *node) BlockChain() *chain.BlockChain        { return n.blockchain }
+func (n *node) TxPool() pool.Pool                    { return n.txPool }
+func (n *node) Signer() types.Signer                 { return n.signer }
+func (n *node) GasPrice() *big.Int                   { return n.gasPrice }
+func (n *node) GasPriceOracle() *gasprice.Oracle     { return n.gasPriceOracle }
+func (n *node) TxHistory() *txhistory.Indexer        { return nil }
+func (n *node) FtlVersion() int                      { return int(protocol.ProtocolVersions[0]) }
+func (n *node) Synchronizer() *ftl_sync.Synchronizer { return nil }
+func (n *node) ChainDb() dbwrapper.Database          { return n.chainDb }
+
+func (n *node) GetPoolTransactions() types.Transactions {
+	var txs types.Transactions
+	for _, batch := range n.txPool.Content() {
+		for _, tx := range batch {
+			txs = append(txs, tx.(*types.Transaction))
+		}
+	}
+	return txs
+}
+
+func (n *node) GetBlock(ctx context.Context, fullHash common.Hash) *types.Block {
+	return n.blockchain.GetBlock(fullHash)
+}
+
+func (n *node) CurrentBlock(ctx context.Context) *types.Block {
+	return n.blockchain.CurrentBlock()
+}
+
+func (n *node) GetMainBranchBlock(height uint64) (*types.Block, error) {
+	return n.blockchain.GetMainBranchBlock(height)
+}
+
+// GetBlockStr returns the block given by "latest", "finalized", a block height
+// in decimal or hex, or a block full hash.
+func (n *node) GetBlockStr(blockStr string) *types.Block {
+	if strings.ToLower(blockStr) == "latest" {
+		return n.blockchain.CurrentBlock()
+	} else if strings.ToLower(blockStr) == "finalized" {
+		return n.blockchain.GetFinalizedBlock()
+	} else if height, err := strconv.ParseUint(blockStr, 0, 64); err == nil {
+		block, _ := n.blockchain.GetMainBranchBlock(height)
+		return block
+	}
+	return n.blockchain.GetBlock(common.HexToHash(blockStr))
+}
+
+func (n *node) GetReceipts(ctx context.Context, fullHash common.Hash) types.Receipts {
+	return dbaccessor.ReadReceipts(n.chainDb, fullHash)
+}
+
+func (n *node) GetLogs(ctx context.Context, fullHash common.Hash) [][]*types.Log {
+	receipts := dbaccessor.ReadReceipts(n.chainDb, fullHash)
+	if receipts == nil {
+		return nil
+	}
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs
+}
+
+func (n *node) BloomRequestsReceiver() chan chan *bloomquery.Retrieval {
+	return n.bloomRequests
+}
+
+func (n *node) SubscribeInsertBloomEvent(ch chan<- types.BloomInsertEvent) event.Subscription {
+	return n.bloomIndexer.SubscribeInsertBloomEvent(ch)
+}
@@ -0,0 +1,19 @@
+;; echo stores the args of any action as the row 1 of the rows table, and adds
+;; them as the data of a stored event. echo.wasm is built from it with
+;; wat2wasm, and echo.abi is its abi.
+(module
+  (import "env" "read_action_data" (func $read_action_data (param i32 i32) (result i32)))
+  (import "env" "db_store" (func $db_store (param i64 i32 i32 i32 i32)))
+  (import "env" "add_log" (func $add_log (param i32 i32 i32 i32)))
+  (memory (export "memory") 1)
+  ;; the key of the row
+  (data (i32.const 0) "\01")
+  ;; the topic of the stored event
+  (data (i32.const 8) "\00\00\00\00\24\75\69\c6\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00\00")
+  (func (export "apply") (result i32)
+    (local $len i32)
+    ;; the action is read at 64, the args follow its name
+    (local.set $len (i32.sub (call $read_action_data (i32.const 64) (i32.const 1024)) (i32.const 8)))
+    (call $db_store (i64.const 0xbd39800000000000) (i32.const 0) (i32.const 1) (i32.const 72) (local.get $len))
+    (call $add_log (i32.const 8) (i32.const 1) (i32.const 72) (local.get $len))
+    (i32.const 0)))
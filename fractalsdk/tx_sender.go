@@ -36,6 +36,18 @@ func (t *txSender) sendTransaction(nonce uint64, to string, amount *big.Int, gas
 		err    error
 		txHash common.Hash
 	)
+	if gasPrice == nil {
+		gasPrice, err = t.GasPrice()
+		if err != nil {
+			return "", err
+		}
+	}
+	if gasLimit == 0 {
+		gasLimit, err = t.EstimateGas(t.accountAddr, to, amount, gasPrice, data)
+		if err != nil {
+			return "", err
+		}
+	}
 	if to == "" {
 		tx = types.NewContractCreation(nonce, amount, gasLimit, gasPrice, data, broadcast)
 	} else {
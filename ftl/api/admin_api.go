@@ -9,9 +9,12 @@ import (
 	"fmt"
 
 	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
 	"github.com/fractal-platform/fractal/keys"
 	"github.com/fractal-platform/fractal/p2p"
 	"github.com/fractal-platform/fractal/p2p/discover"
+	"github.com/fractal-platform/fractal/utils/abi"
 )
 
 var ErrNodeStopped = errors.New("node not started")
@@ -159,3 +162,45 @@ func (api *AdminAPI) StopPacking() {
 func (api *AdminAPI) IsPacking() bool {
 	return api.ftl.Packer().IsPacking()
 }
+
+// RegisterAbi registers the abi of the contract on the node, which is used by
+// ftl_getTableRow to encode the keys and decode the rows of its tables if no
+// abi is set for the contract in the abi registry.
+func (api *AdminAPI) RegisterAbi(address common.Address, abiDef string) error {
+	if _, err := abi.NewAbiSerializer(abiDef); err != nil {
+		return err
+	}
+	dbaccessor.WriteContractAbi(api.ftl.ChainDb(), address, []byte(abiDef))
+	return nil
+}
+
+// SetHead rewinds the chain head to the main branch block at the height, and
+// syncs the blocks above it from the peers again. The miner and the packer
+// are restarted on the new head. It's only served on the local admin
+// endpoint.
+func (api *AdminAPI) SetHead(height hexutil.Uint64) error {
+	mining := api.ftl.IsMining()
+	if mining {
+		api.ftl.StopMining()
+	}
+	packerIndex, packing := api.ftl.Packer().PackerIndex()
+	if packing {
+		api.ftl.Packer().StopPacking()
+	}
+
+	err := api.ftl.BlockChain().SetHead(uint64(height))
+
+	if packing {
+		api.ftl.Packer().StartPacking(packerIndex)
+	}
+	if mining {
+		if err := api.ftl.StartMining(); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	api.ftl.Synchronizer().Resync()
+	return nil
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"math/big"
+	"strings"
 
 	"github.com/fractal-platform/fractal/chain"
 	"github.com/fractal-platform/fractal/common"
@@ -11,7 +12,10 @@ import (
 	"github.com/fractal-platform/fractal/core/types"
 	"github.com/fractal-platform/fractal/dbwrapper"
 	"github.com/fractal-platform/fractal/event"
+	"github.com/fractal-platform/fractal/ftl/gasprice"
+	"github.com/fractal-platform/fractal/ftl/router"
 	"github.com/fractal-platform/fractal/ftl/sync"
+	"github.com/fractal-platform/fractal/ftl/txhistory"
 	"github.com/fractal-platform/fractal/keys"
 	"github.com/fractal-platform/fractal/logbloom/bloomquery"
 	"github.com/fractal-platform/fractal/packer"
@@ -26,10 +30,13 @@ type fractal interface {
 
 	Config() *config.Config
 	Packer() packer.Packer
+	PackerRouter() *router.Router
 	BlockChain() *chain.BlockChain
 	TxPool() pool.Pool
 	Signer() types.Signer
 	GasPrice() *big.Int
+	GasPriceOracle() *gasprice.Oracle
+	TxHistory() *txhistory.Indexer
 	GetPoolTransactions() types.Transactions
 
 	FtlVersion() int
@@ -47,3 +54,11 @@ type fractal interface {
 	BloomRequestsReceiver() chan chan *bloomquery.Retrieval
 	SubscribeInsertBloomEvent(ch chan<- types.BloomInsertEvent) event.Subscription
 }
+
+// finalizedTag is the block tag which restricts a query to the finalized
+// part of the main branch.
+const finalizedTag = "finalized"
+
+func isFinalizedTag(tag *string) bool {
+	return tag != nil && strings.ToLower(*tag) == finalizedTag
+}
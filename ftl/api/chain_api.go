@@ -5,16 +5,23 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 
 	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/nonces"
 	"github.com/fractal-platform/fractal/core/state"
 	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/ftl/txhistory"
 	"github.com/fractal-platform/fractal/rpc"
 	"github.com/fractal-platform/fractal/rpc/server"
+	"github.com/fractal-platform/fractal/transaction/txexec"
 	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/abi"
 	"github.com/fractal-platform/fractal/utils/log"
 )
 
@@ -75,8 +82,33 @@ func (s *BlockChainAPI) GetNearbyBlocks(fullHash common.Hash, num uint32) types.
 	return s.ftl.BlockChain().GetNearbyBlocksFromBlock(fullHash, uint64(num))
 }
 
-// SubNewBlock provides information when new block arrived
-func (s *BlockChainAPI) SubNewBlock(ctx context.Context) (*rpcserver.Subscription, error) {
+// ReorgNotice is pushed to the block subscription when blocks leave the main branch.
+type ReorgNotice struct {
+	Reorg          bool           `json:"reorg"` // always true, to tell a notice from a block
+	CommonAncestor common.Hash    `json:"commonAncestor"`
+	AncestorHeight hexutil.Uint64 `json:"ancestorHeight"`
+	Removed        []common.Hash  `json:"removed"`
+	Added          []common.Hash  `json:"added"`
+}
+
+func newReorgNotice(e types.ChainReorgEvent) *ReorgNotice {
+	notice := &ReorgNotice{
+		Reorg:          true,
+		CommonAncestor: e.CommonAncestor.FullHash(),
+		AncestorHeight: hexutil.Uint64(e.CommonAncestor.Header.Height),
+	}
+	for _, block := range e.Dropped {
+		notice.Removed = append(notice.Removed, block.FullHash())
+	}
+	for _, block := range e.Added {
+		notice.Added = append(notice.Added, block.FullHash())
+	}
+	return notice
+}
+
+// SubNewBlock provides information when new block arrived. If reorgs is true,
+// a ReorgNotice is also pushed when blocks leave the main branch.
+func (s *BlockChainAPI) SubNewBlock(ctx context.Context, reorgs *bool) (*rpcserver.Subscription, error) {
 	notifier, supported := rpcserver.NotifierFromContext(ctx)
 	if !supported {
 		return &rpcserver.Subscription{}, rpc.ErrNotificationsUnsupported
@@ -87,6 +119,48 @@ func (s *BlockChainAPI) SubNewBlock(ctx context.Context) (*rpcserver.Subscriptio
 	go func() {
 		ch := make(chan types.ChainUpdateEvent)
 		sub := s.ftl.BlockChain().SubscribeChainUpdateEvent(ch)
+		reorgCh := make(chan types.ChainReorgEvent)
+		if reorgs != nil && *reorgs {
+			reorgSub := s.ftl.BlockChain().SubscribeChainReorgEvent(reorgCh)
+			defer reorgSub.Unsubscribe()
+		}
+
+		for {
+			select {
+			case e := <-ch:
+				notifier.Notify(rpcSub.ID, e.Block)
+			case e := <-reorgCh:
+				notifier.Notify(rpcSub.ID, newReorgNotice(e))
+			case <-rpcSub.Err():
+				sub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				sub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// GetFinalizedBlock returns the finalized block of the main branch.
+func (s *BlockChainAPI) GetFinalizedBlock() *types.Block {
+	return s.ftl.BlockChain().GetFinalizedBlock()
+}
+
+// SubFinalizedBlock provides the finalized block when finality advances
+func (s *BlockChainAPI) SubFinalizedBlock(ctx context.Context) (*rpcserver.Subscription, error) {
+	notifier, supported := rpcserver.NotifierFromContext(ctx)
+	if !supported {
+		return &rpcserver.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		ch := make(chan types.FinalizedBlockEvent)
+		sub := s.ftl.BlockChain().SubscribeFinalizedBlockEvent(ch)
 
 		for {
 			select {
@@ -160,3 +234,289 @@ func (s *BlockChainAPI) GetContractOwner(ctx context.Context, contractAddress co
 
 	return stateDb.GetContractOwner(contractAddress), nil
 }
+
+// GetAbi returns the abi of the contract set in the abi registry in the state
+// of the block, or nil if it is not set.
+func (s *BlockChainAPI) GetAbi(ctx context.Context, contract common.Address, blockHashStr string) (json.RawMessage, error) {
+	block := s.ftl.GetBlockStr(blockHashStr)
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	stateDb, err := s.ftl.BlockChain().StateAt(block.Header.StateHash)
+	if stateDb == nil || err != nil {
+		return nil, err
+	}
+
+	abiDef := txexec.ReadContractAbi(stateDb, contract)
+	if len(abiDef) == 0 {
+		return nil, nil
+	}
+	return json.RawMessage(abiDef), nil
+}
+
+// GetTableRow returns the row of the contract table with the key in the state
+// of the block, decoded by the abi of the contract. The abi set in the abi
+// registry is used, or else the one registered on the node. The key is given
+// in JSON as the key type of the table, and the block defaults to the head
+// block. It returns nil if the row is not found.
+func (s *BlockChainAPI) GetTableRow(ctx context.Context, contract common.Address, table string, key json.RawMessage, blockStr *string) (json.RawMessage, error) {
+	blockHashStr := "latest"
+	if blockStr != nil {
+		blockHashStr = *blockStr
+	}
+	block := s.ftl.GetBlockStr(blockHashStr)
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	stateDb, err := s.ftl.BlockChain().StateAt(block.Header.StateHash)
+	if stateDb == nil || err != nil {
+		return nil, err
+	}
+
+	abiDef := s.ftl.BlockChain().ContractAbi(stateDb, contract)
+	if len(abiDef) == 0 {
+		return nil, errors.New("abi of the contract is not registered")
+	}
+	serializer, err := abi.NewAbiSerializer(string(abiDef))
+	if err != nil {
+		return nil, err
+	}
+	valueType := serializer.GetTableValueType(table)
+	if valueType == "" {
+		return nil, errors.New("table not found in the abi")
+	}
+
+	var keyData interface{}
+	if err := json.Unmarshal(key, &keyData); err != nil {
+		return nil, err
+	}
+	keyBytes := new(bytes.Buffer)
+	if err := serializer.Serialize(keyData, serializer.GetTableKeyType(table), keyBytes); err != nil {
+		return nil, err
+	}
+
+	t, _ := utils.String2Uint64(table)
+	value := stateDb.GetState(contract, state.GetStorageKey(t, keyBytes.Bytes()))
+	if len(value) == 0 {
+		return nil, nil
+	}
+	return serializer.Deserialize(valueType, value)
+}
+
+// StorageKeyArgs is a key of a contract table.
+type StorageKeyArgs struct {
+	Table string        `json:"table"`
+	Key   hexutil.Bytes `json:"key"`
+}
+
+// StorageProof is the merkle proof of a key in the storage trie of an account.
+type StorageProof struct {
+	Table string          `json:"table"`
+	Key   hexutil.Bytes   `json:"key"`
+	Value hexutil.Bytes   `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// AccountProof is the merkle proof of an account in the state trie, along with
+// the proofs of the requested storage keys of the account.
+type AccountProof struct {
+	Address         common.Address  `json:"address"`
+	TxNonceSet      nonces.NonceSet `json:"txNonceSet"`
+	PackageNonceSet nonces.NonceSet `json:"packageNonceSet"`
+	Balance         *hexutil.Big    `json:"balance"`
+	StorageHash     common.Hash     `json:"storageHash"`
+	CodeHash        common.Hash     `json:"codeHash"`
+	ContractOwner   common.Address  `json:"contractOwner"`
+	AccountProof    []hexutil.Bytes `json:"accountProof"`
+	StorageProof    []StorageProof  `json:"storageProof"`
+}
+
+// GetProof returns the merkle proof of the account and of the storage keys in
+// the state of the given block, which can be verified against the StateHash
+// of the block.
+func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, keys []StorageKeyArgs, blockHashStr string) (*AccountProof, error) {
+	block := s.ftl.GetBlockStr(blockHashStr)
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	stateDb, err := s.ftl.BlockChain().StateAt(block.Header.StateHash)
+	if stateDb == nil || err != nil {
+		return nil, err
+	}
+
+	accountProof, err := stateDb.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountProof{
+		Address:       address,
+		Balance:       (*hexutil.Big)(stateDb.GetBalance(address)),
+		StorageHash:   stateDb.GetStorageRoot(address),
+		CodeHash:      stateDb.GetCodeHash(address),
+		ContractOwner: stateDb.GetContractOwner(address),
+		AccountProof:  toHexSlice(accountProof),
+		StorageProof:  make([]StorageProof, len(keys)),
+	}
+	if nonceSet := stateDb.TxNonceSet(address); nonceSet != nil {
+		result.TxNonceSet = *nonceSet
+	}
+	if nonceSet := stateDb.PackageNonceSet(address); nonceSet != nil {
+		result.PackageNonceSet = *nonceSet
+	}
+
+	for i, key := range keys {
+		table, err := utils.String2Uint64(key.Table)
+		if err != nil {
+			return nil, err
+		}
+		storageKey := state.GetStorageKey(table, key.Key)
+		proof, err := stateDb.GetStorageProof(address, storageKey)
+		if err != nil && err != state.ErrAccountNotExist {
+			return nil, err
+		}
+		result.StorageProof[i] = StorageProof{
+			Table: key.Table,
+			Key:   key.Key,
+			Value: stateDb.GetState(address, storageKey),
+			Proof: toHexSlice(proof),
+		}
+	}
+	return result, stateDb.Error()
+}
+
+func toHexSlice(b [][]byte) []hexutil.Bytes {
+	r := make([]hexutil.Bytes, len(b))
+	for i := range b {
+		r[i] = hexutil.Bytes(b[i])
+	}
+	return r
+}
+
+// RPCReceipt is the receipt of a transaction along with its position in the chain.
+type RPCReceipt struct {
+	BlockHash         common.Hash     `json:"blockHash"`
+	BlockHeight       hexutil.Uint64  `json:"blockHeight"`
+	PackageHash       *common.Hash    `json:"packageHash"`
+	TxPackageIndex    hexutil.Uint64  `json:"txPackageIndex"`
+	TxIndex           hexutil.Uint64  `json:"transactionIndex"`
+	TxHash            common.Hash     `json:"transactionHash"`
+	Status            hexutil.Uint64  `json:"status"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"`
+	Logs              []*types.Log    `json:"logs"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+}
+
+// GetTransactionReceipt returns the receipt of the transaction with the hash,
+// or nil if the transaction is not executed in the main branch yet. With the
+// "finalized" tag, it returns nil until the block of the receipt is finalized.
+func (s *BlockChainAPI) GetTransactionReceipt(hash common.Hash, blockTag *string) (*RPCReceipt, error) {
+	receipt, entry, err := dbaccessor.ReadReceipt(s.ftl.ChainDb(), hash)
+	if err != nil || receipt == nil {
+		return nil, nil
+	}
+	block := s.ftl.BlockChain().GetBlock(entry.BlockFullHash)
+	if block == nil || !s.ftl.BlockChain().IsInMainBranch(block) {
+		return nil, nil
+	}
+	if isFinalizedTag(blockTag) && !s.ftl.BlockChain().IsFinalized(block) {
+		return nil, nil
+	}
+
+	result := &RPCReceipt{
+		BlockHash:         entry.BlockFullHash,
+		BlockHeight:       hexutil.Uint64(block.Header.Height),
+		TxPackageIndex:    hexutil.Uint64(entry.TxPackageIndex),
+		TxIndex:           hexutil.Uint64(entry.TxIndex),
+		TxHash:            hash,
+		Status:            hexutil.Uint64(receipt.Status),
+		GasUsed:           hexutil.Uint64(receipt.GasUsed),
+		CumulativeGasUsed: hexutil.Uint64(receipt.CumulativeGasUsed),
+		Logs:              receipt.Logs,
+	}
+	if entry.TxPackageIndex != types.NotInPackage {
+		if int(entry.TxPackageIndex) >= len(block.Body.TxPackageHashes) {
+			return nil, errors.New("package index out of range")
+		}
+		pkgHash := block.Body.TxPackageHashes[entry.TxPackageIndex]
+		result.PackageHash = &pkgHash
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		contractAddress := receipt.ContractAddress
+		result.ContractAddress = &contractAddress
+	}
+	if result.Logs == nil {
+		result.Logs = []*types.Log{}
+	}
+	return result, nil
+}
+
+// RPCAddressTx is the position of a transaction touching an address in the
+// main branch.
+type RPCAddressTx struct {
+	BlockHeight    hexutil.Uint64 `json:"blockHeight"`
+	BlockHash      common.Hash    `json:"blockHash"`
+	TxPackageIndex hexutil.Uint64 `json:"txPackageIndex"`
+	TxIndex        hexutil.Uint64 `json:"transactionIndex"`
+	TxHash         common.Hash    `json:"transactionHash"`
+}
+
+// AddressTxsResult is a page of the transactions touching an address, the
+// next page is queried with the cursor if it is not nil.
+type AddressTxsResult struct {
+	Transactions []*RPCAddressTx `json:"transactions"`
+	NextCursor   *hexutil.Bytes  `json:"nextCursor"`
+}
+
+// GetTransactionsByAddress returns the transactions in the main branch blocks
+// from fromHeight to toHeight which are sent or received by the address,
+// create it or emit its logs, in the order of the chain. At most limit
+// transactions are returned, after the cursor returned by the previous page.
+// It is only available if the node is started with the tx history index.
+func (s *BlockChainAPI) GetTransactionsByAddress(address common.Address, fromHeight hexutil.Uint64, toHeight hexutil.Uint64, limit hexutil.Uint64, cursor *hexutil.Bytes) (*AddressTxsResult, error) {
+	indexer := s.ftl.TxHistory()
+	if indexer == nil {
+		return nil, errors.New("tx history is not indexed")
+	}
+	var from *txhistory.Cursor
+	if cursor != nil {
+		var err error
+		if from, err = txhistory.ParseCursor(*cursor); err != nil {
+			return nil, err
+		}
+	}
+	entries, next, err := indexer.Query(address, uint64(fromHeight), uint64(toHeight), int(limit), from)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AddressTxsResult{Transactions: make([]*RPCAddressTx, 0, len(entries))}
+	for _, entry := range entries {
+		block, err := s.ftl.GetMainBranchBlock(entry.Height)
+		if err != nil {
+			return nil, err
+		}
+		result.Transactions = append(result.Transactions, &RPCAddressTx{
+			BlockHeight:    hexutil.Uint64(entry.Height),
+			BlockHash:      block.FullHash(),
+			TxPackageIndex: hexutil.Uint64(entry.TxPackageIndex),
+			TxIndex:        hexutil.Uint64(entry.TxIndex),
+			TxHash:         entry.TxHash,
+		})
+	}
+	if next != nil {
+		enc := hexutil.Bytes(next.Bytes())
+		result.NextCursor = &enc
+	}
+	return result, nil
+}
+
+// GetEvidences returns all the miner equivocation evidences found by the node.
+func (s *BlockChainAPI) GetEvidences() types.Evidences {
+	return s.ftl.BlockChain().GetEvidences()
+}
+
+// GetEvidence returns the miner equivocation evidence with the hash.
+func (s *BlockChainAPI) GetEvidence(hash common.Hash) *types.Evidence {
+	return s.ftl.BlockChain().GetEvidence(hash)
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"sync"
 
+	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/core/types"
 	"github.com/fractal-platform/fractal/crypto"
 	"github.com/fractal-platform/fractal/utils/log"
@@ -15,11 +16,8 @@ type CheckPointAPI struct {
 	ftl              fractal
 	checkPointPriKey crypto.PrivateKey
 
-	queryCheckPointCache   *lru.Cache // treePointhash -> checkPoint lru map
-	queryCheckPointCacheMu sync.Mutex
-
-	queryCheckPointHashCache   *lru.Cache // treePointhash -> checkPointHash lru map
-	queryCheckPointHashCacheMu sync.Mutex
+	signsCache   *lru.Cache // checkPointHash -> collected signs lru map
+	signsCacheMu sync.Mutex
 }
 
 func NewCheckPointAPI(ftl fractal, checkPointPriKey crypto.PrivateKey) *CheckPointAPI {
@@ -28,149 +26,133 @@ func NewCheckPointAPI(ftl fractal, checkPointPriKey crypto.PrivateKey) *CheckPoi
 	c.checkPointPriKey = checkPointPriKey
 
 	var err error
-	c.queryCheckPointCache, err = lru.New(10)
-	if err != nil {
-		log.Error("NewCheckPointAPI: init queryCheckPointCache error", "err", err)
-		return nil
-	}
-
-	c.queryCheckPointHashCache, err = lru.New(10)
+	c.signsCache, err = lru.New(20)
 	if err != nil {
-		log.Error("NewCheckPointAPI: init queryCheckPointHashCache error", "err", err)
+		log.Error("NewCheckPointAPI: init signsCache error", "err", err)
 		return nil
 	}
 
 	return c
 }
 
-func (c *CheckPointAPI) GetCheckPointHashByIndex(ctx context.Context, index uint64) (*types.SignedCheckPointHash, error) {
-	c.queryCheckPointHashCacheMu.Lock()
-	defer c.queryCheckPointHashCacheMu.Unlock()
-
-	treePoint, err := c.ftl.BlockChain().GetCheckPointByIndex(index)
-	if err != nil {
-		return nil, err
+// getSigns returns the signs collected for the check point hash, including the sign of local key
+// and the signs received from the network.
+func (c *CheckPointAPI) getSigns(hash common.Hash) [][]byte {
+	signs := c.getLocalSigns(hash)
+	if signed := c.ftl.BlockChain().GetSignedCheckPoint(hash); signed != nil {
+		signs = types.MergeCheckPointSigns(signs, signed.Signs)
 	}
-	treePointHash := treePoint.Hash()
+	return signs
+}
 
-	if p, ok := c.queryCheckPointHashCache.Get(treePointHash); ok {
-		return p.(*types.SignedCheckPointHash), nil
-	}
+func (c *CheckPointAPI) getLocalSigns(hash common.Hash) [][]byte {
+	c.signsCacheMu.Lock()
+	defer c.signsCacheMu.Unlock()
 
-	ret := &types.SignedCheckPointHash{
-		Hash: treePointHash,
+	if p, ok := c.signsCache.Get(hash); ok {
+		return p.([][]byte)
 	}
 
 	key := c.checkPointPriKey
 	if key == nil || reflect.ValueOf(key).IsNil() {
-		return ret, nil
+		return nil
 	}
 
-	sign, err := key.Sign(treePointHash[:])
+	sign, err := key.Sign(hash[:])
 	if err != nil {
-		return ret, nil
+		return nil
 	}
 
-	ret.Sign = sign
-	c.queryCheckPointHashCache.Add(treePointHash, ret)
-
-	return ret, nil
+	signs := [][]byte{sign}
+	c.signsCache.Add(hash, signs)
+	return signs
 }
 
-func (c *CheckPointAPI) GetCheckPointByIndex(ctx context.Context, index uint64) (*types.SignedCheckPoint, error) {
-	c.queryCheckPointCacheMu.Lock()
-	defer c.queryCheckPointCacheMu.Unlock()
-
+func (c *CheckPointAPI) GetCheckPointHashByIndex(ctx context.Context, index uint64) (*types.SignedCheckPointHash, error) {
 	treePoint, err := c.ftl.BlockChain().GetCheckPointByIndex(index)
 	if err != nil {
 		return nil, err
 	}
 
-	if p, ok := c.queryCheckPointCache.Get(treePoint.Hash()); ok {
-		return p.(*types.SignedCheckPoint), nil
-	}
-
-	ret := &types.SignedCheckPoint{
-		CheckPoint: treePoint,
-	}
-
-	key := c.checkPointPriKey
-	if key == nil || reflect.ValueOf(key).IsNil() {
-		return ret, nil
-	}
-
 	hash := treePoint.Hash()
-	sign, err := key.Sign(hash[:])
+	return &types.SignedCheckPointHash{
+		Hash:  hash,
+		Signs: c.getSigns(hash),
+	}, nil
+}
+
+func (c *CheckPointAPI) GetCheckPointByIndex(ctx context.Context, index uint64) (*types.SignedCheckPoint, error) {
+	treePoint, err := c.ftl.BlockChain().GetCheckPointByIndex(index)
 	if err != nil {
-		return ret, nil
+		return nil, err
 	}
 
-	ret.Sign = sign
-	c.queryCheckPointCache.Add(hash, ret)
-
-	return ret, nil
+	return &types.SignedCheckPoint{
+		CheckPoint: treePoint,
+		Signs:      c.getSigns(treePoint.Hash()),
+	}, nil
 }
 
 func (c *CheckPointAPI) GetLastCheckPointHash(ctx context.Context) *types.SignedCheckPointHash {
-	c.queryCheckPointHashCacheMu.Lock()
-	defer c.queryCheckPointHashCacheMu.Unlock()
-
-	treePointHash := c.ftl.BlockChain().GetCheckPoint().Hash()
-
-	if p, ok := c.queryCheckPointHashCache.Get(treePointHash); ok {
-		return p.(*types.SignedCheckPointHash)
-	}
-
-	ret := &types.SignedCheckPointHash{
-		Hash: treePointHash,
-	}
-
-	key := c.checkPointPriKey
-	if key == nil || reflect.ValueOf(key).IsNil() {
-		return ret
-	}
-
-	sign, err := key.Sign(treePointHash[:])
-	if err != nil {
-		return ret
+	hash := c.ftl.BlockChain().GetCheckPoint().Hash()
+	return &types.SignedCheckPointHash{
+		Hash:  hash,
+		Signs: c.getSigns(hash),
 	}
-
-	ret.Sign = sign
-	c.queryCheckPointHashCache.Add(treePointHash, ret)
-
-	return ret
 }
 
 func (c *CheckPointAPI) GetLastCheckPoint(ctx context.Context) *types.SignedCheckPoint {
-	c.queryCheckPointCacheMu.Lock()
-	defer c.queryCheckPointCacheMu.Unlock()
-
 	treePoint := c.ftl.BlockChain().GetCheckPoint()
 	log.Info("get last check point from local", "treePoint", treePoint)
 
-	if p, ok := c.queryCheckPointCache.Get(treePoint.Hash()); ok {
-		return p.(*types.SignedCheckPoint)
-	}
-
-	ret := &types.SignedCheckPoint{
+	return &types.SignedCheckPoint{
 		CheckPoint: treePoint,
+		Signs:      c.getSigns(treePoint.Hash()),
 	}
+}
 
-	key := c.checkPointPriKey
-	if key == nil || reflect.ValueOf(key).IsNil() {
-		return ret
+// SubmitCheckPointSigns collects the partial signs of other check point authorities,
+// so that this node can serve a check point signed by enough authorities.
+// It returns the number of signs collected for the check point hash.
+func (c *CheckPointAPI) SubmitCheckPointSigns(ctx context.Context, signed types.SignedCheckPointHash) (int, error) {
+	authorities, _ := c.ftl.Config().ChainConfig.CheckPointAuthoritySet()
+
+	var valid [][]byte
+	for _, sign := range signed.Signs {
+		if err := types.VerifyCheckPointSigns(signed.Hash, [][]byte{sign}, authorities, 1); err != nil {
+			log.Warn("SubmitCheckPointSigns: ignore invalid sign", "hash", signed.Hash, "err", err)
+			continue
+		}
+		valid = append(valid, sign)
+	}
+	if len(valid) == 0 {
+		return 0, types.ErrCheckPointSignNotAuthority
 	}
 
-	hash := treePoint.Hash()
-	sign, err := key.Sign(hash[:])
-	if err != nil {
-		return ret
+	signs := types.MergeCheckPointSigns(c.getLocalSigns(signed.Hash), valid)
+
+	c.signsCacheMu.Lock()
+	if p, ok := c.signsCache.Get(signed.Hash); ok {
+		signs = types.MergeCheckPointSigns(p.([][]byte), signs)
 	}
+	c.signsCache.Add(signed.Hash, signs)
+	c.signsCacheMu.Unlock()
 
-	ret.Sign = sign
-	c.queryCheckPointCache.Add(hash, ret)
+	// propagate the check point with the collected signs if we have it
+	if checkPoint := c.findCheckPoint(signed.Hash); checkPoint != nil {
+		c.ftl.BlockChain().InsertSignedCheckPoint(&types.SignedCheckPoint{CheckPoint: checkPoint, Signs: signs})
+	}
+	return len(signs), nil
+}
 
-	return ret
+func (c *CheckPointAPI) findCheckPoint(hash common.Hash) *types.CheckPoint {
+	if checkPoint := c.ftl.BlockChain().GetCheckPoint(); checkPoint != nil && checkPoint.Hash() == hash {
+		return checkPoint
+	}
+	if signed := c.ftl.BlockChain().GetSignedCheckPoint(hash); signed != nil {
+		return signed.CheckPoint
+	}
+	return nil
 }
 
 func (c *CheckPointAPI) StartCreateCheckPoint(ctx context.Context) {
@@ -0,0 +1,89 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package api
+
+import (
+	"errors"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/core/wasm"
+)
+
+// TransactionTrace is the result of a traced transaction, with the tree of
+// the contract calls it made.
+type TransactionTrace struct {
+	TxHash  common.Hash       `json:"txHash"`
+	Failed  bool              `json:"failed"`
+	GasUsed hexutil.Uint64    `json:"gasUsed"`
+	Calls   []*wasm.CallTrace `json:"calls"`
+}
+
+// DebugAPI is the collection of debugging API methods.
+type DebugAPI struct {
+	ftl fractal
+}
+
+// NewDebugAPI creates a new DebugAPI.
+func NewDebugAPI(ftl fractal) *DebugAPI {
+	return &DebugAPI{ftl}
+}
+
+// TraceTransaction re-executes the block the transaction is executed in on
+// the state of its parent, and returns the contract calls of the transaction.
+func (api *DebugAPI) TraceTransaction(hash common.Hash) (*TransactionTrace, error) {
+	bc := api.ftl.BlockChain()
+	tx, blockFullHash := ReadTransaction(bc, hash)
+	if tx == nil {
+		return nil, errors.New("transaction not found")
+	}
+	block := bc.GetBlock(blockFullHash)
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+
+	tracer, receipts, err := bc.TraceBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	for _, receipt := range receipts {
+		if receipt.TxHash == hash {
+			return &TransactionTrace{
+				TxHash:  hash,
+				Failed:  receipt.Status == types.ReceiptStatusFailed,
+				GasUsed: hexutil.Uint64(receipt.GasUsed),
+				Calls:   tracer.Calls(hash),
+			}, nil
+		}
+	}
+	return nil, errors.New("transaction not executed in block")
+}
+
+// TraceCall executes the call like ftl_call on top of the block, and returns
+// the contract calls it made.
+func (api *DebugAPI) TraceCall(args SendTxArgs, blockStr *string) (*TransactionTrace, error) {
+	block := api.ftl.BlockChain().CurrentBlock()
+	if blockStr != nil {
+		block = api.ftl.GetBlockStr(*blockStr)
+		if block == nil {
+			return nil, errors.New("block not found")
+		}
+	}
+
+	if err := args.setDefaults(api.ftl); err != nil {
+		return nil, err
+	}
+
+	tracer := wasm.NewCallTracer()
+	_, useGas, wasmFailed, err := doCall(api.ftl, block, &args, uint64(*args.Gas), nil, tracer)
+	if err != nil && !wasmFailed {
+		return nil, err
+	}
+	return &TransactionTrace{
+		Failed:  wasmFailed,
+		GasUsed: hexutil.Uint64(useGas),
+		Calls:   tracer.Calls(common.Hash{}),
+	}, nil
+}
@@ -7,6 +7,7 @@ package api
 import (
 	"context"
 	"math/big"
+	"sort"
 
 	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/common/hexutil"
@@ -30,6 +31,7 @@ type FilterCriteria struct {
 	BlockHash       *common.Hash     // used by ftl_getLogs, return logs only from block with this hash
 	FromBlockHeight *hexutil.Big     // beginning of the queried range, nil means genesis block
 	ToBlockHeight   *hexutil.Big     // end of the range, nil means latest block
+	BlockTag        string           // "finalized" restricts matches to finalized blocks
 	Addresses       []common.Address // restricts matches to events created by specific contracts
 
 	// The Topic list restricts matches to particular event topics. Each event has a list
@@ -68,6 +70,15 @@ func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*type
 		if crit.ToBlockHeight != nil && (*big.Int)(crit.ToBlockHeight).Cmp(common.Big0) > 0 {
 			end = (*big.Int)(crit.ToBlockHeight).Int64()
 		}
+		if isFinalizedTag(&crit.BlockTag) {
+			finalized := api.ftl.BlockChain().GetFinalizedBlock()
+			if finalized == nil {
+				return []*types.Log{}, nil
+			}
+			if end == -1 || uint64(end) > finalized.Header.Height {
+				end = int64(finalized.Header.Height)
+			}
+		}
 		// Construct the range filter
 		filter = bloomquery.NewRangeFilter(api.ftl, begin, end, crit.Addresses, crit.Topics)
 	}
@@ -79,6 +90,27 @@ func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*type
 	return returnLogs(logs), err
 }
 
+// stableHeight returns the height under which the logs are notified. It is the
+// finalized height with the "finalized" tag, or stableDistance below the head.
+func (api *FilterAPI) stableHeight(ctx context.Context, crit FilterCriteria, stableDistance uint64) (uint64, bool) {
+	if isFinalizedTag(&crit.BlockTag) {
+		finalized := api.ftl.BlockChain().GetFinalizedBlock()
+		if finalized == nil {
+			return 0, false
+		}
+		return finalized.Header.Height, true
+	}
+
+	currentBlock := api.ftl.CurrentBlock(ctx)
+	if currentBlock.Header.Height < stableDistance {
+		return 0, false
+	}
+	return currentBlock.Header.Height - stableDistance, true
+}
+
+// SubLogs notifies the logs once their blocks are stable. When blocks leave
+// the main branch, the logs already notified for them are sent again with
+// removed set to true.
 func (api *FilterAPI) SubLogs(ctx context.Context, crit FilterCriteria, stableDistance uint64) (*rpcserver.Subscription, error) {
 	notifier, supported := rpcserver.NotifierFromContext(ctx)
 	if !supported {
@@ -88,11 +120,108 @@ func (api *FilterAPI) SubLogs(ctx context.Context, crit FilterCriteria, stableDi
 	rpcSub := notifier.CreateSubscription()
 	var (
 		unstableBlockLogMap = make(map[uint64][]*types.Log)
+		notifiedHeight      = int64(-1) // logs under this height are notified
 
-		ch  = make(chan types.BloomInsertEvent, 128)
-		sub = api.ftl.SubscribeInsertBloomEvent(ch)
+		ch       = make(chan types.BloomInsertEvent, 128)
+		sub      = api.ftl.SubscribeInsertBloomEvent(ch)
+		reorgCh  = make(chan types.ChainReorgEvent, 16)
+		reorgSub = api.ftl.BlockChain().SubscribeChainReorgEvent(reorgCh)
 	)
+	blockLogs := func(block *types.Block) ([]*types.Log, error) {
+		filter := bloomquery.NewBlockFilter(api.ftl, block.FullHash(), crit.Addresses, crit.Topics)
+		return filter.Logs(ctx, 0)
+	}
+	notifyStable := func() {
+		stableHeight, ok := api.stableHeight(ctx, crit, stableDistance)
+		if !ok {
+			return
+		}
+		// notify in the order of the heights, so that a subscriber can resume
+		// from the last height it received
+		var heights []uint64
+		for key := range unstableBlockLogMap {
+			if key <= stableHeight {
+				heights = append(heights, key)
+			}
+		}
+		sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+		for _, key := range heights {
+			value := unstableBlockLogMap[key]
+			if len(value) > 0 {
+				// is stable
+				log.Info("SubLogs: logs found in new coming data.", "stableHeight", stableHeight, "thisHeight", key, "logsNumber", len(value))
+				if err := notifier.Notify(rpcSub.ID, value); err != nil {
+					log.Error("SubLogs: notify new logs error", "err", err)
+				}
+			}
+			delete(unstableBlockLogMap, key)
+		}
+		if int64(stableHeight) > notifiedHeight {
+			notifiedHeight = int64(stableHeight)
+		}
+	}
+	handleBloom := func(e types.BloomInsertEvent) {
+		// 1. check the coming block
+		logs, err := blockLogs(e.Block)
+		if err != nil {
+			log.Error("SubLogs: get coming block logs error", "err", err)
+		} else {
+			unstableBlockLogMap[e.Block.Header.Height] = logs // Directly overwritten, because the later data must be more accurate than before
+		}
+
+		// 2. notify the stable logs
+		notifyStable()
+	}
+	handleReorg := func(e types.ChainReorgEvent) {
+		// 1. revert the logs notified for the dropped blocks
+		for _, block := range e.Dropped {
+			delete(unstableBlockLogMap, block.Header.Height)
+			if int64(block.Header.Height) > notifiedHeight {
+				continue
+			}
+			logs, err := blockLogs(block)
+			if err != nil || len(logs) == 0 {
+				continue
+			}
+			log.Info("SubLogs: logs removed by reorg.", "thisHeight", block.Header.Height, "logsNumber", len(logs))
+			if err := notifier.Notify(rpcSub.ID, removedLogs(logs)); err != nil {
+				log.Error("SubLogs: notify removed logs error", "err", err)
+			}
+		}
+
+		// 2. notify the logs of the added blocks once they are stable
+		for _, block := range e.Added {
+			if logs, err := blockLogs(block); err == nil {
+				unstableBlockLogMap[block.Header.Height] = logs
+			}
+		}
+		notifyStable()
+	}
 	go func() {
+		defer sub.Unsubscribe()
+		defer reorgSub.Unsubscribe()
+
+		// the history query may take long, keep the events coming meanwhile
+		// so that the feeds are not blocked, and handle them after it
+		var (
+			pending   []interface{}
+			replayed  = make(chan struct{})
+			collected = make(chan struct{})
+		)
+		go func() {
+			defer close(collected)
+			for {
+				select {
+				case e := <-ch:
+					pending = append(pending, e)
+				case e := <-reorgCh:
+					pending = append(pending, e)
+				case <-replayed:
+					return
+				}
+			}
+		}()
+
 		// a) history chain data
 		log.Info("SubLogs: 1.Query logs from history data.")
 		for {
@@ -105,11 +234,8 @@ func (api *FilterAPI) SubLogs(ctx context.Context, crit FilterCriteria, stableDi
 				end = (*big.Int)(crit.ToBlockHeight).Int64()
 			}
 
-			currentBlock := api.ftl.CurrentBlock(ctx)
-			if currentBlock.Header.Height < stableDistance {
-				break
-			}
-			stableHeight := currentBlock.Header.Height - stableDistance
+			// the logs are kept until they are stable if no block is stable yet
+			stableHeight, ok := api.stableHeight(ctx, crit, stableDistance)
 
 			// Construct the range filter
 			filter := bloomquery.NewRangeFilter(api.ftl, begin, end, crit.Addresses, crit.Topics)
@@ -120,7 +246,7 @@ func (api *FilterAPI) SubLogs(ctx context.Context, crit FilterCriteria, stableDi
 				break
 			}
 			for _, value := range logs {
-				if value.BlockNumber > stableHeight {
+				if !ok || value.BlockNumber > stableHeight {
 					unstableBlockLogMap[value.BlockNumber] = append(unstableBlockLogMap[value.BlockNumber], value)
 				} else {
 					// is stable
@@ -130,6 +256,20 @@ func (api *FilterAPI) SubLogs(ctx context.Context, crit FilterCriteria, stableDi
 					}
 				}
 			}
+			if ok {
+				notifiedHeight = int64(stableHeight)
+			}
+			break
+		}
+		close(replayed)
+		<-collected
+		for _, e := range pending {
+			switch e := e.(type) {
+			case types.BloomInsertEvent:
+				handleBloom(e)
+			case types.ChainReorgEvent:
+				handleReorg(e)
+			}
 		}
 
 		// b) new coming data
@@ -137,37 +277,12 @@ func (api *FilterAPI) SubLogs(ctx context.Context, crit FilterCriteria, stableDi
 		for {
 			select {
 			case e := <-ch:
-				// 1. check the coming block
-				filter := bloomquery.NewBlockFilter(api.ftl, e.Block.FullHash(), crit.Addresses, crit.Topics)
-				logs, err := filter.Logs(ctx, 0)
-				if err != nil {
-					log.Error("SubLogs: get coming block logs error", "err", err)
-				} else {
-					unstableBlockLogMap[e.Block.Header.Height] = logs // Directly overwritten, because the later data must be more accurate than before
-				}
-
-				// 2. notify the stable logs
-				currentBlock := api.ftl.CurrentBlock(ctx)
-				if currentBlock.Header.Height < stableDistance {
-					continue
-				}
-				stableHeight := currentBlock.Header.Height - stableDistance
-				for key, value := range unstableBlockLogMap {
-					if len(value) > 0 && key <= stableHeight {
-						// is stable
-						log.Info("SubLogs: logs found in new coming data.", "stableHeight", stableHeight, "thisHeight", key, "logsNumber", len(value))
-						if err := notifier.Notify(rpcSub.ID, value); err != nil {
-							log.Error("SubLogs: notify new logs error", "err", err)
-						}
-						delete(unstableBlockLogMap, key)
-					}
-				}
-
+				handleBloom(e)
+			case e := <-reorgCh:
+				handleReorg(e)
 			case <-rpcSub.Err():
-				sub.Unsubscribe()
 				return
 			case <-notifier.Closed():
-				sub.Unsubscribe()
 				return
 			}
 		}
@@ -175,3 +290,14 @@ func (api *FilterAPI) SubLogs(ctx context.Context, crit FilterCriteria, stableDi
 
 	return rpcSub, nil
 }
+
+// removedLogs returns copies of the logs marked as removed.
+func removedLogs(logs []*types.Log) []*types.Log {
+	removed := make([]*types.Log, len(logs))
+	for i, l := range logs {
+		cpy := *l
+		cpy.Removed = true
+		removed[i] = &cpy
+	}
+	return removed
+}
@@ -5,8 +5,14 @@
 package api
 
 import (
+	"fmt"
+	"math/big"
+
 	"github.com/fractal-platform/fractal/common"
 	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/transaction/txexec"
+	"github.com/fractal-platform/fractal/utils/log"
 )
 
 // FractalAPI provides an API to access Fractal related information.
@@ -34,3 +40,115 @@ func (s *FractalAPI) ProtocolVersion() hexutil.Uint {
 func (s *FractalAPI) ChainId() hexutil.Uint64 {
 	return hexutil.Uint64(s.ftl.Config().ChainConfig.ChainID)
 }
+
+// GasPrice returns the gas price suggested from the transactions of the
+// recent main branch blocks.
+func (s *FractalAPI) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(s.ftl.GasPriceOracle().SuggestPrice())
+}
+
+// FeeHistoryResult is the gas prices of the transactions in a range of main
+// branch blocks, at the percentiles queried.
+type FeeHistoryResult struct {
+	OldestBlock  hexutil.Uint64   `json:"oldestBlock"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+}
+
+// FeeHistory returns the gas prices at the percentiles of the transactions
+// in each of the last blocks of the main branch, from the oldest to the head.
+func (s *FractalAPI) FeeHistory(blocks hexutil.Uint64, percentiles []float64) (*FeeHistoryResult, error) {
+	history, err := s.ftl.GasPriceOracle().FeeHistory(int(blocks), percentiles)
+	if err != nil {
+		return nil, err
+	}
+	result := &FeeHistoryResult{
+		OldestBlock:  hexutil.Uint64(history.OldestBlock),
+		GasUsedRatio: history.GasUsedRatio,
+	}
+	if len(percentiles) > 0 {
+		result.Reward = make([][]*hexutil.Big, len(history.Rewards))
+		for i, rewards := range history.Rewards {
+			result.Reward[i] = make([]*hexutil.Big, len(rewards))
+			for j, reward := range rewards {
+				result.Reward[i][j] = (*hexutil.Big)(reward)
+			}
+		}
+	}
+	return result, nil
+}
+
+// EstimateGasError is returned by EstimateGas when the message fails even with
+// the highest gas limit allowed, so no gas limit can make it succeed.
+type EstimateGasError struct {
+	GasLimit   hexutil.Uint64 `json:"gasLimit"`
+	WasmFailed bool           `json:"wasmFailed"`
+	Reason     string         `json:"reason"`
+}
+
+func (e *EstimateGasError) Error() string {
+	return fmt.Sprintf("gas required exceeds allowance (%d) or always failing transaction: %s", uint64(e.GasLimit), e.Reason)
+}
+
+func (e *EstimateGasError) ErrorCode() int { return -32000 }
+
+func (e *EstimateGasError) ErrorData() interface{} { return e }
+
+// EstimateGas returns the smallest gas limit for which the message described
+// by args executes successfully on top of the current block. The gas of args,
+// if given, caps the search.
+func (s *FractalAPI) EstimateGas(args SendTxArgs) (hexutil.Uint64, error) {
+	var gasCap uint64
+	if args.Gas != nil {
+		gasCap = uint64(*args.Gas)
+	}
+	if err := args.setDefaults(s.ftl); err != nil {
+		return 0, err
+	}
+
+	// Every message pays at least the intrinsic gas, and a contract creation
+	// pays for storing its code as well.
+	intrinsicGas, err := txexec.IntrinsicGas(*args.Data, args.To == nil)
+	if err != nil {
+		return 0, err
+	}
+	if args.To == nil {
+		intrinsicGas += uint64(len(*args.Data)) * params.TxGasContractCreateData
+	}
+	block := s.ftl.BlockChain().CurrentBlock()
+	lo, hi := intrinsicGas-1, gasCap
+	if hi == 0 {
+		hi = block.Header.GasLimit
+	}
+
+	// The sender has to be able to pay for the gas
+	price := (*big.Int)(args.GasPrice)
+	if price.Sign() > 0 {
+		stateDb, err := s.ftl.BlockChain().StateAt(block.Header.StateHash)
+		if err != nil {
+			return 0, err
+		}
+		available := new(big.Int).Sub(stateDb.GetBalance(args.From), (*big.Int)(args.Value))
+		if available.Sign() >= 0 {
+			allowance := available.Div(available, price)
+			if allowance.IsUint64() && allowance.Uint64() < hi {
+				hi = allowance.Uint64()
+			}
+		}
+	}
+
+	_, _, wasmFailed, err := doCall(s.ftl, block, &args, hi, nil, nil)
+	if err != nil {
+		log.Warn("EstimateGas: execution failed with the gas allowance", "from", args.From, "to", args.To, "gas", hi, "err", err)
+		return 0, &EstimateGasError{GasLimit: hexutil.Uint64(hi), WasmFailed: wasmFailed, Reason: err.Error()}
+	}
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		if _, _, _, err := doCall(s.ftl, block, &args, mid, nil, nil); err != nil {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hexutil.Uint64(hi), nil
+}
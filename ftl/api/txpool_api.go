@@ -19,12 +19,14 @@ import (
 	"github.com/fractal-platform/fractal/common/hexutil"
 	"github.com/fractal-platform/fractal/core/config"
 	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/state"
 	"github.com/fractal-platform/fractal/core/types"
 	"github.com/fractal-platform/fractal/core/wasm"
+	"github.com/fractal-platform/fractal/ftl/router"
 	"github.com/fractal-platform/fractal/params"
 	"github.com/fractal-platform/fractal/rlp"
-	"github.com/fractal-platform/fractal/rpc/client"
 	"github.com/fractal-platform/fractal/transaction/txexec"
+	"github.com/fractal-platform/fractal/utils"
 	"github.com/fractal-platform/fractal/utils/log"
 )
 
@@ -157,20 +159,6 @@ func (s *TxPoolAPI) processBatchSend(threadNum int) {
 				request := <-s.batchSendChan
 				task := <-request
 
-				currentBlock := s.ftl.BlockChain().CurrentBlock()
-				packerInfo, _, err := s.ftl.BlockChain().GetPrePackerInfoByIndex(currentBlock, task.packerId)
-				if err != nil {
-					log.Error("cannot get packerInfo", "packerIndex", task.packerId, "err", err)
-					request <- task
-					continue
-				}
-				rpcAddr := packerInfo.RpcAddress
-				client, err := rpcclient.Dial(rpcAddr)
-				if err != nil {
-					log.Error("connect to rpc error", "rpc", rpcAddr, "packerIndex", task.packerId, "err", err)
-					request <- task
-					continue
-				}
 				encodedTxs, err := rlp.EncodeToBytes(task.txs)
 				if err != nil {
 					log.Error("encode txs error", "err", err)
@@ -179,9 +167,9 @@ func (s *TxPoolAPI) processBatchSend(threadNum int) {
 				}
 
 				var packErrors []string
-				err = client.Call(&packErrors, "pack_sendRawTransactions", encodedTxs)
+				err = s.ftl.PackerRouter().CallPacker(&packErrors, task.packerId, "pack_sendRawTransactions", hexutil.Bytes(encodedTxs))
 				if err != nil {
-					log.Error("send tx to packer error:", "rpc", rpcAddr, "packerIndex", task.packerId, "err", err)
+					log.Error("send tx to packer error:", "packerIndex", task.packerId, "err", err)
 				}
 				log.Info("send one batch to packer", "packerId", task.packerId, "tx num", len(task.txs))
 				task.errs = packErrors
@@ -236,20 +224,30 @@ func ReadTransaction(bc *chain.BlockChain, hash common.Hash) (*types.Transaction
 	}
 }
 
-// GetTransactionByHash returns the transaction for the given hash
-func (s *TxPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+// GetTransactionByHash returns the transaction for the given hash. With the
+// "finalized" tag, only a transaction in a finalized block is returned.
+func (s *TxPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash, blockTag *string) *RPCTransaction {
 	// Try to return an already finalized transaction
 	tx, blockHash := ReadTransaction(s.ftl.BlockChain(), hash);
 	if tx == nil {
 		tx, blockHash = s.ftl.BlockChain().SearchTransactionInCache(hash)
 	}
+	if isFinalizedTag(blockTag) {
+		// only the transactions in the finalized blocks are returned
+		if tx == nil || !s.ftl.BlockChain().IsFinalized(s.ftl.BlockChain().GetBlock(blockHash)) {
+			return nil
+		}
+	}
 	if tx != nil {
-		var txReceipt *types.Receipt
-		receipts := dbaccessor.ReadReceipts(s.ftl.ChainDb(), blockHash)
-		for _, value := range receipts {
-			if value.TxHash == hash {
-				txReceipt = value
-				break
+		txReceipt, _, _ := dbaccessor.ReadReceipt(s.ftl.ChainDb(), hash)
+		if txReceipt == nil {
+			// transactions in the cache have no lookup entry yet
+			receipts := dbaccessor.ReadReceipts(s.ftl.ChainDb(), blockHash)
+			for _, value := range receipts {
+				if value.TxHash == hash {
+					txReceipt = value
+					break
+				}
 			}
 		}
 		return newRPCTransaction(tx, blockHash, txReceipt, s.chainConfig)
@@ -348,11 +346,6 @@ func (s *TxPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.By
 
 		txPackingHashUint64 := tx.PackingHashUint64(s.ftl.Signer())
 
-		var (
-			packerInfo *types.PackerInfo
-			client     *rpcclient.Client
-		)
-
 		packerIndex := uint32(txPackingHashUint64 % s.chainConfig.PackerGroupSize)
 		var allowedPackerIndexList []uint32
 		for packerIndex < packerNumber {
@@ -360,40 +353,11 @@ func (s *TxPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.By
 			packerIndex += uint32(s.chainConfig.PackerGroupSize)
 		}
 
-		for {
-			if len(allowedPackerIndexList) == 0 {
-				break
-			}
-
-			r := rand.New(rand.NewSource(time.Now().UnixNano()))
-			groupId := r.Intn(len(allowedPackerIndexList))
-			randPackerIndex := allowedPackerIndexList[groupId]
-
-			packerInfo, _, err = s.ftl.BlockChain().GetPrePackerInfoByIndex(currentBlock, randPackerIndex)
-			if err != nil {
-				log.Error("cannot get packerInfo", "packerIndex", randPackerIndex, "err", err)
-				allowedPackerIndexList = append(allowedPackerIndexList[0:groupId], allowedPackerIndexList[groupId+1:]...)
-				continue
-			}
-			rpcAddr := packerInfo.RpcAddress
-
-			client, err = rpcclient.Dial(rpcAddr)
-			if err != nil {
-				log.Error("connect to rpc error", "rpc", rpcAddr, "packerIndex", randPackerIndex, "err", err)
-				allowedPackerIndexList = append(allowedPackerIndexList[0:groupId], allowedPackerIndexList[groupId+1:]...)
-				continue
-			}
-			var hash common.Hash
-			err = client.Call(&hash, "pack_sendRawTransaction", encodedTx)
-			if err != nil {
-				log.Error("send tx to packer error:", "rpc", rpcAddr, "packerIndex", randPackerIndex, "err", err)
-				allowedPackerIndexList = append(allowedPackerIndexList[0:groupId], allowedPackerIndexList[groupId+1:]...)
-				continue
-			}
-			return hash, nil
+		var hash common.Hash
+		if err := s.ftl.PackerRouter().Call(&hash, allowedPackerIndexList, "pack_sendRawTransaction", encodedTx); err != nil {
+			return common.Hash{}, err
 		}
-
-		return common.Hash{}, err
+		return hash, nil
 	}
 }
 
@@ -456,7 +420,7 @@ func (args *SendTxArgs) setDefaults(ftl fractal) error {
 		*(*uint64)(args.Gas) = 1e7
 	}
 	if args.GasPrice == nil {
-		price := ftl.GasPrice()
+		price := ftl.GasPriceOracle().SuggestPrice()
 		args.GasPrice = (*hexutil.Big)(price)
 	}
 	if args.Value == nil {
@@ -475,7 +439,7 @@ func (args *SendTxArgs) setDefaults(ftl fractal) error {
 		if len(input) == 0 {
 			return errors.New(`contract creation without any data provided`)
 		}
-	} else {
+	} else if args.Data == nil {
 		args.Data = &hexutil.Bytes{}
 	}
 	return nil
@@ -487,7 +451,53 @@ type CallResult struct {
 	//Print   string
 }
 
-func (s *TxPoolAPI) Call(args SendTxArgs) (CallResult, error) {
+// StorageOverride replaces one storage entry of a contract, addressed the same
+// way as in GetStorageAt.
+type StorageOverride struct {
+	Table string        `json:"table"`
+	Key   hexutil.Bytes `json:"key"`
+	Value hexutil.Bytes `json:"value"`
+}
+
+// AccountOverride holds the account fields replaced for a single call. Fields
+// left nil keep their value in the state of the block.
+type AccountOverride struct {
+	Balance *hexutil.Big      `json:"balance"`
+	Code    *hexutil.Bytes    `json:"code"`
+	Owner   *common.Address   `json:"owner"`
+	Storage []StorageOverride `json:"storage"`
+}
+
+// StateOverride is the set of accounts replaced for a single call.
+type StateOverride map[common.Address]AccountOverride
+
+// apply writes the overrides into the state, without committing them.
+func (overrides StateOverride) apply(stateDb *state.StateDB) error {
+	for addr, account := range overrides {
+		if account.Balance != nil {
+			stateDb.SetBalance(addr, (*big.Int)(account.Balance))
+		}
+		if account.Code != nil {
+			stateDb.SetCode(addr, *account.Code)
+		}
+		if account.Owner != nil {
+			stateDb.SetContractOwner(addr, *account.Owner)
+		}
+		for _, storage := range account.Storage {
+			table, err := utils.String2Uint64(storage.Table)
+			if err != nil {
+				return err
+			}
+			stateDb.SetState(addr, state.GetStorageKey(table, storage.Key), storage.Value)
+		}
+	}
+	return stateDb.Error()
+}
+
+// Call executes the message on top of the given block, which is "latest", a
+// block full hash or a block height. The overrides are applied to the state
+// of the block before the execution, and are dropped afterwards.
+func (s *TxPoolAPI) Call(args SendTxArgs, blockStr *string, overrides *StateOverride) (CallResult, error) {
 	defer func(start time.Time) { log.Debug("Executing WASM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	if args.To == nil {
@@ -495,31 +505,30 @@ func (s *TxPoolAPI) Call(args SendTxArgs) (CallResult, error) {
 	}
 
 	block := s.ftl.BlockChain().CurrentBlock()
-	stateDb, _ := s.ftl.BlockChain().StateAt(block.Header.StateHash)
-	prevStateDb, _, _ := s.ftl.BlockChain().GetStateBeforeCacheHeight(block, uint8(params.ConfirmHeightDistance))
+	if blockStr != nil {
+		block = s.ftl.GetBlockStr(*blockStr)
+		if block == nil {
+			return CallResult{}, errors.New("block not found")
+		}
+	}
 
 	err := args.setDefaults(s.ftl)
 	if err != nil {
 		return CallResult{}, err
 	}
 
-	msg := types.NewMessage(args.From, args.To, uint64(*args.Nonce), (*big.Int)(args.Value), uint64(*args.Gas), (*big.Int)(args.GasPrice), *args.Data, false)
+	log.Info("TxPoolAPI Call", "data", hexutil.Encode(*args.Data), "from", args.From, "to", args.To, "block", block.FullHash())
 
-	log.Info("TxPoolAPI Call", "data", hexutil.Encode(*args.Data), "from", args.From, "to", args.To)
-
-	// Setup the gas pool (also for unmetered requests)
-	// and apply the message.
-	gp := new(types.GasPool).AddGas(math.MaxUint64)
-	coinBase := s.ftl.Coinbase()
-	stateDb.Prepare(common.Hash{}, 0, 0)
-	callbackParamKey := wasm.GetGlobalRegisterParam().RegisterParam(stateDb, block)
-	_, useGas, wasmFailed, err := txexec.WasmApplyMessage(prevStateDb, stateDb, msg, gp, s.ftl.BlockChain().GetChainConfig().MaxNonceBitLength, coinBase, callbackParamKey)
-	wasm.GetGlobalRegisterParam().UnRegisterParam(callbackParamKey)
+	var stateOverride StateOverride
+	if overrides != nil {
+		stateOverride = *overrides
+	}
+	stateDb, useGas, wasmFailed, err := doCall(s.ftl, block, &args, uint64(*args.Gas), stateOverride, nil)
 	if err != nil {
 		if wasmFailed {
 			log.Warn("TxPoolAPI Call: WASM execute failed", "err", err)
 		} else {
-			log.Error("TxPoolAPI Call: ApplyTransaction err", "from", msg.From(), "nonce", msg.Nonce(), "err", err)
+			log.Error("TxPoolAPI Call: ApplyTransaction err", "from", args.From, "nonce", uint64(*args.Nonce), "err", err)
 			return CallResult{}, err
 		}
 	}
@@ -530,6 +539,38 @@ func (s *TxPoolAPI) Call(args SendTxArgs) (CallResult, error) {
 	return CallResult{logs, hexutil.Uint64(useGas)}, err
 }
 
+// doCall applies the message described by args with the given gas limit on
+// top of the block, and returns the state it was applied to. The args must
+// have their defaults set. The contract calls are recorded by the tracer if
+// it is not nil.
+func doCall(ftl fractal, block *types.Block, args *SendTxArgs, gas uint64, overrides StateOverride, tracer *wasm.CallTracer) (*state.StateDB, uint64, bool, error) {
+	stateDb, err := ftl.BlockChain().StateAt(block.Header.StateHash)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if err := overrides.apply(stateDb); err != nil {
+		return nil, 0, false, err
+	}
+	prevStateDb, _, _ := ftl.BlockChain().GetStateBeforeCacheHeight(block, uint8(params.ConfirmHeightDistance))
+
+	msg := types.NewMessage(args.From, args.To, uint64(*args.Nonce), (*big.Int)(args.Value), gas, (*big.Int)(args.GasPrice), *args.Data, false)
+
+	// Setup the gas pool (also for unmetered requests)
+	// and apply the message.
+	gp := new(types.GasPool).AddGas(math.MaxUint64)
+	stateDb.Prepare(common.Hash{}, 0, 0)
+	callbackParamKey := wasm.GetGlobalRegisterParam().RegisterParam(stateDb, block)
+	if tracer != nil {
+		tracer.SetAbiReader(func(contract common.Address) []byte { return ftl.BlockChain().ContractAbi(stateDb, contract) })
+		wasm.GetGlobalRegisterParam().SetTracer(callbackParamKey, tracer)
+	}
+	chainConfig := ftl.BlockChain().GetChainConfig()
+	feeRecipient := chainConfig.Rewards().FeeRecipient(ftl.Coinbase())
+	_, useGas, wasmFailed, err := txexec.WasmApplyMessage(prevStateDb, stateDb, msg, gp, chainConfig.MaxNonceBitLength, chainConfig.Forks(), block.Header.Height+1, feeRecipient, callbackParamKey, txexec.NewWasmEngine(chainConfig.TxExecutorType))
+	wasm.GetGlobalRegisterParam().UnRegisterParam(callbackParamKey)
+	return stateDb, useGas, wasmFailed, err
+}
+
 //func (s *TxPoolAPI) GetReceipt(ctx context.Context, hash common.Hash) types.Receipts {
 //	return s.ftl.GetReceipts(ctx, hash)
 //}
@@ -538,6 +579,12 @@ func (s *TxPoolAPI) PendingTransactions() types.Transactions {
 	return s.ftl.GetPoolTransactions()
 }
 
+// PackerStatus returns the health and the forwarding metrics of the packers
+// transactions are routed to.
+func (s *TxPoolAPI) PackerStatus() []router.PackerStatus {
+	return s.ftl.PackerRouter().Status()
+}
+
 func (s *TxPoolAPI) GasPrice() *hexutil.Big {
 	return (*hexutil.Big)(s.ftl.GasPrice())
 }
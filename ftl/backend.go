@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/fractal-platform/fractal/chain"
@@ -17,9 +18,12 @@ import (
 	"github.com/fractal-platform/fractal/dbwrapper"
 	"github.com/fractal-platform/fractal/event"
 	"github.com/fractal-platform/fractal/ftl/api"
+	"github.com/fractal-platform/fractal/ftl/gasprice"
 	"github.com/fractal-platform/fractal/ftl/network"
 	"github.com/fractal-platform/fractal/ftl/protocol"
+	"github.com/fractal-platform/fractal/ftl/router"
 	ftl_sync "github.com/fractal-platform/fractal/ftl/sync"
+	"github.com/fractal-platform/fractal/ftl/txhistory"
 	"github.com/fractal-platform/fractal/keys"
 	"github.com/fractal-platform/fractal/logbloom/bloomquery"
 	"github.com/fractal-platform/fractal/logbloom/bloomstorage"
@@ -64,11 +68,15 @@ type Fractal struct {
 	checkPointNodeType types.CheckPointNodeTypeEnum
 
 	//
-	packer packer.Packer
+	packer       packer.Packer
+	packerRouter *router.Router
 
 	//
-	miner    miner.Miner
-	gasPrice *big.Int
+	miner          miner.Miner
+	gasPrice       *big.Int
+	gasPriceOracle *gasprice.Oracle
+
+	txHistory *txhistory.Indexer // nil if the tx history is not indexed
 
 	// for network
 	protocolManager *network.ProtocolManager
@@ -82,6 +90,12 @@ type Fractal struct {
 
 // New creates a new Fractal object.
 func NewFtl(cfg *config.Config) (*Fractal, error) {
+	return NewFtlWithClock(cfg, miner.SystemClock{})
+}
+
+// NewFtlWithClock creates a new Fractal object, whose miner seals the rounds
+// at the time of the clock.
+func NewFtlWithClock(cfg *config.Config, clock miner.Clock) (*Fractal, error) {
 	var err error
 	ftl := &Fractal{
 		config:        cfg,
@@ -111,6 +125,10 @@ func NewFtl(cfg *config.Config) (*Fractal, error) {
 		return nil, err
 	}
 	log.Info("Initialised chain configuration", "config", cfg.ChainConfig)
+	if err = txexec.CheckChainExecutor(cfg.ChainConfig.TxExecutorType); err != nil {
+		log.Error("invalid tx executor", "type", cfg.ChainConfig.TxExecutorType, "error", err.Error())
+		return nil, err
+	}
 
 	// setup genesis block
 	_, err = config.SetupGenesisBlock(ftl.chainDb, cfg.Genesis)
@@ -131,17 +149,33 @@ func NewFtl(cfg *config.Config) (*Fractal, error) {
 	}
 
 	// create blockchain
-	executor := txexec.NewExecutor(cfg.ChainConfig.TxExecutorType, cfg.ChainConfig.MaxNonceBitLength, ftl.signer)
+	executor := txexec.NewExecutor(cfg.ChainConfig.TxExecutorType, cfg.ChainConfig.MaxNonceBitLength, ftl.signer, cfg.ChainConfig.Rewards(), cfg.ChainConfig.Forks())
 	ftl.blockchain, err = chain.NewBlockChain(cfg, ftl.chainDb, executor, cfg.PackerInfoCacheSize, ftl.checkPointNodeType)
 	if err != nil {
 		log.Error("create blockchain failed", "error", err.Error())
 		return nil, err
 	}
+	if ftl.checkPointNodeType == types.SpecialNode {
+		ftl.blockchain.SetCheckPointPriKey(ftl.checkPointPriKey)
+	}
+
+	// setup gas price oracle
+	ftl.gasPriceOracle = gasprice.NewOracle(ftl.blockchain, gasprice.Config{
+		Blocks:     cfg.GPOBlocks,
+		Percentile: cfg.GPOPercentile,
+		Default:    ftl.gasPrice,
+	})
 
 	// setup bloom
 	ftl.bloomIndexer = bloomstorage.NewBloomIndexer(ftl.chainDb)
 	ftl.bloomIndexer.Start(ftl.blockchain)
 
+	// setup tx history
+	if cfg.TxHistory {
+		ftl.txHistory = txhistory.NewIndexer(ftl.blockchain, ftl.signer)
+		ftl.txHistory.Start()
+	}
+
 	// setup pool
 	if ftl.config.TxPoolConfig.Journal != "" {
 		ftl.config.TxPoolConfig.Journal = cfg.NodeConfig.ResolvePath(ftl.config.TxPoolConfig.Journal)
@@ -166,8 +200,11 @@ func NewFtl(cfg *config.Config) (*Fractal, error) {
 	// setup packer
 	ftl.packer = pksvc.NewPacker(ftl.config, ftl.pkgPool, ftl.packerKeyManager, ftl.signer, ftl.blockchain, ftl.config.ChainConfig.PackerGroupSize)
 
+	// setup router for forwarding txs to packers
+	ftl.packerRouter = router.NewRouter(ftl.blockchain)
+
 	// setup miner
-	ftl.miner = miner.NewFtlMiner(ftl.blockchain, executor, ftl.txPool, ftl.pkgPool, ftl.miningKeyManager)
+	ftl.miner = miner.NewFtlMiner(ftl.blockchain, executor, ftl.txPool, ftl.pkgPool, ftl.miningKeyManager, clock)
 	keys := ftl.miningKeyManager.Keys()
 	for addr := range keys {
 		log.Info("set coinbase", "coinbase", addr)
@@ -201,11 +238,21 @@ func (s *Fractal) Start() error {
 	s.startRPC()
 	s.startAdminRPC()
 
+	s.StartServices(s.server.MaxPeers)
+	return nil
+}
+
+// StartServices starts the internal goroutines without the p2p server and the
+// rpc servers. The peers are then run on the protocols returned by Protocols,
+// over any transport.
+func (s *Fractal) StartServices(maxPeers int) {
+	// start packer router
+	s.packerRouter.Start()
+
 	bloomquery.StartBloomHandlers(s.shutdownChan, s.bloomRequests, s.chainDb)
 
 	// Start the networking layer
-	s.protocolManager.Start(s.server.MaxPeers)
-	return nil
+	s.protocolManager.Start(maxPeers)
 }
 
 // Start create a live P2P node and starts running it.
@@ -287,15 +334,25 @@ func (s *Fractal) startAdminRPC() {
 func (s *Fractal) Stop() error {
 	close(s.shutdownChan)
 	s.bloomIndexer.Close()
+	if s.txHistory != nil {
+		s.txHistory.Stop()
+	}
 	s.miner.Close()
 	s.protocolManager.Stop()
 	s.packer.StopPacking()
 	s.pkgPool.Stop()
 	s.txPool.Stop()
 
-	s.adminRpcServer.Shutdown()
-	s.rpcServer.Shutdown()
-	s.server.Stop()
+	if s.adminRpcServer != nil {
+		s.adminRpcServer.Shutdown()
+	}
+	if s.rpcServer != nil {
+		s.rpcServer.Shutdown()
+	}
+	s.packerRouter.Stop()
+	if s.server != nil {
+		s.server.Stop()
+	}
 
 	s.blockchain.StopRecord()
 	s.miningKeyManager.Stop()
@@ -349,6 +406,10 @@ func (s *Fractal) apiList() []rpcserver.RpcApi {
 			Namespace: "txpool",
 			Version:   "1.0",
 			Service:   api.NewTxPoolAPI(s, s.config),
+		}, {
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   api.NewDebugAPI(s),
 		},
 	}
 }
@@ -365,15 +426,19 @@ func (s *Fractal) Miner() miner.Miner { return s.miner }
 
 func (s *Fractal) BlockChain() *chain.BlockChain        { return s.blockchain }
 func (s *Fractal) Packer() packer.Packer                { return s.packer }
+func (s *Fractal) PackerRouter() *router.Router         { return s.packerRouter }
 func (s *Fractal) Synchronizer() *ftl_sync.Synchronizer { return s.synchronizer }
 func (s *Fractal) TxPool() pool.Pool                    { return s.txPool }
 func (s *Fractal) ChainDb() dbwrapper.Database          { return s.chainDb }
 func (s *Fractal) IsListening() bool                    { return true } // Always listening
+func (s *Fractal) Protocols() []p2p.Protocol            { return s.protocolManager.SubProtocols }
 func (s *Fractal) FtlVersion() int                      { return int(s.protocolManager.SubProtocols[0].Version) }
 func (s *Fractal) NetVersion() uint64                   { return s.config.ChainConfig.ChainID }
 func (s *Fractal) Config() *config.Config               { return s.config }
 func (s *Fractal) Signer() types.Signer                 { return s.signer }
 func (s *Fractal) GasPrice() *big.Int                   { return s.gasPrice }
+func (s *Fractal) GasPriceOracle() *gasprice.Oracle     { return s.gasPriceOracle }
+func (s *Fractal) TxHistory() *txhistory.Indexer        { return s.txHistory }
 
 func (s *Fractal) GetPoolTransactions() types.Transactions {
 	content := s.txPool.Content()
@@ -398,9 +463,16 @@ func (s *Fractal) GetMainBranchBlock(height uint64) (*types.Block, error) {
 	return s.blockchain.GetMainBranchBlock(height)
 }
 
+// GetBlockStr returns the block given by "latest", a block height in decimal
+// or hex, or a block full hash.
 func (s *Fractal) GetBlockStr(blockStr string) *types.Block {
 	if strings.ToLower(blockStr) == "latest" {
 		return s.blockchain.CurrentBlock()
+	} else if strings.ToLower(blockStr) == "finalized" {
+		return s.blockchain.GetFinalizedBlock()
+	} else if height, err := strconv.ParseUint(blockStr, 0, 64); err == nil {
+		block, _ := s.blockchain.GetMainBranchBlock(height)
+		return block
 	} else {
 		blockHash := common.HexToHash(blockStr)
 		return s.blockchain.GetBlock(blockHash)
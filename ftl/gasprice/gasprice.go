@@ -0,0 +1,235 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package gasprice suggests gas prices from the transactions executed in the
+// recent main branch blocks.
+package gasprice
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	// maxFeeHistory is the most blocks returned by a fee history query.
+	maxFeeHistory = 1024
+
+	blockPriceCacheSize = 2048
+)
+
+var (
+	ErrInvalidBlockCount = errors.New("invalid block count")
+	ErrInvalidPercentile = errors.New("invalid reward percentile")
+	ErrNoMainBranchHead  = errors.New("main branch head not found")
+)
+
+type chainBackend interface {
+	GetMainBranchHead() *types.Block
+	GetMainBranchBlock(height uint64) (*types.Block, error)
+	GetTxPackageList(hashes []common.Hash) types.TxPackages
+}
+
+type Config struct {
+	Blocks     int      // number of recent main branch blocks sampled
+	Percentile int      // percentile of the sampled gas prices suggested
+	Default    *big.Int // price suggested when no transaction is sampled
+}
+
+// FeeHistory is the gas prices of the transactions in a range of main branch
+// blocks. Rewards holds, for each block, the gas price at every percentile
+// queried.
+type FeeHistory struct {
+	OldestBlock  uint64
+	GasUsedRatio []float64
+	Rewards      [][]*big.Int
+}
+
+// Oracle suggests the gas price as a percentile of the gas prices of the
+// transactions in the recent main branch blocks. The suggested price is
+// cached until the head of the main branch changes.
+type Oracle struct {
+	chain      chainBackend
+	blocks     int
+	percentile int
+	defaultGas *big.Int
+
+	priceCache *lru.Cache // block full hash -> sorted gas prices
+
+	cacheLock sync.RWMutex
+	lastHead  common.Hash
+	lastPrice *big.Int
+
+	fetchLock sync.Mutex
+}
+
+func NewOracle(chain chainBackend, cfg Config) *Oracle {
+	blocks := cfg.Blocks
+	if blocks < 1 {
+		blocks = 1
+	}
+	percentile := cfg.Percentile
+	if percentile < 0 {
+		percentile = 0
+	}
+	if percentile > 100 {
+		percentile = 100
+	}
+	defaultGas := cfg.Default
+	if defaultGas == nil {
+		defaultGas = common.Big1
+	}
+	priceCache, _ := lru.New(blockPriceCacheSize)
+	return &Oracle{
+		chain:      chain,
+		blocks:     blocks,
+		percentile: percentile,
+		defaultGas: defaultGas,
+		priceCache: priceCache,
+		lastPrice:  defaultGas,
+	}
+}
+
+// SuggestPrice returns the gas price at the configured percentile of the
+// transactions in the last blocks of the main branch.
+func (gpo *Oracle) SuggestPrice() *big.Int {
+	head := gpo.chain.GetMainBranchHead()
+	if head == nil {
+		return new(big.Int).Set(gpo.defaultGas)
+	}
+	headHash := head.FullHash()
+
+	gpo.cacheLock.RLock()
+	lastHead, lastPrice := gpo.lastHead, gpo.lastPrice
+	gpo.cacheLock.RUnlock()
+	if headHash == lastHead {
+		return new(big.Int).Set(lastPrice)
+	}
+
+	gpo.fetchLock.Lock()
+	defer gpo.fetchLock.Unlock()
+
+	// try checking the cache again, maybe the last fetch fetched what we need
+	gpo.cacheLock.RLock()
+	lastHead, lastPrice = gpo.lastHead, gpo.lastPrice
+	gpo.cacheLock.RUnlock()
+	if headHash == lastHead {
+		return new(big.Int).Set(lastPrice)
+	}
+
+	var prices []*big.Int
+	for height, i := head.Header.Height, 0; i < gpo.blocks; height, i = height-1, i+1 {
+		block := head
+		if height != head.Header.Height {
+			var err error
+			if block, err = gpo.chain.GetMainBranchBlock(height); err != nil {
+				break
+			}
+		}
+		prices = append(prices, gpo.blockPrices(block)...)
+		if height == 0 {
+			break
+		}
+	}
+
+	price := gpo.defaultGas
+	if len(prices) > 0 {
+		sort.Sort(bigIntArray(prices))
+		price = prices[(len(prices)-1)*gpo.percentile/100]
+	}
+
+	gpo.cacheLock.Lock()
+	gpo.lastHead = headHash
+	gpo.lastPrice = price
+	gpo.cacheLock.Unlock()
+	return new(big.Int).Set(price)
+}
+
+// FeeHistory returns the gas prices at the percentiles of the transactions in
+// each of the last blocks of the main branch, from the oldest to the head.
+func (gpo *Oracle) FeeHistory(blocks int, percentiles []float64) (*FeeHistory, error) {
+	if blocks < 1 {
+		return nil, ErrInvalidBlockCount
+	}
+	if blocks > maxFeeHistory {
+		blocks = maxFeeHistory
+	}
+	for i, p := range percentiles {
+		if p < 0 || p > 100 || (i > 0 && p < percentiles[i-1]) {
+			return nil, ErrInvalidPercentile
+		}
+	}
+
+	head := gpo.chain.GetMainBranchHead()
+	if head == nil {
+		return nil, ErrNoMainBranchHead
+	}
+	if uint64(blocks) > head.Header.Height+1 {
+		blocks = int(head.Header.Height + 1)
+	}
+
+	oldest := head.Header.Height + 1 - uint64(blocks)
+	history := &FeeHistory{
+		OldestBlock:  oldest,
+		GasUsedRatio: make([]float64, blocks),
+		Rewards:      make([][]*big.Int, blocks),
+	}
+	for i := 0; i < blocks; i++ {
+		block := head
+		if height := oldest + uint64(i); height != head.Header.Height {
+			var err error
+			if block, err = gpo.chain.GetMainBranchBlock(height); err != nil {
+				return nil, err
+			}
+		}
+		if block.Header.GasLimit > 0 {
+			history.GasUsedRatio[i] = float64(block.Header.GasUsed) / float64(block.Header.GasLimit)
+		}
+
+		prices := gpo.blockPrices(block)
+		rewards := make([]*big.Int, len(percentiles))
+		for j, p := range percentiles {
+			if len(prices) == 0 {
+				rewards[j] = new(big.Int)
+				continue
+			}
+			rewards[j] = new(big.Int).Set(prices[int(float64(len(prices)-1)*p/100)])
+		}
+		history.Rewards[i] = rewards
+	}
+	return history, nil
+}
+
+// blockPrices returns the sorted gas prices of the transactions executed in
+// the block, which are the transactions of the block and of the packages it
+// references.
+func (gpo *Oracle) blockPrices(block *types.Block) []*big.Int {
+	if prices, ok := gpo.priceCache.Get(block.FullHash()); ok {
+		return prices.([]*big.Int)
+	}
+
+	var prices []*big.Int
+	for _, tx := range block.Body.Transactions {
+		prices = append(prices, tx.GasPrice())
+	}
+	for _, pkg := range gpo.chain.GetTxPackageList(block.Body.TxPackageHashes) {
+		for _, tx := range pkg.Transactions() {
+			prices = append(prices, tx.GasPrice())
+		}
+	}
+	sort.Sort(bigIntArray(prices))
+
+	gpo.priceCache.Add(block.FullHash(), prices)
+	return prices
+}
+
+type bigIntArray []*big.Int
+
+func (s bigIntArray) Len() int           { return len(s) }
+func (s bigIntArray) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
+func (s bigIntArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
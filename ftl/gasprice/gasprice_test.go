@@ -0,0 +1,163 @@
+package gasprice
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testChain is a main branch of blocks indexed by height.
+type testChain struct {
+	blocks   []*types.Block
+	packages map[common.Hash]*types.TxPackage
+	fetches  int
+}
+
+func (c *testChain) GetMainBranchHead() *types.Block {
+	if len(c.blocks) == 0 {
+		return nil
+	}
+	return c.blocks[len(c.blocks)-1]
+}
+
+func (c *testChain) GetMainBranchBlock(height uint64) (*types.Block, error) {
+	c.fetches++
+	if height >= uint64(len(c.blocks)) {
+		return nil, errors.New("block not found")
+	}
+	return c.blocks[height], nil
+}
+
+func (c *testChain) GetTxPackageList(hashes []common.Hash) types.TxPackages {
+	var pkgs types.TxPackages
+	for _, hash := range hashes {
+		pkgs = append(pkgs, c.packages[hash])
+	}
+	return pkgs
+}
+
+func testTxs(prices ...int64) []*types.Transaction {
+	txs := make([]*types.Transaction, len(prices))
+	for i, price := range prices {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, new(big.Int), 21000, big.NewInt(price), nil, false)
+	}
+	return txs
+}
+
+// push adds a block on the head with the transactions at the prices, and a
+// package with the transactions at the package prices.
+func (c *testChain) push(gasUsed uint64, prices []int64, pkgPrices ...int64) {
+	block := types.NewBlock(common.Hash{}, uint64(len(c.blocks)), nil, common.Address{}, new(big.Int), uint64(len(c.blocks)))
+	block.Header.GasLimit = 100
+	block.Header.GasUsed = gasUsed
+	block.Body.Transactions = testTxs(prices...)
+	if len(pkgPrices) > 0 {
+		pkg := types.NewTxPackage(common.Address{}, uint64(len(c.blocks)), testTxs(pkgPrices...), common.Hash{}, 0)
+		c.packages[pkg.Hash()] = pkg
+		block.Body.TxPackageHashes = []common.Hash{pkg.Hash()}
+	}
+	c.blocks = append(c.blocks, block)
+}
+
+func newTestChain() *testChain {
+	c := &testChain{packages: make(map[common.Hash]*types.TxPackage)}
+	c.push(0, nil)
+	return c
+}
+
+func ints(prices []*big.Int) []int64 {
+	r := make([]int64, len(prices))
+	for i, price := range prices {
+		r[i] = price.Int64()
+	}
+	return r
+}
+
+func TestSuggestPrice(t *testing.T) {
+	Convey("the suggested price", t, func() {
+		c := newTestChain()
+
+		Convey("is the default one if no transaction is sampled", func() {
+			gpo := NewOracle(c, Config{Blocks: 2, Percentile: 50, Default: big.NewInt(7)})
+			So(gpo.SuggestPrice().Int64(), ShouldEqual, 7)
+		})
+
+		Convey("is the price at the percentile of the sampled blocks", func() {
+			c.push(0, []int64{100, 100})
+			c.push(0, []int64{4, 2, 10}, 8, 6)
+			c.push(0, []int64{9, 1, 5}, 3, 7)
+
+			for percentile, price := range map[int]int64{0: 1, 10: 1, 50: 5, 55: 5, 56: 6, 90: 9, 100: 10} {
+				gpo := NewOracle(c, Config{Blocks: 2, Percentile: percentile})
+				So(gpo.SuggestPrice().Int64(), ShouldEqual, price)
+			}
+			gpo := NewOracle(c, Config{Blocks: 10, Percentile: 100})
+			So(gpo.SuggestPrice().Int64(), ShouldEqual, 100)
+		})
+
+		Convey("is cached until the head changes", func() {
+			c.push(0, []int64{1, 2, 3})
+			gpo := NewOracle(c, Config{Blocks: 2, Percentile: 100})
+			So(gpo.SuggestPrice().Int64(), ShouldEqual, 3)
+			fetches := c.fetches
+
+			So(gpo.SuggestPrice().Int64(), ShouldEqual, 3)
+			So(c.fetches, ShouldEqual, fetches)
+
+			c.push(0, []int64{20})
+			So(gpo.SuggestPrice().Int64(), ShouldEqual, 20)
+			So(c.fetches, ShouldBeGreaterThan, fetches)
+		})
+	})
+}
+
+func TestFeeHistory(t *testing.T) {
+	Convey("the fee history", t, func() {
+		c := newTestChain()
+		c.push(50, []int64{4, 2, 10}, 8, 6)
+		c.push(100, []int64{9, 1, 5}, 3, 7)
+		gpo := NewOracle(c, Config{})
+
+		Convey("has the prices at the percentiles of each block", func() {
+			history, err := gpo.FeeHistory(2, []float64{0, 50, 100})
+			So(err, ShouldBeNil)
+			So(history.OldestBlock, ShouldEqual, 1)
+			So(history.GasUsedRatio, ShouldResemble, []float64{0.5, 1})
+			So(history.Rewards, ShouldHaveLength, 2)
+			So(ints(history.Rewards[0]), ShouldResemble, []int64{2, 6, 10})
+			So(ints(history.Rewards[1]), ShouldResemble, []int64{1, 5, 9})
+		})
+
+		Convey("is cut to the blocks of the chain", func() {
+			history, err := gpo.FeeHistory(10, []float64{50})
+			So(err, ShouldBeNil)
+			So(history.OldestBlock, ShouldEqual, 0)
+			So(history.GasUsedRatio, ShouldHaveLength, 3)
+			So(ints(history.Rewards[0]), ShouldResemble, []int64{0})
+			So(ints(history.Rewards[2]), ShouldResemble, []int64{5})
+		})
+
+		Convey("rejects an invalid block count", func() {
+			_, err := gpo.FeeHistory(0, nil)
+			So(err, ShouldEqual, ErrInvalidBlockCount)
+		})
+
+		Convey("rejects invalid percentiles", func() {
+			_, err := gpo.FeeHistory(1, []float64{50, 10})
+			So(err, ShouldEqual, ErrInvalidPercentile)
+			_, err = gpo.FeeHistory(1, []float64{-1})
+			So(err, ShouldEqual, ErrInvalidPercentile)
+			_, err = gpo.FeeHistory(1, []float64{101})
+			So(err, ShouldEqual, ErrInvalidPercentile)
+		})
+
+		Convey("fails without a main branch head", func() {
+			_, err := NewOracle(&testChain{}, Config{}).FeeHistory(1, nil)
+			So(err, ShouldEqual, ErrNoMainBranchHead)
+		})
+	})
+}
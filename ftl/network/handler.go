@@ -188,6 +188,36 @@ func (h *defaultHandler) handleMsg(p *Peer, msg p2p.Msg) (HandleReturn, error) {
 				h.txpkgFetcher.finishTask(p)
 			}()
 		}
+
+	case msg.Code == protocol.SignedCheckPointMsg && p.version >= protocol.Ftl3:
+		var signed types.SignedCheckPoint
+		if err := msg.Decode(&signed); err != nil {
+			return HandleReturnDone, errResp(protocol.ErrDecode, "msg %v: %v", msg, err)
+		}
+		if signed.CheckPoint == nil || signed.CheckPoint.TreePoint == nil {
+			return HandleReturnDone, errResp(protocol.ErrDecode, "msg %v: empty check point", msg)
+		}
+		p.MarkSignedCheckPoint(&signed)
+
+		// verify the signs against the check point authorities before using it
+		updated, err := h.chain.InsertSignedCheckPoint(&signed)
+		if err != nil {
+			h.logger.Warn("Receive invalid signed check point", "peer", p.GetID(), "hash", signed.CheckPoint.Hash(), "err", err)
+		} else if updated {
+			h.logger.Info("Receive signed check point", "peer", p.GetID(), "hash", signed.CheckPoint.Hash(), "height", signed.CheckPoint.Height, "signs", len(signed.Signs))
+		}
+
+	case msg.Code == protocol.SignedCheckPointReqMsg && p.version >= protocol.Ftl3:
+		var req protocol.RequestData
+		if err := msg.Decode(&req); err != nil {
+			return HandleReturnDone, errResp(protocol.ErrDecode, "%v: %v", msg, err)
+		}
+
+		signed := h.chain.GetLatestSignedCheckPoint()
+		if signed != nil {
+			p.AsyncSendSignedCheckPoint(signed)
+		}
+
 	default:
 		return HandleReturnIgnore, nil
 	}
@@ -59,6 +59,10 @@ type blockchain interface {
 	SubscribeFutureBlockEvent(ch chan<- types.FutureBlockEvent) event.Subscription
 	SubscribeFutureTxPackageEvent(ch chan<- types.FutureTxPackageEvent) event.Subscription
 
+	InsertSignedCheckPoint(signed *types.SignedCheckPoint) (bool, error)
+	GetLatestSignedCheckPoint() *types.SignedCheckPoint
+	SubscribeNewSignedCheckPointEvent(ch chan<- types.NewSignedCheckPointEvent) event.Subscription
+
 	// TrieNode retrieves a blob of data associated with a trie node (or code Hash)
 	// either from ephemeral in-memory cache, or from persistent storage.
 	TrieNode(hash common.Hash) ([]byte, error)
@@ -142,6 +146,10 @@ type ProtocolManager struct {
 	futureTxPackageCh  chan types.FutureTxPackageEvent
 	futureTxPackageSub event.Subscription
 
+	// for signed check point broadcast
+	signedCheckPointCh  chan types.NewSignedCheckPointEvent
+	signedCheckPointSub event.Subscription
+
 	// for rate limit
 	bucket *ratelimit.Bucket
 
@@ -267,6 +275,10 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.futureTxPackageCh = make(chan types.FutureTxPackageEvent, 10)
 	pm.futureTxPackageSub = pm.chain.SubscribeFutureTxPackageEvent(pm.futureTxPackageCh)
 
+	// broadcast signed check points
+	pm.signedCheckPointCh = make(chan types.NewSignedCheckPointEvent, 10)
+	pm.signedCheckPointSub = pm.chain.SubscribeNewSignedCheckPointEvent(pm.signedCheckPointCh)
+
 	// loop
 	go pm.loop()
 
@@ -297,6 +309,10 @@ func (pm *ProtocolManager) Stop() {
 		pm.futureTxPackageSub.Unsubscribe()
 	}
 
+	if pm.signedCheckPointSub != nil {
+		pm.signedCheckPointSub.Unsubscribe()
+	}
+
 	close(pm.BlockProcessCh)
 
 	// Quit the sync loop.
@@ -350,6 +366,13 @@ func (pm *ProtocolManager) handle(p *Peer) error {
 
 	pm.synchronizer.AddPeer(p)
 
+	// ask for the latest signed check point, new ones will be sent via broadcasts.
+	if p.version >= protocol.Ftl3 {
+		if err := p.RequestSignedCheckPoint(); err != nil {
+			p.Log().Warn("Request signed check point failed", "err", err)
+		}
+	}
+
 	// Propagate existing transactions. new transactions appearing
 	// after this will be sent via broadcasts.
 	//pm.syncTransactions(p)
@@ -495,7 +518,7 @@ func (pm *ProtocolManager) insertBlockInternal(p *Peer, block *BlockWithVerifyFl
 			p.RequestOneBlock(dependBlockHash)
 			if dependBlockDepth >= pm.synchronizer.GetPeerSyncThreshold() {
 				log.Info("Propagated block verification failed trigger peer sync", "peerSyncThreshold", pm.synchronizer.GetPeerSyncThreshold(), "dependBlockDepth", dependBlockDepth)
-				pm.synchronizer.DoPeerSync(p)
+				go pm.synchronizer.DoPeerSync(p)
 			}
 			return false
 
@@ -571,7 +594,7 @@ func (pm *ProtocolManager) loop() {
 	pm.wg.Add(1)
 	defer pm.wg.Done()
 
-	const channelNumber = 5
+	const channelNumber = 6
 	var closedChannelNumber int
 
 	for {
@@ -596,6 +619,8 @@ func (pm *ProtocolManager) loop() {
 		case event := <-pm.futureTxPackageCh:
 			pkg := event.Pkg
 			pm.insertTxPackage(pkg, false, true)
+		case event := <-pm.signedCheckPointCh:
+			pm.BroadcastSignedCheckPoint(event.CheckPoint)
 
 			// quit
 		case <-pm.newPackedSub.Err():
@@ -623,6 +648,11 @@ func (pm *ProtocolManager) loop() {
 			if closedChannelNumber == channelNumber {
 				return
 			}
+		case <-pm.signedCheckPointSub.Err():
+			closedChannelNumber++
+			if closedChannelNumber == channelNumber {
+				return
+			}
 		}
 	}
 }
@@ -646,6 +676,16 @@ func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
 	}
 }
 
+// BroadcastSignedCheckPoint will propagate a signed check point to all peers which are not known to
+// already have it with the same signs.
+func (pm *ProtocolManager) BroadcastSignedCheckPoint(signed *types.SignedCheckPoint) {
+	peers := pm.peers.PeersWithoutSignedCheckPoint(signed)
+	for _, peer := range peers {
+		peer.AsyncSendSignedCheckPoint(signed)
+	}
+	log.Info("Propagated signed check point", "hash", signed.CheckPoint.Hash(), "signs", len(signed.Signs), "recipients", len(peers))
+}
+
 // NodeInfo retrieves some protocol metadata about the running host node.
 func (pm *ProtocolManager) NodeInfo() *NodeInfo {
 	currentBlock := pm.chain.CurrentBlock()
@@ -27,9 +27,10 @@ var (
 )
 
 const (
-	maxKnownTxs        = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
-	maxKnownBlocks     = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
-	maxKnownTxPackages = 4096  // Maximum tx package hashes to keep in the known list (prevent DOS)
+	maxKnownTxs         = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
+	maxKnownBlocks      = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
+	maxKnownTxPackages  = 4096  // Maximum tx package hashes to keep in the known list (prevent DOS)
+	maxKnownCheckPoints = 64    // Maximum signed check point ids to keep in the known list (prevent DOS)
 
 	handshakeTimeout = 5 * time.Second
 
@@ -69,28 +70,30 @@ type Peer struct {
 	// for rate limit
 	bucket *ratelimit.Bucket
 
-	knownTxs        mapset.Set    // Set of transaction hashes known to be known by this peer
-	knownTxPackages mapset.Set    // Set of tx package hashes known to be known by this peer
-	knownBlocks     mapset.Set    // Set of block hashes known to be known by this peer
-	term            chan struct{} // Termination channel to stop the broadcaster
-	closed          bool
+	knownTxs         mapset.Set    // Set of transaction hashes known to be known by this peer
+	knownTxPackages  mapset.Set    // Set of tx package hashes known to be known by this peer
+	knownBlocks      mapset.Set    // Set of block hashes known to be known by this peer
+	knownCheckPoints mapset.Set    // Set of signed check point ids known to be known by this peer
+	term             chan struct{} // Termination channel to stop the broadcaster
+	closed           bool
 	//lacking         map[common.Hash]struct{} // Set of hashes not to request (didn't have previously)
 }
 
 func NewPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter, bucket *ratelimit.Bucket) *Peer {
 	return &Peer{
-		id:              fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		version:         version,
-		Peer:            p,
-		rw:              rw,
-		reqID:           0,
-		pipe:            newTaskPipe(),
-		bucket:          bucket,
-		knownTxs:        mapset.NewSet(),
-		knownBlocks:     mapset.NewSet(),
-		knownTxPackages: mapset.NewSet(),
-		term:            make(chan struct{}),
-		closed:          false,
+		id:               fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		version:          version,
+		Peer:             p,
+		rw:               rw,
+		reqID:            0,
+		pipe:             newTaskPipe(),
+		bucket:           bucket,
+		knownTxs:         mapset.NewSet(),
+		knownBlocks:      mapset.NewSet(),
+		knownTxPackages:  mapset.NewSet(),
+		knownCheckPoints: mapset.NewSet(),
+		term:             make(chan struct{}),
+		closed:           false,
 	}
 }
 
@@ -202,6 +205,22 @@ func (p *Peer) MarkTxPackage(hash common.Hash) {
 	p.knownTxPackages.Add(hash)
 }
 
+// MarkSignedCheckPoint marks a signed check point as known for the peer, ensuring that
+// the check point with the same signs will never be propagated to this particular peer.
+func (p *Peer) MarkSignedCheckPoint(signed *types.SignedCheckPoint) {
+	// If we reached the memory allowance, drop a previously known check point id
+	for p.knownCheckPoints.Cardinality() >= maxKnownCheckPoints {
+		p.knownCheckPoints.Pop()
+	}
+	p.knownCheckPoints.Add(signedCheckPointID(signed))
+}
+
+// signedCheckPointID identifies a signed check point by its hash and the number of signs,
+// so that a check point is propagated again when it gets more signs.
+func signedCheckPointID(signed *types.SignedCheckPoint) common.Hash {
+	return common.RlpHash([]interface{}{signed.CheckPoint.Hash(), uint64(len(signed.Signs))})
+}
+
 // HasBlock tells whether current peer knows the block
 func (p *Peer) HasBlock(hash common.Hash) bool {
 	return p.knownBlocks.Contains(hash)
@@ -217,6 +236,11 @@ func (p *Peer) HasTxPackage(hash common.Hash) bool {
 	return p.knownTxPackages.Contains(hash)
 }
 
+// HasSignedCheckPoint tells whether current peer knows the signed check point
+func (p *Peer) HasSignedCheckPoint(signed *types.SignedCheckPoint) bool {
+	return p.knownCheckPoints.Contains(signedCheckPointID(signed))
+}
+
 // SendNewBlock propagates an entire block to a remote peer.
 func (p *Peer) SendNewBlock(block *types.Block) error {
 	p.knownBlocks.Add(block.FullHash())
@@ -262,6 +286,17 @@ func (p *Peer) SendTxPackage(pkg *types.TxPackage) error {
 	return p2p.Send(p.rw, protocol.TxPackageRspMsg, pkg)
 }
 
+// SendSignedCheckPoint sends a signed check point to the peer.
+func (p *Peer) SendSignedCheckPoint(signed *types.SignedCheckPoint) error {
+	p.knownCheckPoints.Add(signedCheckPointID(signed))
+	return p2p.Send(p.rw, protocol.SignedCheckPointMsg, signed)
+}
+
+// RequestSignedCheckPoint asks the peer for its latest signed check point.
+func (p *Peer) RequestSignedCheckPoint() error {
+	return p2p.Send(p.rw, protocol.SignedCheckPointReqMsg, protocol.RequestData{ReqID: p.nextRequestID()})
+}
+
 // RequestNodeData fetches a batch of arbitrary data from a node's known state
 // data, corresponding to the specified hashes.
 func (p *Peer) RequestNodeData(hashes []common.Hash) error {
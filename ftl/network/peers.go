@@ -8,6 +8,8 @@ import (
 	"sync"
 
 	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/ftl/protocol"
 	"github.com/fractal-platform/fractal/p2p"
 )
 
@@ -133,6 +135,21 @@ func (ps *Peers) PeersWithTxPackage(hash common.Hash) []*Peer {
 	return list
 }
 
+// PeersWithoutSignedCheckPoint retrieves a list of the ftl3 peers that do not have a given signed check
+// point in their set of known ids.
+func (ps *Peers) PeersWithoutSignedCheckPoint(signed *types.SignedCheckPoint) []*Peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*Peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if p.version >= protocol.Ftl3 && !p.HasSignedCheckPoint(signed) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
 // Close disconnects all peers.
 // No new peers can be registered after Close has returned.
 func (ps *Peers) Close() {
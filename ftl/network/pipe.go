@@ -20,6 +20,7 @@ const (
 	taskTypeAnnounceTxPkg
 	taskTypePropagateTxPkg
 	taskTypePropagateTx
+	taskTypePropagateCheckPoint
 	taskTypeEnd
 )
 
@@ -37,6 +38,9 @@ const (
 	// dropping broadcasts. This is a sensitive number as a transaction list might
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 1024
+
+	// maxQueuedCheckPointProps is the maximum number of signed check points to queue up before dropping broadcasts.
+	maxQueuedCheckPointProps = 16
 )
 
 var taskChannelSizeMap map[taskType]int
@@ -47,6 +51,7 @@ func init() {
 	taskChannelSizeMap[taskTypeAnnounceTxPkg] = maxQueuedTxPkgProps
 	taskChannelSizeMap[taskTypePropagateTxPkg] = maxQueuedTxPkgAnns
 	taskChannelSizeMap[taskTypePropagateTx] = maxQueuedTxs
+	taskChannelSizeMap[taskTypePropagateCheckPoint] = maxQueuedCheckPointProps
 }
 
 type taskPipe struct {
@@ -154,6 +159,13 @@ func (p *Peer) processTask(taskType taskType, taskData interface{}) {
 			p.Log().Error("Propagate transactions failed", "err", err)
 			return
 		}
+	case taskTypePropagateCheckPoint:
+		signed := taskData.(*types.SignedCheckPoint)
+		p.Log().Info("Propagate signed check point", "hash", signed.CheckPoint.Hash(), "signs", len(signed.Signs), "pipe", count)
+		if err := p.SendSignedCheckPoint(signed); err != nil {
+			p.Log().Error("Propagate signed check point failed", "err", err)
+			return
+		}
 	}
 }
 
@@ -211,3 +223,16 @@ func (p *Peer) AsyncSendTransactions(txs types.Transactions) {
 		p.Log().Warn("Dropping transaction propagation", "count", len(txs))
 	}
 }
+
+// AsyncSendSignedCheckPoint queues signed check point propagation to a remote
+// peer. If the peer's broadcast queue is full, the event is silently dropped.
+func (p *Peer) AsyncSendSignedCheckPoint(signed *types.SignedCheckPoint) {
+	select {
+	case p.pipe.channels[taskTypePropagateCheckPoint] <- signed:
+		p.knownCheckPoints.Add(signedCheckPointID(signed))
+		p.increaseCount(taskTypePropagateCheckPoint)
+		p.pipe.notify <- struct{}{}
+	default:
+		p.Log().Warn("Dropping signed check point propagation", "hash", signed.CheckPoint.Hash())
+	}
+}
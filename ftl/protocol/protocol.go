@@ -14,14 +14,15 @@ import (
 // Constants to match up protocol versions and messages
 const (
 	//ftl1 = 1
-	ftl2 = 2
+	Ftl2 = 2
+	Ftl3 = 3 // adds the signed check point messages
 )
 
 // ProtocolName is the official short name of the protocol used during capability negotiation.
 var ProtocolName = "ftl"
 
 // ProtocolVersions are the upported versions of the ftl protocol (first is primary).
-var ProtocolVersions = []uint{ftl2}
+var ProtocolVersions = []uint{Ftl3, Ftl2}
 
 const ProtocolMaxMsgSize = 512 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -71,11 +72,15 @@ const (
 	BlocksForBlockSyncReqMsg
 	BlocksForBlockSyncRspMsg
 
+	// for signed check point propagation, since ftl3
+	SignedCheckPointMsg
+	SignedCheckPointReqMsg
+
 	MsgCodeEnd
 )
 
 // ProtocolLengths are the number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{MsgCodeEnd}
+var ProtocolLengths = []uint64{MsgCodeEnd, SignedCheckPointMsg}
 
 func (s MsgCode) String() string {
 	if s <= MsgCodeBegin || s >= MsgCodeEnd {
@@ -102,7 +107,9 @@ func (s MsgCode) String() string {
 		"PkgsForBlockSyncReqMsg",
 		"PkgsForBlockSyncRspMsg",
 		"BlocksForBlockSyncReqMsg",
-		"BlocksForBlockSyncRspMsg"}
+		"BlocksForBlockSyncRspMsg",
+		"SignedCheckPointMsg",
+		"SignedCheckPointReqMsg"}
 	return list[s-1]
 }
 
@@ -0,0 +1,292 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package router forwards transactions to packers over pooled rpc
+// connections, and keeps the packers that fail out of the routing.
+package router
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/fractal-platform/fractal/chain"
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/rpc"
+	"github.com/fractal-platform/fractal/rpc/client"
+	"github.com/fractal-platform/fractal/utils/log"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	connLifetime        = 300              // seconds an idle connection is kept
+	callTimeout         = 10 * time.Second // timeout of a call forwarded to a packer
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 3 * time.Second
+	maxFailures         = 3 // consecutive failures before a packer is not routed to
+)
+
+var (
+	ErrNoPacker        = errors.New("no packer to route to")
+	ErrNoHealthyPacker = errors.New("no healthy packer to route to")
+)
+
+// PackerStatus is the health and the metrics of a packer.
+type PackerStatus struct {
+	Index      uint32    `json:"index"`
+	RpcAddress string    `json:"rpcAddress"`
+	Healthy    bool      `json:"healthy"`
+	Failures   int       `json:"failures"`
+	Calls      int64     `json:"calls"`
+	Errors     int64     `json:"errors"`
+	LatencyAvg float64   `json:"latencyAvg"` // milliseconds
+	LatencyP95 float64   `json:"latencyP95"` // milliseconds
+	LastError  string    `json:"lastError"`
+	LastCheck  time.Time `json:"lastCheck"`
+}
+
+type packer struct {
+	index      uint32
+	rpcAddress string
+
+	failures  int
+	lastError string
+	lastCheck time.Time
+
+	latency metrics.Timer
+	errors  metrics.Meter
+}
+
+func (p *packer) healthy() bool {
+	return p.failures < maxFailures
+}
+
+// Router routes the calls to packers of the current block.
+type Router struct {
+	chain *chain.BlockChain
+	pool  *rpcclient.RpcConnPool
+
+	packers map[string]*packer // rpc address -> packer
+	mu      sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewRouter(chain *chain.BlockChain) *Router {
+	return &Router{
+		chain:   chain,
+		packers: make(map[string]*packer),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start starts the connection pool and the health check of the packers.
+func (r *Router) Start() {
+	r.pool = rpcclient.NewRpcConnPool(connLifetime)
+
+	r.wg.Add(1)
+	go r.loop()
+}
+
+func (r *Router) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+	r.pool.Close()
+	log.Info("packer router is stopped")
+}
+
+// Call invokes the method on a random healthy packer among the given indexes,
+// and tries the others if the packer cannot be reached. An error returned by
+// the packer itself is returned to the caller without trying the others.
+func (r *Router) Call(result interface{}, indexes []uint32, method string, args ...interface{}) error {
+	if len(indexes) == 0 {
+		return ErrNoPacker
+	}
+
+	var candidates []*packer
+	for _, index := range indexes {
+		p, err := r.packer(index)
+		if err != nil {
+			log.Error("cannot get packerInfo", "packerIndex", index, "err", err)
+			continue
+		}
+		if r.healthy(p) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return ErrNoHealthyPacker
+	}
+
+	var err error
+	for _, i := range rand.Perm(len(candidates)) {
+		p := candidates[i]
+		err = r.call(p, callTimeout, result, method, args...)
+		if _, ok := err.(*rpc.JsonError); ok || err == nil {
+			return err
+		}
+		log.Error("send to packer error", "rpc", p.rpcAddress, "packerIndex", p.index, "method", method, "err", err)
+	}
+	return err
+}
+
+// CallPacker invokes the method on the packer with the index.
+func (r *Router) CallPacker(result interface{}, index uint32, method string, args ...interface{}) error {
+	p, err := r.packer(index)
+	if err != nil {
+		return err
+	}
+	if !r.healthy(p) {
+		return ErrNoHealthyPacker
+	}
+	return r.call(p, callTimeout, result, method, args...)
+}
+
+// Status returns the status of the packers routed to so far.
+func (r *Router) Status() []PackerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var status []PackerStatus
+	for _, p := range r.packers {
+		latency := p.latency.Snapshot()
+		status = append(status, PackerStatus{
+			Index:      p.index,
+			RpcAddress: p.rpcAddress,
+			Healthy:    p.healthy(),
+			Failures:   p.failures,
+			Calls:      latency.Count(),
+			Errors:     p.errors.Count(),
+			LatencyAvg: latency.Mean() / float64(time.Millisecond),
+			LatencyP95: latency.Percentile(0.95) / float64(time.Millisecond),
+			LastError:  p.lastError,
+			LastCheck:  p.lastCheck,
+		})
+	}
+	return status
+}
+
+func (r *Router) healthy(p *packer) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return p.healthy()
+}
+
+// packer returns the packer with the index in the current block.
+func (r *Router) packer(index uint32) (*packer, error) {
+	packerInfo, _, err := r.chain.GetPrePackerInfoByIndex(r.chain.CurrentBlock(), index)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.packers[packerInfo.RpcAddress]
+	if !ok {
+		p = &packer{
+			index:      index,
+			rpcAddress: packerInfo.RpcAddress,
+			latency:    metrics.GetOrRegisterTimer("packer/"+packerInfo.RpcAddress+"/latency", nil),
+			errors:     metrics.GetOrRegisterMeter("packer/"+packerInfo.RpcAddress+"/errors", nil),
+		}
+		r.packers[packerInfo.RpcAddress] = p
+	}
+	p.index = index
+	return p, nil
+}
+
+// call invokes the method on the packer and records the result. A packer
+// that cannot be reached is counted as failing, while a packer that rejects
+// the request is still healthy.
+func (r *Router) call(p *packer, timeout time.Duration, result interface{}, method string, args ...interface{}) error {
+	conn, err := r.pool.FetchRpcConn(p.rpcAddress)
+	if err != nil {
+		r.record(p, 0, err, false)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	start := time.Now()
+	err = conn.Client.CallContext(ctx, result, method, args...)
+	cancel()
+	conn.Release()
+
+	_, rejected := err.(*rpc.JsonError)
+	r.record(p, time.Since(start), err, err == nil || rejected)
+	return err
+}
+
+func (r *Router) record(p *packer, latency time.Duration, err error, reached bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		p.errors.Mark(1)
+		p.lastError = err.Error()
+	}
+	if reached {
+		p.latency.Update(latency)
+		if !p.healthy() {
+			log.Info("packer is healthy again", "rpc", p.rpcAddress, "packerIndex", p.index)
+		}
+		p.failures = 0
+		return
+	}
+
+	p.failures++
+	if p.failures == maxFailures {
+		log.Warn("packer is unhealthy, stop routing to it", "rpc", p.rpcAddress, "packerIndex", p.index, "err", err)
+	}
+	r.pool.Remove(p.rpcAddress)
+}
+
+func (r *Router) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.checkHealth()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// checkHealth pings all the packers of the current block, so that failing
+// packers are found before a transaction is routed to them, and recovered
+// packers are routed to again.
+func (r *Router) checkHealth() {
+	currentBlock := r.chain.CurrentBlock()
+	packerNumber, err := r.chain.GetPrePackerNumber(currentBlock)
+	if err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for index := uint32(0); index < packerNumber; index++ {
+		p, err := r.packer(index)
+		if err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *packer) {
+			defer wg.Done()
+
+			var pkg interface{}
+			r.call(p, healthCheckTimeout, &pkg, "pack_getTxPackageByHash", common.Hash{})
+
+			r.mu.Lock()
+			p.lastCheck = time.Now()
+			r.mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+}
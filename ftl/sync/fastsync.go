@@ -126,7 +126,7 @@ func (s *Synchronizer) syncShortHashListsForFastSync(peers []peer, peerThreshold
 	s.changeFastSyncStatus(FastSyncStatusShortHashList)
 
 	fetcher := newShortHashFetcher(peers, protocol.SyncStageFastSync, s.config.ShortHashListLength, true, peerThreshold, true,
-		s.syncHashListChForFastSync, s.config.ShortTimeOutOfShortLists, s.removePeerCallback, s.log)
+		s.syncHashListChForFastSync, s.config.ShortTimeOutOfShortLists, s.clock, s.removePeerCallback, s.log)
 	res := fetcher.fetch()
 	return fetcher.fetchResult.honestPeers, fetcher.fetchResult.hashes, res
 }
@@ -124,9 +124,9 @@ func (s *Synchronizer) syncHashTree(bestPeer peer, hashFrom common.Hash, hashTo
 	}
 
 	// process hash tree
-	timeout := time.NewTimer(time.Duration(hashTreeTimeout) * time.Microsecond)
+	timeout := s.clock.After(time.Duration(hashTreeTimeout) * time.Microsecond)
 	select {
-	case <-timeout.C:
+	case <-timeout:
 		s.log.Error("request hashTree timeout")
 		return nil, nil, errPeer
 	case hashTreeRsp := <-s.hashTreeRevCh:
@@ -337,9 +337,9 @@ func (s *Synchronizer) syncBestBlocks(bestHashElem protocol.HashElem, commonHigh
 	}
 	for {
 		var finished = false
-		timeout := time.NewTimer(time.Duration(s.config.ShortTimeOutOfSyncVeryHigh) * time.Microsecond)
+		timeout := s.clock.After(time.Duration(s.config.ShortTimeOutOfSyncVeryHigh) * time.Microsecond)
 		select {
-		case <-timeout.C:
+		case <-timeout:
 			s.log.Error("request blocks from best peer timeout", "peer", bestPeer.Name(), "hashTo", commonHighestHashElem, "bestHash", bestHashElem, "err", "timeout")
 			return []peer{bestPeer}, errPeer
 		case blocks := <-s.blocksForPostStateRevCh:
@@ -367,7 +367,7 @@ func (s *Synchronizer) syncBestBlocks(bestHashElem protocol.HashElem, commonHigh
 
 func (s *Synchronizer) waitSyncFinished(bestHashElem protocol.HashElem, bestPeer peer) ([]peer, error) {
 	s.log.Info("wait for highest block process finish", "bestHashElem", bestHashElem, "bestPeer", bestPeer)
-	timeout := time.NewTimer(time.Duration(s.config.LongTimeOutOfFixPointFinish) * time.Microsecond)
+	timeout := s.clock.After(time.Duration(s.config.LongTimeOutOfFixPointFinish) * time.Microsecond)
 	for {
 		if s.chain.HasBlock(bestHashElem.Hash) {
 			s.log.Info("bestHash processed")
@@ -376,7 +376,7 @@ func (s *Synchronizer) waitSyncFinished(bestHashElem protocol.HashElem, bestPeer
 
 		//consume quitCh
 		select {
-		case <-timeout.C:
+		case <-timeout:
 			s.log.Error("wait for highest block timeout", "peer", bestPeer.Name(), "bestHash", bestHashElem)
 			return []peer{bestPeer}, errPeer
 
@@ -385,7 +385,7 @@ func (s *Synchronizer) waitSyncFinished(bestHashElem protocol.HashElem, bestPeer
 			return nil, errors.New("sync recv quit")
 		default:
 		}
-		time.Sleep(1 * time.Second)
+		s.clock.Sleep(1 * time.Second)
 	}
 	return nil, nil
 }
@@ -7,6 +7,7 @@ package sync
 import (
 	"time"
 
+	"github.com/fractal-platform/fractal/common/mclock"
 	"github.com/fractal-platform/fractal/ftl/protocol"
 	"github.com/fractal-platform/fractal/utils/log"
 )
@@ -33,6 +34,7 @@ type hashFetcher struct {
 	serial bool
 
 	timeout  int
+	clock    mclock.Clock
 	removeFn removePeerCallback
 	log      log.Logger
 
@@ -40,7 +42,7 @@ type hashFetcher struct {
 }
 
 func newShortHashFetcher(peers []peer, syncStage protocol.SyncStage, length int, threshold bool, thresholdNum int, serial bool,
-	ch chan PeerHashElemList, timeout int, removePeerFn removePeerCallback, log log.Logger) *hashFetcher {
+	ch chan PeerHashElemList, timeout int, clock mclock.Clock, removePeerFn removePeerCallback, log log.Logger) *hashFetcher {
 	return &hashFetcher{
 		peers:     peers,
 		syncStage: syncStage,
@@ -52,6 +54,7 @@ func newShortHashFetcher(peers []peer, syncStage protocol.SyncStage, length int,
 		serial:       serial,
 		ch:           ch,
 		timeout:      timeout,
+		clock:        clock,
 		removeFn:     removePeerFn,
 		log:          log,
 		fetchResult:  &fetchResult{hashes: make(map[string]protocol.HashElems)},
@@ -59,7 +62,7 @@ func newShortHashFetcher(peers []peer, syncStage protocol.SyncStage, length int,
 }
 
 func newLongHashFetcher(peers []peer, syncStage protocol.SyncStage, length int, from protocol.HashElem, to protocol.HashElem,
-	ch chan PeerHashElemList, timeout int, removePeerFn removePeerCallback, log log.Logger) *hashFetcher {
+	ch chan PeerHashElemList, timeout int, clock mclock.Clock, removePeerFn removePeerCallback, log log.Logger) *hashFetcher {
 	return &hashFetcher{
 		peers:     peers,
 		syncStage: syncStage,
@@ -70,6 +73,7 @@ func newLongHashFetcher(peers []peer, syncStage protocol.SyncStage, length int,
 		to:          to,
 		ch:          ch,
 		timeout:     timeout,
+		clock:       clock,
 		removeFn:    removePeerFn,
 		log:         log,
 		fetchResult: &fetchResult{hashes: make(map[string]protocol.HashElems)},
@@ -97,9 +101,9 @@ func (h *hashFetcher) serialFetch() error {
 			continue
 		}
 
-		timeout := time.NewTimer(time.Duration(h.timeout) * time.Microsecond)
+		timeout := h.clock.After(time.Duration(h.timeout) * time.Microsecond)
 		select {
-		case <-timeout.C:
+		case <-timeout:
 			h.log.Error("sync hash list timeout", "peer", peer.GetID(), "stage", h.syncStage, "type", h.syncType, )
 			h.removeFn(peer.GetID(), false)
 			continue
@@ -140,11 +144,11 @@ func (h *hashFetcher) parallelFetch() error {
 		}
 	}
 
-	timeout := time.NewTimer(time.Duration(h.timeout) * time.Microsecond)
+	timeout := h.clock.After(time.Duration(h.timeout) * time.Microsecond)
 ForEnd:
 	for range h.peers {
 		select {
-		case <-timeout.C:
+		case <-timeout:
 			h.log.Error("parallel fetch hashes timeout")
 			break ForEnd
 		case peerHashList := <-h.ch:
@@ -100,7 +100,7 @@ func (s *Synchronizer) syncShortHashListsForPeerSync(peers []peer, peerThreshold
 	s.log.Info("start to sync short hash list", "peers", peers, "threshold", peerThreshold)
 
 	fetcher := newShortHashFetcher(peers, protocol.SyncStagePeerSync, s.config.ShortHashListLength, true, peerThreshold, true,
-		s.syncHashListChForPeerSync, s.config.ShortTimeOutOfShortLists, s.removePeerCallback, s.log)
+		s.syncHashListChForPeerSync, s.config.ShortTimeOutOfShortLists, s.clock, s.removePeerCallback, s.log)
 	res := fetcher.fetch()
 	return fetcher.fetchResult.honestPeers, fetcher.fetchResult.hashes, res
 }
@@ -132,7 +132,7 @@ func (s *Synchronizer) peerSyncSimple(p *network.Peer, roundTo uint64, lowestCmH
 	}
 
 	quitCh := make(chan struct{})
-	timeout := time.NewTimer(time.Second * 600)
+	timeout := s.clock.After(time.Second * 600)
 	isTimeout := false
 	go func() {
 		cursor := NewCursorRound(blockSyncHashList, s.chain, s.packer, true, s.lengthForStatesSync())
@@ -140,7 +140,7 @@ func (s *Synchronizer) peerSyncSimple(p *network.Peer, roundTo uint64, lowestCmH
 		for {
 			select {
 			case block := <-blockCh:
-				timeout.Reset(time.Second * 600)
+				timeout = s.clock.After(time.Second * 600)
 				err := cursor.ProcessBlock(block)
 				if err == errMainBlockCheckAndExecFailed {
 					break ForLoop
@@ -148,7 +148,7 @@ func (s *Synchronizer) peerSyncSimple(p *network.Peer, roundTo uint64, lowestCmH
 				if cursor.IsFinished() {
 					break ForLoop
 				}
-			case <-timeout.C:
+			case <-timeout:
 				isTimeout = true
 				break ForLoop
 			case <-quitCh:
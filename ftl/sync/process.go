@@ -63,12 +63,13 @@ func (s *Synchronizer) ProcessTxPackagesReq(peer *network.Peer, reqID uint64, st
 func (s *Synchronizer) ProcessTxPackagesRsp(peer *network.Peer, reqID uint64, stage protocol.SyncStage, pkgs []*types.TxPackage) {
 	if stage == protocol.SyncStageCP2FP {
 		s.cp2fp.deliverData(peer.GetID(), pkgs, downloader.Pkgs)
-	} else {
-		if s.blockSync != nil {
-			s.blockSync.DeliverData(peer.GetID(), pkgs, downloader.Pkgs)
-		} else if s.blockSyncRound != nil {
+	} else if stage == protocol.SyncStagePeerSync {
+		// a cp2fp task may fetch by hash while the peer sync fetches by round
+		if s.blockSyncRound != nil {
 			s.blockSyncRound.DeliverData(peer.GetID(), pkgs, downloader.Pkgs)
 		}
+	} else if s.blockSync != nil {
+		s.blockSync.DeliverData(peer.GetID(), pkgs, downloader.Pkgs)
 	}
 }
 
@@ -134,12 +135,13 @@ func (s *Synchronizer) ProcessBlocksReq(peer *network.Peer, reqID uint64, stage
 func (s *Synchronizer) ProcessBlocksRsp(peer *network.Peer, reqID uint64, stage protocol.SyncStage, blocks types.Blocks) {
 	if stage == protocol.SyncStageCP2FP {
 		s.cp2fp.deliverData(peer.GetID(), blocks, downloader.Blocks)
-	} else {
-		if s.blockSync != nil {
-			s.blockSync.DeliverData(peer.GetID(), blocks, downloader.Blocks)
-		} else if s.blockSyncRound != nil {
+	} else if stage == protocol.SyncStagePeerSync {
+		// a cp2fp task may fetch by hash while the peer sync fetches by round
+		if s.blockSyncRound != nil {
 			s.blockSyncRound.DeliverData(peer.GetID(), blocks, downloader.Blocks)
 		}
+	} else if s.blockSync != nil {
+		s.blockSync.DeliverData(peer.GetID(), blocks, downloader.Blocks)
 	}
 }
 
@@ -16,7 +16,7 @@ func (s *Synchronizer) syncSkeletonLists(peers []peer, from protocol.HashElem) (
 	s.changeFastSyncStatus(FastSyncStatusLongHashList)
 
 	fetcher := newLongHashFetcher(peers, protocol.SyncStageFastSync, 0, from, protocol.HashElem{},
-		s.syncHashListChForFastSync, s.config.LongTimeOutOfLongList, s.removePeerCallback, s.log)
+		s.syncHashListChForFastSync, s.config.LongTimeOutOfLongList, s.clock, s.removePeerCallback, s.log)
 	res := fetcher.fetch()
 	return fetcher.fetchResult.hashes, res
 }
@@ -13,6 +13,7 @@ import (
 
 	"github.com/fractal-platform/fractal/chain"
 	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/mclock"
 	"github.com/fractal-platform/fractal/core/config"
 	"github.com/fractal-platform/fractal/core/types"
 	"github.com/fractal-platform/fractal/dbwrapper"
@@ -49,6 +50,7 @@ type removePeerCallback func(id string, addBlack bool)
 
 type Synchronizer struct {
 	config     *config.SyncConfig
+	clock      mclock.Clock   // source of the timeouts
 	syncQuitCh chan struct{}  // for quit
 	syncQuitWg sync.WaitGroup // for shutdown sync
 	log        log.Logger
@@ -99,6 +101,7 @@ type Synchronizer struct {
 func NewSynchronizer(chain blockchain, miner miner, packer packer.Packer, removePeerCallback removePeerCallback, blockProcessCh chan *network.BlockWithVerifyFlag, conf *config.SyncConfig) *Synchronizer {
 	sync := &Synchronizer{
 		config:     conf,
+		clock:      mclock.System{},
 		syncQuitCh: make(chan struct{}),
 		log:        log.NewSubLogger("m", "sync"),
 
@@ -133,6 +136,12 @@ func NewSynchronizer(chain blockchain, miner miner, packer packer.Packer, remove
 	return sync
 }
 
+// SetClock sets the clock the timeouts of the synchronization are on, it must
+// be called before Start.
+func (s *Synchronizer) SetClock(clock mclock.Clock) {
+	s.clock = clock
+}
+
 func (s *Synchronizer) Start() {
 	go s.loop() // trigger
 }
@@ -159,6 +168,19 @@ func (s *Synchronizer) RemovePeer(p *network.Peer) {
 	s.peersLock.Unlock()
 }
 
+// Resync checks the best peer again after the chain head is rewound, and
+// starts a peer sync if the peer is far ahead.
+func (s *Synchronizer) Resync() {
+	if !s.IsSyncStatusNormal() {
+		return
+	}
+	peers := s.getPeers()
+	if len(peers) == 0 {
+		return
+	}
+	go s.doCheckPeer(getBestPeerByHead(peers))
+}
+
 func (s *Synchronizer) loop() {
 	s.syncQuitWg.Add(1)
 	defer s.syncQuitWg.Done()
@@ -249,9 +271,10 @@ func (s *Synchronizer) loop() {
 				}
 
 				// wait some time
-				time.AfterFunc(time.Duration(finishDependErrTime)*time.Second, func() {
-					s.peerSyncStarted[p.GetID()] = false
-				})
+				go func(id string) {
+					<-s.clock.After(time.Duration(finishDependErrTime) * time.Second)
+					s.peerSyncStarted[id] = false
+				}(p.GetID())
 
 				// restart cp2fp
 				go s.cp2fp.startTask(s.chain.CurrentBlock().Header.Height, s.chain.CurrentBlock().FullHash(), s.chain.CurrentBlock().AccHash, s.getPeers())
@@ -0,0 +1,413 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package txhistory indexes the transactions of the main branch by the
+// addresses they touch.
+package txhistory
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/fractal-platform/fractal/chain"
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/event"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+const (
+	// maxHistoryLimit is the most entries returned by a query.
+	maxHistoryLimit = 1000
+
+	// indexLogInterval is the interval between two progress logs of the
+	// indexing at start.
+	indexLogInterval = 8 * time.Second
+)
+
+var (
+	ErrInvalidHeightRange = errors.New("invalid height range")
+	ErrInvalidLimit       = errors.New("invalid limit")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+)
+
+type blockChain interface {
+	Database() dbwrapper.Database
+	GetBlock(hash common.Hash) *types.Block
+	GetMainBranchBlock(height uint64) (*types.Block, error)
+	GetTxPackageList(hashes []common.Hash) types.TxPackages
+	CurrentBlock() *types.Block
+	SubscribeBlockExecutedEvent(ch chan<- types.BlockExecutedEvent) event.Subscription
+	SubscribeChainReorgEvent(ch chan<- types.ChainReorgEvent) event.Subscription
+}
+
+// Cursor is the position of a transaction in the main branch, a query
+// returns the entries after it.
+type Cursor struct {
+	Height         uint64
+	TxPackageIndex uint32
+	TxIndex        uint32
+}
+
+// cursorLen is the length of an encoded cursor: the height, the package index
+// and the tx index, in big endian.
+const cursorLen = 16
+
+// Bytes encodes the cursor.
+func (c *Cursor) Bytes() []byte {
+	enc := make([]byte, cursorLen)
+	binary.BigEndian.PutUint64(enc[:8], c.Height)
+	binary.BigEndian.PutUint32(enc[8:12], c.TxPackageIndex)
+	binary.BigEndian.PutUint32(enc[12:], c.TxIndex)
+	return enc
+}
+
+// ParseCursor decodes a cursor encoded by Cursor.Bytes.
+func ParseCursor(enc []byte) (*Cursor, error) {
+	if len(enc) != cursorLen {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{
+		Height:         binary.BigEndian.Uint64(enc[:8]),
+		TxPackageIndex: binary.BigEndian.Uint32(enc[8:12]),
+		TxIndex:        binary.BigEndian.Uint32(enc[12:]),
+	}, nil
+}
+
+func (c *Cursor) before(entry *dbaccessor.AddressHistoryEntry) bool {
+	if c.Height != entry.Height {
+		return c.Height < entry.Height
+	}
+	if c.TxPackageIndex != entry.TxPackageIndex {
+		return c.TxPackageIndex < entry.TxPackageIndex
+	}
+	return c.TxIndex < entry.TxIndex
+}
+
+// Indexer maintains the history of the transactions touching each address:
+// the senders and the recipients of the transactions, the contracts they
+// create and the contracts which emit their logs. It follows the main branch
+// the same way as the main branch record, by the executed blocks.
+type Indexer struct {
+	chain  blockChain
+	db     dbwrapper.Database
+	signer types.Signer
+
+	head   *types.Block // last indexed main branch block
+	headMu sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewIndexer(chain blockChain, signer types.Signer) *Indexer {
+	return &Indexer{
+		chain:  chain,
+		db:     chain.Database(),
+		signer: signer,
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start indexes the main branch blocks after the last indexed block, and
+// starts following the executed blocks.
+func (ix *Indexer) Start() {
+	events := make(chan types.BlockExecutedEvent, 10)
+	sub := ix.chain.SubscribeBlockExecutedEvent(events)
+	reorgs := make(chan types.ChainReorgEvent, 10)
+	reorgSub := ix.chain.SubscribeChainReorgEvent(reorgs)
+
+	ix.catchUp()
+
+	ix.wg.Add(1)
+	go ix.eventLoop(events, sub, reorgs, reorgSub)
+}
+
+func (ix *Indexer) Stop() {
+	close(ix.quit)
+	ix.wg.Wait()
+	log.Info("Tx history indexer is stopped")
+}
+
+// Head returns the last indexed main branch block.
+func (ix *Indexer) Head() *types.Block {
+	ix.headMu.RLock()
+	defer ix.headMu.RUnlock()
+
+	return ix.head
+}
+
+func (ix *Indexer) setHead(block *types.Block) {
+	ix.headMu.Lock()
+	ix.head = block
+	ix.headMu.Unlock()
+}
+
+// catchUp rolls back the blocks indexed on a branch which is no longer the
+// main branch or which are removed by a rewind, and indexes the main branch
+// blocks up to the current block.
+func (ix *Indexer) catchUp() {
+	head, _ := ix.chain.GetMainBranchBlock(0)
+	if height, hash, err := dbaccessor.ReadAddressHistoryHead(ix.db); err == nil {
+		if main, err := ix.chain.GetMainBranchBlock(height); err == nil && main.FullHash() == hash {
+			head = main
+		} else {
+			// the highest block indexed which is still in the main branch
+			ancestor := height
+			for ; ancestor > 0; ancestor-- {
+				record := dbaccessor.ReadAddressHistoryBlock(ix.db, ancestor)
+				if main, err := ix.chain.GetMainBranchBlock(ancestor); err == nil && record != nil && main.FullHash() == record.Hash {
+					head = main
+					break
+				}
+			}
+			log.Info("Tx history rollback", "height", ancestor, "oldHeight", height)
+			ix.rewind(height, head)
+		}
+	}
+	ix.setHead(head)
+
+	var (
+		current = ix.chain.CurrentBlock()
+		start   = time.Now()
+		lastLog = time.Now()
+	)
+	for height := head.Header.Height + 1; height <= current.Header.Height; height++ {
+		block, err := ix.chain.GetMainBranchBlock(height)
+		if err != nil {
+			// the blocks below the check point of a fast synced node
+			continue
+		}
+		ix.index(block, true)
+
+		if time.Since(lastLog) > indexLogInterval {
+			log.Info("Indexing tx history", "height", height, "head", current.Header.Height, "elapsed", common.PrettyDuration(time.Since(start)))
+			lastLog = time.Now()
+		}
+	}
+}
+
+func (ix *Indexer) eventLoop(events chan types.BlockExecutedEvent, sub event.Subscription, reorgs chan types.ChainReorgEvent, reorgSub event.Subscription) {
+	defer ix.wg.Done()
+	defer sub.Unsubscribe()
+	defer reorgSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-events:
+			block := ev.Block
+			head := ix.Head()
+
+			// the block is removed by a rewind of the chain
+			if ix.chain.GetBlock(block.FullHash()) == nil {
+				continue
+			}
+
+			if block.ReceivedPath == types.BlockFastSync {
+				// fast synced blocks are in the main branch, but they may be
+				// executed below the head
+				ix.index(block, block.Header.Height >= head.Header.Height)
+				continue
+			}
+
+			if head.SimpleHash() == block.Header.ParentHash {
+				ix.index(block, true)
+			} else if chain.IsReOrg(block, head) {
+				reorg := dbaccessor.FindReorgChain(ix.db, &head.Header, &block.Header)
+				if len(reorg) <= 1 {
+					log.Error("Tx history reorg: common ancestor not found", "head", head.FullHash(), "block", block.FullHash())
+					continue
+				}
+				ancestor := ix.chain.GetBlock(reorg[0].FullHash())
+				if ancestor == nil {
+					log.Error("Tx history reorg: common ancestor not found", "height", reorg[0].Height, "hash", reorg[0].FullHash())
+					continue
+				}
+				log.Info("Tx history reorg", "ancestor", reorg[0].Height, "oldHeight", head.Header.Height, "newHeight", block.Header.Height)
+				ix.rewind(head.Header.Height, ancestor)
+				for i := 1; i < len(reorg)-1; i++ {
+					if newBlock := ix.chain.GetBlock(reorg[i].FullHash()); newBlock != nil {
+						ix.index(newBlock, true)
+					}
+				}
+				ix.index(block, true)
+			}
+
+		case ev := <-reorgs:
+			// the reorgs with added blocks are handled by their executed blocks,
+			// a reorg without added blocks is a rewind of the chain head
+			head := ix.Head()
+			if len(ev.Added) > 0 || ev.CommonAncestor.Header.Height >= head.Header.Height {
+				continue
+			}
+			log.Info("Tx history rewind", "height", ev.CommonAncestor.Header.Height, "oldHeight", head.Header.Height)
+			ix.rewind(head.Header.Height, ev.CommonAncestor)
+
+		case <-sub.Err():
+			return
+		case <-ix.quit:
+			return
+		}
+	}
+}
+
+// rewind removes the entries of the blocks indexed at the heights from the
+// height down to the ancestor, and makes the ancestor the last indexed block.
+// The addresses of the entries are the ones recorded when the blocks were
+// indexed, so the receipts of the blocks may be gone.
+func (ix *Indexer) rewind(height uint64, ancestor *types.Block) {
+	for ; height > ancestor.Header.Height; height-- {
+		batch := ix.db.NewBatch()
+		ix.deleteEntries(batch, height)
+		if err := batch.Write(); err != nil {
+			log.Error("Remove tx history failed", "height", height, "err", err)
+		}
+	}
+	ix.writeHead(ancestor)
+}
+
+// index adds the entries of the transactions executed in the block, in place
+// of the entries of a block indexed before at its height, and makes it the
+// last indexed block if advance is set.
+func (ix *Indexer) index(block *types.Block, advance bool) {
+	batch := ix.db.NewBatch()
+	ix.deleteEntries(batch, block.Header.Height)
+	record := &dbaccessor.AddressHistoryBlock{Hash: block.FullHash()}
+	for address, entries := range ix.blockEntries(block) {
+		record.Addresses = append(record.Addresses, address)
+		for i := range entries {
+			dbaccessor.WriteAddressHistoryEntry(batch, address, &entries[i])
+		}
+	}
+	dbaccessor.WriteAddressHistoryBlock(batch, block.Header.Height, record)
+	if advance {
+		dbaccessor.WriteAddressHistoryHead(batch, block.Header.Height, block.FullHash())
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Write tx history failed", "height", block.Header.Height, "err", err)
+		return
+	}
+	if advance {
+		ix.setHead(block)
+	}
+}
+
+// deleteEntries removes the entries of the block indexed at the height.
+func (ix *Indexer) deleteEntries(batch dbwrapper.Batch, height uint64) {
+	record := dbaccessor.ReadAddressHistoryBlock(ix.db, height)
+	if record == nil {
+		return
+	}
+	start := dbaccessor.AddressHistoryEntry{Height: height}
+	for _, address := range record.Addresses {
+		for _, entry := range dbaccessor.ReadAddressHistory(ix.db, address, start, height, math.MaxInt32) {
+			dbaccessor.DeleteAddressHistoryEntry(batch, address, &entry)
+		}
+	}
+	dbaccessor.DeleteAddressHistoryBlock(batch, height)
+}
+
+func (ix *Indexer) writeHead(block *types.Block) {
+	dbaccessor.WriteAddressHistoryHead(ix.db, block.Header.Height, block.FullHash())
+	ix.setHead(block)
+}
+
+// blockEntries returns the entries of the transactions executed in the block
+// by the addresses they touch. If the receipts of the block are not stored,
+// all the transactions of the block and its packages are returned.
+func (ix *Indexer) blockEntries(block *types.Block) map[common.Address][]dbaccessor.AddressHistoryEntry {
+	receipts := make(map[common.Hash]*types.Receipt)
+	for _, receipt := range dbaccessor.ReadReceipts(ix.db, block.FullHash()) {
+		receipts[receipt.TxHash] = receipt
+	}
+
+	entries := make(map[common.Address][]dbaccessor.AddressHistoryEntry)
+	add := func(tx *types.Transaction, pkgIndex uint32, txIndex uint32) {
+		receipt, ok := receipts[tx.Hash()]
+		if len(receipts) > 0 && !ok {
+			// not executed in the block
+			return
+		}
+
+		touched := make(map[common.Address]struct{})
+		if from, err := types.Sender(ix.signer, tx); err == nil {
+			touched[from] = struct{}{}
+		}
+		if to := tx.To(); to != nil {
+			touched[*to] = struct{}{}
+		}
+		if receipt != nil {
+			if receipt.ContractAddress != (common.Address{}) {
+				touched[receipt.ContractAddress] = struct{}{}
+			}
+			for _, l := range receipt.Logs {
+				touched[l.Address] = struct{}{}
+			}
+		}
+
+		entry := dbaccessor.AddressHistoryEntry{
+			Height:         block.Header.Height,
+			TxPackageIndex: pkgIndex,
+			TxIndex:        txIndex,
+			TxHash:         tx.Hash(),
+		}
+		for address := range touched {
+			entries[address] = append(entries[address], entry)
+		}
+	}
+
+	for i, pkg := range ix.chain.GetTxPackageList(block.Body.TxPackageHashes) {
+		for j, tx := range pkg.Transactions() {
+			add(tx, uint32(i), uint32(j))
+		}
+	}
+	for j, tx := range block.Body.Transactions {
+		add(tx, types.NotInPackage, uint32(j))
+	}
+	return entries
+}
+
+// Query returns the entries of the address in the main branch blocks at the
+// heights from fromHeight to toHeight, which are after the cursor if it is
+// not nil. At most limit entries are returned, along with the cursor of the
+// next query if there are more entries.
+func (ix *Indexer) Query(address common.Address, fromHeight uint64, toHeight uint64, limit int, cursor *Cursor) ([]dbaccessor.AddressHistoryEntry, *Cursor, error) {
+	if fromHeight > toHeight {
+		return nil, nil, ErrInvalidHeightRange
+	}
+	if limit <= 0 {
+		return nil, nil, ErrInvalidLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	if head := ix.Head(); head == nil {
+		return nil, nil, nil
+	} else if toHeight > head.Header.Height {
+		toHeight = head.Header.Height
+	}
+
+	// the entry of the cursor is skipped, so one more entry is read to know
+	// whether there is a next page
+	start := dbaccessor.AddressHistoryEntry{Height: fromHeight}
+	if cursor != nil && !cursor.before(&start) {
+		start = dbaccessor.AddressHistoryEntry{Height: cursor.Height, TxPackageIndex: cursor.TxPackageIndex, TxIndex: cursor.TxIndex}
+	}
+	var result []dbaccessor.AddressHistoryEntry
+	for _, entry := range dbaccessor.ReadAddressHistory(ix.db, address, start, toHeight, limit+2) {
+		if cursor != nil && !cursor.before(&entry) {
+			continue
+		}
+		if len(result) == limit {
+			last := result[limit-1]
+			return result, &Cursor{Height: last.Height, TxPackageIndex: last.TxPackageIndex, TxIndex: last.TxIndex}, nil
+		}
+		result = append(result, entry)
+	}
+	return result, nil, nil
+}
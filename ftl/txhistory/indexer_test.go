@@ -0,0 +1,417 @@
+package txhistory
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/dbaccessor"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/event"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testSigner = types.NewEIP155Signer(1)
+
+// testChain is a chain whose main branch is set by the test.
+type testChain struct {
+	db     dbwrapper.Database
+	blocks map[common.Hash]*types.Block
+	main   []*types.Block
+	mu     sync.RWMutex
+
+	executedFeed event.Feed
+	reorgFeed    event.Feed
+}
+
+func newTestChain() *testChain {
+	c := &testChain{db: dbwrapper.NewMemDatabase(), blocks: make(map[common.Hash]*types.Block)}
+	genesis := types.NewBlock(common.Hash{}, 0, nil, common.Address{}, big.NewInt(1), 0)
+	c.add(genesis)
+	c.main = types.Blocks{genesis}
+	return c
+}
+
+func (c *testChain) Database() dbwrapper.Database                           { return c.db }
+func (c *testChain) GetTxPackageList(hashes []common.Hash) types.TxPackages { return nil }
+
+func (c *testChain) GetBlock(hash common.Hash) *types.Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.blocks[hash]
+}
+
+func (c *testChain) CurrentBlock() *types.Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.main[len(c.main)-1]
+}
+
+func (c *testChain) GetMainBranchBlock(height uint64) (*types.Block, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if height >= uint64(len(c.main)) {
+		return nil, errors.New("block not found")
+	}
+	return c.main[height], nil
+}
+
+func (c *testChain) SubscribeBlockExecutedEvent(ch chan<- types.BlockExecutedEvent) event.Subscription {
+	return c.executedFeed.Subscribe(ch)
+}
+
+func (c *testChain) SubscribeChainReorgEvent(ch chan<- types.ChainReorgEvent) event.Subscription {
+	return c.reorgFeed.Subscribe(ch)
+}
+
+func (c *testChain) add(block *types.Block) {
+	dbaccessor.WriteBlock(c.db, block)
+	c.mu.Lock()
+	c.blocks[block.FullHash()] = block
+	c.mu.Unlock()
+}
+
+// rewind cuts the main branch after the block at the height, and removes
+// the blocks above it along with their receipts, as SetHead does.
+func (c *testChain) rewind(height uint64) {
+	c.mu.Lock()
+	for _, block := range c.main[height+1:] {
+		delete(c.blocks, block.FullHash())
+		dbaccessor.DeleteReceipts(c.db, block.FullHash())
+	}
+	c.main = c.main[:height+1]
+	c.mu.Unlock()
+}
+
+// setMain cuts the main branch after the block at the height.
+func (c *testChain) setMain(height uint64) {
+	c.mu.Lock()
+	c.main = c.main[:height+1]
+	c.mu.Unlock()
+}
+
+// child adds a child block of the parent with the transactions, and makes it
+// the head of the main branch.
+func (c *testChain) child(parent *types.Block, round uint64, txs ...*types.Transaction) *types.Block {
+	block := types.NewBlock(parent.SimpleHash(), round, []byte{byte(round)}, common.Address{}, big.NewInt(1), parent.Header.Height+1)
+	block.Header.ParentFullHash = parent.FullHash()
+	block.Body.Transactions = txs
+	c.add(block)
+	c.mu.Lock()
+	c.main = append(c.main[:parent.Header.Height+1], block)
+	c.mu.Unlock()
+	return block
+}
+
+func newTestTx(key crypto.PrivateKey, nonce uint64, to common.Address) *types.Transaction {
+	tx := types.NewTransaction(nonce, to, big.NewInt(1), 21000, big.NewInt(1), nil, false)
+	tx, err := types.SignTx(tx, testSigner, key)
+	So(err, ShouldBeNil)
+	return tx
+}
+
+// newTestReceipt returns the receipt of the tx, which creates the contract
+// and has a log emitted by the emitter.
+func newTestReceipt(tx *types.Transaction, contract common.Address, emitter common.Address) *types.Receipt {
+	receipt := types.NewReceipt(nil, false, 21000)
+	receipt.Bloom = new(types.Bloom)
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = 21000
+	receipt.ContractAddress = contract
+	receipt.Logs = []*types.Log{{Address: emitter, Data: []byte{1}}}
+	return receipt
+}
+
+func startTestIndexer(c *testChain) *Indexer {
+	ix := NewIndexer(c, testSigner)
+	ix.Start()
+	Reset(ix.Stop)
+	return ix
+}
+
+// waitHead waits until the block is the last indexed block.
+func waitHead(ix *Indexer, block *types.Block) {
+	deadline := time.Now().Add(5 * time.Second)
+	for ix.Head().FullHash() != block.FullHash() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	So(ix.Head().FullHash(), ShouldEqual, block.FullHash())
+}
+
+func heights(entries []dbaccessor.AddressHistoryEntry) []uint64 {
+	r := make([]uint64, len(entries))
+	for i, entry := range entries {
+		r[i] = entry.Height
+	}
+	return r
+}
+
+func TestIndexerQuery(t *testing.T) {
+	Convey("the history of an address", t, func() {
+		c := newTestChain()
+		_, key, err := crypto.NewKeys(crypto.ECDSA)
+		So(err, ShouldBeNil)
+		from, to := key.Public().ToAddress(), common.HexToAddress("0x0102")
+		var txs []*types.Transaction
+		for height := uint64(1); height <= 5; height++ {
+			block := []*types.Transaction{
+				newTestTx(key, uint64(len(txs)), to),
+				newTestTx(key, uint64(len(txs)+1), to),
+				newTestTx(key, uint64(len(txs)+2), to),
+			}
+			txs = append(txs, block...)
+			c.child(c.CurrentBlock(), height, block...)
+		}
+		ix := startTestIndexer(c)
+		So(ix.Head().FullHash(), ShouldEqual, c.CurrentBlock().FullHash())
+
+		Convey("is indexed for the sender and the recipient", func() {
+			for _, address := range []common.Address{from, to} {
+				entries, next, err := ix.Query(address, 0, 100, 100, nil)
+				So(err, ShouldBeNil)
+				So(next, ShouldBeNil)
+				So(entries, ShouldHaveLength, len(txs))
+				for i, entry := range entries {
+					So(entry.TxHash, ShouldEqual, txs[i].Hash())
+					So(entry.Height, ShouldEqual, i/3+1)
+					So(entry.TxPackageIndex, ShouldEqual, types.NotInPackage)
+					So(entry.TxIndex, ShouldEqual, i%3)
+				}
+			}
+			entries, _, err := ix.Query(common.HexToAddress("0x0103"), 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(entries, ShouldBeEmpty)
+		})
+
+		Convey("is returned by pages after the cursors", func() {
+			var (
+				entries []dbaccessor.AddressHistoryEntry
+				cursor  *Cursor
+				pages   int
+			)
+			for {
+				page, next, err := ix.Query(to, 0, 100, 4, cursor)
+				So(err, ShouldBeNil)
+				So(len(page), ShouldBeLessThanOrEqualTo, 4)
+				entries = append(entries, page...)
+				pages++
+				if next == nil {
+					break
+				}
+				// the cursor is passed around encoded
+				cursor, err = ParseCursor(next.Bytes())
+				So(err, ShouldBeNil)
+				So(cursor, ShouldResemble, next)
+			}
+			So(pages, ShouldEqual, 4)
+			So(entries, ShouldHaveLength, len(txs))
+			for i, entry := range entries {
+				So(entry.TxHash, ShouldEqual, txs[i].Hash())
+			}
+		})
+
+		Convey("is returned in the height range", func() {
+			entries, next, err := ix.Query(to, 2, 3, 100, nil)
+			So(err, ShouldBeNil)
+			So(next, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{2, 2, 2, 3, 3, 3})
+
+			entries, _, err = ix.Query(to, 2, 3, 100, &Cursor{Height: 2, TxPackageIndex: types.NotInPackage, TxIndex: 1})
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{2, 3, 3, 3})
+		})
+
+		Convey("is not queried with invalid arguments", func() {
+			_, _, err := ix.Query(to, 3, 2, 100, nil)
+			So(err, ShouldEqual, ErrInvalidHeightRange)
+			_, _, err = ix.Query(to, 0, 100, 0, nil)
+			So(err, ShouldEqual, ErrInvalidLimit)
+			_, err = ParseCursor([]byte{1, 2, 3})
+			So(err, ShouldEqual, ErrInvalidCursor)
+		})
+	})
+}
+
+func TestIndexerReorg(t *testing.T) {
+	Convey("the indexer follows the main branch", t, func() {
+		c := newTestChain()
+		_, key, err := crypto.NewKeys(crypto.ECDSA)
+		So(err, ShouldBeNil)
+		oldTo, newTo := common.HexToAddress("0x0102"), common.HexToAddress("0x0103")
+		ix := NewIndexer(c, testSigner)
+		ix.Start()
+		// stops the indexer started again as well
+		Reset(func() { ix.Stop() })
+
+		// the main branch to height 4, executed after the indexer is started
+		var main types.Blocks
+		for height := uint64(1); height <= 4; height++ {
+			block := c.child(c.CurrentBlock(), height*10, newTestTx(key, height-1, oldTo))
+			c.executedFeed.Send(types.BlockExecutedEvent{Block: block})
+			main = append(main, block)
+		}
+		waitHead(ix, main[3])
+		entries, _, err := ix.Query(oldTo, 0, 100, 100, nil)
+		So(err, ShouldBeNil)
+		So(heights(entries), ShouldResemble, []uint64{1, 2, 3, 4})
+
+		// a longer branch from height 2
+		var fork types.Blocks
+		parent := main[1]
+		for height := uint64(3); height <= 5; height++ {
+			parent = c.child(parent, height*10+1, newTestTx(key, height-1, newTo))
+			fork = append(fork, parent)
+		}
+
+		Convey("rolls back the blocks of a reorg", func() {
+			for _, block := range fork {
+				c.executedFeed.Send(types.BlockExecutedEvent{Block: block})
+			}
+			waitHead(ix, fork[2])
+
+			entries, _, err := ix.Query(oldTo, 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{1, 2})
+			entries, _, err = ix.Query(newTo, 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{3, 4, 5})
+			entries, _, err = ix.Query(key.Public().ToAddress(), 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{1, 2, 3, 4, 5})
+
+			Convey("and rewinds the head on a reorg without added blocks", func() {
+				c.setMain(2)
+				c.reorgFeed.Send(types.ChainReorgEvent{CommonAncestor: main[1], Dropped: types.Blocks{fork[2], fork[1], fork[0]}})
+				waitHead(ix, main[1])
+
+				entries, _, err := ix.Query(newTo, 0, 100, 100, nil)
+				So(err, ShouldBeNil)
+				So(entries, ShouldBeEmpty)
+				entries, _, err = ix.Query(key.Public().ToAddress(), 0, 100, 100, nil)
+				So(err, ShouldBeNil)
+				So(heights(entries), ShouldResemble, []uint64{1, 2})
+				height, hash, err := dbaccessor.ReadAddressHistoryHead(c.db)
+				So(err, ShouldBeNil)
+				So(height, ShouldEqual, 2)
+				So(hash, ShouldEqual, main[1].FullHash())
+			})
+		})
+
+		Convey("rolls back the blocks of a reorg when it is started again", func() {
+			ix.Stop()
+			ix = NewIndexer(c, testSigner)
+			ix.Start()
+			So(ix.Head().FullHash(), ShouldEqual, fork[2].FullHash())
+
+			entries, _, err := ix.Query(oldTo, 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{1, 2})
+			entries, _, err = ix.Query(newTo, 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{3, 4, 5})
+		})
+	})
+}
+
+func TestIndexerRewind(t *testing.T) {
+	Convey("the entries of the contracts and the log emitters are removed by a rewind", t, func() {
+		c := newTestChain()
+		_, key, err := crypto.NewKeys(crypto.ECDSA)
+		So(err, ShouldBeNil)
+		to := common.HexToAddress("0x0102")
+		contracts := make(map[uint64]common.Address)
+		emitter := common.HexToAddress("0x0201")
+		var main types.Blocks
+		for height := uint64(1); height <= 5; height++ {
+			tx := newTestTx(key, height-1, to)
+			block := c.child(c.CurrentBlock(), height, tx)
+			contracts[height] = common.BigToAddress(new(big.Int).SetUint64(0x0300 + height))
+			dbaccessor.WriteReceipts(c.db, block.FullHash(), types.Receipts{newTestReceipt(tx, contracts[height], emitter)})
+			main = append(main, block)
+		}
+		ix := NewIndexer(c, testSigner)
+		ix.Start()
+		// stops the indexer started again as well
+		Reset(func() { ix.Stop() })
+
+		entries, _, err := ix.Query(emitter, 0, 100, 100, nil)
+		So(err, ShouldBeNil)
+		So(heights(entries), ShouldResemble, []uint64{1, 2, 3, 4, 5})
+		entries, _, err = ix.Query(contracts[4], 0, 100, 100, nil)
+		So(err, ShouldBeNil)
+		So(heights(entries), ShouldResemble, []uint64{4})
+
+		check := func() {
+			entries, _, err := ix.Query(emitter, 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{1, 2})
+			for height, contract := range contracts {
+				entries, _, err := ix.Query(contract, 0, 100, 100, nil)
+				So(err, ShouldBeNil)
+				if height <= 2 {
+					So(heights(entries), ShouldResemble, []uint64{height})
+				} else {
+					So(entries, ShouldBeEmpty)
+				}
+				// the entries above the head are gone, not only hidden
+				So(dbaccessor.ReadAddressHistory(c.db, contract, dbaccessor.AddressHistoryEntry{Height: 3}, 100, 100), ShouldBeEmpty)
+			}
+			So(dbaccessor.ReadAddressHistory(c.db, emitter, dbaccessor.AddressHistoryEntry{Height: 3}, 100, 100), ShouldBeEmpty)
+		}
+
+		Convey("after the receipts of the dropped blocks are deleted", func() {
+			c.rewind(2)
+			c.reorgFeed.Send(types.ChainReorgEvent{CommonAncestor: main[1], Dropped: types.Blocks{main[4], main[3], main[2]}})
+			waitHead(ix, main[1])
+			check()
+		})
+
+		Convey("when it is started again after the rewind", func() {
+			ix.Stop()
+			c.rewind(2)
+			ix = NewIndexer(c, testSigner)
+			ix.Start()
+			So(ix.Head().FullHash(), ShouldEqual, main[1].FullHash())
+			check()
+		})
+	})
+}
+
+func TestIndexerReindex(t *testing.T) {
+	Convey("a block indexed again below the head", t, func() {
+		c := newTestChain()
+		_, key, err := crypto.NewKeys(crypto.ECDSA)
+		So(err, ShouldBeNil)
+		to, other := common.HexToAddress("0x0102"), common.HexToAddress("0x0103")
+		var main types.Blocks
+		for height := uint64(1); height <= 4; height++ {
+			main = append(main, c.child(c.CurrentBlock(), height, newTestTx(key, 2*height-2, to), newTestTx(key, 2*height-1, to)))
+		}
+		ix := startTestIndexer(c)
+
+		// another block at the height 2 with one tx to another address
+		block := types.NewBlock(main[0].SimpleHash(), 21, []byte{21}, common.Address{}, big.NewInt(1), 2)
+		block.Header.ParentFullHash = main[0].FullHash()
+		block.Body.Transactions = []*types.Transaction{newTestTx(key, 2, other)}
+		c.add(block)
+		ix.index(block, false)
+
+		Convey("replaces only the entries at its height", func() {
+			So(ix.Head().FullHash(), ShouldEqual, main[3].FullHash())
+			entries, _, err := ix.Query(to, 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{1, 1, 3, 3, 4, 4})
+			entries, _, err = ix.Query(other, 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{2})
+			entries, _, err = ix.Query(key.Public().ToAddress(), 0, 100, 100, nil)
+			So(err, ShouldBeNil)
+			So(heights(entries), ShouldResemble, []uint64{1, 1, 2, 3, 3, 4, 4})
+		})
+	})
+}
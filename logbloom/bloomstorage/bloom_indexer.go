@@ -21,6 +21,7 @@ type blockChain interface {
 	GetBlock(hash common.Hash) *types.Block
 	CurrentBlock() *types.Block
 	SubscribeBlockExecutedEvent(ch chan<- types.BlockExecutedEvent) event.Subscription
+	SubscribeChainReorgEvent(ch chan<- types.ChainReorgEvent) event.Subscription
 	GetMainBranchBlock(height uint64) (*types.Block, error)
 	GetCheckPoint() *types.CheckPoint
 }
@@ -93,6 +94,8 @@ func NewBloomIndexer(chainDb dbwrapper.Database) *BloomIndexer {
 func (b *BloomIndexer) Start(chain blockChain) {
 	events := make(chan types.BlockExecutedEvent, 10)
 	sub := chain.SubscribeBlockExecutedEvent(events)
+	reorgs := make(chan types.ChainReorgEvent, 10)
+	reorgSub := chain.SubscribeChainReorgEvent(reorgs)
 
 	bloomInfoList := logbloom.GetBloomList()
 	bloomInfoList.Mu.Lock()
@@ -141,7 +144,7 @@ func (b *BloomIndexer) Start(chain blockChain) {
 
 	bloomInfoList.Mu.Unlock()
 
-	go b.eventLoop(events, sub, chain)
+	go b.eventLoop(events, sub, reorgs, reorgSub, chain)
 }
 
 // Close tears down all goroutines belonging to the indexer and returns any error
@@ -180,11 +183,12 @@ func (b *BloomIndexer) Close() error {
 // eventLoop is a secondary - optional - event loop of the indexer which is only
 // started for the outermost indexer to push block executed events into a processing
 // queue.
-func (b *BloomIndexer) eventLoop(events chan types.BlockExecutedEvent, sub event.Subscription, blockChain blockChain) {
+func (b *BloomIndexer) eventLoop(events chan types.BlockExecutedEvent, sub event.Subscription, reorgs chan types.ChainReorgEvent, reorgSub event.Subscription, blockChain blockChain) {
 	// Mark the chain indexer as active, requiring an additional teardown
 	atomic.StoreUint32(&b.active, 1)
 
 	defer sub.Unsubscribe()
+	defer reorgSub.Unsubscribe()
 
 	for {
 		select {
@@ -254,6 +258,16 @@ func (b *BloomIndexer) eventLoop(events chan types.BlockExecutedEvent, sub event
 				// set the last block bloom
 				b.insertBloom(block, bloomInfoList)
 			}
+
+		case ev := <-reorgs:
+			// the reorgs with added blocks are handled by their executed blocks,
+			// a reorg without added blocks is a rewind of the chain head
+			if len(ev.Added) > 0 || ev.CommonAncestor.Header.Height >= b.blockExecutedHead.Header.Height {
+				continue
+			}
+			log.Info("eventLoop: chain rewind", "height", ev.CommonAncestor.Header.Height, "oldHeight", b.blockExecutedHead.Header.Height)
+			b.clearHigherBlooms(ev.CommonAncestor.Header.Height+1, b.blockExecutedHead.Header.Height, logbloom.GetBloomList(), blockChain)
+			b.blockExecutedHead = ev.CommonAncestor
 		}
 	}
 }
@@ -0,0 +1,21 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package miner contains implementations for block mining strategy.
+package miner
+
+import "time"
+
+// Clock is the source of the time the worker seals the rounds at. It makes
+// it possible to mine on a simulated clock in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock implements Clock using the system clock.
+type SystemClock struct{}
+
+// Now implements Clock.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
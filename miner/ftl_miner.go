@@ -49,12 +49,17 @@ type ftlMiner struct {
 	blockChain        blockChain
 }
 
-func NewFtlMiner(blockChain blockChain, executor txexec.TxExecutor, txPool pool.Pool, pkgPool pool.Pool, keyman *keys.MiningKeyManager) Miner {
+// NewFtlMiner creates a miner which seals the rounds at the time of the clock.
+// A nil clock means the system clock.
+func NewFtlMiner(blockChain blockChain, executor txexec.TxExecutor, txPool pool.Pool, pkgPool pool.Pool, keyman *keys.MiningKeyManager, clock Clock) Miner {
+	if clock == nil {
+		clock = SystemClock{}
+	}
 	miner := &ftlMiner{
 		newMinedBlockFeed: new(event.Feed),
 		blockChain:        blockChain,
 	}
-	miner.worker = newWorker(blockChain, executor, txPool, pkgPool, miner.newMinedBlockFeed, keyman)
+	miner.worker = newWorker(blockChain, executor, txPool, pkgPool, miner.newMinedBlockFeed, keyman, clock)
 
 	return miner
 }
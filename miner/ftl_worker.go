@@ -78,12 +78,13 @@ type worker struct {
 	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
 	keyman   *keys.MiningKeyManager
 	coinbase common.Address
+	clock    Clock
 
 	// atomic status counters
 	running int32 // The indicator whether the consensus engine is running or not.
 }
 
-func newWorker(chain blockChain, executor txexec.TxExecutor, txPool pool.Pool, pkgPool pool.Pool, newMinedBlockFeed *event.Feed, keyman *keys.MiningKeyManager) *worker {
+func newWorker(chain blockChain, executor txexec.TxExecutor, txPool pool.Pool, pkgPool pool.Pool, newMinedBlockFeed *event.Feed, keyman *keys.MiningKeyManager, clock Clock) *worker {
 	worker := &worker{
 		chain:             chain,
 		txPool:            txPool,
@@ -96,6 +97,7 @@ func newWorker(chain blockChain, executor txexec.TxExecutor, txPool pool.Pool, p
 		startCh:           make(chan struct{}, 1),
 		newMinedBlockFeed: newMinedBlockFeed,
 		keyman:            keyman,
+		clock:             clock,
 	}
 
 	// Subscribe events for blockchain
@@ -175,7 +177,7 @@ func (w *worker) mainLoop() {
 // seal pushes a sealing task to consensus engine and submits the result.
 func (w *worker) seal(t *task, stop <-chan struct{}) {
 	var (
-		round = uint64(time.Now().UnixNano() / (1e9 / params.RoundsPerSecond))
+		round = uint64(w.clock.Now().UnixNano() / (1e9 / params.RoundsPerSecond))
 	)
 
 	ticker := time.NewTicker(time.Millisecond * 10)
@@ -193,8 +195,9 @@ search:
 			}
 
 			// Compute the PoS value of this round
-			currentRoundMills := uint64(time.Now().UnixNano() / 1e6)
-			currentRound := uint64(time.Now().UnixNano() / (1e9 / params.RoundsPerSecond))
+			now := w.clock.Now()
+			currentRoundMills := uint64(now.UnixNano() / 1e6)
+			currentRound := uint64(now.UnixNano() / (1e9 / params.RoundsPerSecond))
 			if currentRound <= round {
 				continue search
 			}
@@ -389,7 +392,7 @@ func (w *worker) resultLoop() {
 			block.Header.GasUsed = *usedGas
 
 			// set reward
-			state.AddBlockReward(stateDb, block, confirmedBlocks)
+			state.AddBlockReward(stateDb, w.chain.GetChainConfig().Rewards(), block, confirmedBlocks)
 
 			block.Header.StateHash = stateDb.IntermediateRoot(true)
 
@@ -558,10 +561,13 @@ func (w *worker) packTransactions(block *types.Block, prevStateDb *state.StateDB
 		return nil
 	}
 
-	return w.flattenTransactionsByPrice(block, queue, prevStateDb, stateDb)
+	return FlattenTransactionsByPrice(block, queue, prevStateDb, stateDb, w.chain.GetChainConfig().MaxNonceBitLength)
 }
 
-func (w *worker) flattenTransactionsByPrice(block *types.Block, queue map[common.Address][]pool.Element, prevStateDb *state.StateDB, stateDb *state.StateDB) types.Transactions {
+// FlattenTransactionsByPrice returns the transactions of the queue which can be
+// packed into the block, ordered by price and nonce. The nonces are checked
+// against the state of the parent block and the state before the cache height.
+func FlattenTransactionsByPrice(block *types.Block, queue map[common.Address][]pool.Element, prevStateDb *state.StateDB, stateDb *state.StateDB, maxNonceBitLength uint64) types.Transactions {
 	var packedTxs types.Transactions
 
 	txByPriceAndNonce := pool.NewElementsByPriceAndNonce(queue)
@@ -619,7 +625,7 @@ func (w *worker) flattenTransactionsByPrice(block *types.Block, queue map[common
 			continue
 		}
 
-		searchResult := nonceSet.Search(tx.Nonce(), w.chain.GetChainConfig().MaxNonceBitLength)
+		searchResult := nonceSet.Search(tx.Nonce(), maxNonceBitLength)
 		if searchResult != nonces.NotContainedAndAllowed {
 			log.Info("ignore tx: nonce error", "nonce", tx.Nonce(), "addr", from, "searchResult", searchResult)
 			continue
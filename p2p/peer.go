@@ -112,6 +112,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// testPipe is closed on Disconnect, for the peers created by NewPeerPipe
+	testPipe io.Closer
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -123,6 +126,14 @@ func NewPeer(id discover.NodeID, name string, caps []Cap) *Peer {
 	return peer
 }
 
+// NewPeerPipe returns a peer for testing purposes. The pipe the peer's
+// protocols run on is closed when the peer is disconnected.
+func NewPeerPipe(id discover.NodeID, name string, caps []Cap, pipe io.Closer) *Peer {
+	peer := NewPeer(id, name, caps)
+	peer.testPipe = pipe
+	return peer
+}
+
 // ID returns the node's public key.
 func (p *Peer) ID() discover.NodeID {
 	return p.rw.id
@@ -152,6 +163,9 @@ func (p *Peer) LocalAddr() net.Addr {
 // Disconnect terminates the peer connection with the given reason.
 // It returns immediately and does not wait until the connection is closed.
 func (p *Peer) Disconnect(reason DiscReason) {
+	if p.testPipe != nil {
+		p.testPipe.Close()
+	}
 	select {
 	case p.disc <- reason:
 	case <-p.closed:
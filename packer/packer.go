@@ -14,5 +14,6 @@ type Packer interface {
 	StartPacking(packerIndex uint32)
 	StopPacking()
 	IsPacking() bool
+	PackerIndex() (uint32, bool)
 	Subscribe(ch chan<- types.TxPackages) event.Subscription
 }
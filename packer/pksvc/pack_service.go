@@ -34,7 +34,7 @@ func newPackService(cfg *config.Config, packerKeyManager packerKeyManager, pkgPo
 	fakeMode := cfg.FakeMode
 	interval := cfg.PackerInterval
 	listenAddr := cfg.PackerCollectAddr
-	container := newTxContainer(fakeMode, make(chan *types.Transaction), txSigner, chain, packerGroupSize);
+	container := newTxContainer(fakeMode, make(chan *types.Transaction), txSigner, chain, packerGroupSize, DefaultContainerSize, cfg.TxPoolConfig.PriceBump)
 	p := &packService{
 		txCollector: tx_collector.NewTxCollector(listenAddr),
 		container:   container,
@@ -96,6 +96,14 @@ func (self *packService) IsPacking() bool {
 	return atomic.LoadInt32(&self.running) == 1
 }
 
+// PackerIndex returns the packer index the service is packing for.
+func (self *packService) PackerIndex() (uint32, bool) {
+	if !self.IsPacking() || self.worker.packerIndex == nil {
+		return 0, false
+	}
+	return *self.worker.packerIndex, true
+}
+
 func (self *packService) Subscribe(ch chan<- types.TxPackages) event.Subscription {
 	return self.worker.newPkgEventFeed.Subscribe(ch)
 }
@@ -13,6 +13,10 @@ import (
 
 const (
 	DefaultPkgSize = 1024
+
+	// DefaultContainerSize is the number of transactions kept by the packer
+	// before the cheapest ones are evicted.
+	DefaultContainerSize = 16 * DefaultPkgSize
 )
 
 type blockChain interface {
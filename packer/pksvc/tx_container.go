@@ -3,6 +3,8 @@ package pksvc
 import (
 	"container/heap"
 	"errors"
+	"math/big"
+	"sort"
 	"sync"
 
 	"github.com/fractal-platform/fractal/common"
@@ -16,24 +18,38 @@ import (
 var (
 	ErrTxAlreadyExist            = errors.New("the tx already exists in the tx container of packer")
 	ErrContainerNotBigEnough     = errors.New("the tx container doesn't have enough tx")
+	ErrContainerFull             = errors.New("the tx container is full and the tx is underpriced")
 	ErrTransactionNotMatchPacker = errors.New("the transaction and the packer don't match")
 	ErrIsBroadcastTx             = errors.New("the transaction should be broadcast")
 )
 
+// indexQueue keeps the pending transactions of the packer. The transactions
+// are popped by gas price, while the transactions of a sender are popped in
+// nonce order. When the queue is full, the sender of the cheapest transaction
+// gives up its highest nonce transaction for one paying more than it, so that
+// no nonce gap is left behind. A transaction with the same sender and nonce
+// replaces the queued one only if its gas price is bumped by priceBump percent.
 type indexQueue struct {
-	txSigner types.Signer
+	txSigner  types.Signer
+	maxSize   int
+	priceBump uint64
 
-	queue types.TxByPrice
-	index map[common.Hash]*types.Transaction
+	accounts map[common.Address]types.Transactions // sender -> txs sorted by nonce
+	index    map[common.Hash]*types.Transaction    // packing hash -> tx
+	cheap    txByPriceAsc                          // price heap for eviction, may contain removed txs
+	stales   int                                   // number of removed txs in the price heap
 
 	mu sync.RWMutex
 }
 
-func newIndexQueue(txSigner types.Signer) indexQueue {
+func newIndexQueue(txSigner types.Signer, maxSize int, priceBump uint64) indexQueue {
 	return indexQueue{
-		txSigner: txSigner,
-		queue:    make(types.TxByPrice, 0),
-		index:    make(map[common.Hash]*types.Transaction),
+		txSigner:  txSigner,
+		maxSize:   maxSize,
+		priceBump: priceBump,
+		accounts:  make(map[common.Address]types.Transactions),
+		index:     make(map[common.Hash]*types.Transaction),
+		cheap:     make(txByPriceAsc, 0),
 	}
 }
 
@@ -46,48 +62,154 @@ func (q *indexQueue) push(tx *types.Transaction) error {
 
 func (q *indexQueue) pushUnsafe(tx *types.Transaction) error {
 	if old, ok := q.index[tx.PackingHash(q.txSigner)]; ok {
-		if old.GasPrice().Cmp(tx.GasPrice()) < 0 {
-			log.Info("pksvc tx container: replace a tx", "old price", old.GasPrice().Uint64(), "new price", tx.GasPrice().Uint64())
-			*old = *tx
-			return nil
+		if old.Hash() == tx.Hash() {
+			return ErrTxAlreadyExist
 		}
-		return ErrTxAlreadyExist
+		// the same as the replacement of pool.EleList
+		threshold := new(big.Int).Div(new(big.Int).Mul(old.GasPrice(), big.NewInt(100+int64(q.priceBump))), big.NewInt(100))
+		if old.GasPrice().Cmp(tx.GasPrice()) >= 0 || threshold.Cmp(tx.GasPrice()) > 0 {
+			return pool.ErrReplaceUnderpriced
+		}
+		log.Info("pksvc tx container: replace a tx", "old price", old.GasPrice().Uint64(), "new price", tx.GasPrice().Uint64())
+		q.removeUnsafe(old)
+	} else if q.maxSize > 0 && len(q.index) >= q.maxSize {
+		cheapest := q.cheapestUnsafe()
+		if cheapest == nil || cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0 {
+			return ErrContainerFull
+		}
+		// the txs after the cheapest one can't be packed before it, evict the last of them
+		from, _ := types.Sender(q.txSigner, cheapest)
+		txs := q.accounts[from]
+		last := txs[len(txs)-1]
+		if last.GasPrice().Cmp(tx.GasPrice()) >= 0 {
+			return ErrContainerFull
+		}
+		if sender, _ := types.Sender(q.txSigner, tx); sender == from && tx.Nonce() > last.Nonce() {
+			return ErrContainerFull
+		}
+		log.Debug("pksvc tx container: evict a tx", "hash", last.Hash(), "price", last.GasPrice().Uint64(), "new price", tx.GasPrice().Uint64())
+		q.removeUnsafe(last)
 	}
 
-	heap.Push(&q.queue, tx)
+	from, _ := types.Sender(q.txSigner, tx) // has checked, ignore error
+	txs := q.accounts[from]
+	i := sort.Search(len(txs), func(i int) bool { return txs[i].Nonce() >= tx.Nonce() })
+	txs = append(txs, nil)
+	copy(txs[i+1:], txs[i:])
+	txs[i] = tx
+	q.accounts[from] = txs
+
 	q.index[tx.PackingHash(q.txSigner)] = tx
+	heap.Push(&q.cheap, tx)
+	return nil
+}
+
+// removeUnsafe removes the tx from the queue, leaving it in the price heap
+// until it reaches the top or the heap is rebuilt.
+func (q *indexQueue) removeUnsafe(tx *types.Transaction) {
+	delete(q.index, tx.PackingHash(q.txSigner))
+
+	from, _ := types.Sender(q.txSigner, tx)
+	txs := q.accounts[from]
+	i := sort.Search(len(txs), func(i int) bool { return txs[i].Nonce() >= tx.Nonce() })
+	if i < len(txs) && txs[i] == tx {
+		txs = append(txs[:i], txs[i+1:]...)
+	}
+	if len(txs) == 0 {
+		delete(q.accounts, from)
+	} else {
+		q.accounts[from] = txs
+	}
+
+	q.stales++
+	if q.stales > len(q.cheap)/4 {
+		q.reheapUnsafe()
+	}
+}
+
+// cheapestUnsafe returns the tx with the lowest gas price in the queue.
+func (q *indexQueue) cheapestUnsafe() *types.Transaction {
+	for len(q.cheap) > 0 {
+		tx := q.cheap[0]
+		if q.index[tx.PackingHash(q.txSigner)] == tx {
+			return tx
+		}
+		heap.Pop(&q.cheap)
+		q.stales--
+	}
 	return nil
 }
 
+func (q *indexQueue) reheapUnsafe() {
+	q.cheap = make(txByPriceAsc, 0, len(q.index))
+	for _, tx := range q.index {
+		q.cheap = append(q.cheap, tx)
+	}
+	heap.Init(&q.cheap)
+	q.stales = 0
+}
+
+// popN pops the n best paying txs, in the same way as pool.ElementsByPriceAndNonce.
 func (q *indexQueue) popN(n int) ([]*types.Transaction, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if q.queue.Len() < n {
+	if len(q.index) < n {
 		return nil, ErrContainerNotBigEnough
 	}
+
+	var (
+		heads = make(types.TxByPrice, 0, len(q.accounts))
+		next  = make(map[common.Address]int, len(q.accounts))
+	)
+	for from, txs := range q.accounts {
+		heads = append(heads, txs[0])
+		next[from] = 1
+	}
+	heap.Init(&heads)
+
 	txs := make([]*types.Transaction, n)
 	for i := 0; i < n; i++ {
-		txs[i] = q.popUnsafe()
+		txs[i] = heads[0]
+		from, _ := types.Sender(q.txSigner, txs[i])
+		if accTxs := q.accounts[from]; next[from] < len(accTxs) {
+			heads[0] = accTxs[next[from]]
+			next[from]++
+			heap.Fix(&heads, 0)
+		} else {
+			heap.Pop(&heads)
+		}
 	}
-	return txs, nil
-}
-
-func (q *indexQueue) popUnsafe() *types.Transaction {
-	if len(q.queue) == 0 {
-		return nil
+	for _, tx := range txs {
+		q.removeUnsafe(tx)
 	}
-
-	t := heap.Pop(&q.queue).(*types.Transaction)
-	delete(q.index, t.PackingHash(q.txSigner))
-	return t
+	return txs, nil
 }
 
 func (q *indexQueue) len() int {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	return q.queue.Len()
+	return len(q.index)
+}
+
+// txByPriceAsc is a heap of txs with the lowest gas price on the top.
+type txByPriceAsc types.Transactions
+
+func (s txByPriceAsc) Len() int           { return len(s) }
+func (s txByPriceAsc) Less(i, j int) bool { return s[i].GasPrice().Cmp(s[j].GasPrice()) < 0 }
+func (s txByPriceAsc) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s *txByPriceAsc) Push(x interface{}) {
+	*s = append(*s, x.(*types.Transaction))
+}
+
+func (s *txByPriceAsc) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
 }
 
 type txContainer struct {
@@ -102,9 +224,9 @@ type txContainer struct {
 	mu      sync.RWMutex
 }
 
-func newTxContainer(fakeMode bool, newTxCh chan *types.Transaction, signer types.Signer, chain blockChain, packerGroupSize uint64) *txContainer {
+func newTxContainer(fakeMode bool, newTxCh chan *types.Transaction, signer types.Signer, chain blockChain, packerGroupSize uint64, maxSize int, priceBump uint64) *txContainer {
 	return &txContainer{
-		queue:           newIndexQueue(signer),
+		queue:           newIndexQueue(signer, maxSize, priceBump),
 		fakeMode:        fakeMode,
 		signer:          signer,
 		newTxCh:         newTxCh,
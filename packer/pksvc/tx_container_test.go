@@ -0,0 +1,161 @@
+package pksvc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/pool"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/crypto"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testSigner = types.NewEIP155Signer(1)
+
+func newTestKey() crypto.PrivateKey {
+	_, key, err := crypto.NewKeys(crypto.ECDSA)
+	So(err, ShouldBeNil)
+	return key
+}
+
+func newTestTx(key crypto.PrivateKey, nonce uint64, price int64) *types.Transaction {
+	tx := types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(price), nil, false)
+	tx, err := types.SignTx(tx, testSigner, key)
+	So(err, ShouldBeNil)
+	return tx
+}
+
+func nonces(txs []*types.Transaction) []uint64 {
+	ns := make([]uint64, len(txs))
+	for i, tx := range txs {
+		ns[i] = tx.Nonce()
+	}
+	return ns
+}
+
+func TestIndexQueuePop(t *testing.T) {
+	Convey("the txs are popped by price, and in nonce order for a sender", t, func() {
+		q := newIndexQueue(testSigner, 0, 10)
+		a, b := newTestKey(), newTestKey()
+		txs := []*types.Transaction{
+			newTestTx(a, 1, 10),
+			newTestTx(a, 0, 2),
+			newTestTx(b, 0, 5),
+			newTestTx(b, 1, 4),
+		}
+		for _, tx := range txs {
+			So(q.push(tx), ShouldBeNil)
+		}
+
+		_, err := q.popN(5)
+		So(err, ShouldEqual, ErrContainerNotBigEnough)
+
+		popped, err := q.popN(4)
+		So(err, ShouldBeNil)
+		So(popped, ShouldResemble, []*types.Transaction{txs[2], txs[3], txs[1], txs[0]})
+		So(q.len(), ShouldEqual, 0)
+	})
+}
+
+func TestIndexQueueReplace(t *testing.T) {
+	Convey("a tx with the same sender and nonce", t, func() {
+		q := newIndexQueue(testSigner, 0, 10)
+		key := newTestKey()
+		old := newTestTx(key, 0, 100)
+		So(q.push(old), ShouldBeNil)
+
+		Convey("is rejected if it is the queued one", func() {
+			So(q.push(old), ShouldEqual, ErrTxAlreadyExist)
+		})
+
+		Convey("is rejected if its price is not bumped enough", func() {
+			So(q.push(newTestTx(key, 0, 90)), ShouldEqual, pool.ErrReplaceUnderpriced)
+			So(q.push(newTestTx(key, 0, 109)), ShouldEqual, pool.ErrReplaceUnderpriced)
+			popped, err := q.popN(1)
+			So(err, ShouldBeNil)
+			So(popped[0], ShouldEqual, old)
+		})
+
+		Convey("replaces the queued one if its price is bumped enough", func() {
+			tx := newTestTx(key, 0, 110)
+			So(q.push(tx), ShouldBeNil)
+			So(q.len(), ShouldEqual, 1)
+			popped, err := q.popN(1)
+			So(err, ShouldBeNil)
+			So(popped[0], ShouldEqual, tx)
+		})
+	})
+}
+
+func TestIndexQueueEvict(t *testing.T) {
+	Convey("a full queue", t, func() {
+		q := newIndexQueue(testSigner, 3, 10)
+		a, b := newTestKey(), newTestKey()
+		So(q.push(newTestTx(a, 0, 1)), ShouldBeNil)
+		So(q.push(newTestTx(a, 1, 5)), ShouldBeNil)
+		So(q.push(newTestTx(b, 0, 3)), ShouldBeNil)
+
+		Convey("rejects a tx paying no more than the cheapest one", func() {
+			So(q.push(newTestTx(b, 1, 1)), ShouldEqual, ErrContainerFull)
+			So(q.len(), ShouldEqual, 3)
+		})
+
+		Convey("keeps the last tx of the sender of the cheapest one if it pays more", func() {
+			// a1 pays 5, more than the tx would evict it for
+			So(q.push(newTestTx(b, 1, 2)), ShouldEqual, ErrContainerFull)
+			So(q.len(), ShouldEqual, 3)
+			So(nonces(q.accounts[a.Public().ToAddress()]), ShouldResemble, []uint64{0, 1})
+			So(nonces(q.accounts[b.Public().ToAddress()]), ShouldResemble, []uint64{0})
+		})
+
+		Convey("evicts the last tx of the sender of the cheapest one for a tx paying more", func() {
+			tx := newTestTx(b, 1, 6)
+			So(q.push(tx), ShouldBeNil)
+			So(q.len(), ShouldEqual, 3)
+
+			from, _ := types.Sender(testSigner, tx)
+			So(nonces(q.accounts[from]), ShouldResemble, []uint64{0, 1})
+			for sender, txs := range q.accounts {
+				if sender != from {
+					So(nonces(txs), ShouldResemble, []uint64{0})
+				}
+			}
+		})
+
+		Convey("rejects a tx after the evicted one of the same sender", func() {
+			So(q.push(newTestTx(a, 2, 10)), ShouldEqual, ErrContainerFull)
+			So(q.len(), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestIndexQueueStales(t *testing.T) {
+	Convey("the removed txs are dropped from the price heap", t, func() {
+		q := newIndexQueue(testSigner, 0, 10)
+		key := newTestKey()
+		for i := 0; i < 8; i++ {
+			So(q.push(newTestTx(key, uint64(i), int64(i+1))), ShouldBeNil)
+		}
+
+		popped, err := q.popN(2)
+		So(err, ShouldBeNil)
+		So(nonces(popped), ShouldResemble, []uint64{0, 1})
+		So(q.stales, ShouldEqual, 2)
+		So(len(q.cheap), ShouldEqual, 8)
+
+		Convey("when the cheapest tx is looked up", func() {
+			cheapest := q.cheapestUnsafe()
+			So(cheapest.Nonce(), ShouldEqual, 2)
+			So(q.stales, ShouldEqual, 0)
+			So(len(q.cheap), ShouldEqual, 6)
+		})
+
+		Convey("when a quarter of the heap is stale", func() {
+			_, err := q.popN(1)
+			So(err, ShouldBeNil)
+			So(q.stales, ShouldEqual, 0)
+			So(len(q.cheap), ShouldEqual, 5)
+		})
+	})
+}
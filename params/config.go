@@ -15,8 +15,11 @@ const (
 	TxGasContractCreateData uint64 = 20000   //
 	TxDataZeroGas           uint64 = 400     // Per byte of data attached to a transaction that equals zero. NOTE: Not payable on data of calls between transactions.
 	TxDataNonZeroGas        uint64 = 6800    // Per byte of data attached to a transaction that is not equal to zero. NOTE: Not payable on data of calls between transactions.
+	EvidenceVerifyGas       uint64 = 2000000 // Per evidence submitted to the miner key contract, for verifying the signatures.
+	AbiStoreDataGas         uint64 = 20000   // Per byte of abi stored in the abi registry.
 
 	MaxCodeSize = 256 * 1024 * 1024 // Maximum bytecode to permit for a contract
+	MaxAbiSize  = 64 * 1024         // Maximum abi to permit in the abi registry
 
 	BloomByteSize               uint64 = 512
 	BloomBitsSize                      = BloomByteSize * 8 // 4096, Must be divisible by 8
@@ -1,6 +1,11 @@
 package params
 
-import "math/big"
+import (
+	"errors"
+	"math/big"
+
+	"github.com/fractal-platform/fractal/common"
+)
 
 var (
 	OriginalMiningRewardValue    = big.NewInt(5 * 1e9) // 5FRA
@@ -13,4 +18,91 @@ var (
 const (
 	MiningAwardCycle              uint64 = 3002368 // Must be divisible by 4096
 	MaxMiningRewardReductionTimes uint64 = 10
+	MiningRewardReductionPercent  uint64 = 10
 )
+
+var (
+	ErrRewardValue            = errors.New("reward schedule: rewards must be set and not negative")
+	ErrRewardCycle            = errors.New("reward schedule: cycle can't be 0")
+	ErrRewardReductionPercent = errors.New("reward schedule: reduction percent must be at most 100")
+	ErrRewardFeeRecipient     = errors.New("reward schedule: fees can't be both burnt and paid to the treasury")
+)
+
+// DefaultRewardSchedule is the reward schedule of the chains which don't set one.
+var DefaultRewardSchedule = &RewardSchedule{
+	MiningReward:     OriginalMiningRewardValue,
+	ConfirmReward:    OriginalConfirmRewardValue,
+	ConfirmedReward:  OriginalConfirmedRewardValue,
+	Cycle:            MiningAwardCycle,
+	ReductionPercent: MiningRewardReductionPercent,
+	MaxReductions:    MaxMiningRewardReductionTimes,
+}
+
+// RewardSchedule is the economics of a chain. The block rewards start at the
+// initial values, and are reduced by ReductionPercent at the start of each
+// cycle, at most MaxReductions times. The transaction fees are paid to the
+// miner of the block, unless they are burnt or paid to the treasury.
+type RewardSchedule struct {
+	MiningReward    *big.Int `json:"miningReward"`    // paid to the miner of a block
+	ConfirmReward   *big.Int `json:"confirmReward"`   // paid to the miner of a block for each block it confirms
+	ConfirmedReward *big.Int `json:"confirmedReward"` // paid to the miner of each confirmed block
+
+	Cycle            uint64 `json:"cycle"` // number of blocks between two reductions
+	ReductionPercent uint64 `json:"reductionPercent"`
+	MaxReductions    uint64 `json:"maxReductions"`
+
+	FeeBurn  bool            `json:"feeBurn,omitempty"`
+	Treasury *common.Address `json:"treasury,omitempty"`
+}
+
+// Validate checks the schedule is usable by a chain.
+func (r *RewardSchedule) Validate() error {
+	for _, value := range []*big.Int{r.MiningReward, r.ConfirmReward, r.ConfirmedReward} {
+		if value == nil || value.Sign() < 0 {
+			return ErrRewardValue
+		}
+	}
+	if r.Cycle == 0 {
+		return ErrRewardCycle
+	}
+	if r.ReductionPercent > 100 {
+		return ErrRewardReductionPercent
+	}
+	if r.FeeBurn && r.Treasury != nil {
+		return ErrRewardFeeRecipient
+	}
+	return nil
+}
+
+// Rewards returns the mining, confirm and confirmed rewards of the block at
+// the height.
+func (r *RewardSchedule) Rewards(height uint64) (mining *big.Int, confirm *big.Int, confirmed *big.Int) {
+	reductions := height / r.Cycle
+	if reductions > r.MaxReductions {
+		reductions = r.MaxReductions
+	}
+
+	mining, confirm, confirmed = r.MiningReward, r.ConfirmReward, r.ConfirmedReward
+	keep := big.NewInt(int64(100 - r.ReductionPercent))
+	for i := uint64(0); i < reductions; i++ {
+		mining = new(big.Int).Quo(new(big.Int).Mul(mining, keep), big100)
+		confirm = new(big.Int).Quo(new(big.Int).Mul(confirm, keep), big100)
+		confirmed = new(big.Int).Quo(new(big.Int).Mul(confirmed, keep), big100)
+	}
+	return mining, confirm, confirmed
+}
+
+// FeeRecipient returns the account the transaction fees of a block mined by
+// the coinbase are paid to, or nil if they are burnt.
+func (r *RewardSchedule) FeeRecipient(coinbase common.Address) *common.Address {
+	switch {
+	case r.FeeBurn:
+		return nil
+	case r.Treasury != nil:
+		return r.Treasury
+	default:
+		return &coinbase
+	}
+}
+
+var big100 = big.NewInt(100)
@@ -0,0 +1,75 @@
+package params
+
+import (
+	"math/big"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// legacyRewards returns the rewards AddBlockReward paid before the schedule
+// was part of the chain config.
+func legacyRewards(height uint64) (*big.Int, *big.Int, *big.Int) {
+	reductionTime := MaxMiningRewardReductionTimes
+	if height/MiningAwardCycle < reductionTime {
+		reductionTime = height / MiningAwardCycle
+	}
+
+	mining, confirm, confirmed := OriginalMiningRewardValue, OriginalConfirmRewardValue, OriginalConfirmedRewardValue
+	for i := 0; i < int(reductionTime); i++ {
+		mining = new(big.Int).Quo(new(big.Int).Mul(mining, big.NewInt(9)), big.NewInt(10))
+		confirm = new(big.Int).Quo(new(big.Int).Mul(confirm, big.NewInt(9)), big.NewInt(10))
+		confirmed = new(big.Int).Quo(new(big.Int).Mul(confirmed, big.NewInt(9)), big.NewInt(10))
+	}
+	return mining, confirm, confirmed
+}
+
+func TestDefaultRewardSchedule(t *testing.T) {
+	Convey("the default schedule pays the legacy rewards", t, func() {
+		So(DefaultRewardSchedule.Validate(), ShouldBeNil)
+
+		heights := []uint64{
+			0,
+			1,
+			MiningAwardCycle - 1,
+			MiningAwardCycle,
+			MiningAwardCycle + 1,
+			2*MiningAwardCycle - 1,
+			2 * MiningAwardCycle,
+			5*MiningAwardCycle + 4096,
+			MaxMiningRewardReductionTimes*MiningAwardCycle - 1,
+			MaxMiningRewardReductionTimes * MiningAwardCycle,
+			(MaxMiningRewardReductionTimes+1)*MiningAwardCycle - 1,
+			(MaxMiningRewardReductionTimes + 1) * MiningAwardCycle,
+			100 * MiningAwardCycle,
+			^uint64(0),
+		}
+		for _, height := range heights {
+			mining, confirm, confirmed := DefaultRewardSchedule.Rewards(height)
+			legacyMining, legacyConfirm, legacyConfirmed := legacyRewards(height)
+			So(mining.String(), ShouldEqual, legacyMining.String())
+			So(confirm.String(), ShouldEqual, legacyConfirm.String())
+			So(confirmed.String(), ShouldEqual, legacyConfirmed.String())
+		}
+	})
+
+	Convey("the rewards are reduced each cycle until the cap", t, func() {
+		tests := []struct {
+			height          uint64
+			mining, confirm int64
+		}{
+			{0, 5e9, 5e8},
+			{MiningAwardCycle - 1, 5e9, 5e8},
+			{MiningAwardCycle, 45e8, 45e7},
+			{2 * MiningAwardCycle, 405e7, 405e6},
+			{MaxMiningRewardReductionTimes * MiningAwardCycle, 1743392200, 174339219},
+			{^uint64(0), 1743392200, 174339219},
+		}
+		for _, test := range tests {
+			mining, confirm, confirmed := DefaultRewardSchedule.Rewards(test.height)
+			So(mining.Int64(), ShouldEqual, test.mining)
+			So(confirm.Int64(), ShouldEqual, test.confirm)
+			So(confirmed.Int64(), ShouldEqual, test.confirm)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package params
+
+// ForkSchedule is the heights the changes of the protocol activate at. A
+// change whose height is not set is never active, so that the upgraded nodes
+// compute the same state as the old ones until the change is scheduled.
+type ForkSchedule struct {
+	AbiRegistryHeight      *uint64 `json:"abiRegistryHeight,omitempty"`      // the native abi registry contract
+	MinerKeyEvidenceHeight *uint64 `json:"minerKeyEvidenceHeight,omitempty"` // the evidence action of the miner key contract
+}
+
+// NoForks is the fork schedule of the chains which don't set one.
+var NoForks = &ForkSchedule{}
+
+// GenesisForks returns the fork schedule activating every change at the
+// genesis, for the new chains.
+func GenesisForks() *ForkSchedule {
+	genesis := uint64(0)
+	return &ForkSchedule{
+		AbiRegistryHeight:      &genesis,
+		MinerKeyEvidenceHeight: &genesis,
+	}
+}
+
+func isForked(fork *uint64, height uint64) bool {
+	return fork != nil && height >= *fork
+}
+
+// IsAbiRegistry returns whether the abi registry is active at the height.
+func (f *ForkSchedule) IsAbiRegistry(height uint64) bool {
+	return isForked(f.AbiRegistryHeight, height)
+}
+
+// IsMinerKeyEvidence returns whether the evidence action of the miner key
+// contract is active at the height.
+func (f *ForkSchedule) IsMinerKeyEvidence(height uint64) bool {
+	return isForked(f.MinerKeyEvidenceHeight, height)
+}
@@ -4,10 +4,16 @@ const (
 	MinerKeyContractAddr            = "0x0000000000000000000000000000000000000001"
 	PackerKeyContractAddr           = "0x0000000000000000000000000000000000000002"
 	TransferRestrictionContractAddr = "0x0000000000000000000000000000000000000003"
+	AbiRegistryContractAddr         = "0x0000000000000000000000000000000000000004"
 
 	MinerKeyContractTable      = "minerkey"
+	MinerKeyEvidenceTable      = "evidence" // the evidences consumed by the miner key contract
 	PackerKeyContractInfoTable = "packerkey"
 	PackerKeyContractSizeTable = "packersize"
 	TransferWhiteListTable     = "whiteaddr"
 	TransferBlackListTable     = "blackaddr"
+	ContractAbiTable           = "abi" // the abis of the contracts in the storage of the abi registry
+
+	MinerKeyContractEvidenceAction = "evidence"
+	AbiRegistrySetAbiAction        = "setabi"
 )
@@ -11,83 +11,168 @@ import (
 	"github.com/fractal-platform/fractal/utils/log"
 )
 
+// RpcConnection is a client fetched from a RpcConnPool. It must be released
+// after use so that other callers can reuse it.
 type RpcConnection struct {
 	Client   *Client
+	pool     *RpcConnPool
 	idle     bool
-	timer    *time.Timer
-	lifetime int
+	removed  bool
+	lastUsed time.Time
 }
 
 func (c *RpcConnection) Release() {
-	c.timer.Reset(time.Second * time.Duration(c.lifetime))
+	c.pool.mutex.Lock()
+	defer c.pool.mutex.Unlock()
+
+	if c.removed {
+		c.Client.Close()
+		return
+	}
+	c.lastUsed = time.Now()
 	c.idle = true
 }
 
+// RpcConnPool keeps connections to rpc servers alive between calls. A
+// connection is closed after being idle for the lifetime in seconds.
 type RpcConnPool struct {
 	lifetime int
 	connMap  map[string][]*RpcConnection
+	removals uint64 // number of Remove and Close calls, to detect them during a dial
 	mutex    sync.Mutex
+	quit     chan struct{}
 }
 
 func NewRpcConnPool(lifetime int) *RpcConnPool {
 	pool := &RpcConnPool{
 		lifetime: lifetime,
 		connMap:  make(map[string][]*RpcConnection),
+		quit:     make(chan struct{}),
 	}
 	go pool.loop()
 	return pool
 }
 
 func (p *RpcConnPool) loop() {
+	ticker := time.NewTicker(time.Second * time.Duration(p.lifetime))
+	defer ticker.Stop()
+
 	for {
-		p.mutex.Lock()
-		for _, conns := range p.connMap {
-			for i, conn := range conns {
-				if conn.idle {
-					select {
-					case <-conn.timer.C:
-						conns = append(conns[:i], conns[i+1:]...)
-						break
-					default:
-						break
-					}
-				}
-			}
+		select {
+		case <-ticker.C:
+			p.expire(time.Now().Add(-time.Second * time.Duration(p.lifetime)))
+		case <-p.quit:
+			return
 		}
-		p.mutex.Unlock()
-
-		t := time.NewTimer(time.Second * 60)
-		<-t.C
 	}
 }
 
-func (p *RpcConnPool) FetchRpcConn(rpcAddr string) *RpcConnection {
+// expire closes the idle connections not used since the deadline.
+func (p *RpcConnPool) expire(deadline time.Time) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	conns, ok := p.connMap[rpcAddr]
-	if !ok {
-		conns = make([]*RpcConnection, 0)
+	for rpcAddr, conns := range p.connMap {
+		alive := conns[:0]
+		for _, conn := range conns {
+			if conn.idle && conn.lastUsed.Before(deadline) {
+				conn.Client.Close()
+				continue
+			}
+			alive = append(alive, conn)
+		}
+		if len(alive) == 0 {
+			delete(p.connMap, rpcAddr)
+		} else {
+			p.connMap[rpcAddr] = alive
+		}
 	}
+}
 
-	for _, conn := range conns {
-		if conn.idle {
-			conn.idle = false
-			return conn
-		}
+// FetchRpcConn returns an idle connection to the rpc server, or dials a new
+// one if all of them are in use. The dial is done without the lock, so that
+// the other servers are not blocked by a slow one.
+func (p *RpcConnPool) FetchRpcConn(rpcAddr string) (*RpcConnection, error) {
+	p.mutex.Lock()
+	if conn := p.idleConnUnsafe(rpcAddr); conn != nil {
+		p.mutex.Unlock()
+		return conn, nil
 	}
+	removals := p.removals
+	p.mutex.Unlock()
 
 	client, err := Dial(rpcAddr)
 	if err != nil {
 		log.Error("connect to rpc error", "rpc", rpcAddr, "err", err)
+		return nil, err
 	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	conn := &RpcConnection{
 		Client:   client,
+		pool:     p,
 		idle:     false,
-		timer:    time.NewTimer(time.Second * time.Duration(p.lifetime)),
-		lifetime: p.lifetime,
+		lastUsed: time.Now(),
+	}
+	if p.removals != removals {
+		// the server may be removed during the dial, the connection is only
+		// used by this caller then
+		conn.removed = true
+		return conn, nil
+	}
+	if idle := p.idleConnUnsafe(rpcAddr); idle != nil {
+		// a connection released during the dial is reused instead
+		client.Close()
+		return idle, nil
+	}
+	p.connMap[rpcAddr] = append(p.connMap[rpcAddr], conn)
+	return conn, nil
+}
+
+// idleConnUnsafe returns an idle connection to the rpc server marked in use,
+// or nil if there is none.
+func (p *RpcConnPool) idleConnUnsafe(rpcAddr string) *RpcConnection {
+	for _, conn := range p.connMap[rpcAddr] {
+		if conn.idle {
+			conn.idle = false
+			return conn
+		}
+	}
+	return nil
+}
+
+// Remove drops the connections to the rpc server, so that the next fetch
+// dials it again. Connections in use are closed when released.
+func (p *RpcConnPool) Remove(rpcAddr string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.removals++
+	for _, conn := range p.connMap[rpcAddr] {
+		conn.removed = true
+		if conn.idle {
+			conn.Client.Close()
+		}
+	}
+	delete(p.connMap, rpcAddr)
+}
+
+// Close stops the pool and closes all connections.
+func (p *RpcConnPool) Close() {
+	close(p.quit)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.removals++
+	for _, conns := range p.connMap {
+		for _, conn := range conns {
+			conn.removed = true
+			if conn.idle {
+				conn.Client.Close()
+			}
+		}
 	}
-	conns = append(conns, conn)
-	p.connMap[rpcAddr] = conns
-	return conn
+	p.connMap = make(map[string][]*RpcConnection)
 }
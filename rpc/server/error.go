@@ -16,6 +16,12 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is an Error that carries additional data for the client.
+type DataError interface {
+	Error
+	ErrorData() interface{} // returns the error data
+}
+
 // request is for an unknown service
 type methodNotFoundError struct {
 	service string
@@ -197,7 +197,11 @@ func (c *jsonCodec) CreateResponse(id interface{}, reply interface{}) interface{
 
 // CreateErrorResponse will create a JSON-RPC error response with the given id and error.
 func (c *jsonCodec) CreateErrorResponse(id interface{}, err Error) interface{} {
-	return &rpc.JsonErrResponse{Version: rpc.JsonrpcVersion, Id: id, Error: rpc.JsonError{Code: err.ErrorCode(), Message: err.Error()}}
+	jsonErr := rpc.JsonError{Code: err.ErrorCode(), Message: err.Error()}
+	if de, ok := err.(DataError); ok {
+		jsonErr.Data = de.ErrorData()
+	}
+	return &rpc.JsonErrResponse{Version: rpc.JsonrpcVersion, Id: id, Error: jsonErr}
 }
 
 // CreateNotification will create a JSON-RPC notification with the given subscription id and event as params.
@@ -7,6 +7,7 @@ package rpcserver
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
@@ -81,6 +82,12 @@ func (srv *Server) ListenAndServe() {
 	srv.httpServer.ListenAndServe()
 }
 
+// Serve starts the request handler loop on the listener, instead of the
+// address of the server.
+func (srv *Server) Serve(l net.Listener) error {
+	return srv.httpServer.Serve(l)
+}
+
 // reqHandler encapsulate the handler for both rpc & websocket
 type reqHandler struct {
 	rpcHandler *rpcHandler
@@ -0,0 +1,137 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package testnet
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fractal-platform/fractal/common/mclock"
+	"github.com/fractal-platform/fractal/params"
+)
+
+// roundDuration is the duration of a mining round.
+const roundDuration = time.Second / params.RoundsPerSecond
+
+// SimClock is a simulated clock the nodes of a network mine on, the messages
+// between them are delayed on, and the timeouts of their synchronizers are on.
+// It only moves when it is advanced.
+type SimClock struct {
+	mu      sync.RWMutex
+	now     time.Time
+	changed chan struct{} // closed when the clock is advanced
+	timers  []*simTimer
+}
+
+type simTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewSimClock creates a simulated clock at the start time.
+func NewSimClock(start time.Time) *SimClock {
+	return &SimClock{
+		now:     start,
+		changed: make(chan struct{}),
+	}
+}
+
+// Now implements miner.Clock.
+func (c *SimClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.now
+}
+
+// Round returns the mining round of the current time.
+func (c *SimClock) Round() uint64 {
+	return uint64(c.Now().UnixNano() / int64(roundDuration))
+}
+
+// Advance moves the clock forward by the duration.
+func (c *SimClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	close(c.changed)
+	c.changed = make(chan struct{})
+
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			timers = append(timers, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = timers
+}
+
+// After returns a channel which receives the time once the clock is advanced
+// by the duration.
+func (c *SimClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, &simTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Mono returns the clock as a mclock.Clock.
+func (c *SimClock) Mono() mclock.Clock {
+	return simMonoClock{c}
+}
+
+// wait returns a channel which is closed when the clock is advanced next.
+func (c *SimClock) wait() <-chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.changed
+}
+
+// simMonoClock implements mclock.Clock on a simulated clock.
+type simMonoClock struct {
+	clock *SimClock
+}
+
+// Now implements mclock.Clock.
+func (m simMonoClock) Now() mclock.AbsTime {
+	return mclock.AbsTime(m.clock.Now().UnixNano())
+}
+
+// Sleep implements mclock.Clock.
+func (m simMonoClock) Sleep(d time.Duration) {
+	<-m.clock.After(d)
+}
+
+// After implements mclock.Clock.
+func (m simMonoClock) After(d time.Duration) <-chan time.Time {
+	return m.clock.After(d)
+}
+
+// nodeClock is the view of a node on the simulated clock, which counts the
+// times the miner of the node looks at it.
+type nodeClock struct {
+	*SimClock
+	reads int64
+}
+
+// Now implements miner.Clock.
+func (c *nodeClock) Now() time.Time {
+	atomic.AddInt64(&c.reads, 1)
+	return c.SimClock.Now()
+}
+
+func (c *nodeClock) readCount() int64 {
+	return atomic.LoadInt64(&c.reads)
+}
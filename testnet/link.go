@@ -0,0 +1,174 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package testnet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fractal-platform/fractal/p2p"
+)
+
+type linkMsg struct {
+	code      uint64
+	data      []byte
+	deliverAt time.Time
+}
+
+// msgQueue holds the messages sent in one direction of a link.
+type msgQueue struct {
+	mu     sync.Mutex
+	msgs   []*linkMsg
+	notify chan struct{}
+}
+
+func newMsgQueue() *msgQueue {
+	return &msgQueue{notify: make(chan struct{}, 1)}
+}
+
+func (q *msgQueue) push(msg *linkMsg) {
+	q.mu.Lock()
+	q.msgs = append(q.msgs, msg)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// deliverable returns whether the first message is delivered at the time.
+func (q *msgQueue) deliverable(now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.msgs) > 0 && !now.Before(q.msgs[0].deliverAt)
+}
+
+// pop returns the first message if it is delivered at the time.
+func (q *msgQueue) pop(now time.Time) *linkMsg {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.msgs) == 0 || now.Before(q.msgs[0].deliverAt) {
+		return nil
+	}
+	msg := q.msgs[0]
+	q.msgs = q.msgs[1:]
+	return msg
+}
+
+// link is a simulated connection between two nodes. The messages written to
+// one end are read from the other end once the latency has passed on the
+// simulated clock, in the order they are written.
+type link struct {
+	clock   *SimClock
+	latency time.Duration
+	queues  [2]*msgQueue
+	ends    [2]*linkEnd
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+func newLink(clock *SimClock, latency time.Duration) *link {
+	l := &link{
+		clock:   clock,
+		latency: latency,
+		queues:  [2]*msgQueue{newMsgQueue(), newMsgQueue()},
+		closing: make(chan struct{}),
+	}
+	for i := range l.ends {
+		l.ends[i] = &linkEnd{link: l, in: l.queues[i], out: l.queues[1-i]}
+	}
+	return l
+}
+
+// end returns the end of the link with the index 0 or 1.
+func (l *link) end(i int) *linkEnd {
+	return l.ends[i]
+}
+
+// idle returns whether the messages delivered on the link are read, and both
+// ends wait for the next ones. A node has handled a message when it reads the
+// next one.
+func (l *link) idle() bool {
+	if l.closed() {
+		return true
+	}
+	now := l.clock.Now()
+	for _, e := range l.ends {
+		if atomic.LoadInt32(&e.waiting) == 0 || e.in.deliverable(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close closes both ends of the link.
+func (l *link) Close() error {
+	l.closeOnce.Do(func() { close(l.closing) })
+	return nil
+}
+
+func (l *link) closed() bool {
+	select {
+	case <-l.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// linkEnd implements p2p.MsgReadWriter on one end of a link.
+type linkEnd struct {
+	link    *link
+	in      *msgQueue
+	out     *msgQueue
+	waiting int32 // 1 while ReadMsg waits for a message
+}
+
+func (e *linkEnd) WriteMsg(msg p2p.Msg) error {
+	if e.link.closed() {
+		return p2p.ErrPipeClosed
+	}
+	data, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	e.out.push(&linkMsg{
+		code:      msg.Code,
+		data:      data,
+		deliverAt: e.link.clock.Now().Add(e.link.latency),
+	})
+	return nil
+}
+
+func (e *linkEnd) ReadMsg() (p2p.Msg, error) {
+	for {
+		if e.link.closed() {
+			return p2p.Msg{}, p2p.ErrPipeClosed
+		}
+		advanced := e.link.clock.wait()
+		if msg := e.in.pop(e.link.clock.Now()); msg != nil {
+			return p2p.Msg{
+				Code:       msg.code,
+				Size:       uint32(len(msg.data)),
+				Payload:    bytes.NewReader(msg.data),
+				ReceivedAt: time.Now(),
+			}, nil
+		}
+		atomic.StoreInt32(&e.waiting, 1)
+		select {
+		case <-e.in.notify:
+		case <-advanced:
+		case <-e.link.closing:
+			return p2p.Msg{}, p2p.ErrPipeClosed
+		}
+		atomic.StoreInt32(&e.waiting, 0)
+	}
+}
@@ -0,0 +1,515 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package testnet runs a network of full Fractal nodes in one process, on a
+// simulated clock and a simulated p2p transport, for tests of the consensus
+// and the synchronization between the nodes.
+//
+// The miners seal the rounds of the simulated clock, the messages between the
+// nodes are delayed by the latency on the simulated clock, and the timeouts of
+// the synchronizers are on the simulated clock, so the rounds of the mined
+// blocks and the syncs don't depend on the speed of the machine. At each
+// round, Run waits until the miners have looked at the clock, the syncing
+// nodes have caught up and the nodes have read all the messages delivered to
+// them, for at most the round wait. The request timeouts of the block and
+// state fetchers are still on the wall clock.
+//
+// The check point authorities serve the check point api on a loopback port,
+// which the other nodes query when they start.
+//
+// The nodes share the process-wide bloom list of the logbloom package.
+package testnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/p2p"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+const (
+	// DefaultStake is the genesis balance of the account of each node.
+	DefaultStake = uint64(1e18)
+
+	// DefaultRoundWait is the longest wall clock time the nodes are given to
+	// handle a round.
+	DefaultRoundWait = 500 * time.Millisecond
+
+	// settlePoll is the interval Run checks whether the nodes have handled a
+	// round at, and settlePolls the number of checks in a row they must pass.
+	settlePoll  = time.Millisecond
+	settlePolls = 5
+)
+
+var (
+	ErrNoNodes        = errors.New("no nodes in the network")
+	ErrNodeNotFound   = errors.New("node not found")
+	ErrNodeNotRunning = errors.New("node not running")
+	ErrNodeRunning    = errors.New("node already running")
+	ErrPartitioned    = errors.New("nodes are in different partitions")
+)
+
+// Config is the configuration of a simulated network.
+type Config struct {
+	ChainConfig *config.ChainConfig // defaults to the test net chain config
+	Alloc       config.GenesisAlloc // accounts added to the genesis
+	Stake       uint64              // genesis balance of the account of each node
+	Difficulty  *big.Int            // genesis difficulty, defaults to the total stake of the miners
+
+	Latency   time.Duration // message latency on the simulated clock
+	RoundWait time.Duration // longest wall clock time the nodes are given to handle a round
+
+	Nodes []NodeConfig
+}
+
+// Network is a network of full nodes in one process.
+type Network struct {
+	cfg   Config
+	clock *SimClock
+	nodes []*Node
+
+	lock   sync.Mutex
+	links  map[[2]int]*link
+	wanted map[[2]int]struct{} // the connections kept up by Run
+	groups map[int]int         // partition of the nodes, nil if not partitioned
+}
+
+// New creates the keys and the genesis of the nodes of the network, the nodes
+// are created and started by Start.
+func New(cfg Config) (*Network, error) {
+	if len(cfg.Nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+	if cfg.Stake == 0 {
+		cfg.Stake = DefaultStake
+	}
+	if cfg.RoundWait == 0 {
+		cfg.RoundWait = DefaultRoundWait
+	}
+	chainConfig := *config.TestnetChainConfig
+	if cfg.ChainConfig != nil {
+		chainConfig = *cfg.ChainConfig
+	}
+
+	gk := &genesisKeys{
+		alloc:      make(config.GenesisAlloc),
+		minerKeys:  make(state.Storage),
+		packerKeys: make(state.Storage),
+	}
+	n := &Network{
+		cfg:    cfg,
+		links:  make(map[[2]int]*link),
+		wanted: make(map[[2]int]struct{}),
+	}
+	miners := uint64(0)
+	for i, nc := range cfg.Nodes {
+		node, err := newNode(i, nc, gk)
+		if err != nil {
+			n.removeData()
+			return nil, err
+		}
+		n.nodes = append(n.nodes, node)
+		gk.alloc[node.Address] = config.GenesisAccount{Balance: new(big.Int).SetUint64(cfg.Stake)}
+		if nc.Miner {
+			miners++
+		}
+	}
+
+	// register the keys of the miners, the packers and the check point authorities
+	for addr, account := range cfg.Alloc {
+		gk.alloc[addr] = account
+	}
+	gk.alloc[common.HexToAddress(params.MinerKeyContractAddr)] = config.GenesisAccount{
+		Balance: new(big.Int),
+		Owner:   common.HexToAddress(params.MinerKeyContractAddr),
+		Storage: gk.minerKeys,
+	}
+	if gk.packers > 0 {
+		table, _ := utils.String2Uint64(params.PackerKeyContractSizeTable)
+		var value = make([]byte, 5)
+		binary.LittleEndian.PutUint32(value[1:5], gk.packers)
+		gk.packerKeys[state.GetStorageKey(table, []byte{0})] = value
+		gk.alloc[common.HexToAddress(params.PackerKeyContractAddr)] = config.GenesisAccount{
+			Balance: new(big.Int),
+			Storage: gk.packerKeys,
+		}
+	}
+	if len(gk.authorities) > 0 {
+		chainConfig.CheckPointEnable = true
+		chainConfig.CheckPointAuthorities = gk.authorities
+		if chainConfig.CheckPointThreshold == 0 {
+			chainConfig.CheckPointThreshold = uint64(len(gk.authorities))
+		}
+	}
+
+	difficulty := cfg.Difficulty
+	if difficulty == nil {
+		if miners == 0 {
+			miners = 1
+		}
+		difficulty = new(big.Int).Mul(new(big.Int).SetUint64(cfg.Stake), new(big.Int).SetUint64(miners))
+	}
+	genesis := config.DefaultTestnetGenesisBlock()
+	genesis.Difficulty = difficulty
+	genesis.Alloc = gk.alloc
+
+	// start the clock at the round after the genesis
+	n.clock = NewSimClock(time.Unix(0, int64(genesis.Round+1)*int64(roundDuration)))
+
+	for _, node := range n.nodes {
+		nodeChainConfig := chainConfig
+		node.cfg.ChainConfig = &nodeChainConfig
+		node.cfg.Genesis = genesis
+		if len(gk.checkPointRPCs) > 0 {
+			node.cfg.CheckPointRPCs = gk.checkPointRPCs
+		}
+	}
+	return n, nil
+}
+
+// Clock returns the simulated clock of the network.
+func (n *Network) Clock() *SimClock { return n.clock }
+
+// Nodes returns all the nodes of the network.
+func (n *Network) Nodes() []*Node { return n.nodes }
+
+// Node returns the node at the index.
+func (n *Network) Node(i int) *Node { return n.nodes[i] }
+
+// Start starts the nodes which are not offline, and connects every two of
+// them. The check point authorities are started first, for the other nodes
+// get the signed check point from them.
+func (n *Network) Start() error {
+	for _, authorities := range []bool{true, false} {
+		for _, node := range n.nodes {
+			if node.Config.Offline || node.Config.CheckPoint != authorities {
+				continue
+			}
+			if err := n.StartNode(node.Index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// StartNode creates the node on its data folder and starts it, and connects
+// it to all the running nodes. A stopped node is started again on the chain
+// it has stored.
+func (n *Network) StartNode(i int) error {
+	node, err := n.node(i)
+	if err != nil {
+		return err
+	}
+	if node.running {
+		return ErrNodeRunning
+	}
+	if err := node.create(n.clock); err != nil {
+		return err
+	}
+	if err := node.startCheckPointRPC(); err != nil {
+		node.Stop()
+		node.Fractal = nil
+		return err
+	}
+
+	node.StartServices(len(n.nodes))
+	node.running = true
+	log.Info("Testnet node started", "node", node.Index, "id", node.id)
+
+	for _, other := range n.nodes {
+		if other != node && other.running {
+			if err := n.Connect(node.Index, other.Index); err != nil && err != ErrPartitioned {
+				return err
+			}
+		}
+	}
+	if node.Config.Miner {
+		return node.StartMining()
+	}
+	return nil
+}
+
+// StopNode disconnects the node, and stops it.
+func (n *Network) StopNode(i int) error {
+	node, err := n.node(i)
+	if err != nil {
+		return err
+	}
+	if !node.running {
+		return ErrNodeNotRunning
+	}
+
+	n.lock.Lock()
+	for pair, l := range n.links {
+		if pair[0] == i || pair[1] == i {
+			l.Close()
+			delete(n.links, pair)
+			delete(n.wanted, pair)
+		}
+	}
+	n.lock.Unlock()
+
+	node.running = false
+	node.stopCheckPointRPC()
+	err = node.Stop()
+	node.Fractal = nil
+	log.Info("Testnet node stopped", "node", node.Index)
+	return err
+}
+
+// Stop stops all the nodes, and removes their data.
+func (n *Network) Stop() {
+	for _, node := range n.nodes {
+		if node.running {
+			n.StopNode(node.Index)
+		}
+	}
+	n.removeData()
+}
+
+// Connect connects the two running nodes, and keeps them connected.
+func (n *Network) Connect(i, j int) error {
+	a, err := n.node(i)
+	if err != nil {
+		return err
+	}
+	b, err := n.node(j)
+	if err != nil {
+		return err
+	}
+	if !a.running || !b.running {
+		return ErrNodeNotRunning
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	pair := linkPair(i, j)
+	n.wanted[pair] = struct{}{}
+	if n.groups != nil && n.groups[i] != n.groups[j] {
+		return ErrPartitioned
+	}
+	n.connect(a, b)
+	return nil
+}
+
+// Disconnect disconnects the two nodes.
+func (n *Network) Disconnect(i, j int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	pair := linkPair(i, j)
+	delete(n.wanted, pair)
+	if l, ok := n.links[pair]; ok {
+		l.Close()
+		delete(n.links, pair)
+	}
+}
+
+// Partition splits the nodes into the groups, and cuts the connections
+// between the groups until Heal is called. The nodes in no group are put in
+// a group together.
+func (n *Network) Partition(groups ...[]int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.groups = make(map[int]int)
+	for g, group := range groups {
+		for _, i := range group {
+			n.groups[i] = g + 1
+		}
+	}
+	for pair, l := range n.links {
+		if n.groups[pair[0]] != n.groups[pair[1]] {
+			l.Close()
+			delete(n.links, pair)
+		}
+	}
+	log.Info("Testnet partitioned", "groups", groups)
+}
+
+// Heal removes the partition, and connects the nodes cut by it again.
+func (n *Network) Heal() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.groups = nil
+	n.reconnect()
+	log.Info("Testnet healed")
+}
+
+// Run advances the clock by the rounds, and waits for the nodes to handle
+// each of them. The connections broken by the nodes are made again.
+func (n *Network) Run(rounds int) {
+	for r := 0; r < rounds; r++ {
+		n.lock.Lock()
+		n.reconnect()
+		n.lock.Unlock()
+
+		reads := n.clockReads()
+		n.clock.Advance(roundDuration)
+		n.settle(reads)
+	}
+}
+
+// clockReads returns the number of times the miner of each mining node has
+// looked at the clock.
+func (n *Network) clockReads() map[int]int64 {
+	reads := make(map[int]int64)
+	for _, node := range n.nodes {
+		if node.running && node.IsMining() {
+			reads[node.Index] = node.clock.readCount()
+		}
+	}
+	return reads
+}
+
+// settle waits until the miners have looked at the clock since the reads, no
+// node is in a peer sync or a fast sync, and the nodes have read all the
+// messages delivered to them, for settlePolls checks in a row. It gives up
+// after the round wait.
+func (n *Network) settle(reads map[int]int64) {
+	deadline := time.Now().Add(n.cfg.RoundWait)
+	for passed := 0; passed < settlePolls && time.Now().Before(deadline); {
+		time.Sleep(settlePoll)
+		if n.handled(reads) {
+			passed++
+		} else {
+			passed = 0
+		}
+	}
+}
+
+func (n *Network) handled(reads map[int]int64) bool {
+	for i, count := range reads {
+		if node := n.nodes[i]; node.running && node.clock.readCount() == count {
+			return false
+		}
+	}
+	for _, node := range n.nodes {
+		if node.running && node.syncing() {
+			return false
+		}
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, l := range n.links {
+		if !l.idle() {
+			return false
+		}
+	}
+	return true
+}
+
+// RunUntil runs the network round by round until the condition is met, and
+// returns false if it is not met after maxRounds rounds.
+func (n *Network) RunUntil(maxRounds int, cond func() bool) bool {
+	for r := 0; r < maxRounds; r++ {
+		if cond() {
+			return true
+		}
+		n.Run(1)
+	}
+	return cond()
+}
+
+// Converged returns whether all the running nodes have the same head.
+func (n *Network) Converged() bool {
+	var head common.Hash
+	first := true
+	for _, node := range n.nodes {
+		if !node.running {
+			continue
+		}
+		hash := node.BlockChain().CurrentBlock().FullHash()
+		if first {
+			head, first = hash, false
+		} else if hash != head {
+			return false
+		}
+	}
+	return true
+}
+
+// Heights returns the head heights of the nodes, zero for the nodes not
+// running.
+func (n *Network) Heights() []uint64 {
+	heights := make([]uint64, len(n.nodes))
+	for i, node := range n.nodes {
+		if node.running {
+			heights[i] = node.BlockChain().CurrentBlock().Header.Height
+		}
+	}
+	return heights
+}
+
+func (n *Network) node(i int) (*Node, error) {
+	if i < 0 || i >= len(n.nodes) {
+		return nil, ErrNodeNotFound
+	}
+	return n.nodes[i], nil
+}
+
+// connect runs the protocols of the two nodes on a new link between them,
+// the lock must be held.
+func (n *Network) connect(a, b *Node) {
+	pair := linkPair(a.Index, b.Index)
+	if l, ok := n.links[pair]; ok && !l.closed() {
+		return
+	}
+
+	l := newLink(n.clock, n.cfg.Latency)
+	n.links[pair] = l
+	run := func(local *Node, remote *Node, rw p2p.MsgReadWriter) {
+		proto := local.Protocols()[0]
+		peer := p2p.NewPeerPipe(remote.id, remote.name, []p2p.Cap{{Name: proto.Name, Version: proto.Version}}, l)
+		err := proto.Run(peer, rw)
+		log.Debug("Testnet link closed", "local", local.Index, "remote", remote.Index, "err", err)
+		l.Close()
+	}
+	go run(a, b, l.end(0))
+	go run(b, a, l.end(1))
+}
+
+// reconnect connects the wanted pairs of running nodes which are not
+// connected, the lock must be held.
+func (n *Network) reconnect() {
+	for pair := range n.wanted {
+		a, b := n.nodes[pair[0]], n.nodes[pair[1]]
+		if !a.running || !b.running {
+			continue
+		}
+		if n.groups != nil && n.groups[pair[0]] != n.groups[pair[1]] {
+			continue
+		}
+		n.connect(a, b)
+	}
+}
+
+func (n *Network) removeData() {
+	for _, node := range n.nodes {
+		node.stopCheckPointRPC()
+		if err := os.RemoveAll(node.dataDir); err != nil {
+			log.Warn("Remove testnet node data failed", "node", node.Index, "err", err)
+		}
+	}
+}
+
+func linkPair(i, j int) [2]int {
+	if i > j {
+		i, j = j, i
+	}
+	return [2]int{i, j}
+}
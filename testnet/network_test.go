@@ -0,0 +1,81 @@
+package testnet
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func startTestNetwork(cfg Config) *Network {
+	n, err := New(cfg)
+	So(err, ShouldBeNil)
+	Reset(n.Stop)
+	So(n.Start(), ShouldBeNil)
+	return n
+}
+
+func minHeight(n *Network) uint64 {
+	var min uint64
+	for i, height := range n.Heights() {
+		if n.Node(i).Running() && (min == 0 || height < min) {
+			min = height
+		}
+	}
+	return min
+}
+
+func TestNetworkConverge(t *testing.T) {
+	Convey("the miners converge on one chain", t, func() {
+		n := startTestNetwork(Config{Nodes: []NodeConfig{{Miner: true}, {Miner: true}, {Miner: true}}})
+
+		So(n.RunUntil(200, func() bool { return minHeight(n) >= 20 && n.Converged() }), ShouldBeTrue)
+
+		Convey("and again after a partition is healed", func() {
+			n.Partition([]int{0, 1}, []int{2})
+			// each side must have a short hash list for the peer sync
+			height := n.Heights()[0]
+			So(n.RunUntil(500, func() bool { return minHeight(n) >= height+60 }), ShouldBeTrue)
+			So(n.Converged(), ShouldBeFalse)
+
+			n.Heal()
+			So(n.RunUntil(300, n.Converged), ShouldBeTrue)
+		})
+	})
+}
+
+func TestNetworkSync(t *testing.T) {
+	Convey("a node started late syncs the chain of the miners", t, func() {
+		n := startTestNetwork(Config{Nodes: []NodeConfig{{Miner: true}, {Miner: true}, {Offline: true}}})
+
+		// the miners must have a short hash list for the fast sync
+		So(n.RunUntil(400, func() bool { return minHeight(n) >= 60 }), ShouldBeTrue)
+		head := n.Node(0).BlockChain().CurrentBlock()
+
+		So(n.StartNode(2), ShouldBeNil)
+		So(n.RunUntil(1000, func() bool { return n.Converged() && n.Heights()[2] > head.Header.Height }), ShouldBeTrue)
+		So(n.Node(2).BlockChain().GetBlock(head.FullHash()), ShouldNotBeNil)
+	})
+}
+
+func TestNetworkCheckPoint(t *testing.T) {
+	Convey("the nodes accept the check points signed by the authority", t, func() {
+		n := startTestNetwork(Config{Nodes: []NodeConfig{{Miner: true, CheckPoint: true}, {Miner: true}, {Miner: true}}})
+
+		So(n.RunUntil(1500, func() bool {
+			for _, node := range n.Nodes() {
+				signed := node.BlockChain().GetLatestSignedCheckPoint()
+				if signed == nil || signed.CheckPoint.Height == 0 || node.BlockChain().GetCheckPoint().Height == 0 {
+					return false
+				}
+			}
+			return true
+		}), ShouldBeTrue)
+
+		// the normal nodes have checked their check point with the authority
+		checkPoint := n.Node(0).BlockChain().GetCheckPoint()
+		for _, node := range n.Nodes()[1:] {
+			So(node.BlockChain().GetLatestSignedCheckPoint().CheckPoint.Hash(), ShouldEqual, n.Node(0).BlockChain().GetLatestSignedCheckPoint().CheckPoint.Hash())
+			So(node.BlockChain().GetCheckPoint().Hash(), ShouldEqual, checkPoint.Hash())
+		}
+	})
+}
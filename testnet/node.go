@@ -0,0 +1,243 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package testnet
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/ftl"
+	"github.com/fractal-platform/fractal/ftl/api"
+	"github.com/fractal-platform/fractal/keys"
+	"github.com/fractal-platform/fractal/p2p"
+	"github.com/fractal-platform/fractal/p2p/discover"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/rpc/server"
+	"github.com/fractal-platform/fractal/utils"
+)
+
+const (
+	keyPass = "testnet"
+
+	// packerRPCAddress is the rpc address of the packers in the genesis, no
+	// rpc server is started for the packers of a simulated network.
+	packerRPCAddress = "http://127.0.0.1:1"
+)
+
+// NodeConfig is the role of a node in a simulated network.
+type NodeConfig struct {
+	Miner      bool // mines with the stake of its account
+	Packer     bool // packs the transactions inserted into its packer
+	CheckPoint bool // signs the check points as a check point authority
+	Offline    bool // not started with the network, see StartNode
+
+	SyncConfig *config.SyncConfig // defaults to config.DefaultSyncConfig
+}
+
+// Node is a full node of a simulated network.
+type Node struct {
+	*ftl.Fractal
+
+	Index   int
+	Config  NodeConfig
+	Key     *ecdsa.PrivateKey // key of the account funded in the genesis
+	Address common.Address
+
+	id      discover.NodeID
+	name    string
+	dataDir string
+	cfg     *config.Config
+	clock   *nodeClock
+	running bool // the Fractal object is created and started
+
+	// check point rpc server of a check point authority, which the other
+	// nodes query the signed check point from when they start
+	checkPointAddr     string
+	checkPointListener net.Listener
+	checkPointServer   *rpcserver.Server
+}
+
+// ID returns the p2p node id of the node.
+func (n *Node) ID() discover.NodeID { return n.id }
+
+// Running returns whether the node is started.
+func (n *Node) Running() bool { return n.running }
+
+// genesisKeys collects the keys of the nodes registered in the genesis.
+type genesisKeys struct {
+	alloc       config.GenesisAlloc
+	minerKeys   state.Storage
+	packerKeys  state.Storage
+	packers     uint32
+	authorities []string
+
+	checkPointRPCs []string
+}
+
+// newNode creates the data folder and the keys of the node, and registers
+// them in the genesis.
+func newNode(index int, nc NodeConfig, gk *genesisKeys) (*Node, error) {
+	dataDir, err := ioutil.TempDir("", fmt.Sprintf("testnet-node%d-", index))
+	if err != nil {
+		return nil, err
+	}
+	keyDir := filepath.Join(dataDir, "keys")
+	if err := os.MkdirAll(keyDir, 0755); err != nil {
+		return nil, err
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	nodeKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{
+		Index:   index,
+		Config:  nc,
+		Key:     key,
+		Address: crypto.PubkeyToAddress(key.PublicKey),
+		id:      discover.PubkeyID(&nodeKey.PublicKey),
+		name:    fmt.Sprintf("testnet-node%d", index),
+		dataDir: dataDir,
+	}
+
+	syncConfig := config.DefaultSyncConfig
+	if nc.SyncConfig != nil {
+		syncConfig = *nc.SyncConfig
+	}
+	txPoolConfig, pkgPoolConfig := config.DefaultPoolConfig, config.DefaultPoolConfig
+
+	cfg := config.DefaultConfig
+	cfg.NodeConfig = &config.NodeConfig{
+		DataDir: dataDir,
+		P2P:     p2p.Config{PrivateKey: nodeKey, Name: node.name},
+	}
+	cfg.DatabaseCache = 16
+	cfg.DatabaseHandles = 16
+	cfg.SyncConfig = &syncConfig
+	cfg.TxPoolConfig = &txPoolConfig
+	cfg.PkgPoolConfig = &pkgPoolConfig
+	cfg.KeyPass = keyPass
+	cfg.CheckPointPriKeyPass = keyPass
+	cfg.CheckPointRPCs = []string{packerRPCAddress}
+	cfg.MinerKeyFolder = filepath.Join(keyDir, "mining")
+	cfg.PackerKeyFolder = filepath.Join(keyDir, "packer")
+	node.cfg = &cfg
+
+	if nc.Miner {
+		pubkey := keys.PublicKeyForMining(keys.NewMiningKeyManager(cfg.MinerKeyFolder, keyPass).CreateKey(node.Address))
+
+		table, _ := utils.String2Uint64(params.MinerKeyContractTable)
+		var value = make([]byte, 22+crypto.BlsPubkeyLen) // 20 + 2 + BlsPubkeyLen
+		copy(value[:20], node.Address[:])
+		value[20] = 0x80 // two bytes for len
+		value[21] = 1    // two bytes for len
+		copy(value[22:], pubkey[:])
+		gk.minerKeys[state.GetStorageKey(table, node.Address[:])] = value
+	}
+	if nc.Packer {
+		pubkey := keys.PublicKeyForPacker(keys.NewPackerKeyManager(cfg.PackerKeyFolder, keyPass).CreateKey(node.Address))
+
+		table, _ := utils.String2Uint64(params.PackerKeyContractInfoTable)
+		indexByte := make([]byte, 4)
+		binary.LittleEndian.PutUint32(indexByte, gk.packers)
+		var value = make([]byte, 87) // 20 + 1 + 65(PackerECPubKey) + 1
+		copy(value[:20], node.Address[:])
+		value[20] = 65
+		copy(value[21:86], pubkey[:])
+		value[86] = byte(len(packerRPCAddress))
+		value = append(value, []byte(packerRPCAddress)...)
+		gk.packerKeys[state.GetStorageKey(table, indexByte)] = value
+
+		cfg.PackerEnable = true
+		cfg.PackerId = gk.packers
+		gk.packers++
+	}
+	if nc.CheckPoint {
+		priKey := keys.CreateCheckPointKey(filepath.Join(keyDir, "check_point_key.json"), keyPass)
+		if priKey == nil {
+			return nil, fmt.Errorf("create check point key of node %d failed", index)
+		}
+		gk.authorities = append(gk.authorities, hexutil.Encode(priKey.Public().Marshal()))
+
+		// the port is kept for the node across restarts
+		node.checkPointListener, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		node.checkPointAddr = node.checkPointListener.Addr().String()
+		gk.checkPointRPCs = append(gk.checkPointRPCs, "http://"+node.checkPointAddr)
+	}
+	return node, nil
+}
+
+// create creates the Fractal object of the node on its data folder, whose
+// miner and synchronizer are on the simulated clock.
+func (n *Node) create(clock *SimClock) error {
+	n.clock = &nodeClock{SimClock: clock}
+	f, err := ftl.NewFtlWithClock(n.cfg, n.clock)
+	if err != nil {
+		return err
+	}
+	f.Synchronizer().SetClock(clock.Mono())
+	n.Fractal = f
+	return nil
+}
+
+// syncing returns whether the node is in a peer sync or a fast sync.
+func (n *Node) syncing() bool {
+	s := n.Synchronizer()
+	return !s.IsSyncStatusNormal() && !s.IsSyncStatusInit()
+}
+
+// startCheckPointRPC serves the check point api of a check point authority.
+func (n *Node) startCheckPointRPC() error {
+	if !n.Config.CheckPoint {
+		return nil
+	}
+	priKey, err := keys.LoadCheckPointKey(filepath.Join(n.dataDir, "keys", "check_point_key.json"), keyPass)
+	if err != nil {
+		return err
+	}
+
+	l := n.checkPointListener
+	n.checkPointListener = nil
+	if l == nil {
+		if l, err = net.Listen("tcp", n.checkPointAddr); err != nil {
+			return err
+		}
+	}
+	n.checkPointServer = rpcserver.NewServer(nil, "")
+	n.checkPointServer.RegisterApis([]rpcserver.RpcApi{{
+		Namespace: "ftl",
+		Version:   "1.0",
+		Service:   api.NewCheckPointAPI(n.Fractal, priKey),
+	}})
+	go n.checkPointServer.Serve(l)
+	return nil
+}
+
+func (n *Node) stopCheckPointRPC() {
+	if n.checkPointServer != nil {
+		n.checkPointServer.Shutdown()
+		n.checkPointServer = nil
+	}
+	if n.checkPointListener != nil {
+		n.checkPointListener.Close()
+		n.checkPointListener = nil
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package txexec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/nonces"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+var (
+	abiRegistryContractAddr = common.HexToAddress(params.AbiRegistryContractAddr)
+	contractAbiTable, _     = utils.String2Uint64(params.ContractAbiTable)
+	setAbiAction, _         = utils.String2Uint64(params.AbiRegistrySetAbiAction)
+)
+
+var (
+	errAbiTooLarge   = errors.New("abi too large")
+	errAbiNotJSONObj = errors.New("abi is not a json object")
+)
+
+// SetAbiData returns the data of the message which sets the abi of the
+// contract in the abi registry: the action, the contract address and the abi
+// json. An empty abi removes the abi of the contract.
+func SetAbiData(contract common.Address, abiDef []byte) []byte {
+	data := make([]byte, 8+common.AddressLength, 8+common.AddressLength+len(abiDef))
+	binary.LittleEndian.PutUint64(data[:8], setAbiAction)
+	copy(data[8:], contract[:])
+	return append(data, abiDef...)
+}
+
+// contractAbiKey is the key of the abi of a contract in the storage of the
+// abi registry. The abis are kept out of the storage of the contracts, which
+// the contracts write themselves.
+func contractAbiKey(contract common.Address) state.StorageKey {
+	return state.GetStorageKey(contractAbiTable, contract[:])
+}
+
+// ReadContractAbi returns the abi json of the contract set in the abi
+// registry, or nil if it is not set.
+func ReadContractAbi(statedb *state.StateDB, contract common.Address) []byte {
+	return statedb.GetState(abiRegistryContractAddr, contractAbiKey(contract))
+}
+
+// isSetAbiCall returns whether the message calls the setabi action of the abi
+// registry.
+func (st *StateTransition) isSetAbiCall() bool {
+	return st.to() == abiRegistryContractAddr && len(st.data) >= 8+common.AddressLength &&
+		binary.LittleEndian.Uint64(st.data[:8]) == setAbiAction
+}
+
+// applySetAbi stores the abi of a contract in the storage of the registry.
+// Only the owner of the contract can set its abi.
+func (st *StateTransition) applySetAbi() error {
+	contract := common.BytesToAddress(st.data[8 : 8+common.AddressLength])
+	abiDef := st.data[8+common.AddressLength:]
+
+	if err := st.useGas(uint64(len(abiDef)) * params.AbiStoreDataGas); err != nil {
+		return err
+	}
+	if len(st.state.GetCode(contract)) == 0 || st.state.GetContractOwner(contract) != st.msg.From() {
+		log.Warn("set abi by non owner", "contract", contract, "from", st.msg.From())
+		return ErrWasmExec
+	}
+	var value []byte // nil removes the abi
+	if len(abiDef) > 0 {
+		if err := validateAbiV1(abiDef); err != nil {
+			log.Warn("set invalid abi", "contract", contract, "err", err)
+			return ErrWasmExec
+		}
+		value = common.CopyBytes(abiDef)
+	}
+
+	st.state.SetState(abiRegistryContractAddr, contractAbiKey(contract), value)
+	// like a created contract the registry starts at nonce 1, so that it is
+	// not cleared with its storage as an empty account
+	if nonceSet := st.state.TxNonceSet(abiRegistryContractAddr); nonceSet.NextNonce() == 0 {
+		prev := nonces.NewNonceSet(nonceSet)
+		nonceSet.Reset(1)
+		st.state.MarkNonceSetJournal(abiRegistryContractAddr, prev)
+	}
+	log.Info("contract abi set", "contract", contract, "len", len(abiDef), "from", st.msg.From())
+	return nil
+}
+
+// validateAbiV1 is the check of the abis stored by the abi registry. It is
+// part of the consensus, so it must never change: it only checks the size
+// and that the abi is a json object, and leaves the abi itself to the
+// clients. A stricter check needs a new version activated by a fork.
+func validateAbiV1(abiDef []byte) error {
+	if len(abiDef) > params.MaxAbiSize {
+		return errAbiTooLarge
+	}
+	if !json.Valid(abiDef) {
+		return errAbiNotJSONObj
+	}
+	if trimmed := bytes.TrimLeft(abiDef, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '{' {
+		return errAbiNotJSONObj
+	}
+	return nil
+}
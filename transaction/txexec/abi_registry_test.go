@@ -0,0 +1,132 @@
+package txexec
+
+import (
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/core/wasm"
+	"github.com/fractal-platform/fractal/dbwrapper"
+	"github.com/fractal-platform/fractal/params"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestState() *state.StateDB {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(dbwrapper.NewMemDatabase()))
+	return statedb
+}
+
+func TestValidateAbiV1(t *testing.T) {
+	Convey("validate abi", t, func() {
+		So(validateAbiV1([]byte(`{"version":"ftl::abi/0.3.0","events":[{"name":"not_a_valid_event_name","type":"x"}]}`)), ShouldBeNil)
+		So(validateAbiV1([]byte(` {}`)), ShouldBeNil)
+
+		So(validateAbiV1([]byte(`{"version":`)), ShouldEqual, errAbiNotJSONObj)
+		So(validateAbiV1([]byte(`[]`)), ShouldEqual, errAbiNotJSONObj)
+		So(validateAbiV1([]byte(`"abi"`)), ShouldEqual, errAbiNotJSONObj)
+		So(validateAbiV1([]byte(`{"a":"`+strings.Repeat("x", params.MaxAbiSize)+`"}`)), ShouldEqual, errAbiTooLarge)
+	})
+}
+
+func TestSetAbi(t *testing.T) {
+	var (
+		owner    = common.HexToAddress("0x0101010101010101010101010101010101010101")
+		other    = common.HexToAddress("0x0202020202020202020202020202020202020202")
+		contract = common.HexToAddress("0x0303030303030303030303030303030303030303")
+		abiDef   = []byte(`{"version":"ftl::abi/0.3.0"}`)
+		nonce    uint64
+	)
+	genesis := uint64(0)
+	forks := &params.ForkSchedule{AbiRegistryHeight: &genesis}
+
+	setAbi := func(statedb *state.StateDB, forks *params.ForkSchedule, from common.Address, abiDef []byte) (bool, error) {
+		nonce++
+		msg := types.NewMessage(from, &abiRegistryContractAddr, nonce, new(big.Int), 1e9, big.NewInt(1), SetAbiData(contract, abiDef), false)
+		gp := new(types.GasPool).AddGas(math.MaxUint64)
+		_, _, failed, err := WasmApplyMessage(nil, statedb, msg, gp, 1024, forks, 1, nil, 0, CallGoWasmContract)
+		return failed, err
+	}
+
+	Convey("set abi", t, func() {
+		statedb := newTestState()
+		statedb.AddBalance(owner, big.NewInt(1e18))
+		statedb.AddBalance(other, big.NewInt(1e18))
+		statedb.SetCode(contract, []byte{0})
+		statedb.SetContractOwner(contract, owner)
+
+		failed, err := setAbi(statedb, params.NoForks, owner, abiDef)
+		So(err, ShouldBeNil)
+		So(failed, ShouldBeFalse)
+		So(ReadContractAbi(statedb, contract), ShouldBeNil)
+
+		failed, err = setAbi(statedb, forks, other, abiDef)
+		So(failed, ShouldBeTrue)
+		So(ReadContractAbi(statedb, contract), ShouldBeNil)
+
+		failed, err = setAbi(statedb, forks, owner, []byte(`{"version":`))
+		So(failed, ShouldBeTrue)
+		So(ReadContractAbi(statedb, contract), ShouldBeNil)
+
+		failed, err = setAbi(statedb, forks, owner, abiDef)
+		So(err, ShouldBeNil)
+		So(failed, ShouldBeFalse)
+		So(ReadContractAbi(statedb, contract), ShouldResemble, abiDef)
+
+		failed, err = setAbi(statedb, forks, owner, nil)
+		So(err, ShouldBeNil)
+		So(failed, ShouldBeFalse)
+		So(ReadContractAbi(statedb, contract), ShouldBeEmpty)
+	})
+
+	Convey("the abi is not written by the contracts", t, func() {
+		// the abiwriter contract of testdata stores the args of its actions in
+		// its own abi table
+		code, err := ioutil.ReadFile("testdata/abiwriter.wasm")
+		So(err, ShouldBeNil)
+		statedb := newTestState()
+		statedb.AddBalance(owner, big.NewInt(1e18))
+		statedb.SetCode(contract, code)
+		statedb.SetContractOwner(contract, owner)
+		call := func(args []byte) {
+			nonce++
+			block := types.NewBlockWithHeader(&types.BlockHeader{Height: 1, Round: 1, Difficulty: big.NewInt(1)})
+			key := wasm.GetGlobalRegisterParam().RegisterParam(statedb, block)
+			defer wasm.GetGlobalRegisterParam().UnRegisterParam(key)
+			msg := types.NewMessage(owner, &contract, nonce, new(big.Int), 1e9, big.NewInt(1), append([]byte("action01"), args...), false)
+			gp := new(types.GasPool).AddGas(math.MaxUint64)
+			_, _, failed, err := WasmApplyMessage(nil, statedb, msg, gp, 1024, forks, 1, nil, key, CallGoWasmContract)
+			So(err, ShouldBeNil)
+			So(failed, ShouldBeFalse)
+		}
+		ownAbiKey := state.GetStorageKey(contractAbiTable, nil)
+
+		failed, err := setAbi(statedb, forks, owner, abiDef)
+		So(err, ShouldBeNil)
+		So(failed, ShouldBeFalse)
+
+		forged := []byte(`{"version":"forged"}`)
+		call(forged)
+		So(statedb.GetState(contract, ownAbiKey), ShouldResemble, forged)
+		So(ReadContractAbi(statedb, contract), ShouldResemble, abiDef)
+
+		// the abi set does not overwrite the abi table of the contract
+		newAbiDef := []byte(`{"version":"ftl::abi/0.4.0"}`)
+		failed, err = setAbi(statedb, forks, owner, newAbiDef)
+		So(err, ShouldBeNil)
+		So(failed, ShouldBeFalse)
+		So(statedb.GetState(contract, ownAbiKey), ShouldResemble, forged)
+
+		// and the registry keeps it in the committed state
+		root, err := statedb.Commit(true)
+		So(err, ShouldBeNil)
+		committed, err := state.New(root, statedb.Database())
+		So(err, ShouldBeNil)
+		So(ReadContractAbi(committed, contract), ShouldResemble, newAbiDef)
+		So(committed.GetState(contract, ownAbiKey), ShouldResemble, forged)
+	})
+}
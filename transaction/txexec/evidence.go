@@ -0,0 +1,78 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package txexec
+
+import (
+	"encoding/binary"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/rlp"
+	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+var (
+	minerKeyContractAddr = common.HexToAddress(params.MinerKeyContractAddr)
+	evidenceAction, _    = utils.String2Uint64(params.MinerKeyContractEvidenceAction)
+	minerKeyTable, _     = utils.String2Uint64(params.MinerKeyContractTable)
+	evidenceTable, _     = utils.String2Uint64(params.MinerKeyEvidenceTable)
+)
+
+// evidenceKey is the storage key marking the evidence consumed in the
+// storage of the miner key contract. The evidence is keyed by its blocks,
+// whatever their order in the data, so that it is consumed only once.
+func evidenceKey(evidence *types.Evidence) state.StorageKey {
+	hash := types.NewEvidence(&evidence.HeaderA, &evidence.HeaderB).Hash()
+	return state.GetStorageKey(evidenceTable, hash[:])
+}
+
+// isEvidenceCall returns whether the message calls the evidence action of the
+// miner key contract. The action data is the rlp encoded evidence.
+func (st *StateTransition) isEvidenceCall() bool {
+	return st.to() == minerKeyContractAddr && len(st.data) >= 8 &&
+		binary.LittleEndian.Uint64(st.data[:8]) == evidenceAction
+}
+
+// applyEvidence deregisters the mining key of a miner who signed two blocks
+// in the same round. The evidence is checked against the mining pubkey
+// currently registered for the coinbase, and anyone can submit it. Each
+// evidence is consumed once, so that it can't deregister the key the miner
+// registers again.
+func (st *StateTransition) applyEvidence() error {
+	if err := st.useGas(params.EvidenceVerifyGas); err != nil {
+		return err
+	}
+
+	var evidence types.Evidence
+	if err := rlp.DecodeBytes(st.data[8:], &evidence); err != nil {
+		log.Warn("decode evidence failed", "from", st.msg.From(), "err", err)
+		return ErrWasmExec
+	}
+
+	consumedKey := evidenceKey(&evidence)
+	if len(st.state.GetState(minerKeyContractAddr, consumedKey)) > 0 {
+		log.Warn("evidence already consumed", "evidence", evidence.Hash(), "from", st.msg.From())
+		return ErrWasmExec
+	}
+
+	coinbase := evidence.Coinbase()
+	storageKey := state.GetStorageKey(minerKeyTable, coinbase[:])
+	storageBytes := st.state.GetState(minerKeyContractAddr, storageKey)
+	if len(storageBytes) <= 22 {
+		log.Warn("evidence coinbase has no mining key", "coinbase", coinbase, "evidence", evidence.Hash())
+		return ErrWasmExec
+	}
+	if err := evidence.Verify(storageBytes[22:]); err != nil {
+		log.Warn("verify evidence failed", "coinbase", coinbase, "evidence", evidence.Hash(), "err", err)
+		return ErrWasmExec
+	}
+
+	st.state.SetState(minerKeyContractAddr, storageKey, nil)
+	st.state.SetState(minerKeyContractAddr, consumedKey, []byte{1})
+	log.Info("mining key deregistered by evidence", "coinbase", coinbase, "round", evidence.Round(), "evidence", evidence.Hash(), "from", st.msg.From())
+	return nil
+}
@@ -0,0 +1,127 @@
+package txexec
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/rlp"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// signedHeader returns a header of the coinbase in the round, signed by the key.
+func signedHeader(key crypto.PrivateKey, coinbase common.Address, round uint64, parent common.Hash) *types.BlockHeader {
+	header := &types.BlockHeader{ParentHash: parent, Round: round, Coinbase: coinbase, Difficulty: big.NewInt(1)}
+	header.Sig, _ = key.Sign(types.NewBlockWithHeader(header).SignHashByte())
+	header.FullSig, _ = key.Sign(header.FullHash().Bytes())
+	return header
+}
+
+func registerMiningKey(statedb *state.StateDB, coinbase common.Address, pubkey crypto.PublicKey) {
+	value := make([]byte, 22+crypto.BlsPubkeyLen)
+	copy(value[:20], coinbase[:])
+	value[20], value[21] = 0x80, 1
+	copy(value[22:], pubkey.Marshal())
+	statedb.SetState(minerKeyContractAddr, state.GetStorageKey(minerKeyTable, coinbase[:]), value)
+}
+
+func hasMiningKey(statedb *state.StateDB, coinbase common.Address) bool {
+	return len(statedb.GetState(minerKeyContractAddr, state.GetStorageKey(minerKeyTable, coinbase[:]))) > 0
+}
+
+func TestEvidence(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x0101010101010101010101010101010101010101")
+		coinbase = common.HexToAddress("0x0202020202020202020202020202020202020202")
+		nonce    uint64
+	)
+	genesis := uint64(0)
+	forks := &params.ForkSchedule{MinerKeyEvidenceHeight: &genesis}
+	pubkey, key, err := crypto.NewKeys(crypto.BLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := crypto.NewKeys(crypto.BLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(statedb *state.StateDB, forks *params.ForkSchedule, evidence *types.Evidence) bool {
+		encoded, err := rlp.EncodeToBytes(evidence)
+		So(err, ShouldBeNil)
+		data := make([]byte, 8, 8+len(encoded))
+		binary.LittleEndian.PutUint64(data, evidenceAction)
+		data = append(data, encoded...)
+
+		nonce++
+		msg := types.NewMessage(sender, &minerKeyContractAddr, nonce, new(big.Int), 1e9, big.NewInt(1), data, false)
+		gp := new(types.GasPool).AddGas(math.MaxUint64)
+		_, _, failed, err := WasmApplyMessage(nil, statedb, msg, gp, 1024, forks, 1, nil, 0, CallGoWasmContract)
+		// a rejected evidence fails the transaction, which is still executed
+		if err != nil {
+			So(failed, ShouldBeTrue)
+		}
+		return err == nil
+	}
+	newState := func() *state.StateDB {
+		statedb := newTestState()
+		statedb.AddBalance(sender, big.NewInt(1e18))
+		registerMiningKey(statedb, coinbase, pubkey)
+		return statedb
+	}
+	headerA := signedHeader(key, coinbase, 7, common.HexToHash("0x01"))
+	headerB := signedHeader(key, coinbase, 7, common.HexToHash("0x02"))
+
+	Convey("valid evidence", t, func() {
+		statedb := newState()
+		So(submit(statedb, forks, types.NewEvidence(headerA, headerB)), ShouldBeTrue)
+		So(hasMiningKey(statedb, coinbase), ShouldBeFalse)
+	})
+
+	Convey("evidence before the fork", t, func() {
+		statedb := newState()
+		So(submit(statedb, params.NoForks, types.NewEvidence(headerA, headerB)), ShouldBeTrue)
+		So(hasMiningKey(statedb, coinbase), ShouldBeTrue)
+	})
+
+	Convey("duplicate evidence", t, func() {
+		statedb := newState()
+		So(submit(statedb, forks, types.NewEvidence(headerA, headerB)), ShouldBeTrue)
+		So(submit(statedb, forks, types.NewEvidence(headerA, headerB)), ShouldBeFalse)
+	})
+
+	Convey("forged evidence", t, func() {
+		statedb := newState()
+		forged := signedHeader(otherKey, coinbase, 7, common.HexToHash("0x03"))
+		So(submit(statedb, forks, types.NewEvidence(headerA, forged)), ShouldBeFalse)
+		So(hasMiningKey(statedb, coinbase), ShouldBeTrue)
+
+		otherRound := signedHeader(key, coinbase, 8, common.HexToHash("0x03"))
+		So(submit(statedb, forks, types.NewEvidence(headerA, otherRound)), ShouldBeFalse)
+		So(submit(statedb, forks, &types.Evidence{HeaderA: *headerA, HeaderB: *headerA}), ShouldBeFalse)
+		So(hasMiningKey(statedb, coinbase), ShouldBeTrue)
+	})
+
+	Convey("replayed evidence after the key registers again", t, func() {
+		statedb := newState()
+		So(submit(statedb, forks, types.NewEvidence(headerA, headerB)), ShouldBeTrue)
+		registerMiningKey(statedb, coinbase, pubkey)
+
+		So(submit(statedb, forks, types.NewEvidence(headerA, headerB)), ShouldBeFalse)
+		// the same blocks in the other order are the same evidence
+		So(submit(statedb, forks, &types.Evidence{HeaderA: *headerB, HeaderB: *headerA}), ShouldBeFalse)
+		So(hasMiningKey(statedb, coinbase), ShouldBeTrue)
+
+		// a new equivocation still deregisters the key
+		headerC := signedHeader(key, coinbase, 9, common.HexToHash("0x01"))
+		headerD := signedHeader(key, coinbase, 9, common.HexToHash("0x02"))
+		So(submit(statedb, forks, types.NewEvidence(headerC, headerD)), ShouldBeTrue)
+		So(hasMiningKey(statedb, coinbase), ShouldBeFalse)
+	})
+}
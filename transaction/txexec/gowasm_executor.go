@@ -0,0 +1,383 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+package txexec
+
+import (
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/core/wasm"
+	"github.com/fractal-platform/fractal/core/wasm/vm"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/crypto/sha3"
+	"github.com/fractal-platform/fractal/params"
+	"github.com/fractal-platform/fractal/utils/log"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	// goWasmEntry is the exported function called for every action.
+	goWasmEntry = "apply"
+	// goWasmImportModule is the module name of the host functions.
+	goWasmImportModule = "env"
+
+	goWasmModuleCacheSize = 64
+
+	// gas charged by host functions, on top of vm.GasPerCall. Like the
+	// schedule of the interpreter, it must be the metering of libwasmlib.
+	goWasmDbReadGas  = 50
+	goWasmDbWriteGas = 200
+	goWasmLogGas     = 100
+	goWasmHashGas    = 30
+)
+
+var goWasmModuleCache, _ = lru.New(goWasmModuleCacheSize)
+
+// NewGoWasmExecutor returns a wasm executor backed by the in-process
+// interpreter of core/wasm/vm instead of libwasmlib.
+func NewGoWasmExecutor(signer types.Signer, maxBitLength uint64, rewards *params.RewardSchedule, forks *params.ForkSchedule) TxExecutor {
+	log.Info("NewExecutor: Init GoWasmExecutor")
+	return &WasmExecutor{
+		signer:       signer,
+		maxBitLength: maxBitLength,
+		rewards:      rewards,
+		forks:        forks,
+		engine:       CallGoWasmContract,
+	}
+}
+
+// CallGoWasmContract is the pure Go counterpart of CallWasmContract.
+func CallGoWasmContract(code []byte, action []byte, from common.Address, to common.Address, user common.Address, owner common.Address, amount uint64, storageDelegate bool, userDelegate bool, remainedGas *uint64, callbackParamKey uint64) int {
+	// prepare
+	wasm.GetGlobalRegisterParam().SetRemainedGas(callbackParamKey, remainedGas)
+	wasm.GetGlobalRegisterParam().AddCallstack(callbackParamKey, from, to, user, action, storageDelegate, userDelegate)
+
+	// check depth
+	depth := wasm.GetGlobalRegisterParam().GetCurrentDepth(callbackParamKey)
+	if depth >= MaxWasmCallDepth {
+		wasm.GetGlobalRegisterParam().FulfillCallstack(callbackParamKey, wasm.WasmErrorDepthExceed, "wasm call depth exceed", 0)
+		return wasm.WasmErrorDepthExceed
+	}
+
+	preGas := *remainedGas
+	ctx := &goWasmContext{
+		callbackParamKey: callbackParamKey,
+		action:           action,
+		from:             from,
+		to:               to,
+		owner:            owner,
+		user:             user,
+		amount:           amount,
+	}
+	ret, errStr := ctx.run(code, remainedGas)
+	postGas := *remainedGas
+	gas := preGas - postGas
+	wasm.GetGlobalRegisterParam().FulfillCallstack(callbackParamKey, ret, errStr, gas)
+	log.Info("Call go wasm contract finish", "depth", depth, "gas", gas, "ret", ret)
+	return ret
+}
+
+func loadGoWasmModule(code []byte) (*vm.Module, error) {
+	hash := crypto.Keccak256Hash(code)
+	if m, ok := goWasmModuleCache.Get(hash); ok {
+		return m.(*vm.Module), nil
+	}
+	m, err := vm.ReadModule(code)
+	if err != nil {
+		return nil, err
+	}
+	goWasmModuleCache.Add(hash, m)
+	return m, nil
+}
+
+// goWasmContext is the environment of one contract call. Its methods are
+// the host functions imported by contracts, one for each c_* callback of
+// libwasmlib plus accessors for the call parameters.
+type goWasmContext struct {
+	callbackParamKey uint64
+	action           []byte
+	from             common.Address
+	to               common.Address
+	owner            common.Address
+	user             common.Address
+	amount           uint64
+}
+
+func (c *goWasmContext) run(code []byte, remainedGas *uint64) (int, string) {
+	module, err := loadGoWasmModule(code)
+	if err != nil {
+		log.Error("Go wasm load module failed", "contract", c.to, "err", err)
+		return wasm.WasmErrorInvalidModule, err.Error()
+	}
+
+	inst, err := vm.NewInstance(module, c.resolve, remainedGas)
+	if err == nil {
+		var results []uint64
+		results, err = inst.Invoke(goWasmEntry)
+		if err == nil {
+			if len(results) > 0 {
+				return int(int32(results[0])), ""
+			}
+			return 0, ""
+		}
+	}
+
+	log.Warn("Go wasm execute failed", "contract", c.to, "err", err)
+	if err == vm.ErrOutOfGas {
+		return wasm.WasmErrorOutOfGas, err.Error()
+	}
+	return wasm.WasmErrorTrap, err.Error()
+}
+
+func (c *goWasmContext) resolve(module string, name string) (*vm.HostFunction, error) {
+	const (
+		i32 = vm.ValueTypeI32
+		i64 = vm.ValueTypeI64
+	)
+	if module != goWasmImportModule {
+		return nil, vm.ErrImportNotFound
+	}
+
+	var (
+		params  []byte
+		results []byte
+		gas     uint64
+		call    func(in *vm.Instance, args []uint64) ([]uint64, error)
+	)
+	switch name {
+	case "db_store":
+		params, gas, call = []byte{i64, i32, i32, i32, i32}, goWasmDbWriteGas, c.dbStore
+	case "db_load":
+		params, results, gas, call = []byte{i64, i32, i32, i32, i32}, []byte{i32}, goWasmDbReadGas, c.dbLoad
+	case "db_has_key":
+		params, results, gas, call = []byte{i64, i32, i32}, []byte{i32}, goWasmDbReadGas, c.dbHasKey
+	case "db_remove_key":
+		params, gas, call = []byte{i64, i32, i32}, goWasmDbWriteGas, c.dbRemoveKey
+	case "db_has_table":
+		params, results, gas, call = []byte{i64}, []byte{i32}, goWasmDbReadGas, c.dbHasTable
+	case "db_remove_table":
+		params, gas, call = []byte{i64}, goWasmDbWriteGas, c.dbRemoveTable
+	case "chain_current_time":
+		results, call = []byte{i64}, c.chainCurrentTime
+	case "chain_current_height":
+		results, call = []byte{i64}, c.chainCurrentHeight
+	case "chain_current_hash":
+		params, call = []byte{i32}, c.chainCurrentHash
+	case "add_log":
+		params, gas, call = []byte{i32, i32, i32, i32}, goWasmLogGas, c.addLog
+	case "transfer":
+		params, results, call = []byte{i32, i64}, []byte{i32}, c.transfer
+	case "call_action":
+		params, results, call = []byte{i32, i32, i32, i64, i32, i32}, []byte{i32}, c.callAction
+	case "call_result":
+		params, results, call = []byte{i32, i32}, []byte{i32}, c.callResult
+	case "set_result":
+		params, results, call = []byte{i32, i32}, []byte{i32}, c.setResult
+	case "call_depth":
+		results, call = []byte{i32}, c.callDepth
+	case "sha256":
+		params, results, gas, call = []byte{i32, i32, i32}, []byte{i32}, goWasmHashGas, c.sha256
+	case "action_data_size":
+		results, call = []byte{i32}, c.actionDataSize
+	case "read_action_data":
+		params, results, call = []byte{i32, i32}, []byte{i32}, c.readActionData
+	case "current_from":
+		params, call = []byte{i32}, c.addressWriter(c.from)
+	case "current_to":
+		params, call = []byte{i32}, c.addressWriter(c.to)
+	case "current_owner":
+		params, call = []byte{i32}, c.addressWriter(c.owner)
+	case "current_user":
+		params, call = []byte{i32}, c.addressWriter(c.user)
+	case "current_amount":
+		results, call = []byte{i64}, c.currentAmount
+	default:
+		log.Error("Go wasm import not found", "module", module, "name", name)
+		return nil, vm.ErrImportNotFound
+	}
+	return &vm.HostFunction{Type: vm.FuncType{Params: params, Results: results}, Gas: gas, Call: call}, nil
+}
+
+func readAddress(in *vm.Instance, ptr uint64) (common.Address, error) {
+	b, err := in.ReadMemory(uint32(ptr), common.AddressLength)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(b), nil
+}
+
+// writeResult copies as much of data as fits in the buffer and returns the
+// full length, like the c_* callbacks do.
+func writeResult(in *vm.Instance, ptr uint64, length uint64, data []byte) ([]uint64, error) {
+	if uint32(length) > 0 {
+		n := len(data)
+		if uint64(n) > uint64(uint32(length)) {
+			n = int(uint32(length))
+		}
+		if err := in.WriteMemory(uint32(ptr), data[:n]); err != nil {
+			return nil, err
+		}
+	}
+	return []uint64{uint64(uint32(len(data)))}, nil
+}
+
+func (c *goWasmContext) storage() common.Address {
+	return wasm.GetGlobalRegisterParam().GetCurrentStorage(c.callbackParamKey)
+}
+
+func (c *goWasmContext) dbStore(in *vm.Instance, args []uint64) ([]uint64, error) {
+	key, err := in.ReadMemory(uint32(args[1]), uint32(args[2]))
+	if err != nil {
+		return nil, err
+	}
+	value, err := in.ReadMemory(uint32(args[3]), uint32(args[4]))
+	if err != nil {
+		return nil, err
+	}
+	wasm.DbStore(c.callbackParamKey, c.storage(), args[0], key, value)
+	return nil, nil
+}
+
+func (c *goWasmContext) dbLoad(in *vm.Instance, args []uint64) ([]uint64, error) {
+	key, err := in.ReadMemory(uint32(args[1]), uint32(args[2]))
+	if err != nil {
+		return nil, err
+	}
+	value := wasm.DbLoad(c.callbackParamKey, c.storage(), args[0], key)
+	return writeResult(in, args[3], args[4], value)
+}
+
+func (c *goWasmContext) dbHasKey(in *vm.Instance, args []uint64) ([]uint64, error) {
+	key, err := in.ReadMemory(uint32(args[1]), uint32(args[2]))
+	if err != nil {
+		return nil, err
+	}
+	return []uint64{uint64(uint32(wasm.DbHasKey(c.callbackParamKey, c.storage(), args[0], key)))}, nil
+}
+
+func (c *goWasmContext) dbRemoveKey(in *vm.Instance, args []uint64) ([]uint64, error) {
+	key, err := in.ReadMemory(uint32(args[1]), uint32(args[2]))
+	if err != nil {
+		return nil, err
+	}
+	wasm.DbRemoveKey(c.callbackParamKey, c.storage(), args[0], key)
+	return nil, nil
+}
+
+func (c *goWasmContext) dbHasTable(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return []uint64{uint64(uint32(wasm.DbHasTable(c.callbackParamKey, c.storage(), args[0])))}, nil
+}
+
+func (c *goWasmContext) dbRemoveTable(in *vm.Instance, args []uint64) ([]uint64, error) {
+	wasm.DbRemoveTable(c.callbackParamKey, c.storage(), args[0])
+	return nil, nil
+}
+
+func (c *goWasmContext) chainCurrentTime(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return []uint64{wasm.GetBlockRound(c.callbackParamKey)}, nil
+}
+
+func (c *goWasmContext) chainCurrentHeight(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return []uint64{wasm.GetBlockHeight(c.callbackParamKey)}, nil
+}
+
+func (c *goWasmContext) chainCurrentHash(in *vm.Instance, args []uint64) ([]uint64, error) {
+	hash := wasm.GetBlockHash(c.callbackParamKey)
+	return nil, in.WriteMemory(uint32(args[0]), hash[:])
+}
+
+func (c *goWasmContext) addLog(in *vm.Instance, args []uint64) ([]uint64, error) {
+	topicNum := uint32(args[1])
+	if uint64(topicNum)*common.HashLength > uint64(len(in.Memory())) {
+		return nil, vm.ErrMemoryOutOfBounds
+	}
+	topics, err := in.ReadMemory(uint32(args[0]), topicNum*common.HashLength)
+	if err != nil {
+		return nil, err
+	}
+	data, err := in.ReadMemory(uint32(args[2]), uint32(args[3]))
+	if err != nil {
+		return nil, err
+	}
+	wasm.AddLog(c.callbackParamKey, c.storage(), topics, int(topicNum), data, len(data))
+	return nil, nil
+}
+
+func (c *goWasmContext) transfer(in *vm.Instance, args []uint64) ([]uint64, error) {
+	to, err := readAddress(in, args[0])
+	if err != nil {
+		return nil, err
+	}
+	fromAddr := wasm.GetGlobalRegisterParam().GetCurrentContract(c.callbackParamKey)
+	remainedGas := wasm.GetGlobalRegisterParam().GetRemainedGas(c.callbackParamKey)
+	return []uint64{uint64(uint32(wasm.Transfer(c.callbackParamKey, fromAddr, to, args[1], remainedGas)))}, nil
+}
+
+func (c *goWasmContext) callAction(in *vm.Instance, args []uint64) ([]uint64, error) {
+	contractAddr, err := readAddress(in, args[0])
+	if err != nil {
+		return nil, err
+	}
+	action, err := in.ReadMemory(uint32(args[1]), uint32(args[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	code := wasm.GetGlobalRegisterParam().GetContractCode(c.callbackParamKey, contractAddr)
+	if code == nil {
+		log.Error("Go wasm call action failed: contract code is nil")
+	}
+	owner := wasm.GetGlobalRegisterParam().GetContractOwner(c.callbackParamKey, contractAddr)
+	from := wasm.GetGlobalRegisterParam().GetCurrentContract(c.callbackParamKey)
+	user := wasm.GetGlobalRegisterParam().GetCurrentUser(c.callbackParamKey)
+	remainedGas := wasm.GetGlobalRegisterParam().GetRemainedGas(c.callbackParamKey)
+	// the argument order matches c_call_action
+	ret := CallGoWasmContract(code, action, from, contractAddr, owner, user, args[3], uint32(args[4]) != 0, uint32(args[5]) != 0, remainedGas, c.callbackParamKey)
+	return []uint64{uint64(uint32(int32(ret)))}, nil
+}
+
+func (c *goWasmContext) callResult(in *vm.Instance, args []uint64) ([]uint64, error) {
+	result := wasm.GetGlobalRegisterParam().GetCallResult(c.callbackParamKey)
+	return writeResult(in, args[0], args[1], result)
+}
+
+func (c *goWasmContext) setResult(in *vm.Instance, args []uint64) ([]uint64, error) {
+	result, err := in.ReadMemory(uint32(args[0]), uint32(args[1]))
+	if err != nil {
+		return nil, err
+	}
+	wasm.GetGlobalRegisterParam().SetCallResult(c.callbackParamKey, result)
+	return []uint64{uint64(uint32(len(result)))}, nil
+}
+
+func (c *goWasmContext) callDepth(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return []uint64{uint64(wasm.GetGlobalRegisterParam().GetCurrentDepth(c.callbackParamKey))}, nil
+}
+
+// sha256 uses sha3 just like c_sha256.
+func (c *goWasmContext) sha256(in *vm.Instance, args []uint64) ([]uint64, error) {
+	input, err := in.ReadMemory(uint32(args[0]), uint32(args[1]))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha3.Sum256(input)
+	return []uint64{0}, in.WriteMemory(uint32(args[2]), hash[:])
+}
+
+func (c *goWasmContext) actionDataSize(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return []uint64{uint64(uint32(len(c.action)))}, nil
+}
+
+func (c *goWasmContext) readActionData(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return writeResult(in, args[0], args[1], c.action)
+}
+
+func (c *goWasmContext) addressWriter(addr common.Address) func(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return func(in *vm.Instance, args []uint64) ([]uint64, error) {
+		return nil, in.WriteMemory(uint32(args[0]), addr[:])
+	}
+}
+
+func (c *goWasmContext) currentAmount(in *vm.Instance, args []uint64) ([]uint64, error) {
+	return []uint64{c.amount}, nil
+}
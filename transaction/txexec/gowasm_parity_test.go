@@ -0,0 +1,153 @@
+//go:build !nowasmlib
+// +build !nowasmlib
+
+package txexec
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/core/wasm"
+	"github.com/fractal-platform/fractal/params"
+)
+
+// The parity test runs the same contracts and transactions on libwasmlib and
+// on the gowasm interpreter, and compares the receipts and the state roots.
+// It runs wherever libwasmlib is linked. The gowasm executor can't run a
+// chain until it passes.
+
+func wasmUleb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func wasmVec(items ...[]byte) []byte {
+	return bytes.Join(append([][]byte{wasmUleb(uint64(len(items)))}, items...), nil)
+}
+
+func wasmName(s string) []byte {
+	return append(wasmUleb(uint64(len(s))), s...)
+}
+
+func wasmSection(id byte, items ...[]byte) []byte {
+	payload := wasmVec(items...)
+	return bytes.Join([][]byte{{id}, wasmUleb(uint64(len(payload))), payload}, nil)
+}
+
+func wasmI32(v uint32) []byte {
+	// small non-negative constants only
+	return append([]byte{0x41}, wasmUleb(uint64(v))...)
+}
+
+// parityContract loops the times given, then stores a row and adds a log.
+func parityContract(loops uint32) []byte {
+	const (
+		i32 = 0x7f
+		i64 = 0x7e
+	)
+	topic := bytes.Repeat([]byte{0xab}, common.HashLength)
+	code := bytes.Join([][]byte{
+		// block { loop { if i >= loops break; i++; continue } }
+		{0x02, 0x40, 0x03, 0x40},
+		{0x20, 0x00}, wasmI32(loops), {0x4e, 0x0d, 0x01},
+		{0x20, 0x00}, wasmI32(1), {0x6a, 0x21, 0x00, 0x0c, 0x00},
+		{0x0b, 0x0b},
+		// db_store(1, "key1", "val1")
+		{0x42, 0x01}, wasmI32(0), wasmI32(4), wasmI32(4), wasmI32(4), {0x10, 0x00},
+		// add_log(topic, 1, "key1val1")
+		wasmI32(8), wasmI32(1), wasmI32(0), wasmI32(8), {0x10, 0x01},
+		wasmI32(0),
+		{0x0b},
+	}, nil)
+	body := append(wasmVec(append(wasmUleb(1), i32)), code...)
+
+	return bytes.Join([][]byte{
+		{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		wasmSection(1,
+			[]byte{0x60, 5, i64, i32, i32, i32, i32, 0},
+			[]byte{0x60, 4, i32, i32, i32, i32, 0},
+			[]byte{0x60, 0, 1, i32}),
+		wasmSection(2,
+			append(append(wasmName("env"), wasmName("db_store")...), 0x00, 0),
+			append(append(wasmName("env"), wasmName("add_log")...), 0x00, 1)),
+		wasmSection(3, []byte{2}),
+		wasmSection(5, []byte{0x00, 1}),
+		wasmSection(7,
+			append(wasmName("memory"), 0x02, 0),
+			append(wasmName("apply"), 0x00, 2)),
+		wasmSection(10, append(wasmUleb(uint64(len(body))), body...)),
+		wasmSection(11, bytes.Join([][]byte{{0x00}, wasmI32(0), {0x0b}, wasmUleb(8 + common.HashLength), []byte("key1val1"), topic}, nil)),
+	}, nil)
+}
+
+type parityReceipt struct {
+	gasUsed uint64
+	failed  bool
+	logs    []*types.Log
+}
+
+// runParity executes the transactions calling the contracts on the engine.
+func runParity(engine WasmEngine, contracts [][]byte, calls int) ([]parityReceipt, common.Hash) {
+	sender := common.HexToAddress("0x0101010101010101010101010101010101010101")
+	statedb := newTestState()
+	statedb.AddBalance(sender, big.NewInt(1e18))
+	var addrs []common.Address
+	for i, code := range contracts {
+		addr := common.BytesToAddress([]byte{0x10, byte(i)})
+		statedb.SetCode(addr, code)
+		statedb.SetContractOwner(addr, sender)
+		addrs = append(addrs, addr)
+	}
+	block := types.NewBlockWithHeader(&types.BlockHeader{Height: 1, Round: 1, Difficulty: big.NewInt(1)})
+
+	var receipts []parityReceipt
+	nonce := uint64(0)
+	for i := 0; i < calls; i++ {
+		for _, addr := range addrs {
+			nonce++
+			txHash := common.BytesToHash([]byte{byte(nonce)})
+			statedb.Prepare(txHash, 0, uint32(nonce))
+			key := wasm.GetGlobalRegisterParam().RegisterParam(statedb, block)
+			msg := types.NewMessage(sender, &addr, nonce, big.NewInt(0), 1e10, big.NewInt(1), []byte("action01"), false)
+			gp := new(types.GasPool).AddGas(math.MaxUint64)
+			_, gas, failed, _ := WasmApplyMessage(nil, statedb, msg, gp, 1024, params.NoForks, 1, nil, key, engine)
+			wasm.GetGlobalRegisterParam().UnRegisterParam(key)
+			statedb.FinaliseOne()
+			receipts = append(receipts, parityReceipt{gasUsed: gas, failed: failed, logs: statedb.GetLogs(txHash)})
+		}
+	}
+	return receipts, statedb.IntermediateRoot(true)
+}
+
+func TestGoWasmParity(t *testing.T) {
+	contracts := [][]byte{parityContract(0), parityContract(10), parityContract(1000)}
+
+	libReceipts, libRoot := runParity(CallWasmContract, contracts, 2)
+	goReceipts, goRoot := runParity(CallGoWasmContract, contracts, 2)
+
+	for i := range libReceipts {
+		lib, gow := libReceipts[i], goReceipts[i]
+		if lib.gasUsed != gow.gasUsed || lib.failed != gow.failed {
+			t.Errorf("tx %d: libwasmlib used %d gas (failed %v), gowasm used %d gas (failed %v)", i, lib.gasUsed, lib.failed, gow.gasUsed, gow.failed)
+		}
+		if fmt.Sprint(lib.logs) != fmt.Sprint(gow.logs) {
+			t.Errorf("tx %d: libwasmlib logs %v, gowasm logs %v", i, lib.logs, gow.logs)
+		}
+	}
+	if libRoot != goRoot {
+		t.Errorf("state root: libwasmlib %x, gowasm %x", libRoot, goRoot)
+	}
+}
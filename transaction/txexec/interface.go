@@ -10,6 +10,7 @@ import (
 
 	"github.com/fractal-platform/fractal/core/state"
 	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/params"
 	"github.com/fractal-platform/fractal/utils/log"
 )
 
@@ -26,6 +27,7 @@ var (
 	ErrCodeStoreOutOfGas         = errors.New("contract creation code storage out of gas")
 	ErrWasmExec                  = errors.New("wasm exec return error")
 	ErrTransferIsNotAllowed      = errors.New("transfer is not allowed")
+	ErrExecutorNotConsensus      = errors.New("the gowasm executor doesn't meter gas as libwasmlib, it can't run a chain")
 )
 
 type TxExecutor interface {
@@ -33,18 +35,39 @@ type TxExecutor interface {
 	ExecuteTransactions(txs types.Transactions, prevStateDb *state.StateDB, state *state.StateDB, receipts types.Receipts, block *types.Block, txPackageIndex uint32, executedTxs []*types.TxWithIndex, usedGas *uint64, allLogs []*types.Log, gasPool *types.GasPool, callbackParamKey uint64) ([]*types.TxWithIndex, []*types.Log, types.Receipts, int)
 }
 
-func NewExecutor(exeType string, maxBitLength uint64, signer types.Signer) TxExecutor {
+func NewExecutor(exeType string, maxBitLength uint64, signer types.Signer, rewards *params.RewardSchedule, forks *params.ForkSchedule) TxExecutor {
 	switch exeType {
 	case "wasm":
-		return NewWasmExecutor(signer, maxBitLength)
+		return NewWasmExecutor(signer, maxBitLength, rewards, forks)
+	case "gowasm":
+		return NewGoWasmExecutor(signer, maxBitLength, rewards, forks)
 	case "simple":
-		return NewSimpleExecutor(signer, maxBitLength)
+		return NewSimpleExecutor(signer, maxBitLength, rewards)
 	case "dumb":
 		return &dumbExecutor{}
 	}
 
 	// if no special config, make sure everything is ok.
-	return NewWasmExecutor(signer, maxBitLength)
+	return NewWasmExecutor(signer, maxBitLength, rewards, forks)
+}
+
+// CheckChainExecutor returns whether the executor type can run the chain of a
+// node. The gowasm interpreter doesn't meter gas as libwasmlib, so its
+// receipts and state roots differ, and it only runs the local tools and
+// simulations.
+func CheckChainExecutor(exeType string) error {
+	if exeType == "gowasm" {
+		return ErrExecutorNotConsensus
+	}
+	return nil
+}
+
+// NewWasmEngine returns the engine that runs wasm contracts for the executor type.
+func NewWasmEngine(exeType string) WasmEngine {
+	if exeType == "gowasm" {
+		return CallGoWasmContract
+	}
+	return CallWasmContract
 }
 
 type TxExecResult struct {
@@ -9,19 +9,22 @@ import (
 	"github.com/fractal-platform/fractal/core/nonces"
 	"github.com/fractal-platform/fractal/core/state"
 	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/params"
 	"github.com/fractal-platform/fractal/utils/log"
 )
 
 type SimpleExecutor struct {
 	signer       types.Signer
 	maxBitLength uint64
+	rewards      *params.RewardSchedule
 }
 
-func NewSimpleExecutor(signer types.Signer, maxBitLength uint64) TxExecutor {
+func NewSimpleExecutor(signer types.Signer, maxBitLength uint64, rewards *params.RewardSchedule) TxExecutor {
 	log.Info("NewExecutor: Init SimpleExecutor")
 	return &SimpleExecutor{
 		signer:       signer,
 		maxBitLength: maxBitLength,
+		rewards:      rewards,
 	}
 }
 
@@ -153,7 +156,7 @@ func (e *SimpleExecutor) ApplyTransaction(prevStateDb *state.StateDB, state *sta
 	if err != nil {
 		return nil, 0, common.Address{}, err
 	}
-	_, useGas, err := SimpleApplyMessage(prevStateDb, state, msg, gp, e.maxBitLength, block.Header.Coinbase, callbackParamKey)
+	_, useGas, err := SimpleApplyMessage(prevStateDb, state, msg, gp, e.maxBitLength, e.rewards.FeeRecipient(block.Header.Coinbase), callbackParamKey)
 
 	if err != nil {
 		log.Info("ApplyTransaction err", "from", msg.From(), "to", msg.To(), "nonce", msg.Nonce(), "hash", tx.Hash(), "err", err)
@@ -165,12 +168,13 @@ func (e *SimpleExecutor) ApplyTransaction(prevStateDb *state.StateDB, state *sta
 	return nil, useGas, msg.From(), nil
 }
 
-func SimpleApplyMessage(prevStateDb *state.StateDB, statedb *state.StateDB, msg Message, gp *types.GasPool, maxBitLength uint64, coinbase common.Address, callbackParamKey uint64) ([]byte, uint64, error) {
+func SimpleApplyMessage(prevStateDb *state.StateDB, statedb *state.StateDB, msg Message, gp *types.GasPool, maxBitLength uint64, feeRecipient *common.Address, callbackParamKey uint64) ([]byte, uint64, error) {
 	nonceSet := statedb.TxNonceSet(msg.From())
 	if nonceSet == nil {
 		log.Error("SimpleApplyMessage: cannot find tx nonce set", "addr", msg.From())
 		return nil, 0, ErrNonceSetNotFound
 	}
 
-	return NewStateTransition(prevStateDb, statedb, msg, gp, nonceSet, maxBitLength, callbackParamKey).SimpleTransitionDb(coinbase)
+	// simple transactions call no contract, so no fork changes them
+	return NewStateTransition(prevStateDb, statedb, msg, gp, nonceSet, maxBitLength, params.NoForks, 0, callbackParamKey).SimpleTransitionDb(feeRecipient)
 }
@@ -50,6 +50,8 @@ type StateTransition struct {
 
 	nonceSet         *nonces.NonceSet
 	maxBitLength     uint64
+	forks            *params.ForkSchedule
+	height           uint64 // the height of the block the message is executed in
 	callbackParamKey uint64
 }
 
@@ -101,7 +103,7 @@ func IntrinsicGas(data []byte, contractCreation bool) (uint64, error) {
 }
 
 // NewStateTransition initialises and returns a new state transition object.
-func NewStateTransition(prevStateDb *state.StateDB, statedb *state.StateDB, msg Message, gp *types.GasPool, nonceSet *nonces.NonceSet, maxBitLength uint64, callbackParamKey uint64) *StateTransition {
+func NewStateTransition(prevStateDb *state.StateDB, statedb *state.StateDB, msg Message, gp *types.GasPool, nonceSet *nonces.NonceSet, maxBitLength uint64, forks *params.ForkSchedule, height uint64, callbackParamKey uint64) *StateTransition {
 	return &StateTransition{
 		gp:               gp,
 		msg:              msg,
@@ -112,6 +114,8 @@ func NewStateTransition(prevStateDb *state.StateDB, statedb *state.StateDB, msg
 		state:            statedb,
 		nonceSet:         nonceSet,
 		maxBitLength:     maxBitLength,
+		forks:            forks,
+		height:           height,
 		callbackParamKey: callbackParamKey,
 	}
 }
@@ -212,12 +216,20 @@ func (st *StateTransition) refundGas() {
 	st.gp.AddGas(st.gas)
 }
 
+// payFee pays the fee of the gas used to the recipient, the fee is burnt if
+// the recipient is nil.
+func (st *StateTransition) payFee(feeRecipient *common.Address) {
+	if feeRecipient != nil {
+		st.state.AddBalance(*feeRecipient, types.GasFee(st.gasUsed(), st.gasPrice))
+	}
+}
+
 // gasUsed returns the amount of gas used up by the state transition.
 func (st *StateTransition) gasUsed() uint64 {
 	return st.initialGas - st.gas
 }
 
-func (st *StateTransition) SimpleTransitionDb(coinbase common.Address) (ret []byte, usedGas uint64, err error) {
+func (st *StateTransition) SimpleTransitionDb(feeRecipient *common.Address) (ret []byte, usedGas uint64, err error) {
 	if err = st.preCheck(); err != nil {
 		return nil, 0, err
 	}
@@ -247,12 +259,12 @@ func (st *StateTransition) SimpleTransitionDb(coinbase common.Address) (ret []by
 	Transfer(st.state, msg.From(), *msg.To(), st.value)
 
 	st.refundGas()
-	st.state.AddBalance(coinbase, types.GasFee(st.gasUsed(), st.gasPrice))
+	st.payFee(feeRecipient)
 
 	return ret, st.gasUsed(), err
 }
 
-func (st *StateTransition) WasmTransitionDb(coinbase common.Address) (ret []byte, usedGas uint64, wasmFailed bool, err error) {
+func (st *StateTransition) WasmTransitionDb(feeRecipient *common.Address, engine WasmEngine) (ret []byte, usedGas uint64, wasmFailed bool, err error) {
 	if err = st.preCheck(); err != nil {
 		return nil, 0, false, err
 	}
@@ -284,11 +296,11 @@ func (st *StateTransition) WasmTransitionDb(coinbase common.Address) (ret []byte
 			return nil, 0, false, err
 		}
 	} else {
-		err = st.callWasm()
+		err = st.callWasm(engine)
 	}
 
 	st.refundGas()
-	st.state.AddBalance(coinbase, types.GasFee(st.gasUsed(), st.gasPrice))
+	st.payFee(feeRecipient)
 
 	return ret, st.gasUsed(), err == ErrWasmExec, err
 }
@@ -308,9 +320,17 @@ func (st *StateTransition) createWasmAccount() error {
 	return nil
 }
 
-func (st *StateTransition) callWasm() error {
+func (st *StateTransition) callWasm(engine WasmEngine) error {
 	Transfer(st.state, st.msg.From(), st.to(), st.value)
 
+	// equivocation evidence and contract abis are handled natively instead of by a contract
+	if st.forks.IsMinerKeyEvidence(st.height) && st.isEvidenceCall() {
+		return st.applyEvidence()
+	}
+	if st.forks.IsAbiRegistry(st.height) && st.isSetAbiCall() {
+		return st.applySetAbi()
+	}
+
 	code := st.state.GetCode(st.to())
 	if len(st.data) > 0 && len(code) > 0 {
 		from := st.msg.From()
@@ -323,7 +343,7 @@ func (st *StateTransition) callWasm() error {
 		wasmMutex.Lock()
 		defer wasmMutex.Unlock()
 
-		ret := CallWasmContract(code, st.data, from, to, owner, from, value, false, false, &st.gas, st.callbackParamKey)
+		ret := engine(code, st.data, from, to, owner, from, value, false, false, &st.gas, st.callbackParamKey)
 		if ret != 0 {
 			log.Error("CallWasmContract return with error", "ret", ret)
 			return ErrWasmExec
@@ -0,0 +1,78 @@
+package txexec
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/state"
+	"github.com/fractal-platform/fractal/core/types"
+	"github.com/fractal-platform/fractal/params"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPayFee(t *testing.T) {
+	var (
+		from     = common.HexToAddress("0x0101010101010101010101010101010101010101")
+		to       = common.HexToAddress("0x0202020202020202020202020202020202020202")
+		coinbase = common.HexToAddress("0x0303030303030303030303030303030303030303")
+		treasury = common.HexToAddress("0x0404040404040404040404040404040404040404")
+		balance  = big.NewInt(1e18)
+		value    = big.NewInt(1e9)
+		gasPrice = big.NewInt(30)
+	)
+
+	apply := map[string]func(statedb *state.StateDB, msg Message, feeRecipient *common.Address) (uint64, error){
+		"simple": func(statedb *state.StateDB, msg Message, feeRecipient *common.Address) (uint64, error) {
+			gp := new(types.GasPool).AddGas(math.MaxUint64)
+			_, usedGas, err := SimpleApplyMessage(nil, statedb, msg, gp, 1024, feeRecipient, 0)
+			return usedGas, err
+		},
+		"wasm": func(statedb *state.StateDB, msg Message, feeRecipient *common.Address) (uint64, error) {
+			gp := new(types.GasPool).AddGas(math.MaxUint64)
+			_, usedGas, failed, err := WasmApplyMessage(nil, statedb, msg, gp, 1024, params.NoForks, 1, feeRecipient, 0, CallGoWasmContract)
+			So(failed, ShouldBeFalse)
+			return usedGas, err
+		},
+	}
+	tests := []struct {
+		name      string
+		rewards   *params.RewardSchedule
+		recipient *common.Address
+	}{
+		{"paid to the miner", params.DefaultRewardSchedule, &coinbase},
+		{"burnt", &params.RewardSchedule{FeeBurn: true}, nil},
+		{"paid to the treasury", &params.RewardSchedule{Treasury: &treasury}, &treasury},
+	}
+
+	for _, kind := range []string{"simple", "wasm"} {
+		for _, test := range tests {
+			Convey("the fee of a "+kind+" transaction is "+test.name, t, func() {
+				statedb := newTestState()
+				statedb.AddBalance(from, balance)
+
+				feeRecipient := test.rewards.FeeRecipient(coinbase)
+				So(feeRecipient, ShouldResemble, test.recipient)
+
+				msg := types.NewMessage(from, &to, 1, value, 1e7, gasPrice, nil, false)
+				usedGas, err := apply[kind](statedb, msg, feeRecipient)
+				So(err, ShouldBeNil)
+				So(usedGas, ShouldBeGreaterThan, 0)
+
+				fee := types.GasFee(usedGas, gasPrice)
+				So(fee.Sign(), ShouldBeGreaterThan, 0)
+				So(statedb.GetBalance(from), ShouldResemble, new(big.Int).Sub(balance, new(big.Int).Add(value, fee)))
+				So(statedb.GetBalance(to), ShouldResemble, value)
+
+				for _, addr := range []common.Address{coinbase, treasury} {
+					if test.recipient != nil && addr == *test.recipient {
+						So(statedb.GetBalance(addr), ShouldResemble, fee)
+					} else {
+						So(statedb.GetBalance(addr).Sign(), ShouldEqual, 0)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,12 @@
+;; abiwriter stores the args of any action with an empty key in the abi table
+;; of its own storage. abiwriter.wasm is built from it with wat2wasm.
+(module
+  (import "env" "read_action_data" (func $read_action_data (param i32 i32) (result i32)))
+  (import "env" "db_store" (func $db_store (param i64 i32 i32 i32 i32)))
+  (memory (export "memory") 1)
+  (func (export "apply") (result i32)
+    (local $len i32)
+    ;; the action is read at 64, the args follow its name
+    (local.set $len (i32.sub (call $read_action_data (i32.const 64) (i32.const 1024)) (i32.const 8)))
+    (call $db_store (i64.const 0x31dc000000000000) (i32.const 0) (i32.const 0) (i32.const 72) (local.get $len))
+    (i32.const 0)))
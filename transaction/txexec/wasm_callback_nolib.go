@@ -0,0 +1,20 @@
+//go:build nowasmlib
+// +build nowasmlib
+
+package txexec
+
+import (
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/core/wasm"
+	"github.com/fractal-platform/fractal/utils/log"
+)
+
+// WasmLibLinked reports whether the contracts can run on libwasmlib.
+const WasmLibLinked = false
+
+// CallWasmContract is not available when building without libwasmlib, the
+// local tools use the "gowasm" executor instead.
+func CallWasmContract(code []byte, action []byte, from common.Address, to common.Address, user common.Address, owner common.Address, amount uint64, storageDelegate bool, userDelegate bool, remainedGas *uint64, callbackParamKey uint64) int {
+	log.Error("Call wasm contract failed: built without libwasmlib", "contract", to)
+	return wasm.WasmErrorNotSupported
+}
@@ -1,5 +1,12 @@
 package abi
 
+import (
+	"encoding/binary"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/utils"
+)
+
 type AbiType struct {
 	NewTypeName string `json:"new_type_name"    gencodec:"required"`
 	Type        string `json:"type"             gencodec:"required"`
@@ -27,10 +34,36 @@ type AbiTable struct {
 	ValueType string `json:"value_type"    gencodec:"required"`
 }
 
+type AbiVariant struct {
+	Name  string   `json:"name"     gencodec:"required"`
+	Types []string `json:"types"    gencodec:"required"`
+}
+
+// AbiEvent is a log of the contract. The first topic of the log is the event
+// topic of the name, and the data is the value of the type.
+type AbiEvent struct {
+	Name string `json:"name"    gencodec:"required"`
+	Type string `json:"type"    gencodec:"required"`
+}
+
 type AbiDef struct {
-	Version string      `json:"version"     gencodec:"required"`
-	Types   []AbiType   `json:"types"       gencodec:"required"`
-	Structs []AbiStruct `json:"structs"     gencodec:"required"`
-	Actions []AbiAction `json:"actions"     gencodec:"required"`
-	Tables  []AbiTable  `json:"tables"      gencodec:"required"`
+	Version  string       `json:"version"     gencodec:"required"`
+	Types    []AbiType    `json:"types"       gencodec:"required"`
+	Structs  []AbiStruct  `json:"structs"     gencodec:"required"`
+	Actions  []AbiAction  `json:"actions"     gencodec:"required"`
+	Tables   []AbiTable   `json:"tables"      gencodec:"required"`
+	Variants []AbiVariant `json:"variants"`
+	Events   []AbiEvent   `json:"events"`
+}
+
+// EventTopic returns the first topic of the logs of the event: the name as
+// in an action, in the first 8 bytes.
+func EventTopic(name string) (common.Hash, error) {
+	var topic common.Hash
+	value, err := utils.String2Uint64(name)
+	if err != nil {
+		return topic, err
+	}
+	binary.LittleEndian.PutUint64(topic[:8], value)
+	return topic, nil
 }
@@ -0,0 +1,211 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fractal-platform/fractal/common"
+	"github.com/fractal-platform/fractal/common/hexutil"
+)
+
+// fullReader fails the reads which can't fill the buffer, so that truncated
+// data is not decoded with zero bytes.
+type fullReader struct {
+	*bytes.Reader
+}
+
+func (f fullReader) Read(p []byte) (int, error) {
+	return io.ReadFull(f.Reader, p)
+}
+
+// readLength reads the length of a string or an array, which can't be more
+// than the bytes left.
+func (f fullReader) readLength() (uint32, error) {
+	var length uint32
+	if err := unpackVaruint32(&length, f); err != nil {
+		return 0, err
+	}
+	if int64(length) > int64(f.Len()) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return length, nil
+}
+
+// abiObject is a struct value, which keeps the order of the fields in JSON.
+type abiObject struct {
+	names  []string
+	values []interface{}
+}
+
+func (o *abiObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range o.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Deserialize decodes the data of the type into JSON. Structs are decoded
+// into objects with the fields of their bases first, optional values which
+// are not set into null, and variants into [type, value].
+func (s *AbiSerializer) Deserialize(typeName string, data []byte) (json.RawMessage, error) {
+	reader := fullReader{bytes.NewReader(data)}
+	value, err := s.deserialize(typeName, reader, 0)
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes left after decoding %s", reader.Len(), typeName)
+	}
+	return json.Marshal(value)
+}
+
+func (s *AbiSerializer) deserialize(typeName string, r fullReader, depth int) (interface{}, error) {
+	if depth == maxTypeDepth {
+		return nil, errTypeTooDeep
+	}
+	depth++
+
+	if strings.HasSuffix(typeName, "?") {
+		var set bool
+		if err := unpackBool(&set, r); err != nil {
+			return nil, err
+		}
+		if !set {
+			return nil, nil
+		}
+		return s.deserialize(typeName[:len(typeName)-1], r, depth)
+	}
+
+	if strings.HasSuffix(typeName, "[]") {
+		length, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0)
+		for i := uint32(0); i < length; i++ {
+			v, err := s.deserialize(typeName[:len(typeName)-2], r, depth)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+
+	var err error
+	switch typeName {
+	case "bool":
+		var v bool
+		err = unpackBool(&v, r)
+		return v, err
+	case "int8":
+		var v int8
+		err = unpackInt8(&v, r)
+		return v, err
+	case "uint8":
+		var v uint8
+		err = unpackUint8(&v, r)
+		return v, err
+	case "int16":
+		var v int16
+		err = unpackInt16(&v, r)
+		return v, err
+	case "uint16":
+		var v uint16
+		err = unpackUint16(&v, r)
+		return v, err
+	case "int32":
+		var v int32
+		err = unpackInt32(&v, r)
+		return v, err
+	case "uint32":
+		var v uint32
+		err = unpackUint32(&v, r)
+		return v, err
+	case "int64":
+		var v int64
+		err = unpackInt64(&v, r)
+		return v, err
+	case "uint64":
+		var v uint64
+		err = unpackUint64(&v, r)
+		return v, err
+	case "varuint32":
+		var v uint32
+		err = unpackVaruint32(&v, r)
+		return v, err
+	case "address":
+		var v common.Address
+		err = unpackAddress(&v, r)
+		return hexutil.Encode(v[:]), err
+	case "checksum256":
+		var v common.Hash
+		err = unpackChecksum256(&v, r)
+		return hexutil.Encode(v[:]), err
+	case "string":
+		length, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		v := make([]byte, length)
+		_, err = r.Read(v)
+		return string(v), err
+	}
+
+	if t, ok := s.types[typeName]; ok {
+		return s.deserialize(t.Type, r, depth)
+	}
+
+	if st, ok := s.structs[typeName]; ok {
+		fields, err := s.structFields(st)
+		if err != nil {
+			return nil, err
+		}
+		obj := &abiObject{}
+		for _, field := range fields {
+			v, err := s.deserialize(field.Type, r, depth)
+			if err != nil {
+				return nil, err
+			}
+			obj.names = append(obj.names, field.Name)
+			obj.values = append(obj.values, v)
+		}
+		return obj, nil
+	}
+
+	if v, ok := s.variants[typeName]; ok {
+		var index uint32
+		if err := unpackVaruint32(&index, r); err != nil {
+			return nil, err
+		}
+		if index >= uint32(len(v.Types)) {
+			return nil, fmt.Errorf("variant %s has no type %d", typeName, index)
+		}
+		value, err := s.deserialize(v.Types[index], r, depth)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v.Types[index], value}, nil
+	}
+
+	return nil, fmt.Errorf("unknown type %s", typeName)
+}
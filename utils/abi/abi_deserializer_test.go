@@ -0,0 +1,182 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fractal-platform/fractal/common"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const shapeAbi = `{
+    "version": "ftl::abi/0.3.0",
+    "types": [
+        {
+            "new_type_name": "amount",
+            "type": "uint64"
+        }
+    ],
+    "structs": [
+        {
+            "name": "base",
+            "base": "",
+            "fields": [
+                {
+                    "name": "owner",
+                    "type": "address"
+                }
+            ]
+        },
+        {
+            "name": "circle",
+            "base": "",
+            "fields": [
+                {
+                    "name": "radius",
+                    "type": "uint32"
+                }
+            ]
+        },
+        {
+            "name": "square",
+            "base": "",
+            "fields": [
+                {
+                    "name": "side",
+                    "type": "int16"
+                }
+            ]
+        },
+        {
+            "name": "drawing",
+            "base": "base",
+            "fields": [
+                {
+                    "name": "title",
+                    "type": "string?"
+                },
+                {
+                    "name": "price",
+                    "type": "amount"
+                },
+                {
+                    "name": "shapes",
+                    "type": "shape[]"
+                }
+            ]
+        }
+    ],
+    "actions": [],
+    "tables": [
+        {
+            "name": "dr",
+            "key_type": "string",
+            "value_type": "drawing"
+        }
+    ],
+    "variants": [
+        {
+            "name": "shape",
+            "types": ["circle", "square"]
+        }
+    ]
+}`
+
+func TestAbiDeserializer(t *testing.T) {
+	Convey("abi deserializer", t, func() {
+		s, err := NewAbiSerializer(tokenAbi)
+		So(err, ShouldBeNil)
+
+		data, err := s.Deserialize("create", common.Hex2Bytes("034142436400000000000000"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `{"symbol":"ABC","max_supply":100}`)
+
+		_, err = s.Deserialize("create", common.Hex2Bytes("0341424364000000"))
+		So(err, ShouldNotBeNil)
+		_, err = s.Deserialize("create", common.Hex2Bytes("03414243640000000000000000"))
+		So(err, ShouldNotBeNil)
+		_, err = s.Deserialize("create", common.Hex2Bytes("ff41424364000000"))
+		So(err, ShouldNotBeNil)
+		_, err = s.Deserialize("unknown", nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("abi deserializer with base, optional and variant types", t, func() {
+		s, err := NewAbiSerializer(shapeAbi)
+		So(err, ShouldBeNil)
+		So(s.GetTableValueType("dr"), ShouldEqual, "drawing")
+
+		for value, expected := range map[string]string{
+			`["0x0102030405060708090a0b0c0d0e0f1011121314","sun",7,[["circle",[3]],["square",[-2]]]]`: `{"owner":"0x0102030405060708090a0b0c0d0e0f1011121314","title":"sun","price":7,"shapes":[["circle",{"radius":3}],["square",{"side":-2}]]}`,
+			`["0x0102030405060708090a0b0c0d0e0f1011121314",null,7,[]]`:                                `{"owner":"0x0102030405060708090a0b0c0d0e0f1011121314","title":null,"price":7,"shapes":[]}`,
+		} {
+			var data interface{}
+			err = json.Unmarshal([]byte(value), &data)
+			So(err, ShouldBeNil)
+
+			w := bytes.NewBuffer([]byte{})
+			err = s.Serialize(data, "drawing", w)
+			So(err, ShouldBeNil)
+
+			decoded, err := s.Deserialize("drawing", w.Bytes())
+			So(err, ShouldBeNil)
+			So(string(decoded), ShouldEqual, expected)
+		}
+
+		data, err := s.Deserialize("drawing", common.Hex2Bytes("0102030405060708090a0b0c0d0e0f1011121314"+"00"+"0700000000000000"+"01"+"01"+"feff"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `{"owner":"0x0102030405060708090a0b0c0d0e0f1011121314","title":null,"price":7,"shapes":[["square",{"side":-2}]]}`)
+
+		_, err = s.Deserialize("drawing", common.Hex2Bytes("0102030405060708090a0b0c0d0e0f1011121314"+"00"+"0700000000000000"+"01"+"02"+"feff"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestAbiEvents(t *testing.T) {
+	const eventsAbi = `{
+    "version": "ftl::abi/0.3.0",
+    "types": [],
+    "structs": [],
+    "actions": [],
+    "tables": [],
+    "events": [
+        {
+            "name": "drawn",
+            "type": "string"
+        },
+        {
+            "name": "drawn_on_the_wall",
+            "type": "string"
+        }
+    ]
+}`
+
+	Convey("abi events", t, func() {
+		s, err := NewAbiSerializer(eventsAbi)
+		So(err, ShouldBeNil)
+
+		topic, err := EventTopic("drawn")
+		So(err, ShouldBeNil)
+		event, err := s.GetEvent(topic)
+		So(err, ShouldBeNil)
+		So(event.Type, ShouldEqual, "string")
+
+		_, err = s.GetEvent(common.Hash{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "drawn_on_the_wall")
+	})
+
+	Convey("abi events with valid names", t, func() {
+		s, err := NewAbiSerializer(strings.Replace(eventsAbi, `"drawn_on_the_wall"`, `"erased"`, 1))
+		So(err, ShouldBeNil)
+
+		topic, err := EventTopic("erased")
+		So(err, ShouldBeNil)
+		_, err = s.GetEvent(topic)
+		So(err, ShouldBeNil)
+		_, err = s.GetEvent(common.Hash{})
+		So(err, ShouldEqual, ErrUnknownEvent)
+	})
+}
@@ -13,11 +13,25 @@ import (
 	"github.com/fractal-platform/fractal/utils/log"
 )
 
+// maxTypeDepth is the most nested types resolved, which stops the types
+// defined by themselves.
+const maxTypeDepth = 32
+
+var (
+	errTypeTooDeep = errors.New("abi type nested too deep")
+
+	ErrUnknownEvent = errors.New("unknown event")
+)
+
 type AbiSerializer struct {
-	abiDef  AbiDef
-	types   map[string]AbiType
-	structs map[string]AbiStruct
-	tables  map[string]AbiTable
+	abiDef   AbiDef
+	types    map[string]AbiType
+	structs  map[string]AbiStruct
+	tables   map[string]AbiTable
+	variants map[string]AbiVariant
+	events   map[common.Hash]AbiEvent // by the event topic
+
+	invalidEvents []string // the events skipped for their names
 }
 
 func NewAbiSerializer(abi string) (*AbiSerializer, error) {
@@ -42,12 +56,74 @@ func NewAbiSerializer(abi string) (*AbiSerializer, error) {
 		s.tables[tbl.Name] = tbl
 	}
 
+	s.variants = make(map[string]AbiVariant)
+	for _, v := range s.abiDef.Variants {
+		s.variants[v.Name] = v
+	}
+
+	s.events = make(map[common.Hash]AbiEvent)
+	for _, e := range s.abiDef.Events {
+		// an event with a name which can't be a topic matches no log, but
+		// the rest of the abi is still usable
+		topic, err := EventTopic(e.Name)
+		if err != nil {
+			s.invalidEvents = append(s.invalidEvents, e.Name)
+			continue
+		}
+		s.events[topic] = e
+	}
+
 	return &s, nil
 }
 
-func (s *AbiSerializer) Serialize(data interface{}, typeName string, w io.Writer) error {
+// structFields returns the fields of the struct, following the fields of its
+// bases.
+func (s *AbiSerializer) structFields(st AbiStruct) ([]AbiField, error) {
+	var fields []AbiField
+	for depth := 0; ; depth++ {
+		if depth == maxTypeDepth {
+			return nil, errTypeTooDeep
+		}
+		fields = append(append([]AbiField{}, st.Fields...), fields...)
+		if st.Base == "" {
+			return fields, nil
+		}
+		base, ok := s.structs[st.Base]
+		if !ok {
+			return nil, fmt.Errorf("unknown base %s of struct %s", st.Base, st.Name)
+		}
+		st = base
+	}
+}
+
+func (s *AbiSerializer) Serialize(data interface{}, typeName string, w io.Writer) (err error) {
+	// the data which doesn't match the type fails the type assertions
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid value of type %s: %v", typeName, r)
+		}
+	}()
+	return s.serialize(data, typeName, w, 0)
+}
+
+func (s *AbiSerializer) serialize(data interface{}, typeName string, w io.Writer, depth int) error {
 	var err error
 
+	if depth == maxTypeDepth {
+		return errTypeTooDeep
+	}
+	depth++
+
+	if strings.HasSuffix(typeName, "?") {
+		if data == nil {
+			return packBool(false, w)
+		}
+		if err = packBool(true, w); err != nil {
+			return err
+		}
+		return s.serialize(data, typeName[:len(typeName)-1], w, depth)
+	}
+
 	if strings.HasSuffix(typeName, "[]") {
 		d := data.([]interface{})
 		length := uint32(len(d))
@@ -56,7 +132,7 @@ func (s *AbiSerializer) Serialize(data interface{}, typeName string, w io.Writer
 			return err
 		}
 		for _, v := range d {
-			err = s.Serialize(v, typeName[:len(typeName)-2], w)
+			err = s.serialize(v, typeName[:len(typeName)-2], w, depth)
 			if err != nil {
 				return err
 			}
@@ -137,15 +213,23 @@ func (s *AbiSerializer) Serialize(data interface{}, typeName string, w io.Writer
 	default:
 		t, ok := s.types[typeName]
 		if ok {
-			err = s.Serialize(data, t.Type, w)
+			err = s.serialize(data, t.Type, w, depth)
 			break
 		}
 
 		st, ok := s.structs[typeName]
 		if ok {
-			for i, field := range st.Fields {
-				m := data.([]interface{})
-				err = s.Serialize(m[i], field.Type, w)
+			var fields []AbiField
+			fields, err = s.structFields(st)
+			if err != nil {
+				break
+			}
+			m := data.([]interface{})
+			if len(m) != len(fields) {
+				return fmt.Errorf("struct %s has %d fields, got %d", typeName, len(fields), len(m))
+			}
+			for i, field := range fields {
+				err = s.serialize(m[i], field.Type, w, depth)
 				if err != nil {
 					break
 				}
@@ -153,6 +237,24 @@ func (s *AbiSerializer) Serialize(data interface{}, typeName string, w io.Writer
 			break
 		}
 
+		v, ok := s.variants[typeName]
+		if ok {
+			// a variant is given as [type, value]
+			m, ok := data.([]interface{})
+			if !ok || len(m) != 2 {
+				return fmt.Errorf("variant %s must be [type, value]", typeName)
+			}
+			for i, typ := range v.Types {
+				if typ == m[0] {
+					if err = packVaruint32(uint32(i), w); err != nil {
+						return err
+					}
+					return s.serialize(m[1], typ, w, depth)
+				}
+			}
+			return fmt.Errorf("type %v is not in variant %s", m[0], typeName)
+		}
+
 		return fmt.Errorf("unknown type %s", typeName)
 	}
 	return err
@@ -161,3 +263,31 @@ func (s *AbiSerializer) Serialize(data interface{}, typeName string, w io.Writer
 func (s *AbiSerializer) GetTableKeyType(table string) string {
 	return s.tables[table].KeyType
 }
+
+func (s *AbiSerializer) GetTableValueType(table string) string {
+	return s.tables[table].ValueType
+}
+
+// GetActionType returns the type of the args of the action, or "" if the abi
+// has no such action.
+func (s *AbiSerializer) GetActionType(action string) string {
+	for _, a := range s.abiDef.Actions {
+		if a.Name == action {
+			return a.Type
+		}
+	}
+	return ""
+}
+
+// GetEvent returns the event of the first topic of a log. If no event has the
+// topic, the error is ErrUnknownEvent, or names the events skipped for their
+// invalid names when the abi has some.
+func (s *AbiSerializer) GetEvent(topic common.Hash) (AbiEvent, error) {
+	if e, ok := s.events[topic]; ok {
+		return e, nil
+	}
+	if len(s.invalidEvents) > 0 {
+		return AbiEvent{}, fmt.Errorf("%v, events with invalid names are skipped: %s", ErrUnknownEvent, strings.Join(s.invalidEvents, ", "))
+	}
+	return AbiEvent{}, ErrUnknownEvent
+}
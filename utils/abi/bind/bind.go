@@ -0,0 +1,372 @@
+// Copyright 2018 The go-fractal Authors
+// This file is part of the go-fractal library.
+
+// Package bind generates the Go bindings of the contracts from their abi.
+package bind
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/fractal-platform/fractal/utils"
+	"github.com/fractal-platform/fractal/utils/abi"
+)
+
+// maxTypeDepth is the most nested types resolved, as in the abi serializer.
+const maxTypeDepth = 32
+
+var errTypeTooDeep = errors.New("abi type nested too deep")
+
+var builtinTypes = map[string]string{
+	"bool":        "bool",
+	"int8":        "int8",
+	"uint8":       "uint8",
+	"int16":       "int16",
+	"uint16":      "uint16",
+	"int32":       "int32",
+	"uint32":      "uint32",
+	"int64":       "int64",
+	"uint64":      "uint64",
+	"varuint32":   "abi.Varuint32",
+	"address":     "common.Address",
+	"checksum256": "common.Hash",
+	"string":      "string",
+}
+
+const (
+	abiPackage        = "github.com/fractal-platform/fractal/utils/abi"
+	commonPackage     = "github.com/fractal-platform/fractal/common"
+	fractalsdkPackage = "github.com/fractal-platform/fractal/fractalsdk"
+)
+
+type generator struct {
+	def      abi.AbiDef
+	types    map[string]abi.AbiType
+	structs  map[string]abi.AbiStruct
+	variants map[string]abi.AbiVariant
+
+	names   map[string]string // the go name of each type of the abi
+	imports map[string]bool
+}
+
+// Bind generates the Go bindings of the contract with the abi, as the type in
+// the package. The bindings have a struct for each struct of the abi, a
+// method sending and a method calling each action, a method reading each
+// table and a method decoding each event.
+func Bind(abiDef string, pkg string, typeName string) ([]byte, error) {
+	if _, err := abi.NewAbiSerializer(abiDef); err != nil {
+		return nil, err
+	}
+	g := &generator{
+		types:    make(map[string]abi.AbiType),
+		structs:  make(map[string]abi.AbiStruct),
+		variants: make(map[string]abi.AbiVariant),
+		names:    make(map[string]string),
+		imports:  map[string]bool{fractalsdkPackage: true},
+	}
+	if err := json.Unmarshal([]byte(abiDef), &g.def); err != nil {
+		return nil, err
+	}
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid package name %s", pkg)
+	}
+	if typeName == "" {
+		typeName = goName(pkg)
+	}
+	if !token.IsIdentifier(typeName) || !token.IsExported(typeName) {
+		return nil, fmt.Errorf("invalid type name %s", typeName)
+	}
+
+	// name the types first, so that they can refer to each other
+	declared := map[string]string{typeName: "contract", typeName + "Abi": "abi"}
+	declare := func(abiName string) error {
+		name := goName(abiName)
+		if other, ok := declared[name]; ok {
+			return fmt.Errorf("%s and %s are both bound to %s", abiName, other, name)
+		}
+		declared[name] = abiName
+		g.names[abiName] = name
+		return nil
+	}
+	for _, t := range g.def.Types {
+		g.types[t.NewTypeName] = t
+		if err := declare(t.NewTypeName); err != nil {
+			return nil, err
+		}
+	}
+	for _, st := range g.def.Structs {
+		g.structs[st.Name] = st
+		if err := declare(st.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range g.def.Variants {
+		g.variants[v.Name] = v
+		if err := declare(v.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := g.data(pkg, typeName, abiDef)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("bind").Parse(tmplSource)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// data returns the data of the template.
+func (g *generator) data(pkg string, typeName string, abiDef string) (*tmplData, error) {
+	data := &tmplData{Package: pkg, Type: typeName, Abi: abiDef}
+
+	for _, t := range g.def.Types {
+		typ, err := g.goType(t.Type, 0)
+		if err != nil {
+			return nil, err
+		}
+		data.Aliases = append(data.Aliases, &tmplAlias{Name: g.names[t.NewTypeName], AbiName: t.NewTypeName, Type: typ})
+	}
+
+	for _, st := range g.def.Structs {
+		fields, err := g.structFields(st)
+		if err != nil {
+			return nil, err
+		}
+		data.Structs = append(data.Structs, &tmplStruct{Name: g.names[st.Name], AbiName: st.Name, Fields: fields})
+	}
+
+	for _, v := range g.def.Variants {
+		variant := &tmplVariant{Name: g.names[v.Name], AbiName: v.Name}
+		fields := make(map[string]bool)
+		for i, t := range v.Types {
+			typ, err := g.goType(t, 0)
+			if err != nil {
+				return nil, err
+			}
+			field := memberName(t)
+			if fields[field] {
+				return nil, fmt.Errorf("variant %s has the type %s twice", v.Name, field)
+			}
+			fields[field] = true
+			variant.Members = append(variant.Members, &tmplMember{Field: field, AbiType: t, Type: typ, Index: i})
+		}
+		data.Variants = append(data.Variants, variant)
+		g.imports[abiPackage] = true
+		g.imports["errors"] = true
+		g.imports["fmt"] = true
+		g.imports["io"] = true
+	}
+
+	methods := map[string]string{"Address": "address"}
+	method := func(name string, abiName string) error {
+		if _, err := utils.String2Uint64(abiName); err != nil {
+			return fmt.Errorf("invalid name %s: %v", abiName, err)
+		}
+		if other, ok := methods[name]; ok {
+			return fmt.Errorf("%s and %s are both bound to the method %s", abiName, other, name)
+		}
+		methods[name] = abiName
+		return nil
+	}
+
+	for _, a := range g.def.Actions {
+		action := &tmplAction{Method: goName(a.Name), AbiName: a.Name}
+		if err := method(action.Method, a.Name); err != nil {
+			return nil, err
+		}
+		if err := method("Call"+action.Method, a.Name); err != nil {
+			return nil, err
+		}
+		typ, err := g.goType(a.Type, 0)
+		if err != nil {
+			return nil, err
+		}
+		action.Args = typ
+		if st, ok := g.structs[a.Type]; ok {
+			fields, err := g.structFields(st)
+			if err != nil {
+				return nil, err
+			}
+			action.Flat = true
+			action.Params = params(fields)
+		}
+		data.Actions = append(data.Actions, action)
+	}
+
+	for _, t := range g.def.Tables {
+		table := &tmplTable{Method: "Get" + goName(t.Name), AbiName: t.Name}
+		if err := method(table.Method, t.Name); err != nil {
+			return nil, err
+		}
+		var err error
+		if table.KeyType, err = g.goType(t.KeyType, 0); err != nil {
+			return nil, err
+		}
+		if table.ValueType, err = g.goType(t.ValueType, 0); err != nil {
+			return nil, err
+		}
+		data.Tables = append(data.Tables, table)
+	}
+
+	for _, e := range g.def.Events {
+		event := &tmplEvent{Method: "Parse" + goName(e.Name), AbiName: e.Name}
+		if err := method(event.Method, e.Name); err != nil {
+			return nil, err
+		}
+		typ, err := g.goType(e.Type, 0)
+		if err != nil {
+			return nil, err
+		}
+		event.Type = typ
+		data.Events = append(data.Events, event)
+	}
+
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(path, ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	if len(std) > 0 {
+		data.Imports = append(data.Imports, std)
+	}
+	data.Imports = append(data.Imports, other)
+	return data, nil
+}
+
+// structFields returns the fields of the struct, following the fields of its
+// bases, which are flattened into the struct as they are encoded.
+func (g *generator) structFields(st abi.AbiStruct) ([]*tmplField, error) {
+	var fields []*tmplField
+	names := make(map[string]string)
+	for depth := 0; ; depth++ {
+		if depth == maxTypeDepth {
+			return nil, errTypeTooDeep
+		}
+		var own []*tmplField
+		for _, f := range st.Fields {
+			name := goName(f.Name)
+			if other, ok := names[name]; ok {
+				return nil, fmt.Errorf("fields %s and %s of struct %s are both bound to %s", f.Name, other, st.Name, name)
+			}
+			names[name] = f.Name
+			typ, err := g.goType(f.Type, 0)
+			if err != nil {
+				return nil, err
+			}
+			own = append(own, &tmplField{Name: name, AbiName: f.Name, Type: typ})
+		}
+		fields = append(own, fields...)
+		if st.Base == "" {
+			return fields, nil
+		}
+		base, ok := g.structs[st.Base]
+		if !ok {
+			return nil, fmt.Errorf("unknown base %s of struct %s", st.Base, st.Name)
+		}
+		st = base
+	}
+}
+
+// goType returns the go type of the abi type.
+func (g *generator) goType(typeName string, depth int) (string, error) {
+	if depth == maxTypeDepth {
+		return "", errTypeTooDeep
+	}
+	if strings.HasSuffix(typeName, "?") {
+		typ, err := g.goType(typeName[:len(typeName)-1], depth+1)
+		return "*" + typ, err
+	}
+	if strings.HasSuffix(typeName, "[]") {
+		typ, err := g.goType(typeName[:len(typeName)-2], depth+1)
+		return "[]" + typ, err
+	}
+	if typ, ok := builtinTypes[typeName]; ok {
+		switch typeName {
+		case "varuint32":
+			g.imports[abiPackage] = true
+		case "address", "checksum256":
+			g.imports[commonPackage] = true
+		}
+		return typ, nil
+	}
+	if name, ok := g.names[typeName]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown type %s", typeName)
+}
+
+// params returns the parameters of the fields, named so that they don't
+// collide with the keywords and the other parameters of the methods.
+func params(fields []*tmplField) []*tmplParam {
+	var params []*tmplParam
+	for _, f := range fields {
+		name := []rune(f.Name)
+		name[0] = unicode.ToLower(name[0])
+		p := &tmplParam{Name: string(name), Field: f.Name, Type: f.Type}
+		if token.IsKeyword(p.Name) || p.Name == "opts" || p.Name == "c" {
+			p.Name += "Arg"
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// goName returns the exported go name of the abi name, in camel case.
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '.':
+			upper = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if b.Len() == 0 && unicode.IsDigit(r) {
+				b.WriteRune('X')
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+// memberName returns the field name of the type in a variant.
+func memberName(typeName string) string {
+	var suffix string
+	for {
+		switch {
+		case strings.HasSuffix(typeName, "?"):
+			typeName, suffix = typeName[:len(typeName)-1], "Opt"+suffix
+		case strings.HasSuffix(typeName, "[]"):
+			typeName, suffix = typeName[:len(typeName)-2], "List"+suffix
+		default:
+			return goName(typeName) + suffix
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package bind
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fractal-platform/fractal/transaction/txexec"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const shopAbi = `{
+    "version": "ftl::abi/0.3.0",
+    "types": [
+        {
+            "new_type_name": "amount",
+            "type": "uint64"
+        }
+    ],
+    "structs": [
+        {
+            "name": "item_key",
+            "base": "",
+            "fields": [
+                {
+                    "name": "owner",
+                    "type": "address"
+                },
+                {
+                    "name": "id",
+                    "type": "varuint32"
+                }
+            ]
+        },
+        {
+            "name": "item",
+            "base": "item_key",
+            "fields": [
+                {
+                    "name": "title",
+                    "type": "string?"
+                },
+                {
+                    "name": "price",
+                    "type": "amount"
+                },
+                {
+                    "name": "tags",
+                    "type": "tag[]"
+                }
+            ]
+        },
+        {
+            "name": "sell",
+            "base": "",
+            "fields": [
+                {
+                    "name": "item",
+                    "type": "item"
+                },
+                {
+                    "name": "type",
+                    "type": "uint8"
+                }
+            ]
+        },
+        {
+            "name": "sold",
+            "base": "",
+            "fields": [
+                {
+                    "name": "buyer",
+                    "type": "address"
+                },
+                {
+                    "name": "hash",
+                    "type": "checksum256"
+                }
+            ]
+        }
+    ],
+    "actions": [
+        {
+            "name": "sell",
+            "type": "sell"
+        },
+        {
+            "name": "setprice",
+            "type": "amount"
+        }
+    ],
+    "tables": [
+        {
+            "name": "items",
+            "key_type": "item_key",
+            "value_type": "item"
+        }
+    ],
+    "variants": [
+        {
+            "name": "tag",
+            "types": ["string", "uint32[]"]
+        }
+    ],
+    "events": [
+        {
+            "name": "sold",
+            "type": "sold"
+        }
+    ]
+}`
+
+// typeCheck type checks the generated source against the packages it imports.
+func typeCheck(src []byte) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "shop.go", src, 0)
+	if err != nil {
+		return err
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("shop", fset, []*ast.File{f}, nil)
+	return err
+}
+
+func TestBind(t *testing.T) {
+	Convey("bind abi", t, func() {
+		src, err := Bind(shopAbi, "shop", "")
+		So(err, ShouldBeNil)
+
+		code := string(src)
+		for _, decl := range []string{
+			"type Amount = uint64",
+			"type ItemKey struct",
+			"type Tag struct",
+			"String     *string",
+			"Uint32List *[]uint32",
+			"func (c *Shop) Sell(opts *fractalsdk.TransactOpts, item Item, typeArg uint8) (*fractalsdk.TransactionDetails, error)",
+			`c.contract.Transact(opts, "sell", Sell{Item: item, Type: typeArg})`,
+			"func (c *Shop) CallSetprice(opts *fractalsdk.CallOpts, args Amount) (fractalsdk.CallResult, error)",
+			"func (c *Shop) GetItems(key ItemKey, blockFullHash string) (*Item, error)",
+			"func (c *Shop) ParseSold(log *fractalsdk.Log) (*Sold, error)",
+		} {
+			So(code, ShouldContainSubstring, decl)
+		}
+		// the fields of the base come first
+		So(strings.Index(code, "Owner common.Address"), ShouldBeLessThan, strings.Index(code, "Title *string"))
+
+		So(typeCheck(src), ShouldBeNil)
+	})
+
+	Convey("bind invalid abi", t, func() {
+		_, err := Bind(shopAbi, "shop", "Item")
+		So(err, ShouldNotBeNil)
+		_, err = Bind(shopAbi, "shop-1", "")
+		So(err, ShouldNotBeNil)
+		_, err = Bind(strings.Replace(shopAbi, `"type": "amount"`, `"type": "price"`, 1), "shop", "")
+		So(err, ShouldNotBeNil)
+		_, err = Bind(strings.Replace(shopAbi, `"name": "setprice"`, `"name": "set_price_of_item"`, 1), "shop", "")
+		So(err, ShouldNotBeNil)
+		_, err = Bind(strings.Replace(shopAbi, `"name": "price"`, `"name": "owner"`, 1), "shop", "")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// echoTest is the test run with the bindings of the echo contract of the
+// simulated backend, which stores the args of an action as the row 1 and adds
+// them as a stored event.
+const echoTest = `package echo
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/fractal-platform/fractal/core/config"
+	"github.com/fractal-platform/fractal/crypto"
+	"github.com/fractal-platform/fractal/fractalsdk/simulated"
+)
+
+func TestEcho(t *testing.T) {
+	_, key, err := crypto.NewKeys(crypto.ECDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := simulated.NewBackend(config.GenesisAlloc{key.Public().ToAddress(): {Balance: big.NewInt(1e18)}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	code, err := ioutil.ReadFile("../../../../fractalsdk/simulated/testdata/echo.wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, err := backend.SendTransactionSync("", new(big.Int), 0, nil, code, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo := NewEcho(details.Receipt.ContractAddress, nil, backend)
+
+	details, err = echo.Set(nil, 7, "seven")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details.Receipt.Logs) != 1 {
+		t.Fatalf("set logs: %d", len(details.Receipt.Logs))
+	}
+	if stored, err := echo.ParseStored(details.Receipt.Logs[0]); err != nil || stored == nil || *stored != (Row{Id: 7, Name: "seven"}) {
+		t.Fatalf("set stored: %v %v", stored, err)
+	}
+
+	result, err := echo.CallSet(nil, 8, "eight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Logs) != 1 {
+		t.Fatalf("call logs: %d", len(result.Logs))
+	}
+	if stored, err := echo.ParseStored(result.Logs[0]); err != nil || stored == nil || *stored != (Row{Id: 8, Name: "eight"}) {
+		t.Fatalf("call stored: %v %v", stored, err)
+	}
+
+	// the call doesn't change the state
+	if row, err := echo.GetRows(1, ""); err != nil || row == nil || *row != (Row{Id: 7, Name: "seven"}) {
+		t.Fatalf("row 1: %v %v", row, err)
+	}
+	if row, err := echo.GetRows(2, ""); err != nil || row != nil {
+		t.Fatalf("row 2: %v %v", row, err)
+	}
+}
+`
+
+func TestBindSimulated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the bindings with the go tool")
+	}
+
+	Convey("the bindings run against the simulated backend", t, func() {
+		abiDef, err := ioutil.ReadFile("../../../fractalsdk/simulated/testdata/echo.abi")
+		So(err, ShouldBeNil)
+		src, err := Bind(string(abiDef), "echo", "")
+		So(err, ShouldBeNil)
+
+		// the package is built in the tree, where its imports are found
+		dir, err := ioutil.TempDir(".", "_echo")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(ioutil.WriteFile(filepath.Join(dir, "echo.go"), src, 0644), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(dir, "echo_test.go"), []byte(echoTest), 0644), ShouldBeNil)
+
+		args := []string{"test", "-count=1"}
+		if !txexec.WasmLibLinked {
+			args = append(args, "-tags", "nowasmlib")
+		}
+		cmd := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), append(args, ".")...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Log(string(out))
+		}
+		So(err, ShouldBeNil)
+	})
+}
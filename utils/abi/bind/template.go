@@ -0,0 +1,193 @@
+package bind
+
+// tmplData is the data of the bindings template.
+type tmplData struct {
+	Package  string
+	Type     string
+	Abi      string
+	Imports  [][]string // the standard and the other imports
+	Aliases  []*tmplAlias
+	Structs  []*tmplStruct
+	Variants []*tmplVariant
+	Actions  []*tmplAction
+	Tables   []*tmplTable
+	Events   []*tmplEvent
+}
+
+type tmplAlias struct {
+	Name    string
+	AbiName string
+	Type    string
+}
+
+type tmplField struct {
+	Name    string
+	AbiName string
+	Type    string
+}
+
+type tmplStruct struct {
+	Name    string
+	AbiName string
+	Fields  []*tmplField
+}
+
+type tmplMember struct {
+	Field   string
+	AbiType string
+	Type    string
+	Index   int
+}
+
+type tmplVariant struct {
+	Name    string
+	AbiName string
+	Members []*tmplMember
+}
+
+type tmplParam struct {
+	Name  string
+	Field string
+	Type  string
+}
+
+type tmplAction struct {
+	Method  string
+	AbiName string
+	Args    string       // the go type of the args
+	Flat    bool         // whether the args are given as the fields of the struct
+	Params  []*tmplParam // the fields of the args
+}
+
+type tmplTable struct {
+	Method    string
+	AbiName   string
+	KeyType   string
+	ValueType string
+}
+
+type tmplEvent struct {
+	Method  string
+	AbiName string
+	Type    string
+}
+
+const tmplSource = `// Code generated by gtool abigen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range $i, $group := .Imports}}{{if $i}}
+{{end}}
+{{- range $group}}
+	"{{.}}"
+{{- end}}
+{{- end}}
+)
+
+// {{.Type}}Abi is the abi the bindings are generated from.
+const {{.Type}}Abi = {{printf "%q" .Abi}}
+{{range .Aliases}}
+// {{.Name}} is the {{.AbiName}} type of the abi.
+type {{.Name}} = {{.Type}}
+{{end}}
+{{- range .Structs}}
+// {{.Name}} is the {{.AbiName}} struct of the abi.
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} // {{.AbiName}}
+{{- end}}
+}
+{{end}}
+{{- range .Variants}}
+// {{.Name}} is the {{.AbiName}} variant of the abi, which holds one of its types.
+type {{.Name}} struct {
+{{- range .Members}}
+	{{.Field}} *{{.Type}} // {{.AbiType}}
+{{- end}}
+}
+
+// EncodeAbi encodes the index and the value of the type set.
+func (v {{.Name}}) EncodeAbi(w io.Writer) error {
+	switch {
+{{- range .Members}}
+	case v.{{.Field}} != nil:
+		if err := abi.Encode(abi.Varuint32({{.Index}}), w); err != nil {
+			return err
+		}
+		return abi.Encode(*v.{{.Field}}, w)
+{{- end}}
+	}
+	return errors.New("variant {{.AbiName}} has no value")
+}
+
+// DecodeAbi decodes the index and the value of a type.
+func (v *{{.Name}}) DecodeAbi(r io.Reader) error {
+	var index abi.Varuint32
+	if err := abi.Decode(&index, r); err != nil {
+		return err
+	}
+	*v = {{.Name}}{}
+	switch index {
+{{- range .Members}}
+	case {{.Index}}:
+		v.{{.Field}} = new({{.Type}})
+		return abi.Decode(v.{{.Field}}, r)
+{{- end}}
+	}
+	return fmt.Errorf("variant {{.AbiName}} has no type %d", index)
+}
+{{end}}
+// {{.Type}} is the binding of the contract.
+type {{.Type}} struct {
+	contract *fractalsdk.BoundContract
+}
+
+// New{{.Type}} binds the contract at the address. The sender can be nil if no
+// transaction is sent.
+func New{{.Type}}(address string, reader fractalsdk.ChainReader, sender fractalsdk.TxSender) *{{.Type}} {
+	return &{{.Type}}{contract: fractalsdk.NewBoundContract(address, reader, sender)}
+}
+
+// Address returns the address of the contract.
+func (c *{{.Type}}) Address() string {
+	return c.contract.Address()
+}
+{{$type := .Type}}
+{{- range .Actions}}
+// {{.Method}} sends a transaction calling the {{.AbiName}} action, and waits for it to be executed.
+func (c *{{$type}}) {{.Method}}(opts *fractalsdk.TransactOpts{{template "params" .}}) (*fractalsdk.TransactionDetails, error) {
+	return c.contract.Transact(opts, "{{.AbiName}}", {{template "args" .}})
+}
+
+// Call{{.Method}} executes the {{.AbiName}} action without sending a transaction.
+func (c *{{$type}}) Call{{.Method}}(opts *fractalsdk.CallOpts{{template "params" .}}) (fractalsdk.CallResult, error) {
+	return c.contract.Call(opts, "{{.AbiName}}", {{template "args" .}})
+}
+{{end}}
+{{- range .Tables}}
+// {{.Method}} reads the row of the {{.AbiName}} table with the key in the state of the block, which is the head block if empty. It returns nil if the row is not found.
+func (c *{{$type}}) {{.Method}}(key {{.KeyType}}, blockFullHash string) (*{{.ValueType}}, error) {
+	row := new({{.ValueType}})
+	found, err := c.contract.ReadRow("{{.AbiName}}", key, row, blockFullHash)
+	if !found || err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+{{end}}
+{{- range .Events}}
+// {{.Method}} decodes the log of the {{.AbiName}} event. It returns nil if the log is not the event of the contract.
+func (c *{{$type}}) {{.Method}}(log *fractalsdk.Log) (*{{.Type}}, error) {
+	value := new({{.Type}})
+	ok, err := c.contract.UnpackLog("{{.AbiName}}", log, value)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+{{end}}
+
+{{- define "params"}}{{if .Flat}}{{range .Params}}, {{.Name}} {{.Type}}{{end}}{{else}}, args {{.Args}}{{end}}{{end}}
+{{- define "args"}}{{if .Flat}}{{.Args}}{ {{- range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Field}}: {{$p.Name}}{{end -}} }{{else}}args{{end}}{{end}}
+`
@@ -1,6 +1,7 @@
 package abi
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"reflect"
@@ -8,9 +9,58 @@ import (
 	"github.com/fractal-platform/fractal/common"
 )
 
+// Varuint32 is the varuint32 type of the abi, which is encoded in 1 to 5
+// bytes.
+type Varuint32 uint32
+
+var varuint32Type = reflect.TypeOf(Varuint32(0))
+
+// Encoder is implemented by the types which encode themselves, like the
+// variants.
+type Encoder interface {
+	EncodeAbi(writer io.Writer) error
+}
+
+// Decoder is implemented by the types which decode themselves, like the
+// variants.
+type Decoder interface {
+	DecodeAbi(reader io.Reader) error
+}
+
+// Marshal returns the encoding of the value.
+func Marshal(val interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := Encode(val, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the data into the value the pointer points to. The data
+// must be decoded fully.
+func Unmarshal(data []byte, val interface{}) error {
+	reader := fullReader{bytes.NewReader(data)}
+	if err := Decode(val, reader); err != nil {
+		return err
+	}
+	if reader.Len() > 0 {
+		return fmt.Errorf("%d bytes left after decoding", reader.Len())
+	}
+	return nil
+}
+
+// Encode encodes the value. Pointers are encoded as optional values.
 func Encode(val interface{}, writer io.Writer) error {
 	typ := reflect.TypeOf(val)
+	if typ.Kind() == reflect.Ptr {
+		return encodeOptional(val, writer)
+	}
+	if e, ok := val.(Encoder); ok {
+		return e.EncodeAbi(writer)
+	}
 	switch {
+	case typ == varuint32Type:
+		return packVaruint32(uint32(val.(Varuint32)), writer)
 	case typ.Name() == "Address":
 		return packAddress(val, writer)
 	case typ.Name() == "Hash":
@@ -45,6 +95,17 @@ func Encode(val interface{}, writer io.Writer) error {
 	return fmt.Errorf("unsupported encode type: %s", typ.Name())
 }
 
+func encodeOptional(val interface{}, writer io.Writer) error {
+	v := reflect.ValueOf(val)
+	if v.IsNil() {
+		return packBool(false, writer)
+	}
+	if err := packBool(true, writer); err != nil {
+		return err
+	}
+	return Encode(v.Elem().Interface(), writer)
+}
+
 func encodeStruct(val interface{}, writer io.Writer) error {
 	typ := reflect.TypeOf(val)
 	v := reflect.ValueOf(val)
@@ -73,6 +134,8 @@ func encodeSlice(val interface{}, writer io.Writer) error {
 	return nil
 }
 
+// Decode decodes into the value the pointer points to, or into the settable
+// reflect.Value. Pointers are decoded as optional values.
 func Decode(val interface{}, reader io.Reader) error {
 	var typ reflect.Type
 	var v reflect.Value
@@ -86,7 +149,32 @@ func Decode(val interface{}, reader io.Reader) error {
 		typ = v.Type()
 	}
 
+	if typ.Kind() == reflect.Ptr {
+		if isPointer {
+			v = reflect.ValueOf(val).Elem()
+		}
+		return decodeOptional(v, reader)
+	}
+	if isPointer {
+		if d, ok := val.(Decoder); ok {
+			return d.DecodeAbi(reader)
+		}
+	} else if v.CanAddr() {
+		if d, ok := v.Addr().Interface().(Decoder); ok {
+			return d.DecodeAbi(reader)
+		}
+	}
+
 	switch {
+	case typ == varuint32Type:
+		if isPointer {
+			return unpackVaruint32(val, reader)
+		} else {
+			var r uint32
+			err := unpackVaruint32(&r, reader)
+			v.SetUint(uint64(r))
+			return err
+		}
 	case typ.Name() == "Address":
 		if isPointer {
 			return unpackAddress(val, reader)
@@ -205,6 +293,23 @@ func Decode(val interface{}, reader io.Reader) error {
 	return fmt.Errorf("unsupported decode type: %s", typ.Name())
 }
 
+func decodeOptional(v reflect.Value, reader io.Reader) error {
+	var set bool
+	if err := unpackBool(&set, reader); err != nil {
+		return err
+	}
+	if !set {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	elem := reflect.New(v.Type().Elem())
+	if err := Decode(elem.Interface(), reader); err != nil {
+		return err
+	}
+	v.Set(elem)
+	return nil
+}
+
 func decodeStruct(val interface{}, reader io.Reader) error {
 	var typ reflect.Type
 	var v reflect.Value
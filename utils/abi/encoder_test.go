@@ -2,6 +2,7 @@ package abi
 
 import (
 	"bytes"
+	"io"
 	"testing"
 
 	"github.com/fractal-platform/fractal/common"
@@ -554,3 +555,63 @@ func TestArray(t *testing.T) {
 		})
 	})
 }
+
+type __variant struct {
+	A *uint32
+	B *string
+}
+
+func (v __variant) EncodeAbi(w io.Writer) error {
+	if v.A != nil {
+		Encode(Varuint32(0), w)
+		return Encode(*v.A, w)
+	}
+	Encode(Varuint32(1), w)
+	return Encode(*v.B, w)
+}
+
+func (v *__variant) DecodeAbi(r io.Reader) error {
+	var index Varuint32
+	if err := Decode(&index, r); err != nil {
+		return err
+	}
+	if index == 0 {
+		v.A = new(uint32)
+		return Decode(v.A, r)
+	}
+	v.B = new(string)
+	return Decode(v.B, r)
+}
+
+func TestOptionalAndVariant(t *testing.T) {
+	Convey("encode/decode optional, varuint32 and variant", t, func() {
+		type __ts struct {
+			A *uint16
+			B Varuint32
+			C []__variant
+			D *string
+		}
+		a, b := uint16(100), "abc"
+		s := __ts{A: &a, B: 300, C: []__variant{{A: new(uint32)}, {B: &b}}}
+		bs, err := Marshal(s)
+		So(err, ShouldBeNil)
+		So(bs, ShouldResemble, common.Hex2Bytes("016400ac020200000000000103616263"+"00"))
+
+		var r __ts
+		err = Unmarshal(bs, &r)
+		So(err, ShouldBeNil)
+		So(r, ShouldResemble, s)
+
+		err = Unmarshal(bs[:len(bs)-1], &r)
+		So(err, ShouldNotBeNil)
+		err = Unmarshal(append(bs, 0), &r)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("event topic", t, func() {
+		topic, err := EventTopic("transfer")
+		So(err, ShouldBeNil)
+		So(topic[:8], ShouldResemble, common.Hex2Bytes("000000572d3ccdcd"))
+		So(topic[8:], ShouldResemble, make([]byte, 24))
+	})
+}